@@ -72,6 +72,7 @@ type Config struct {
 	RabbitMQ    QueueConfig       `json:"rabbitmq"    yaml:"rabbitmq"`
 	Kafka       KafkaConfig       `json:"kafka"       yaml:"kafka"`
 	Catalog     CatalogConfig     `json:"catalog"    yaml:"catalog"`
+	Inventory   InventoryConfig   `json:"inventory"   yaml:"inventory"`
 	Idempotency IdempotencyConfig `json:"idempotency" yaml:"idempotency"`
 	Redis       RedisConfig       `json:"redis"       yaml:"redis"`
 	RateLimit   RateLimitConfig   `json:"rateLimit"   yaml:"rateLimit"`
@@ -148,6 +149,31 @@ type RateLimitConfig struct {
 	Description         string      `json:"description"         yaml:"description"`
 }
 
+// InventoryConfig holds inventory domain knobs. ReservationTTLSeconds
+// is the hold applied to reservations whose request doesn't carry its
+// own TTL; zero means reservations never expire unless asked to. The
+// sweeper runs every ReservationSweepSeconds; zero or negative
+// disables it. LotSweepSeconds does the same for the sweeper that
+// moves expired lots out of available stock, and
+// BackorderMetricsSeconds for the job that refreshes the per-SKU
+// backorder gauge. DefaultLocation, LocationStrategy and LocationPriority
+// decide where stock lands and is reserved from when a request
+// doesn't name a warehouse location. AllocationStrategy decides how
+// stock is shared between open reservations; AllocationOverrides
+// swaps it for individual SKUs.
+type InventoryConfig struct {
+	ReservationTTLSeconds   IntConfig    `json:"reservationTtlSeconds"   yaml:"reservationTtlSeconds"`
+	ReservationSweepSeconds IntConfig    `json:"reservationSweepSeconds" yaml:"reservationSweepSeconds"`
+	LotSweepSeconds         IntConfig    `json:"lotSweepSeconds"         yaml:"lotSweepSeconds"`
+	BackorderMetricsSeconds IntConfig    `json:"backorderMetricsSeconds" yaml:"backorderMetricsSeconds"`
+	DefaultLocation         StringConfig `json:"defaultLocation"         yaml:"defaultLocation"`
+	LocationStrategy        StringConfig `json:"locationStrategy"        yaml:"locationStrategy"`
+	LocationPriority        StringConfig `json:"locationPriority"        yaml:"locationPriority"`
+	AllocationStrategy      StringConfig `json:"allocationStrategy"      yaml:"allocationStrategy"`
+	AllocationOverrides     StringConfig `json:"allocationOverrides"     yaml:"allocationOverrides"`
+	Description             string       `json:"description"             yaml:"description"`
+}
+
 // IdempotencyConfig holds the DSN-019 REST idempotency knobs. The
 // store choice is wired in cmd/main.go (in-memory today; DSN-021
 // will plug Redis in); TTL is configurable here.
@@ -345,6 +371,13 @@ func bindSensitiveEnv() {
 		"catalog.timeoutMs",
 		"catalog.perAttemptMs",
 		"catalog.maxAttempts",
+		"inventory.reservationTtlSeconds",
+		"inventory.reservationSweepSeconds",
+		"inventory.lotSweepSeconds",
+		"inventory.backorderMetricsSeconds",
+		"inventory.defaultLocation",
+		"inventory.locationStrategy",
+		"inventory.locationPriority",
 		"idempotency.ttlMinutes",
 		"redis.url",
 		"redis.cacheTtlMinutes",
@@ -649,6 +682,17 @@ func setupDefaults(config *Config) {
 	config.Catalog.PerAttemptTimeout = IntConfig{Value: 1000, Default: 1000, Description: "Per-attempt HTTP timeout in milliseconds."}
 	config.Catalog.MaxAttempts = IntConfig{Value: 3, Default: 3, Description: "Total catalog Lookup attempts including the first call (1 initial + N-1 retries)."}
 
+	config.Inventory.Description = "Inventory domain settings. Reservations past their expiry are swept back into available stock."
+	config.Inventory.ReservationTTLSeconds = IntConfig{Value: 0, Default: 0, Description: "Default reservation hold, in seconds, for requests that don't set ttlSeconds. 0 disables the default so reservations only expire when the request asks for it."}
+	config.Inventory.ReservationSweepSeconds = IntConfig{Value: 60, Default: 60, Description: "How often the expired-reservation sweeper runs, in seconds. 0 or negative disables the sweeper."}
+	config.Inventory.LotSweepSeconds = IntConfig{Value: 60, Default: 60, Description: "How often the expired-lot sweeper runs, in seconds. 0 or negative disables the sweeper; expired lots are then only caught when their SKU's stock is next drawn."}
+	config.Inventory.BackorderMetricsSeconds = IntConfig{Value: 60, Default: 60, Description: "How often the per-SKU smfg_inventory_backorder_shortfall gauge is refreshed, in seconds. 0 or negative turns the gauge off, which keeps one series per backordered SKU out of /metrics."}
+	config.Inventory.DefaultLocation = StringConfig{Value: "default", Default: "default", Description: "Warehouse location used for production, adjustments and new products when the request doesn't name one."}
+	config.Inventory.LocationStrategy = StringConfig{Value: "most_available", Default: "most_available", Description: "How reservations without a preferred location pick one: most_available (the location holding the most stock) or priority (the first location in locationPriority that can cover the request)."}
+	config.Inventory.LocationPriority = StringConfig{Value: "", Default: "", Description: "Comma-separated location order used by the priority location strategy."}
+	config.Inventory.AllocationStrategy = StringConfig{Value: "fifo", Default: "fifo", Description: "How stock is shared between open reservations: fifo (oldest first), priority (highest reservation priority first), fair_share (split across requesters in proportion to demand when short) or all_or_nothing (only fill reservations that can be covered in full)."}
+	config.Inventory.AllocationOverrides = StringConfig{Value: "", Default: "", Description: "Comma-separated sku=strategy pairs that override allocationStrategy for individual SKUs."}
+
 	config.Idempotency.Description = "DSN-019: REST Idempotency-Key cache. Retains cached responses for ttlMinutes so retries replay byte-for-byte."
 	config.Idempotency.TTLMinutes = IntConfig{Value: 24 * 60, Default: 24 * 60, Description: "Retention window for cached responses, in minutes. Stripe-style 24h default."}
 
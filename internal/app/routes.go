@@ -52,7 +52,8 @@ const (
 //
 // idempotencyMw is the optional DSN-019 Idempotency-Key middleware.
 // nil leaves the mutating routes unwrapped; non-nil applies it to
-// productionEvent and the reservation Create route.
+// productionEvent, adjustment and the reservation Create and shipment
+// routes.
 //
 // authRateLimitMw is the optional DSN-021b rate-limit middleware
 // applied to /auth/token. nil leaves the route un-throttled.
@@ -75,12 +76,12 @@ func ConfigureRouter(cfg *config.Config, invSvc inventory.InventoryService, resS
 	if origins := parseCORSOrigins(cfg.CORS.AllowedOrigins.Value); len(origins) > 0 {
 		r.Use(cors.Handler(cors.Options{
 			AllowedOrigins: origins,
-			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 			// X-CSRF-Token removed: SEC-002c took the project to
 			// bearer-token auth, and no CSRF token strategy exists
 			// to back the header. Re-add only when a real one does.
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-			ExposedHeaders:   []string{"Link"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match"},
+			ExposedHeaders:   []string{"Link", "ETag"},
 			AllowCredentials: cfg.CORS.AllowCredentials.Value,
 			MaxAge:           300, // Maximum value not ignored by any of major browsers
 		}))
@@ -357,6 +357,16 @@ func buildDeps(ctx context.Context, cfg *config.Config) (Deps, error) {
 	if redisClient != nil {
 		invService.SetCache(cache.NewRedisCache(redisClient), time.Duration(cfg.Redis.CacheTTLMinutes.Value)*time.Minute)
 	}
+	invService.SetReservationTTL(time.Duration(cfg.Inventory.ReservationTTLSeconds.Value) * time.Second)
+	if err := configureLocations(cfg, invService); err != nil {
+		return Deps{}, err
+	}
+	if err := configureAllocation(cfg, invService); err != nil {
+		return Deps{}, err
+	}
+	startReservationSweeper(ctx, cfg, invService)
+	startLotSweeper(ctx, cfg, invService)
+	startBackorderReporter(ctx, cfg, invService)
 
 	ur := user.NewPostgresRepo(dbPool)
 	if redisClient != nil {
@@ -372,6 +382,7 @@ func buildDeps(ctx context.Context, cfg *config.Config) (Deps, error) {
 	if err != nil {
 		return Deps{}, err
 	}
+	configureCursorKey()
 
 	readinessDeps := map[string]Pinger{"db": dbPool}
 	if redisClient != nil {
@@ -410,6 +421,131 @@ func buildDeps(ctx context.Context, cfg *config.Config) (Deps, error) {
 	}, nil
 }
 
+// reservationSweeper is the slice of the inventory service the
+// expiry job needs.
+type reservationSweeper interface {
+	SweepExpiredReservations(ctx context.Context, every time.Duration)
+}
+
+// startReservationSweeper launches the background job that expires
+// overdue reservations. It stops when ctx is canceled at shutdown. A
+// non-positive inventory.reservationSweepSeconds leaves it off.
+func startReservationSweeper(ctx context.Context, cfg *config.Config, invService reservationSweeper) {
+	every := time.Duration(cfg.Inventory.ReservationSweepSeconds.Value) * time.Second
+	if every <= 0 {
+		log.Info().Msg("reservation expiry sweeper disabled (inventory.reservationSweepSeconds <= 0)")
+		return
+	}
+	go invService.SweepExpiredReservations(ctx, every)
+	log.Info().
+		Dur("every", every).
+		Int64("defaultTtlSeconds", cfg.Inventory.ReservationTTLSeconds.Value).
+		Msg("reservation expiry sweeper started")
+}
+
+// lotSweeper is the slice of the inventory service the lot expiry job
+// needs.
+type lotSweeper interface {
+	SweepExpiredLots(ctx context.Context, every time.Duration)
+}
+
+// startLotSweeper launches the background job that moves lots past
+// their expiry date out of available stock. It stops when ctx is
+// canceled at shutdown. A non-positive inventory.lotSweepSeconds
+// leaves it off.
+func startLotSweeper(ctx context.Context, cfg *config.Config, invService lotSweeper) {
+	every := time.Duration(cfg.Inventory.LotSweepSeconds.Value) * time.Second
+	if every <= 0 {
+		log.Info().Msg("lot expiry sweeper disabled (inventory.lotSweepSeconds <= 0)")
+		return
+	}
+	go invService.SweepExpiredLots(ctx, every)
+	log.Info().Dur("every", every).Msg("lot expiry sweeper started")
+}
+
+// backorderReporter is the slice of the inventory service the
+// backorder gauge job needs.
+type backorderReporter interface {
+	ReportBackorders(ctx context.Context, every time.Duration)
+}
+
+// startBackorderReporter launches the background job that refreshes
+// the per-SKU backorder gauge. It stops when ctx is canceled at
+// shutdown. A non-positive inventory.backorderMetricsSeconds leaves it
+// off, and the gauge is never registered.
+func startBackorderReporter(ctx context.Context, cfg *config.Config, invService backorderReporter) {
+	every := time.Duration(cfg.Inventory.BackorderMetricsSeconds.Value) * time.Second
+	if every <= 0 {
+		log.Info().Msg("backorder gauge disabled (inventory.backorderMetricsSeconds <= 0)")
+		return
+	}
+	go invService.ReportBackorders(ctx, every)
+	log.Info().Dur("every", every).Msg("backorder gauge reporter started")
+}
+
+// locationConfigurer is the slice of the inventory service
+// configureLocations needs.
+type locationConfigurer interface {
+	SetDefaultLocation(location string)
+	SetLocationStrategy(strategy inventory.LocationStrategy, priority []string)
+}
+
+// configureLocations applies the inventory.* location settings. An
+// unknown strategy fails startup rather than silently falling back.
+func configureLocations(cfg *config.Config, invService locationConfigurer) error {
+	strategy, err := inventory.ParseLocationStrategy(cfg.Inventory.LocationStrategy.Value)
+	if err != nil {
+		return fmt.Errorf("inventory.locationStrategy: %w", err)
+	}
+	var priority []string
+	for _, l := range strings.Split(cfg.Inventory.LocationPriority.Value, ",") {
+		if trimmed := strings.TrimSpace(l); trimmed != "" {
+			priority = append(priority, trimmed)
+		}
+	}
+	if strategy == inventory.LocationPriority && len(priority) == 0 {
+		return errors.New("inventory.locationPriority is required when inventory.locationStrategy is priority")
+	}
+	invService.SetDefaultLocation(cfg.Inventory.DefaultLocation.Value)
+	invService.SetLocationStrategy(strategy, priority)
+	return nil
+}
+
+// allocationConfigurer is the slice of the inventory service
+// configureAllocation needs.
+type allocationConfigurer interface {
+	SetAllocationStrategy(global inventory.AllocationStrategy, perSku map[string]inventory.AllocationStrategy)
+}
+
+// configureAllocation applies inventory.allocationStrategy and the
+// per-SKU inventory.allocationOverrides. Like the location settings,
+// an unknown strategy or a malformed override fails startup.
+func configureAllocation(cfg *config.Config, invService allocationConfigurer) error {
+	global, err := inventory.ParseAllocationStrategy(cfg.Inventory.AllocationStrategy.Value)
+	if err != nil {
+		return fmt.Errorf("inventory.allocationStrategy: %w", err)
+	}
+	perSku := make(map[string]inventory.AllocationStrategy)
+	for _, pair := range strings.Split(cfg.Inventory.AllocationOverrides.Value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		sku, name, ok := strings.Cut(pair, "=")
+		sku, name = strings.TrimSpace(sku), strings.TrimSpace(name)
+		if !ok || sku == "" || name == "" {
+			return fmt.Errorf("inventory.allocationOverrides: %q is not sku=strategy", pair)
+		}
+		strategy, err := inventory.ParseAllocationStrategy(name)
+		if err != nil {
+			return fmt.Errorf("inventory.allocationOverrides %q: %w", sku, err)
+		}
+		perSku[sku] = strategy
+	}
+	invService.SetAllocationStrategy(global, perSku)
+	return nil
+}
+
 // redisPinger adapts a *redis.Client to Pinger so /ready can verify
 // Redis connectivity. The native Redis Ping returns a *StatusCmd
 // instead of an error directly; wrap to match the interface.
@@ -547,6 +683,19 @@ func configureSigner(profile string) (*auth.Signer, error) {
 	return signer, nil
 }
 
+// configureCursorKey sets the key list cursors are signed with. Like the
+// JWT signer it falls back to a per-process key, which breaks cursors
+// handed out by one replica and followed on another.
+func configureCursorKey() {
+	key := []byte(os.Getenv("GME_PAGINATION_CURSOR_KEY"))
+	if len(key) < auth.MinSigningKeyBytes {
+		log.Warn().Msg("GME_PAGINATION_CURSOR_KEY missing or shorter than 32 bytes; using ephemeral key. Pagination cursors will not survive a restart or work across replicas.")
+		return
+	}
+	httpx.SetCursorKey(key)
+	log.Info().Msg("pagination cursor key ready")
+}
+
 // kafkaInventoryService is the surface startKafka needs from the
 // inventory service. Defining it inline keeps this package off the
 // package-private *inventory.service while still requiring the
@@ -555,6 +704,9 @@ type kafkaInventoryService interface {
 	SetEventEmitter(inventory.EventEmitter)
 	GetProduct(ctx context.Context, sku string) (inventory.Product, error)
 	Produce(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error
+	Adjust(ctx context.Context, product inventory.Product, ar inventory.AdjustmentRequest) (inventory.Adjustment, error)
+	Ship(ctx context.Context, ID uint64, sr inventory.ShipmentRequest) (inventory.Shipment, error)
+	RecordReturn(ctx context.Context, product inventory.Product, rr inventory.ReturnRequest) (inventory.Return, error)
 }
 
 func startKafka(ctx context.Context, cfg *config.Config, invService kafkaInventoryService, pool *pgxpool.Pool) func() {
@@ -22,7 +22,7 @@ type fakeInvService struct {
 	inventory.ReservationService
 }
 
-func (fakeInvService) GetAllProductInventory(_ context.Context, _, _ int) ([]inventory.ProductInventory, error) {
+func (fakeInvService) GetAllProductInventory(_ context.Context, _ inventory.ProductListOptions, _, _ int) ([]inventory.ProductInventory, error) {
 	return []inventory.ProductInventory{}, nil
 }
 
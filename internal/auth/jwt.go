@@ -102,7 +102,10 @@ func (s *Signer) Issue(u user.User) (token string, expiresAt time.Time, err erro
 	expiresAt = now.Add(s.ttl)
 	roles := []string{}
 	if u.IsAdmin {
-		roles = append(roles, "admin")
+		roles = append(roles, RoleAdmin)
+	}
+	if u.IsInventoryManager {
+		roles = append(roles, RoleInventoryManager)
 	}
 	c := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
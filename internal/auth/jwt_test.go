@@ -93,6 +93,18 @@ func TestNonAdminHasEmptyRoles(t *testing.T) {
 	}
 }
 
+func TestInventoryManagerRole(t *testing.T) {
+	s, _ := auth.NewSigner([]byte(validKey), 0, true)
+	tok, _, _ := s.Issue(user.User{Username: "carol", IsInventoryManager: true})
+	claims, err := s.Parse(tok)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != auth.RoleInventoryManager {
+		t.Errorf("roles got=%v want=[%s]", claims.Roles, auth.RoleInventoryManager)
+	}
+}
+
 func TestParseRejectsExpired(t *testing.T) {
 	// 1ns TTL guarantees expiry by the time we parse.
 	s, _ := auth.NewSigner([]byte(validKey), time.Nanosecond, true)
@@ -18,6 +18,12 @@ type CtxKey string
 
 const CtxKeyUser CtxKey = "user"
 
+// JWT role names carried in Claims.Roles.
+const (
+	RoleAdmin            = "admin"
+	RoleInventoryManager = "inventory-manager"
+)
+
 // Authenticate requires a Bearer JWT (SEC-002c). HTTP Basic credentials
 // are no longer accepted on protected routes; callers must exchange
 // credentials at /auth/token (SEC-002a) and present the issued JWT.
@@ -52,8 +58,11 @@ func authenticateBearer(token string, signer *Signer) (user.User, error) {
 	}
 	u := user.User{Username: claims.Subject}
 	for _, role := range claims.Roles {
-		if role == "admin" {
+		switch role {
+		case RoleAdmin:
 			u.IsAdmin = true
+		case RoleInventoryManager:
+			u.IsInventoryManager = true
 		}
 	}
 	return u, nil
@@ -74,6 +83,22 @@ func AdminOnly(next http.Handler) http.Handler {
 	})
 }
 
+// InventoryManagerOnly rejects requests whose authenticated user is
+// neither an admin nor an inventory manager. Must mount inside
+// Authenticate so CtxKeyUser is set.
+func InventoryManagerOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usr, ok := r.Context().Value(CtxKeyUser).(user.User)
+
+		if !ok || !(usr.IsAdmin || usr.IsInventoryManager) {
+			authErr(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func authErr(w http.ResponseWriter) {
 	w.Header().Set("WWW-Authenticate", `Bearer realm="restricted"`)
 	http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -0,0 +1,164 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AllocationStrategy decides how FillReserves shares the stock held at
+// one location between the open reservations drawing from it.
+type AllocationStrategy interface {
+	// Allocate returns how much of available each reservation in open
+	// receives, indexed like open. The total never exceeds available
+	// and no reservation is given more than it still needs.
+	Allocate(open []Reservation, available int64) []int64
+}
+
+const (
+	AllocationFIFO         = "fifo"
+	AllocationPriority     = "priority"
+	AllocationFairShare    = "fair_share"
+	AllocationAllOrNothing = "all_or_nothing"
+)
+
+// ParseAllocationStrategy returns the strategy with the given config
+// name. Empty means FIFO, which is how reserves were always filled.
+func ParseAllocationStrategy(v string) (AllocationStrategy, error) {
+	switch v {
+	case AllocationFIFO, "":
+		return FIFOAllocation{}, nil
+	case AllocationPriority:
+		return PriorityAllocation{}, nil
+	case AllocationFairShare:
+		return FairShareAllocation{}, nil
+	case AllocationAllOrNothing:
+		return AllOrNothingAllocation{}, nil
+	default:
+		return nil, fmt.Errorf("invalid allocation strategy %q: %w", v, ErrInvalidInput)
+	}
+}
+
+// FIFOAllocation fills the oldest reservations first.
+type FIFOAllocation struct{}
+
+func (FIFOAllocation) Allocate(open []Reservation, available int64) []int64 {
+	return fillInOrder(open, byCreated(open), available, false)
+}
+
+// PriorityAllocation fills the highest Priority first, oldest first
+// among equals.
+type PriorityAllocation struct{}
+
+func (PriorityAllocation) Allocate(open []Reservation, available int64) []int64 {
+	order := byCreated(open)
+	sort.SliceStable(order, func(i, j int) bool {
+		return open[order[i]].Priority > open[order[j]].Priority
+	})
+	return fillInOrder(open, order, available, false)
+}
+
+// AllOrNothingAllocation fills reservations oldest first but only when
+// the stock covers everything they still need; a reservation it can't
+// cover is skipped so smaller ones behind it can still be filled.
+type AllOrNothingAllocation struct{}
+
+func (AllOrNothingAllocation) Allocate(open []Reservation, available int64) []int64 {
+	return fillInOrder(open, byCreated(open), available, true)
+}
+
+// FairShareAllocation fills everything when there's enough stock. When
+// there isn't, it splits the stock between requesters in proportion to
+// what each still needs, hands any rounding remainder out one unit at
+// a time starting with the requester who has waited longest, and
+// fills each requester's own reservations oldest first.
+type FairShareAllocation struct{}
+
+func (FairShareAllocation) Allocate(open []Reservation, available int64) []int64 {
+	order := byCreated(open)
+
+	var total int64
+	var requesters []string
+	need := make(map[string]int64)
+	for _, i := range order {
+		n := outstanding(open[i])
+		if n == 0 {
+			continue
+		}
+		if _, ok := need[open[i].Requester]; !ok {
+			requesters = append(requesters, open[i].Requester)
+		}
+		need[open[i].Requester] += n
+		total += n
+	}
+	if total <= available {
+		return fillInOrder(open, order, available, false)
+	}
+
+	share := make(map[string]int64, len(requesters))
+	left := available
+	for _, r := range requesters {
+		share[r] = available * need[r] / total
+		left -= share[r]
+	}
+	for left > 0 {
+		for _, r := range requesters {
+			if left == 0 {
+				break
+			}
+			if share[r] < need[r] {
+				share[r]++
+				left--
+			}
+		}
+	}
+
+	out := make([]int64, len(open))
+	for _, i := range order {
+		r := open[i].Requester
+		out[i] = min(outstanding(open[i]), share[r])
+		share[r] -= out[i]
+	}
+	return out
+}
+
+func outstanding(r Reservation) int64 {
+	if n := r.RequestedQuantity - r.ReservedQuantity; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// byCreated returns the indexes of open, oldest reservation first.
+func byCreated(open []Reservation) []int {
+	order := make([]int, len(open))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := open[order[i]], open[order[j]]
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+		return a.ID < b.ID
+	})
+	return order
+}
+
+// fillInOrder walks open in order handing out stock until it runs
+// out. With whole set, a reservation only receives stock if all of
+// what it still needs can be covered.
+func fillInOrder(open []Reservation, order []int, available int64, whole bool) []int64 {
+	out := make([]int64, len(open))
+	for _, i := range order {
+		n := outstanding(open[i])
+		if n > available {
+			if whole {
+				continue
+			}
+			n = available
+		}
+		out[i] = n
+		available -= n
+	}
+	return out
+}
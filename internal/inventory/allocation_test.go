@@ -0,0 +1,120 @@
+package inventory_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sksmith/go-micro-example/internal/inventory"
+)
+
+func TestAllocationStrategies(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return t0.Add(time.Duration(minutes) * time.Minute) }
+
+	tests := []struct {
+		name      string
+		strategy  string
+		open      []inventory.Reservation
+		available int64
+		want      []int64
+	}{
+		{
+			name:     "fifo fills oldest first regardless of read order",
+			strategy: inventory.AllocationFIFO,
+			open: []inventory.Reservation{
+				{ID: 2, RequestedQuantity: 5, Created: at(2)},
+				{ID: 1, RequestedQuantity: 5, Created: at(1)},
+			},
+			available: 7,
+			want:      []int64{2, 5},
+		},
+		{
+			name:     "fifo counts what is already reserved",
+			strategy: inventory.AllocationFIFO,
+			open: []inventory.Reservation{
+				{ID: 1, RequestedQuantity: 5, ReservedQuantity: 4, Created: at(1)},
+				{ID: 2, RequestedQuantity: 5, Created: at(2)},
+			},
+			available: 3,
+			want:      []int64{1, 2},
+		},
+		{
+			name:     "priority fills highest first then oldest",
+			strategy: inventory.AllocationPriority,
+			open: []inventory.Reservation{
+				{ID: 1, RequestedQuantity: 4, Created: at(1)},
+				{ID: 2, RequestedQuantity: 4, Created: at(2), Priority: 5},
+				{ID: 3, RequestedQuantity: 4, Created: at(3), Priority: 5},
+			},
+			available: 6,
+			want:      []int64{0, 4, 2},
+		},
+		{
+			name:     "all or nothing skips what it can't cover",
+			strategy: inventory.AllocationAllOrNothing,
+			open: []inventory.Reservation{
+				{ID: 1, RequestedQuantity: 8, Created: at(1)},
+				{ID: 2, RequestedQuantity: 3, Created: at(2)},
+				{ID: 3, RequestedQuantity: 3, Created: at(3)},
+			},
+			available: 5,
+			want:      []int64{0, 3, 0},
+		},
+		{
+			name:     "fair share fills everything when stock covers demand",
+			strategy: inventory.AllocationFairShare,
+			open: []inventory.Reservation{
+				{ID: 1, Requester: "a", RequestedQuantity: 3, Created: at(1)},
+				{ID: 2, Requester: "b", RequestedQuantity: 2, Created: at(2)},
+			},
+			available: 10,
+			want:      []int64{3, 2},
+		},
+		{
+			name:     "fair share splits shortfall in proportion to demand",
+			strategy: inventory.AllocationFairShare,
+			open: []inventory.Reservation{
+				{ID: 1, Requester: "a", RequestedQuantity: 6, Created: at(1)},
+				{ID: 2, Requester: "b", RequestedQuantity: 2, Created: at(2)},
+				{ID: 3, Requester: "a", RequestedQuantity: 4, Created: at(3)},
+			},
+			available: 6,
+			// a needs 10 of 12 and gets 5, b needs 2 of 12 and gets 1.
+			want: []int64{5, 1, 0},
+		},
+		{
+			name:     "fair share hands the remainder to the longest waiting requester",
+			strategy: inventory.AllocationFairShare,
+			open: []inventory.Reservation{
+				{ID: 2, Requester: "b", RequestedQuantity: 5, Created: at(2)},
+				{ID: 1, Requester: "a", RequestedQuantity: 5, Created: at(1)},
+			},
+			available: 5,
+			want:      []int64{2, 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strategy, err := inventory.ParseAllocationStrategy(test.strategy)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := strategy.Allocate(test.open, test.available)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("allocation got=%v want=%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseAllocationStrategy(t *testing.T) {
+	if s, err := inventory.ParseAllocationStrategy(""); err != nil || s != (inventory.FIFOAllocation{}) {
+		t.Errorf("empty got=%v, %v want FIFO", s, err)
+	}
+	if _, err := inventory.ParseAllocationStrategy("lifo"); !errors.Is(err, inventory.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got=%v", err)
+	}
+}
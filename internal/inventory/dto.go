@@ -16,6 +16,10 @@ type ProductResponse struct {
 	// or when the upstream is unreachable — the inventory response
 	// still succeeds in that case.
 	Catalog *CatalogInfo `json:"catalog,omitempty"`
+
+	// AsOf echoes the instant a historical read was reconstructed at.
+	// Omitted for current-inventory reads.
+	AsOf *time.Time `json:"asOf,omitempty"`
 } // @name ProductResponse
 
 // CatalogInfo is the subset of upstream catalog data the inventory
@@ -54,13 +58,45 @@ func NewReservationListResponse(reservations []Reservation) []render.Renderer {
 	return list
 }
 
+type MovementResponse struct {
+	InventoryMovement
+} // @name MovementResponse
+
+func (m *MovementResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewMovementListResponse(movements []InventoryMovement) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, mv := range movements {
+		list = append(list, &MovementResponse{InventoryMovement: mv})
+	}
+	return list
+}
+
 type CreateProductRequest struct {
 	Product
 } // @name CreateProductRequest
 
 func (p *CreateProductRequest) Bind(_ *http.Request) error {
-	if p.Upc == "" || p.Name == "" || p.Sku == "" {
-		return errors.New("missing required field(s)")
+	return validateNewProduct(p.Product)
+}
+
+type ImportResponse struct {
+	ImportResult
+} // @name ImportResponse
+
+func (i *ImportResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+type ProductUpdateDto struct {
+	*ProductUpdate
+} // @name ProductUpdateDto
+
+func (p *ProductUpdateDto) Bind(_ *http.Request) error {
+	if p.ProductUpdate == nil || *p.ProductUpdate == (ProductUpdate{}) {
+		return errors.New("at least one of upc, name or state is required")
 	}
 
 	return nil
@@ -87,6 +123,287 @@ func (p *CreateProductionEventRequest) Bind(_ *http.Request) error {
 	return nil
 }
 
+type AdjustmentRequestDto struct {
+	*AdjustmentRequest
+} // @name AdjustmentRequestDto
+
+func (a *AdjustmentRequestDto) Bind(_ *http.Request) error {
+	if a.AdjustmentRequest == nil {
+		return errors.New("missing required Adjustment fields")
+	}
+	if a.RequestID == "" {
+		return errors.New("requestId is required")
+	}
+	if a.Quantity == 0 {
+		return errors.New("quantity must not be zero")
+	}
+	if a.Reason == "" {
+		return errors.New("reason is required")
+	}
+
+	return nil
+}
+
+type AdjustmentResponse struct {
+	Adjustment
+} // @name AdjustmentResponse
+
+func (a *AdjustmentResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+type StatusChangeRequestDto struct {
+	*StatusChangeRequest
+} // @name StatusChangeRequestDto
+
+func (c *StatusChangeRequestDto) Bind(_ *http.Request) error {
+	if c.StatusChangeRequest == nil {
+		return errors.New("missing required status change fields")
+	}
+	if c.RequestID == "" {
+		return errors.New("requestId is required")
+	}
+	if c.From == "" || c.To == "" {
+		return errors.New("from and to are required")
+	}
+	if c.Quantity < 1 {
+		return errors.New("quantity must be greater than zero")
+	}
+	if c.Reason == "" {
+		return errors.New("reason is required")
+	}
+
+	return nil
+}
+
+type StatusChangeResponse struct {
+	StatusChange
+} // @name StatusChangeResponse
+
+func (c *StatusChangeResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+type TransferRequestDto struct {
+	*TransferRequest
+} // @name TransferRequestDto
+
+func (t *TransferRequestDto) Bind(_ *http.Request) error {
+	if t.TransferRequest == nil {
+		return errors.New("missing required Transfer fields")
+	}
+	if t.RequestID == "" {
+		return errors.New("requestId is required")
+	}
+	if t.From == "" || t.To == "" {
+		return errors.New("from and to are required")
+	}
+	if t.Quantity < 1 {
+		return errors.New("quantity must be greater than zero")
+	}
+
+	return nil
+}
+
+type TransferResponse struct {
+	Transfer
+} // @name TransferResponse
+
+func (t *TransferResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewTransferListResponse(transfers []Transfer) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, t := range transfers {
+		list = append(list, &TransferResponse{Transfer: t})
+	}
+	return list
+}
+
+type ReturnRequestDto struct {
+	*ReturnRequest
+} // @name ReturnRequestDto
+
+func (r *ReturnRequestDto) Bind(_ *http.Request) error {
+	if r.ReturnRequest == nil {
+		return errors.New("missing required Return fields")
+	}
+	if r.RequestID == "" {
+		return errors.New("requestId is required")
+	}
+	if r.Quantity < 1 {
+		return errors.New("quantity must be greater than zero")
+	}
+	if r.Outcome == "" {
+		return errors.New("outcome is required")
+	}
+	if r.ReservationID == 0 && r.Requester == "" {
+		return errors.New("reservationId or requester is required")
+	}
+
+	return nil
+}
+
+type ReturnResponse struct {
+	Return
+} // @name ReturnResponse
+
+func (r *ReturnResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewReturnListResponse(returns []Return) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, ret := range returns {
+		list = append(list, &ReturnResponse{Return: ret})
+	}
+	return list
+}
+
+type BillOfMaterialsRequestDto struct {
+	Components []BOMComponent `json:"components"`
+} // @name BillOfMaterialsRequestDto
+
+func (b *BillOfMaterialsRequestDto) Bind(_ *http.Request) error {
+	if len(b.Components) == 0 {
+		return errors.New("components are required; delete the bill of materials to remove it")
+	}
+	return nil
+}
+
+type BillOfMaterialsResponse struct {
+	BillOfMaterials
+} // @name BillOfMaterialsResponse
+
+func (b *BillOfMaterialsResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+type BuildableResponse struct {
+	Buildable
+} // @name BuildableResponse
+
+func (b *BuildableResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+type LotResponse struct {
+	Lot
+} // @name LotResponse
+
+func (l *LotResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewLotListResponse(lots []Lot) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, l := range lots {
+		list = append(list, &LotResponse{Lot: l})
+	}
+	return list
+}
+
+type LotRecipientResponse struct {
+	LotRecipient
+} // @name LotRecipientResponse
+
+func (l *LotRecipientResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewLotRecipientListResponse(recipients []LotRecipient) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, r := range recipients {
+		list = append(list, &LotRecipientResponse{LotRecipient: r})
+	}
+	return list
+}
+
+type ReorderPolicyRequestDto struct {
+	ReorderPoint int64 `json:"reorderPoint"`
+	TargetLevel  int64 `json:"targetLevel"`
+} // @name ReorderPolicyRequestDto
+
+func (p *ReorderPolicyRequestDto) Bind(_ *http.Request) error {
+	if p.ReorderPoint < 1 {
+		return errors.New("reorderPoint must be greater than zero; delete the reorder policy to stop alerts")
+	}
+	if p.TargetLevel < p.ReorderPoint {
+		return errors.New("targetLevel must not be below reorderPoint")
+	}
+	return nil
+}
+
+type ReorderPolicyResponse struct {
+	ReorderPolicy
+} // @name ReorderPolicyResponse
+
+func (p *ReorderPolicyResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+type LowStockResponse struct {
+	LowStock
+} // @name LowStockResponse
+
+func (l *LowStockResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewLowStockListResponse(low []LowStock) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, l := range low {
+		list = append(list, &LowStockResponse{LowStock: l})
+	}
+	return list
+}
+
+type PlannedOrderRequestDto struct {
+	*PlannedOrderRequest
+} // @name PlannedOrderRequestDto
+
+func (p *PlannedOrderRequestDto) Bind(_ *http.Request) error {
+	if p.PlannedOrderRequest == nil {
+		return errors.New("missing required planned order fields")
+	}
+	if p.RequestID == "" {
+		return errors.New("requestId is required")
+	}
+	if p.Quantity < 1 {
+		return errors.New("quantity must be greater than zero")
+	}
+	if p.ExpectedDate.IsZero() {
+		return errors.New("expectedDate is required")
+	}
+
+	return nil
+}
+
+type PlannedOrderResponse struct {
+	PlannedOrder
+} // @name PlannedOrderResponse
+
+func (p *PlannedOrderResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewPlannedOrderListResponse(orders []PlannedOrder) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, o := range orders {
+		list = append(list, &PlannedOrderResponse{PlannedOrder: o})
+	}
+	return list
+}
+
+type AvailableToPromiseResponse struct {
+	AvailableToPromise
+} // @name AvailableToPromiseResponse
+
+func (a *AvailableToPromiseResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
 type ProductionEventResponse struct{} // @name ProductionEventResponse
 
 func (p *ProductionEventResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
@@ -3,6 +3,8 @@ package inventory
 import (
 	"errors"
 	"net/http"
+
+	"github.com/go-chi/render"
 )
 
 type ReservationRequestDto struct {
@@ -19,6 +21,24 @@ func (r *ReservationRequestDto) Bind(_ *http.Request) error {
 	if r.Quantity < 1 {
 		return errors.New("requested quantity must be greater than zero")
 	}
+	if r.TTLSeconds < 0 {
+		return errors.New("ttl seconds cannot be negative")
+	}
+
+	return nil
+}
+
+type ReservationUpdateDto struct {
+	*ReservationUpdate
+} // @name ReservationUpdateDto
+
+func (r *ReservationUpdateDto) Bind(_ *http.Request) error {
+	if r.ReservationUpdate == nil {
+		return errors.New("missing required Reservation fields")
+	}
+	if r.Quantity < 1 {
+		return errors.New("quantity must be greater than zero")
+	}
 
 	return nil
 }
@@ -30,3 +50,75 @@ type ReservationResponse struct {
 func (r *ReservationResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
 	return nil
 }
+
+type ShipmentRequestDto struct {
+	*ShipmentRequest
+} // @name ShipmentRequestDto
+
+func (s *ShipmentRequestDto) Bind(_ *http.Request) error {
+	if s.ShipmentRequest == nil {
+		return errors.New("missing required Shipment fields")
+	}
+	if s.RequestID == "" {
+		return errors.New("requestId is required")
+	}
+	if s.Quantity < 1 {
+		return errors.New("quantity must be greater than zero")
+	}
+
+	return nil
+}
+
+type ShipmentResponse struct {
+	Shipment
+} // @name ShipmentResponse
+
+func (s *ShipmentResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+type BackorderResponse struct {
+	Backorder
+} // @name BackorderResponse
+
+func (b *BackorderResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewBackorderListResponse(backorders []Backorder) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, b := range backorders {
+		list = append(list, &BackorderResponse{Backorder: b})
+	}
+	return list
+}
+
+type RequesterLimitsRequestDto struct {
+	Class               string `json:"class,omitempty"`
+	MaxReservedPerSku   int64  `json:"maxReservedPerSku"`
+	MaxOpenReservations int64  `json:"maxOpenReservations"`
+	DailyCap            int64  `json:"dailyCap"`
+} // @name RequesterLimitsRequestDto
+
+func (l *RequesterLimitsRequestDto) Bind(_ *http.Request) error {
+	if l.MaxReservedPerSku < 0 || l.MaxOpenReservations < 0 || l.DailyCap < 0 {
+		return errors.New("limits cannot be negative; use 0 for unlimited")
+	}
+	return nil
+}
+
+type RequesterLimitsResponse struct {
+	RequesterLimits
+} // @name RequesterLimitsResponse
+
+func (l *RequesterLimitsResponse) Render(_ http.ResponseWriter, _ *http.Request) error {
+	return nil
+}
+
+func NewRequesterLimitsListResponse(limits []RequesterLimits) []render.Renderer {
+	list := make([]render.Renderer, 0)
+	for _, l := range limits {
+		list = append(list, &RequesterLimitsResponse{RequesterLimits: l})
+	}
+	return list
+}
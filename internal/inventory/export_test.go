@@ -18,3 +18,11 @@ func StreamInventoryToClientForTest(svc InventoryService, w textWriter) {
 func StreamReservationsToClientForTest(svc ReservationService, w textWriter) {
 	streamReservationsToClient(svc, w)
 }
+
+// SetExportFetchSizeForTest changes how many rows an export fetches at
+// a time and returns a func restoring the previous size.
+func SetExportFetchSizeForTest(n int) (restore func()) {
+	prev := exportFetchSize
+	exportFetchSize = n
+	return func() { exportFetchSize = prev }
+}
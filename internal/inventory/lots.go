@@ -0,0 +1,220 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// lotSet is a SKU's lots, loaded and locked for one write. Stock leaves
+// them first-expiring-first-out: lots with the earliest expiry date go
+// first, lots without one after every lot that has one, and stock
+// produced without a lot after that. It remembers which lots changed so
+// only those are saved.
+type lotSet struct {
+	sku     string
+	lots    []Lot
+	changed map[lotKey]bool
+}
+
+type lotKey struct {
+	location string
+	lot      string
+}
+
+func newLotSet(sku string, lots []Lot) *lotSet {
+	ls := &lotSet{sku: sku, lots: lots, changed: make(map[lotKey]bool)}
+	ls.sort()
+	return ls
+}
+
+func (ls *lotSet) sort() {
+	sort.SliceStable(ls.lots, func(i, j int) bool { return expiresBefore(ls.lots[i], ls.lots[j]) })
+}
+
+// expiresBefore orders lots by expiry date, undated lots last, and by
+// lot number between lots expiring together.
+func expiresBefore(a, b Lot) bool {
+	switch {
+	case a.ExpiresAt == nil && b.ExpiresAt == nil:
+		return a.Lot < b.Lot
+	case a.ExpiresAt == nil:
+		return false
+	case b.ExpiresAt == nil:
+		return true
+	case !a.ExpiresAt.Equal(*b.ExpiresAt):
+		return a.ExpiresAt.Before(*b.ExpiresAt)
+	default:
+		return a.Lot < b.Lot
+	}
+}
+
+func (ls *lotSet) find(location, lot string) *Lot {
+	for i := range ls.lots {
+		if ls.lots[i].Location == location && ls.lots[i].Lot == lot {
+			return &ls.lots[i]
+		}
+	}
+	return nil
+}
+
+func (ls *lotSet) touch(l *Lot) {
+	ls.changed[lotKey{location: l.Location, lot: l.Lot}] = true
+}
+
+// add puts qty units of lot into stock at location, creating the lot if
+// it is new. Topping up a lot keeps its expiry date; naming a different
+// one, or topping up a lot that has already expired, is invalid input.
+func (ls *lotSet) add(location, lot string, expiresAt *time.Time, qty int64, now time.Time) error {
+	l := ls.find(location, lot)
+	if l == nil {
+		ls.lots = append(ls.lots, Lot{Sku: ls.sku, Location: location, Lot: lot, ExpiresAt: expiresAt})
+		l = &ls.lots[len(ls.lots)-1]
+	}
+	switch {
+	case expiresAt != nil && (l.ExpiresAt == nil || !l.ExpiresAt.Equal(*expiresAt)):
+		return fmt.Errorf("lot %q at %q already has a different expiry date: %w", lot, location, ErrInvalidInput)
+	case l.ExpiredAt(now):
+		return fmt.Errorf("lot %q at %q expired at %s: %w",
+			lot, location, l.ExpiresAt.Format(time.RFC3339), ErrInvalidInput)
+	}
+	l.Available += qty
+	ls.touch(l)
+	ls.sort()
+	return nil
+}
+
+// arrive puts qty units of lot, brought from another location, into
+// stock at location, creating the lot there with expiresAt if it is
+// new. Unlike add it takes stock whose lot has expired on the way;
+// callers expire that straight after.
+func (ls *lotSet) arrive(location, lot string, expiresAt *time.Time, qty int64) {
+	l := ls.find(location, lot)
+	if l == nil {
+		ls.lots = append(ls.lots, Lot{Sku: ls.sku, Location: location, Lot: lot, ExpiresAt: expiresAt})
+		l = &ls.lots[len(ls.lots)-1]
+	}
+	l.Available += qty
+	ls.touch(l)
+	ls.sort()
+}
+
+// take removes qty units at location from its lots, first-expiring
+// first, and returns what it drew from each. Whatever the lots don't
+// cover comes out of the location's untracked stock and isn't recorded.
+// Lots past their expiry date are never drawn from; callers expire them
+// first.
+func (ls *lotSet) take(location string, qty int64, now time.Time) []LotDraw {
+	var draws []LotDraw
+	for i := range ls.lots {
+		l := &ls.lots[i]
+		if qty == 0 {
+			break
+		}
+		if l.Location != location || l.Available == 0 || l.ExpiredAt(now) {
+			continue
+		}
+		n := min(qty, l.Available)
+		l.Available -= n
+		qty -= n
+		ls.touch(l)
+		draws = append(draws, LotDraw{Lot: l.Lot, Quantity: n})
+	}
+	return draws
+}
+
+// takeLot removes qty units at location from lot alone. A lot that isn't
+// there, or holds less than qty, is invalid input; one that has expired
+// holds nothing by the time callers draw from it.
+func (ls *lotSet) takeLot(location, lot string, qty int64) error {
+	l := ls.find(location, lot)
+	switch {
+	case l == nil:
+		return fmt.Errorf("no lot %q at %q: %w", lot, location, ErrInvalidInput)
+	case l.Available < qty:
+		return fmt.Errorf("lot %q at %q holds %d available, not %d: %w", lot, location, l.Available, qty, ErrInvalidInput)
+	}
+	l.Available -= qty
+	ls.touch(l)
+	return nil
+}
+
+// putBack returns qty units at location to lot, which has to exist
+// already and not have expired.
+func (ls *lotSet) putBack(location, lot string, qty int64, now time.Time) error {
+	if ls.find(location, lot) == nil {
+		return fmt.Errorf("no lot %q at %q: %w", lot, location, ErrInvalidInput)
+	}
+	return ls.add(location, lot, nil, qty, now)
+}
+
+// give hands qty units at location back to the lots in draws,
+// latest-expiring first, so the stock that leaves first comes back
+// last. It returns what each lot got back and how much of it went
+// straight to Expired because its lot has expired since it was drawn.
+// Whatever draws don't cover goes back as untracked stock.
+func (ls *lotSet) give(location string, draws []LotDraw, qty int64, now time.Time) ([]LotDraw, int64) {
+	drawn := make(map[string]int64, len(draws))
+	for _, d := range draws {
+		drawn[d.Lot] += d.Quantity
+	}
+	var back []LotDraw
+	var expired int64
+	for i := len(ls.lots) - 1; i >= 0 && qty > 0; i-- {
+		l := &ls.lots[i]
+		if l.Location != location || drawn[l.Lot] <= 0 {
+			continue
+		}
+		n := min(qty, drawn[l.Lot])
+		if l.ExpiredAt(now) {
+			l.Expired += n
+			expired += n
+		} else {
+			l.Available += n
+		}
+		qty -= n
+		ls.touch(l)
+		back = append(back, LotDraw{Lot: l.Lot, Quantity: n})
+	}
+	return back, expired
+}
+
+// expire moves every lot past its expiry date at now out of Available
+// and into Expired, and returns each lot that expired with Available
+// set to how much did.
+func (ls *lotSet) expire(now time.Time) []Lot {
+	var expired []Lot
+	for i := range ls.lots {
+		l := &ls.lots[i]
+		if l.Available == 0 || !l.ExpiredAt(now) {
+			continue
+		}
+		e := *l
+		l.Expired += l.Available
+		l.Available = 0
+		ls.touch(l)
+		expired = append(expired, e)
+	}
+	return expired
+}
+
+// dirty returns the lots changed since the set was loaded or last
+// saved, and forgets them.
+func (ls *lotSet) dirty() []Lot {
+	var dirty []Lot
+	for _, l := range ls.lots {
+		if ls.changed[lotKey{location: l.Location, lot: l.Lot}] {
+			dirty = append(dirty, l)
+		}
+	}
+	ls.changed = make(map[lotKey]bool)
+	return dirty
+}
+
+func negateDraws(draws []LotDraw) []LotDraw {
+	negated := make([]LotDraw, len(draws))
+	for i, d := range draws {
+		negated[i] = LotDraw{Lot: d.Lot, Quantity: -d.Quantity}
+	}
+	return negated
+}
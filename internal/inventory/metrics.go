@@ -0,0 +1,40 @@
+package inventory
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricsOnce        sync.Once
+	backorderShortfall *prometheus.GaugeVec
+
+	limitMetricsOnce sync.Once
+	limitRejections  *prometheus.CounterVec
+)
+
+// ensureMetrics registers the inventory gauges on first use. They are
+// only registered once the backorder reporter starts, so turning it
+// off keeps the per-SKU series out of /metrics entirely.
+func ensureMetrics() {
+	metricsOnce.Do(func() {
+		backorderShortfall = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "smfg_inventory_backorder_shortfall",
+			Help: "Units open reservations are still waiting on, per SKU. SKUs without a shortfall have no series.",
+		}, []string{"sku"})
+		prometheus.MustRegister(backorderShortfall)
+	})
+}
+
+// ensureLimitMetrics registers the requester limit counter on the first
+// rejection.
+func ensureLimitMetrics() {
+	limitMetricsOnce.Do(func() {
+		limitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "smfg_inventory_reservation_limit_rejections_total",
+			Help: "Reservations rejected for exceeding a requester limit, by requester class and the limit hit.",
+		}, []string{"class", "limit"})
+		prometheus.MustRegister(limitRejections)
+	})
+}
@@ -11,36 +11,237 @@ import (
 type ProductionRequest struct {
 	RequestID string `json:"requestID"`
 	Quantity  int64  `json:"quantity"`
+	// Location is the warehouse the stock was produced into. Empty
+	// means the configured default location.
+	Location string `json:"location,omitempty"`
+	// Lot tracks the stock as a lot, expiring at ExpiresAt if set.
+	// Producing into an existing lot tops it up; ExpiresAt needs a Lot.
+	Lot       string     `json:"lot,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// PlannedRequestID completes the Scheduled planned order with that
+	// request ID, so it stops counting towards available-to-promise.
+	PlannedRequestID string `json:"plannedRequestId,omitempty"`
 }
 
 // ProductionEvent is an entity. An addition to inventory through production of a Product.
 type ProductionEvent struct {
-	ID        uint64    `json:"id"`
-	RequestID string    `json:"requestID"`
-	Sku       string    `json:"sku"`
-	Quantity  int64     `json:"quantity"`
-	Created   time.Time `json:"created"`
+	ID        uint64     `json:"id"`
+	RequestID string     `json:"requestID"`
+	Sku       string     `json:"sku"`
+	Location  string     `json:"location"`
+	Quantity  int64      `json:"quantity"`
+	Lot       string     `json:"lot,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Created   time.Time  `json:"created"`
 }
 
 // Product is a value object. A SKU able to be produced by the factory.
+// Version goes up with every write to the product or to its stock, so it
+// identifies one state of the SKU's inventory as a whole.
 type Product struct {
-	Sku  string `json:"sku"`
-	Upc  string `json:"upc"`
-	Name string `json:"name"`
+	Sku     string       `json:"sku"`
+	Upc     string       `json:"upc"`
+	Name    string       `json:"name"`
+	State   ProductState `json:"state,omitempty"`
+	Version int64        `json:"version"`
+}
+
+// Active reports whether the product takes new production and reservations. A product with no state set is
+// Active, the state every product starts in.
+func (p Product) Active() bool {
+	return p.State == ProductActive || p.State == ""
+}
+
+// ProductState is where a product is in its lifecycle.
+type ProductState string // @name ProductState
+
+const (
+	// ProductActive products can be produced, reserved and shipped.
+	ProductActive ProductState = "Active"
+	// ProductDiscontinued products take no new production or reservations but keep filling and shipping
+	// the reservations they already have.
+	ProductDiscontinued ProductState = "Discontinued"
+	// ProductArchived products are retired. They hold no stock or reservations and can't change again.
+	ProductArchived ProductState = "Archived"
+)
+
+// ProductUpdate is a value object. A change to a product's UPC, name or lifecycle state; empty fields are
+// left as they are.
+type ProductUpdate struct {
+	Upc   string       `json:"upc,omitempty"`
+	Name  string       `json:"name,omitempty"`
+	State ProductState `json:"state,omitempty"`
 }
 
 // ProductInventory is an entity. It represents current inventory levels for the associated product.
+// Available, InTransit, Expired and the stock status buckets are totals across every location; Locations
+// breaks them down per warehouse.
 type ProductInventory struct {
 	Product
-	Available int64 `json:"available"`
+	Available   int64               `json:"available"`
+	InTransit   int64               `json:"inTransit,omitempty"`
+	Expired     int64               `json:"expired,omitempty"`
+	OnHold      int64               `json:"onHold,omitempty"`
+	Quarantined int64               `json:"quarantined,omitempty"`
+	Damaged     int64               `json:"damaged,omitempty"`
+	Locations   []LocationInventory `json:"locations,omitempty"`
+}
+
+// LocationInventory is a value object. The stock of one product held at a single warehouse location.
+// InTransit is stock on its way to the location that can't be reserved until the transfer is received.
+// Expired is stock from lots past their expiry date, which is never reserved again. OnHold, Quarantined
+// and Damaged are stock moved out of Available by hand; it can't be reserved until it is moved back.
+type LocationInventory struct {
+	Location    string `json:"location"`
+	Available   int64  `json:"available"`
+	InTransit   int64  `json:"inTransit,omitempty"`
+	Expired     int64  `json:"expired,omitempty"`
+	OnHold      int64  `json:"onHold,omitempty"`
+	Quarantined int64  `json:"quarantined,omitempty"`
+	Damaged     int64  `json:"damaged,omitempty"`
+}
+
+// in returns the field counting status's stock, nil for an unknown status.
+func (l *LocationInventory) in(status StockStatus) *int64 {
+	switch status {
+	case StatusAvailable:
+		return &l.Available
+	case StatusOnHold:
+		return &l.OnHold
+	case StatusQuarantined:
+		return &l.Quarantined
+	case StatusDamaged:
+		return &l.Damaged
+	default:
+		return nil
+	}
+}
+
+// AvailableAt returns the stock held at location, zero if the product has never been stocked there.
+func (pi ProductInventory) AvailableAt(location string) int64 {
+	for _, l := range pi.Locations {
+		if l.Location == location {
+			return l.Available
+		}
+	}
+	return 0
+}
+
+// Add changes the stock at location by qty, adding the location if it is new, and keeps Available in step
+// with the per-location total.
+func (pi *ProductInventory) Add(location string, qty int64) {
+	pi.Available += qty
+	pi.at(location).Available += qty
+}
+
+// AddInTransit changes the stock in transit to location by qty, adding the location if it is new, and keeps
+// InTransit in step with the per-location total.
+func (pi *ProductInventory) AddInTransit(location string, qty int64) {
+	pi.InTransit += qty
+	pi.at(location).InTransit += qty
+}
+
+// AddExpired changes the expired stock at location by qty, adding the location if it is new, and keeps
+// Expired in step with the per-location total.
+func (pi *ProductInventory) AddExpired(location string, qty int64) {
+	pi.Expired += qty
+	pi.at(location).Expired += qty
+}
+
+// StatusAt returns the stock in status at location, zero if the product has never been stocked there.
+func (pi ProductInventory) StatusAt(location string, status StockStatus) int64 {
+	for _, l := range pi.Locations {
+		if l.Location == location {
+			if n := l.in(status); n != nil {
+				return *n
+			}
+		}
+	}
+	return 0
+}
+
+// AddStatus changes the stock in status at location by qty, adding the location if it is new, and keeps the
+// status's total in step with the per-location total. Adding to StatusAvailable is the same as Add.
+func (pi *ProductInventory) AddStatus(location string, status StockStatus, qty int64) {
+	switch status {
+	case StatusAvailable:
+		pi.Available += qty
+	case StatusOnHold:
+		pi.OnHold += qty
+	case StatusQuarantined:
+		pi.Quarantined += qty
+	case StatusDamaged:
+		pi.Damaged += qty
+	default:
+		return
+	}
+	*pi.at(location).in(status) += qty
+}
+
+func (pi *ProductInventory) at(location string) *LocationInventory {
+	for i := range pi.Locations {
+		if pi.Locations[i].Location == location {
+			return &pi.Locations[i]
+		}
+	}
+	pi.Locations = append(pi.Locations, LocationInventory{Location: location})
+	return &pi.Locations[len(pi.Locations)-1]
+}
+
+// StockStatus is the bucket a unit of stock sits in at its location. Only available stock is reserved; the
+// others hold stock back from sale until it is moved to available again.
+type StockStatus string // @name StockStatus
+
+const (
+	StatusAvailable StockStatus = "available"
+	// StatusOnHold is stock waiting on a decision, such as QA sign-off on a production run.
+	StatusOnHold StockStatus = "on_hold"
+	// StatusQuarantined is stock suspected to be bad and kept apart until it is inspected.
+	StatusQuarantined StockStatus = "quarantined"
+	// StatusDamaged is stock that can't be sold as it is.
+	StatusDamaged StockStatus = "damaged"
+)
+
+func ParseStockStatus(v string) (StockStatus, error) {
+	switch s := StockStatus(v); s {
+	case StatusAvailable, StatusOnHold, StatusQuarantined, StatusDamaged:
+		return s, nil
+	default:
+		return "", fmt.Errorf("invalid stock status %q: %w", v, ErrInvalidInput)
+	}
+}
+
+// LocationStrategy decides which location a reservation draws from when the request doesn't name one.
+type LocationStrategy string // @name LocationStrategy
+
+const (
+	// LocationMostAvailable picks the location currently holding the most stock.
+	LocationMostAvailable LocationStrategy = "most_available"
+	// LocationPriority walks a configured location order and picks the first that can cover the whole
+	// reservation, falling back to the first with any stock at all.
+	LocationPriority LocationStrategy = "priority"
+)
+
+func ParseLocationStrategy(v string) (LocationStrategy, error) {
+	switch v {
+	case string(LocationMostAvailable), "":
+		return LocationMostAvailable, nil
+	case string(LocationPriority):
+		return LocationPriority, nil
+	default:
+		return "", fmt.Errorf("invalid location strategy %q: %w", v, ErrInvalidInput)
+	}
 }
 
 type ReserveState string // @name ReserveState
 
 const (
-	Open   ReserveState = "Open"
-	Closed ReserveState = "Closed"
-	None   ReserveState = ""
+	Open      ReserveState = "Open"
+	Closed    ReserveState = "Closed"
+	Cancelled ReserveState = "Cancelled"
+	Expired   ReserveState = "Expired"
+	Fulfilled ReserveState = "Fulfilled"
+	None      ReserveState = ""
 )
 
 func ParseReserveState(v string) (ReserveState, error) {
@@ -49,6 +250,12 @@ func ParseReserveState(v string) (ReserveState, error) {
 		return Open, nil
 	case string(Closed):
 		return Closed, nil
+	case string(Cancelled):
+		return Cancelled, nil
+	case string(Expired):
+		return Expired, nil
+	case string(Fulfilled):
+		return Fulfilled, nil
 	case string(None):
 		return None, nil
 	default:
@@ -56,11 +263,72 @@ func ParseReserveState(v string) (ReserveState, error) {
 	}
 }
 
+// FillPolicy is the smallest first fill a reservation will accept.
+// Once it holds any stock, later fills top it up by whatever is
+// available.
+type FillPolicy string // @name FillPolicy
+
+const (
+	// FillPartial accepts any amount. The default.
+	FillPartial FillPolicy = "partial"
+	// FillAllOrNothing only accepts the whole requested quantity.
+	FillAllOrNothing FillPolicy = "all_or_nothing"
+	// FillMinimum accepts at least MinQuantity.
+	FillMinimum FillPolicy = "minimum"
+)
+
+func ParseFillPolicy(v string) (FillPolicy, error) {
+	switch v {
+	case string(FillPartial), "":
+		return FillPartial, nil
+	case string(FillAllOrNothing):
+		return FillAllOrNothing, nil
+	case string(FillMinimum):
+		return FillMinimum, nil
+	default:
+		return "", fmt.Errorf("invalid fill policy %q: %w", v, ErrInvalidInput)
+	}
+}
+
+// ReserveMode decides what Reserve does when stock isn't there yet.
+type ReserveMode string // @name ReserveMode
+
+const (
+	// ReserveQueue leaves the reservation Open as a backorder to be
+	// filled as stock arrives. The default.
+	ReserveQueue ReserveMode = "queue"
+	// ReserveImmediate reserves the whole quantity now or fails with
+	// ErrInsufficientStock, never leaving a backorder behind.
+	ReserveImmediate ReserveMode = "immediate"
+)
+
 type ReservationRequest struct {
 	Sku       string `json:"sku"`
 	RequestID string `json:"requestId"`
 	Requester string `json:"requester"`
 	Quantity  int64  `json:"quantity"`
+	// Location is the preferred warehouse to reserve from. Empty lets
+	// the configured location strategy choose.
+	Location string `json:"location,omitempty"`
+	// TTLSeconds is how long the reservation may hold stock before
+	// the sweeper expires it. Zero falls back to the configured
+	// default, which may itself be "never".
+	TTLSeconds int64 `json:"ttlSeconds,omitempty"`
+	// Priority orders reservations under the priority allocation
+	// strategy; higher is filled first. Other strategies ignore it.
+	Priority int `json:"priority,omitempty"`
+	// FillPolicy defaults to partial. MinQuantity is required by, and
+	// only used with, the minimum policy.
+	FillPolicy  FillPolicy `json:"fillPolicy,omitempty"`
+	MinQuantity int64      `json:"minQuantity,omitempty"`
+	// Mode defaults to queue.
+	Mode ReserveMode `json:"mode,omitempty"`
+}
+
+// ReservationUpdate is a value object. A change to an open or closed
+// reservation's requested quantity.
+type ReservationUpdate struct {
+	Quantity int64 `json:"quantity"`
 }
 
 // Reservation is an entity. An amount of inventory set aside for a given Customer.
@@ -69,8 +337,489 @@ type Reservation struct {
 	RequestID         string       `json:"requestId"`
 	Requester         string       `json:"requester"`
 	Sku               string       `json:"sku"`
+	Location          string       `json:"location"`
 	State             ReserveState `json:"state"`
 	ReservedQuantity  int64        `json:"reservedQuantity"`
 	RequestedQuantity int64        `json:"requestedQuantity"`
+	ShippedQuantity   int64        `json:"shippedQuantity"`
 	Created           time.Time    `json:"created"`
+	ExpiresAt         *time.Time   `json:"expiresAt,omitempty"`
+	Priority          int          `json:"priority"`
+	FillPolicy        FillPolicy   `json:"fillPolicy"`
+	MinQuantity       int64        `json:"minQuantity,omitempty"`
+	Version           int64        `json:"version"`
+}
+
+// MinimumFill is the smallest amount the reservation will accept as its
+// first fill under its fill policy.
+func (r Reservation) MinimumFill() int64 {
+	switch r.FillPolicy {
+	case FillAllOrNothing:
+		return r.RequestedQuantity
+	case FillMinimum:
+		return r.MinQuantity
+	default:
+		return 1
+	}
+}
+
+// MovementReason records why an inventory balance changed.
+type MovementReason string // @name MovementReason
+
+const (
+	// MovementOpening is the balance a SKU started out with: what it
+	// carried when the ledger was introduced, written by migration, or
+	// the opening quantity it was bulk imported with.
+	MovementOpening      MovementReason = "opening"
+	MovementProduction   MovementReason = "production"
+	MovementReservation  MovementReason = "reservation"
+	MovementCancellation MovementReason = "cancellation"
+	MovementExpiry       MovementReason = "expiry"
+	// MovementReservationChange is stock handed back when a
+	// reservation's requested quantity is lowered below what it holds.
+	MovementReservationChange MovementReason = "reservation_change"
+	MovementTransferOut       MovementReason = "transfer_out"
+	MovementTransferIn        MovementReason = "transfer_in"
+	// MovementAssembly is component stock consumed by producing a kit
+	// that lists it in its bill of materials.
+	MovementAssembly MovementReason = "assembly"
+	// MovementLotExpiry is stock taken out of Available because the
+	// lot it belongs to passed its expiry date. Lot carries the lot
+	// number.
+	MovementLotExpiry MovementReason = "lot_expiry"
+	// MovementStatusChange is stock moved into or out of Available
+	// from another stock status, such as a QA hold or its release.
+	MovementStatusChange MovementReason = "status_change"
+	// MovementReturn is returned goods restocked to Available after
+	// inspection. ReservationID is set when they came back against a
+	// reservation.
+	MovementReturn MovementReason = "return"
+)
+
+// InventoryMovement is an entity. One append-only entry in a SKU's
+// inventory ledger: the change to Available, why it happened, who or
+// what caused it, and the balance it left behind at its location.
+type InventoryMovement struct {
+	ID            uint64         `json:"id"`
+	Sku           string         `json:"sku"`
+	Location      string         `json:"location"`
+	Delta         int64          `json:"delta"`
+	Reason        MovementReason `json:"reason"`
+	RequestID     string         `json:"requestId,omitempty"`
+	ReservationID *uint64        `json:"reservationId,omitempty"`
+	Lot           string         `json:"lot,omitempty"`
+	Actor         string         `json:"actor"`
+	Balance       int64          `json:"balance"`
+	Created       time.Time      `json:"created"`
+}
+
+// AdjustmentReason classifies a manual stock correction. The code is
+// also recorded as the ledger reason for the movement it produces.
+type AdjustmentReason string // @name AdjustmentReason
+
+const (
+	AdjustCycleCount AdjustmentReason = "cycle_count"
+	AdjustDamage     AdjustmentReason = "damage"
+	AdjustShrinkage  AdjustmentReason = "shrinkage"
+	AdjustFound      AdjustmentReason = "found"
+)
+
+// AdjustmentRequest is a value object. Quantity is signed: positive
+// adds stock, negative removes it.
+type AdjustmentRequest struct {
+	RequestID string           `json:"requestId"`
+	Quantity  int64            `json:"quantity"`
+	Reason    AdjustmentReason `json:"reason"`
+	// Location is the warehouse being corrected. Empty means the
+	// configured default location.
+	Location string `json:"location,omitempty"`
+}
+
+// Adjustment is an entity. One applied stock correction.
+type Adjustment struct {
+	ID        uint64           `json:"id"`
+	RequestID string           `json:"requestId"`
+	Sku       string           `json:"sku"`
+	Location  string           `json:"location"`
+	Quantity  int64            `json:"quantity"`
+	Reason    AdjustmentReason `json:"reason"`
+	Actor     string           `json:"actor"`
+	Created   time.Time        `json:"created"`
+}
+
+// StatusChangeRequest is a value object. A request to move Quantity units of a SKU at a location from one
+// stock status to another, for the recorded Reason.
+type StatusChangeRequest struct {
+	RequestID string      `json:"requestId"`
+	From      StockStatus `json:"from"`
+	To        StockStatus `json:"to"`
+	Quantity  int64       `json:"quantity"`
+	Reason    string      `json:"reason"`
+	// Location is the warehouse holding the stock. Empty means the
+	// configured default location.
+	Location string `json:"location,omitempty"`
+	// Lot is the lot the stock leaves when moved out of available, or
+	// goes back to when moved into it. Empty draws first-expiring
+	// first on the way out and returns untracked stock on the way in.
+	Lot string `json:"lot,omitempty"`
+}
+
+// StatusChange is an entity. One applied move of stock between stock statuses.
+type StatusChange struct {
+	ID        uint64      `json:"id"`
+	RequestID string      `json:"requestId"`
+	Sku       string      `json:"sku"`
+	Location  string      `json:"location"`
+	From      StockStatus `json:"from"`
+	To        StockStatus `json:"to"`
+	Quantity  int64       `json:"quantity"`
+	Lot       string      `json:"lot,omitempty"`
+	Reason    string      `json:"reason"`
+	Actor     string      `json:"actor"`
+	Created   time.Time   `json:"created"`
+}
+
+// ShipmentRequest is a value object. A request to ship some or all of
+// a Closed reservation's reserved inventory.
+type ShipmentRequest struct {
+	RequestID string `json:"requestId"`
+	Quantity  int64  `json:"quantity"`
+}
+
+// Shipment is an entity. Reserved inventory that has physically left
+// the building against a Reservation.
+type Shipment struct {
+	ID            uint64    `json:"id"`
+	RequestID     string    `json:"requestId"`
+	ReservationID uint64    `json:"reservationId"`
+	Sku           string    `json:"sku"`
+	Quantity      int64     `json:"quantity"`
+	Created       time.Time `json:"created"`
+}
+
+type TransferState string // @name TransferState
+
+const (
+	TransferRequested TransferState = "Requested"
+	TransferInTransit TransferState = "InTransit"
+	TransferReceived  TransferState = "Received"
+)
+
+// TransferRequest is a value object. A request to move stock of a SKU
+// from one location to another.
+type TransferRequest struct {
+	RequestID string `json:"requestId"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Quantity  int64  `json:"quantity"`
+}
+
+// Transfer is an entity. Stock leaving From when the transfer is
+// created and arriving at To when it is received. Until then the
+// quantity is counted as in transit at To.
+type Transfer struct {
+	ID        uint64        `json:"id"`
+	RequestID string        `json:"requestId"`
+	Sku       string        `json:"sku"`
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Quantity  int64         `json:"quantity"`
+	State     TransferState `json:"state"`
+	Actor     string        `json:"actor"`
+	Created   time.Time     `json:"created"`
+	Updated   time.Time     `json:"updated"`
+}
+
+// BOMComponent is a value object. Quantity units of Sku go into every unit of the kit that lists it.
+type BOMComponent struct {
+	Sku      string `json:"sku"`
+	Quantity int64  `json:"quantity"`
+}
+
+// BillOfMaterials is an entity. The components consumed from stock to produce one unit of Sku. A product
+// without components is produced from nothing, as every product was before kits.
+type BillOfMaterials struct {
+	Sku        string         `json:"sku"`
+	Components []BOMComponent `json:"components"`
+}
+
+// ComponentShortage is a value object. A component a kit's production needed more of than its location held.
+type ComponentShortage struct {
+	Sku       string `json:"sku"`
+	Required  int64  `json:"required"`
+	Available int64  `json:"available"`
+}
+
+// Buildable is a value object. How many units of a kit the component stock at Location could produce right
+// now, and which components limit it.
+type Buildable struct {
+	Sku        string               `json:"sku"`
+	Location   string               `json:"location"`
+	Quantity   int64                `json:"quantity"`
+	Components []BuildableComponent `json:"components"`
+}
+
+// BuildableComponent is a value object. One component's share of a Buildable: Quantity per kit, the stock
+// Available at the location and the number of kits that stock alone would build.
+type BuildableComponent struct {
+	Sku       string `json:"sku"`
+	Quantity  int64  `json:"quantity"`
+	Available int64  `json:"available"`
+	Buildable int64  `json:"buildable"`
+}
+
+// Lot is an entity. The stock of one production lot of a SKU at one location. Available is part of the
+// location's Available, not in addition to it; once ExpiresAt passes, the sweeper moves it into Expired.
+type Lot struct {
+	Sku       string     `json:"sku"`
+	Location  string     `json:"location"`
+	Lot       string     `json:"lot"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Available int64      `json:"available"`
+	Expired   int64      `json:"expired,omitempty"`
+}
+
+// ExpiredAt reports whether the lot is past its expiry date at now. Lots without one never expire.
+func (l Lot) ExpiredAt(now time.Time) bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.After(now)
+}
+
+// LotDraw is a value object. Quantity units of Lot taken by a reservation.
+type LotDraw struct {
+	Lot      string `json:"lot"`
+	Quantity int64  `json:"quantity"`
+}
+
+// LotRecipient is a value object. A reservation that drew Quantity units from a lot, which is who a
+// recall of the lot has to reach. ShippedQuantity is the reservation's, across every lot it drew from.
+type LotRecipient struct {
+	ReservationID   uint64       `json:"reservationId"`
+	RequestID       string       `json:"requestId"`
+	Requester       string       `json:"requester"`
+	Location        string       `json:"location"`
+	State           ReserveState `json:"state"`
+	Quantity        int64        `json:"quantity"`
+	ShippedQuantity int64        `json:"shippedQuantity"`
+}
+
+// ReorderPolicy is an entity. When a SKU's Available falls below ReorderPoint, purchasing should bring it back
+// up to TargetLevel. LowSince is when it last fell below and is cleared once it recovers.
+type ReorderPolicy struct {
+	Sku          string     `json:"sku"`
+	ReorderPoint int64      `json:"reorderPoint"`
+	TargetLevel  int64      `json:"targetLevel"`
+	LowSince     *time.Time `json:"lowSince,omitempty"`
+}
+
+// LowStock is a value object. A SKU whose Available is below its reorder point, and the SuggestedQuantity
+// that would bring it back up to its target level. LowSince is unset until the SKU's low-stock alert has gone
+// out.
+type LowStock struct {
+	Sku               string     `json:"sku"`
+	Available         int64      `json:"available"`
+	ReorderPoint      int64      `json:"reorderPoint"`
+	TargetLevel       int64      `json:"targetLevel"`
+	SuggestedQuantity int64      `json:"suggestedQuantity"`
+	LowSince          *time.Time `json:"lowSince,omitempty"`
+}
+
+// NewLowStock reports p's SKU as low with available on hand.
+func NewLowStock(p ReorderPolicy, available int64) LowStock {
+	return LowStock{
+		Sku:               p.Sku,
+		Available:         available,
+		ReorderPoint:      p.ReorderPoint,
+		TargetLevel:       p.TargetLevel,
+		SuggestedQuantity: p.TargetLevel - available,
+		LowSince:          p.LowSince,
+	}
+}
+
+// Backorder is a value object. The demand a SKU's open reservations are still waiting on: how many of them are
+// short, what they asked for, what has been set aside so far and the Shortfall between the two. OldestCreated is
+// when the longest-waiting of them was made and OldestAgeSeconds how long ago that was when the report was read.
+type Backorder struct {
+	Sku              string    `json:"sku"`
+	OpenReservations int64     `json:"openReservations"`
+	Requested        int64     `json:"requested"`
+	Reserved         int64     `json:"reserved"`
+	Shortfall        int64     `json:"shortfall"`
+	OldestCreated    time.Time `json:"oldestCreated"`
+	OldestAgeSeconds int64     `json:"oldestAgeSeconds"`
+}
+
+type PlannedOrderState string // @name PlannedOrderState
+
+const (
+	PlannedOrderScheduled PlannedOrderState = "Scheduled"
+	PlannedOrderCompleted PlannedOrderState = "Completed"
+	PlannedOrderCancelled PlannedOrderState = "Cancelled"
+)
+
+// PlannedOrderRequest is a value object. A request to schedule production of Quantity units of a SKU, expected
+// to be finished by ExpectedDate.
+type PlannedOrderRequest struct {
+	RequestID    string    `json:"requestId"`
+	Quantity     int64     `json:"quantity"`
+	ExpectedDate time.Time `json:"expectedDate"`
+}
+
+// PlannedOrder is an entity. Production that is scheduled but hasn't happened yet. A Scheduled order counts
+// towards available-to-promise from its ExpectedDate until production naming it completes it.
+type PlannedOrder struct {
+	ID                  uint64            `json:"id"`
+	RequestID           string            `json:"requestId"`
+	Sku                 string            `json:"sku"`
+	Quantity            int64             `json:"quantity"`
+	ExpectedDate        time.Time         `json:"expectedDate"`
+	State               PlannedOrderState `json:"state"`
+	ProductionRequestID string            `json:"productionRequestId,omitempty"`
+	Actor               string            `json:"actor"`
+	Created             time.Time         `json:"created"`
+	Updated             time.Time         `json:"updated"`
+}
+
+// AvailableToPromise is a value object. When Quantity more units of Sku can be promised to a new order. Open
+// reservations are filled oldest first, so the Backordered units they are still short come out of Available and
+// Scheduled production before a new order gets any. PromiseDate is unset, and Promisable false, when current
+// stock and everything scheduled still isn't enough. Available and Backordered are totals across every location.
+type AvailableToPromise struct {
+	Sku         string     `json:"sku"`
+	Quantity    int64      `json:"quantity"`
+	Available   int64      `json:"available"`
+	Backordered int64      `json:"backordered"`
+	Scheduled   int64      `json:"scheduled"`
+	Promisable  bool       `json:"promisable"`
+	PromiseDate *time.Time `json:"promiseDate,omitempty"`
+}
+
+// ReturnOutcome is what inspection decided to do with returned goods.
+type ReturnOutcome string // @name ReturnOutcome
+
+const (
+	// ReturnRestock puts the goods back into available stock.
+	ReturnRestock ReturnOutcome = "restock"
+	// ReturnQuarantine keeps the goods apart as quarantined stock.
+	ReturnQuarantine ReturnOutcome = "quarantine"
+	// ReturnScrap records the goods as returned but adds no stock.
+	ReturnScrap ReturnOutcome = "scrap"
+)
+
+func ParseReturnOutcome(v string) (ReturnOutcome, error) {
+	switch o := ReturnOutcome(v); o {
+	case ReturnRestock, ReturnQuarantine, ReturnScrap:
+		return o, nil
+	default:
+		return "", fmt.Errorf("invalid return outcome %q: %w", v, ErrInvalidInput)
+	}
 }
+
+// ReturnRequest is a value object. Quantity units of a SKU coming back, either against the reservation they
+// were shipped on or from a free-form Requester, and the Outcome of their inspection.
+type ReturnRequest struct {
+	RequestID string        `json:"requestId"`
+	Quantity  int64         `json:"quantity"`
+	Outcome   ReturnOutcome `json:"outcome"`
+	// ReservationID ties the return to a reservation, which must have
+	// shipped at least Quantity units not already returned. Requester
+	// is taken from the reservation.
+	ReservationID uint64 `json:"reservationId,omitempty"`
+	// Requester is who is returning the goods. Required when there is
+	// no ReservationID.
+	Requester string `json:"requester,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// Location is the warehouse receiving the goods. Empty means the
+	// reservation's location, or the configured default location.
+	Location string `json:"location,omitempty"`
+	// Lot is the existing lot restocked goods go back to. Only
+	// restocked goods can name a lot.
+	Lot string `json:"lot,omitempty"`
+}
+
+// Return is an entity. Goods that came back to a location and what was done with them.
+type Return struct {
+	ID            uint64        `json:"id"`
+	RequestID     string        `json:"requestId"`
+	Sku           string        `json:"sku"`
+	Location      string        `json:"location"`
+	ReservationID *uint64       `json:"reservationId,omitempty"`
+	Requester     string        `json:"requester"`
+	Quantity      int64         `json:"quantity"`
+	Outcome       ReturnOutcome `json:"outcome"`
+	Lot           string        `json:"lot,omitempty"`
+	Reason        string        `json:"reason,omitempty"`
+	Actor         string        `json:"actor"`
+	Created       time.Time     `json:"created"`
+}
+
+// DefaultRequester is the requester whose RequesterLimits apply to every requester without limits of its own.
+const DefaultRequester = "*"
+
+// RequesterLimit names one of the quotas in RequesterLimits.
+type RequesterLimit string // @name RequesterLimit
+
+const (
+	// LimitReservedPerSku caps the units of one SKU a requester's outstanding reservations can ask for.
+	LimitReservedPerSku RequesterLimit = "maxReservedPerSku"
+	// LimitOpenReservations caps how many outstanding reservations a requester can have.
+	LimitOpenReservations RequesterLimit = "maxOpenReservations"
+	// LimitDailyCap caps the units a requester can reserve in a UTC day.
+	LimitDailyCap RequesterLimit = "dailyCap"
+)
+
+// RequesterLimits is an entity. Quotas on what one requester can reserve, so a single large customer can't
+// drain a scarce SKU. A zero limit is unlimited. Class groups requesters in rejection metrics.
+type RequesterLimits struct {
+	Requester           string `json:"requester"`
+	Class               string `json:"class"`
+	MaxReservedPerSku   int64  `json:"maxReservedPerSku"`
+	MaxOpenReservations int64  `json:"maxOpenReservations"`
+	DailyCap            int64  `json:"dailyCap"`
+}
+
+// Unlimited reports whether none of l's limits are set.
+func (l RequesterLimits) Unlimited() bool {
+	return l.MaxReservedPerSku == 0 && l.MaxOpenReservations == 0 && l.DailyCap == 0
+}
+
+// RequesterUsage is a value object. What a requester already holds against its RequesterLimits: the units
+// its Open and Closed reservations for one SKU still ask for, how many Open and Closed reservations it has,
+// and the units it has reserved or raised reservations by since the start of the day, leaving out reservations
+// since cancelled or expired.
+type RequesterUsage struct {
+	ReservedForSku   int64
+	OpenReservations int64
+	ReservedToday    int64
+}
+
+// ProductImport is a value object. One row of a bulk import: a new product and the stock it opens with at
+// Location. Line is where the row sits in the imported file, for reporting errors against it.
+type ProductImport struct {
+	Line     int    `json:"line"`
+	Sku      string `json:"sku"`
+	Upc      string `json:"upc"`
+	Name     string `json:"name"`
+	Quantity int64  `json:"quantity"`
+	// Location is the warehouse the opening quantity is held at. Empty means the configured default location.
+	Location string `json:"location,omitempty"`
+}
+
+// ImportRowError is a value object. Why one row of a bulk import was not imported.
+type ImportRowError struct {
+	Line   int    `json:"line"`
+	Sku    string `json:"sku,omitempty"`
+	Detail string `json:"detail"`
+} // @name ImportRowError
+
+func (e *ImportRowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Detail)
+}
+
+// ImportResult is a value object. The outcome of a bulk import: how many rows were read, how many were
+// imported (or, on a dry run, would have been) and why the rest were not.
+type ImportResult struct {
+	DryRun   bool             `json:"dryRun"`
+	Rows     int              `json:"rows"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+} // @name ImportResult
@@ -23,7 +23,7 @@ func TestProblemResponseConformsToRFC7807(t *testing.T) {
 	defer ts.Close()
 
 	const secret = "raw-database-error-with-pii"
-	mockInvSvc.GetAllProductInventoryFunc = func(ctx context.Context, limit, offset int) ([]inventory.ProductInventory, error) {
+	mockInvSvc.GetAllProductInventoryFunc = func(ctx context.Context, options inventory.ProductListOptions, limit, offset int) ([]inventory.ProductInventory, error) {
 		return nil, errors.New(secret)
 	}
 
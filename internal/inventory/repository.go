@@ -3,9 +3,13 @@ package inventory
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/rs/zerolog/log"
 	"github.com/sksmith/go-micro-example/internal/platform/persistence"
 )
@@ -21,301 +25,1987 @@ func NewPostgresRepo(conn persistence.Conn) *dbRepo {
 	}
 }
 
+// SaveProduct inserts a new product or, when the options carry a
+// version, updates the product at that version. A product that isn't
+// where the caller expects it — already there for an insert, missing or
+// moved on for an update — is persistence.ErrVersionConflict.
 func (d *dbRepo) SaveProduct(ctx context.Context, product Product, options ...persistence.UpdateOptions) error {
 	m := persistence.StartMetric("SaveProduct")
 	tx := persistence.GetUpdateOptions(d.conn, options...)
 
-	ct, err := tx.Exec(ctx, `
+	var (
+		ct  pgconn.CommandTag
+		err error
+	)
+	if version := persistence.GetExpectedVersion(options...); version != 0 {
+		ct, err = tx.Exec(ctx, `
 		UPDATE products
-           SET upc = $2, name = $3
-         WHERE sku = $1;`,
-		product.Sku, product.Upc, product.Name)
+           SET upc = $2, name = $3, state = $4, version = version + 1
+         WHERE sku = $1 AND version = $5;`,
+			product.Sku, product.Upc, product.Name, product.State, version)
+	} else {
+		ct, err = tx.Exec(ctx, `
+		INSERT INTO products (sku, upc, name, state)
+                      VALUES ($1, $2, $3, $4)
+		ON CONFLICT (sku) DO NOTHING;`,
+			product.Sku, product.Upc, product.Name, product.State)
+	}
 	if err != nil {
-		m.Complete(nil)
+		m.Complete(err)
 		return err
 	}
 	if ct.RowsAffected() == 0 {
-		_, err := tx.Exec(ctx, `
-		INSERT INTO products (sku, upc, name)
-                      VALUES ($1, $2, $3);`,
-			product.Sku, product.Upc, product.Name)
+		m.Complete(persistence.ErrVersionConflict)
+		return persistence.ErrVersionConflict
+	}
+	m.Complete(nil)
+	return nil
+}
+
+// SaveProductInventory writes every location in productInventory,
+// inserting locations the SKU hasn't been stocked at before. The
+// Available, InTransit, Expired and status totals are derived on read and never stored.
+// It bumps the product's version first, which is where a conditional
+// write finds out the SKU has moved on.
+func (d *dbRepo) SaveProductInventory(ctx context.Context, productInventory ProductInventory, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveProductInventory")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	bump, args := versioned(`UPDATE products SET version = version + 1 WHERE sku = $1`, []interface{}{productInventory.Sku}, options...)
+	ct, err := tx.Exec(ctx, bump, args...)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	if err = versionConflict(ct, options...); err != nil {
+		m.Complete(err)
+		return err
+	}
+
+	locations := make([]string, 0, len(productInventory.Locations))
+	available := make([]int64, 0, len(productInventory.Locations))
+	inTransit := make([]int64, 0, len(productInventory.Locations))
+	expired := make([]int64, 0, len(productInventory.Locations))
+	onHold := make([]int64, 0, len(productInventory.Locations))
+	quarantined := make([]int64, 0, len(productInventory.Locations))
+	damaged := make([]int64, 0, len(productInventory.Locations))
+	for _, l := range productInventory.Locations {
+		locations = append(locations, l.Location)
+		available = append(available, l.Available)
+		inTransit = append(inTransit, l.InTransit)
+		expired = append(expired, l.Expired)
+		onHold = append(onHold, l.OnHold)
+		quarantined = append(quarantined, l.Quarantined)
+		damaged = append(damaged, l.Damaged)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO product_inventory (sku, location, available, in_transit, expired, on_hold, quarantined, damaged)
+		     SELECT $1, l.location, l.available, l.in_transit, l.expired, l.on_hold, l.quarantined, l.damaged FROM unnest($2::text[], $3::bigint[], $4::bigint[], $5::bigint[], $6::bigint[], $7::bigint[], $8::bigint[]) AS l(location, available, in_transit, expired, on_hold, quarantined, damaged)
+		ON CONFLICT (sku, location) DO UPDATE SET available = EXCLUDED.available, in_transit = EXCLUDED.in_transit, expired = EXCLUDED.expired, on_hold = EXCLUDED.on_hold, quarantined = EXCLUDED.quarantined, damaged = EXCLUDED.damaged, version = product_inventory.version + 1
+		     WHERE (product_inventory.available, product_inventory.in_transit, product_inventory.expired, product_inventory.on_hold, product_inventory.quarantined, product_inventory.damaged) IS DISTINCT FROM (EXCLUDED.available, EXCLUDED.in_transit, EXCLUDED.expired, EXCLUDED.on_hold, EXCLUDED.quarantined, EXCLUDED.damaged);`,
+		productInventory.Sku, locations, available, inTransit, expired, onHold, quarantined, damaged)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) GetProduct(ctx context.Context, sku string, options ...persistence.QueryOptions) (Product, error) {
+	m := persistence.StartMetric("GetProduct")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	product := Product{}
+	err := tx.QueryRow(ctx, `SELECT sku, upc, name, state, version FROM products WHERE sku = $1 `+forUpdate, sku).
+		Scan(&product.Sku, &product.Upc, &product.Name, &product.State, &product.Version)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return product, persistence.ErrNotFound
+		}
+		return product, err
+	}
+
+	m.Complete(nil)
+	return product, nil
+}
+
+// GetConflictingProducts returns the products that already hold any
+// of skus or upcs, so an import can leave them out before it copies.
+func (d *dbRepo) GetConflictingProducts(ctx context.Context, skus, upcs []string, options ...persistence.QueryOptions) ([]Product, error) {
+	m := persistence.StartMetric("GetConflictingProducts")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	products := make([]Product, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT sku, upc, name, state, version FROM products WHERE sku = ANY($1) OR upc = ANY($2) ORDER BY sku `+forUpdate,
+		skus, upcs)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product := Product{}
+		if err = rows.Scan(&product.Sku, &product.Upc, &product.Name, &product.State, &product.Version); err != nil {
+			m.Complete(err)
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return products, nil
+}
+
+// ImportProducts copies new products, their opening stock and the
+// ledger entries recording it in with COPY rather than an insert per
+// row. Every row must be a SKU and UPC the database doesn't hold yet;
+// a single clash fails the whole copy. Rows opening with no stock get
+// a location but no ledger entry.
+func (d *dbRepo) ImportProducts(ctx context.Context, products []ProductImport, actor string, at time.Time, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("ImportProducts")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"products"}, []string{"sku", "upc", "name", "state"},
+		pgx.CopyFromSlice(len(products), func(i int) ([]interface{}, error) {
+			p := products[i]
+			return []interface{}{p.Sku, p.Upc, p.Name, string(ProductActive)}, nil
+		}))
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"product_inventory"}, []string{"sku", "location", "available"},
+		pgx.CopyFromSlice(len(products), func(i int) ([]interface{}, error) {
+			p := products[i]
+			return []interface{}{p.Sku, p.Location, p.Quantity}, nil
+		}))
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+
+	stocked := make([]ProductImport, 0, len(products))
+	for _, p := range products {
+		if p.Quantity > 0 {
+			stocked = append(stocked, p)
+		}
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"inventory_movements"}, []string{"sku", "location", "delta", "reason", "actor", "balance", "created"},
+		pgx.CopyFromSlice(len(stocked), func(i int) ([]interface{}, error) {
+			p := stocked[i]
+			return []interface{}{p.Sku, p.Location, p.Quantity, string(MovementOpening), actor, p.Quantity, at}, nil
+		}))
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+
+	m.Complete(nil)
+	return nil
+}
+
+// GetProductInventory returns a SKU's stock broken down by location.
+// Locking reads lock every location row along with the product, so
+// a writer holds the whole SKU until it commits.
+func (d *dbRepo) GetProductInventory(ctx context.Context, sku string, options ...persistence.QueryOptions) (ProductInventory, error) {
+	m := persistence.StartMetric("GetProductInventory")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	rows, err := tx.Query(ctx,
+		`SELECT p.sku, p.upc, p.name, p.state, p.version, pi.location, pi.available, pi.in_transit, pi.expired, pi.on_hold, pi.quarantined, pi.damaged FROM products p JOIN product_inventory pi ON pi.sku = p.sku WHERE p.sku = $1 ORDER BY pi.location `+forUpdate,
+		sku)
+	if err != nil {
+		m.Complete(err)
+		return ProductInventory{}, err
+	}
+	defer rows.Close()
+
+	products, err := scanProductInventory(rows)
+	if err != nil {
+		m.Complete(err)
+		return ProductInventory{}, err
+	}
+	if len(products) == 0 {
+		m.Complete(persistence.ErrNotFound)
+		return ProductInventory{}, persistence.ErrNotFound
+	}
+
+	m.Complete(nil)
+	return products[0], nil
+}
+
+// locationBalancesAsOf is the ledger lookup shared by the as-of reads:
+// for each location p.sku has moved at, the balance left by the last
+// movement written at or before the bound instant. A SKU that had not
+// moved yet joins a single row of NULLs.
+const locationBalancesAsOf = `LEFT JOIN LATERAL (SELECT DISTINCT ON (m.location) m.location, m.balance FROM inventory_movements m WHERE m.sku = p.sku AND m.created <= $1 ORDER BY m.location, m.created DESC, m.id DESC) b ON TRUE`
+
+// GetProductInventoryAsOf reconstructs a SKU's inventory at asOf from
+// the movement ledger. production_events and reservations alone can't
+// answer this: reservations overwrite reserved_quantity in place. The
+// ledger only follows available stock, so in-transit, expired and the
+// other stock statuses read as zero, and the name, state and version
+// are the product's current ones. Callers check asOf against
+// GetProductCreated first; before it the ledger has nothing to go on.
+func (d *dbRepo) GetProductInventoryAsOf(ctx context.Context, sku string, asOf time.Time, options ...persistence.QueryOptions) (ProductInventory, error) {
+	m := persistence.StartMetric("GetProductInventoryAsOf")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
+
+	rows, err := tx.Query(ctx,
+		`SELECT p.sku, p.upc, p.name, p.state, p.version, b.location, b.balance, 0, 0, 0, 0, 0 FROM products p `+locationBalancesAsOf+` WHERE p.sku = $2 ORDER BY b.location`,
+		asOf, sku)
+	if err != nil {
+		m.Complete(err)
+		return ProductInventory{}, err
+	}
+	defer rows.Close()
+
+	products, err := scanProductInventory(rows)
+	if err != nil {
+		m.Complete(err)
+		return ProductInventory{}, err
+	}
+	if len(products) == 0 {
+		m.Complete(persistence.ErrNotFound)
+		return ProductInventory{}, persistence.ErrNotFound
+	}
+
+	m.Complete(nil)
+	return products[0], nil
+}
+
+// GetAllProductInventoryAsOf is GetProductInventoryAsOf for a page of
+// products, leaving out those created after asOf.
+func (d *dbRepo) GetAllProductInventoryAsOf(ctx context.Context, asOf time.Time, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+	m := persistence.StartMetric("GetAllProductInventoryAsOf")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
+
+	rows, err := tx.Query(ctx,
+		`SELECT p.sku, p.upc, p.name, p.state, p.version, b.location, b.balance, 0, 0, 0, 0, 0 FROM (SELECT sku, upc, name, state, version FROM products WHERE created <= $1 ORDER BY sku LIMIT $2 OFFSET $3) p `+locationBalancesAsOf+` ORDER BY p.sku, b.location`,
+		asOf, limit, offset)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	products, err := scanProductInventory(rows)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return products, nil
+}
+
+// GetProductCreated returns when sku was created, which is as far back
+// as its ledger goes. Products older than the ledger are dated to its
+// opening entry.
+func (d *dbRepo) GetProductCreated(ctx context.Context, sku string, options ...persistence.QueryOptions) (time.Time, error) {
+	m := persistence.StartMetric("GetProductCreated")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
+
+	var created time.Time
+	err := tx.QueryRow(ctx, `SELECT created FROM products WHERE sku = $1`, sku).Scan(&created)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return created, persistence.ErrNotFound
+		}
+		return created, err
+	}
+
+	m.Complete(nil)
+	return created, nil
+}
+
+// GetInventoryHistoryStart returns the earliest instant any product's
+// history covers. Before it there is nothing to tell a product that
+// didn't exist yet from one the ledger hadn't started following.
+func (d *dbRepo) GetInventoryHistoryStart(ctx context.Context, options ...persistence.QueryOptions) (time.Time, error) {
+	m := persistence.StartMetric("GetInventoryHistoryStart")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
+
+	var start *time.Time
+	if err := tx.QueryRow(ctx, `SELECT MIN(created) FROM products`).Scan(&start); err != nil {
+		m.Complete(err)
+		return time.Time{}, err
+	}
+	if start == nil {
+		m.Complete(persistence.ErrNotFound)
+		return time.Time{}, persistence.ErrNotFound
+	}
+
+	m.Complete(nil)
+	return *start, nil
+}
+
+// GetAllProductInventory pages over products, not locations: limit
+// and offset pick the SKUs, and each comes back with every location
+// it is stocked at.
+func (d *dbRepo) GetAllProductInventory(ctx context.Context, listOptions ProductListOptions, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+	m := persistence.StartMetric("GetAllProducts")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	query, params, err := productInventoryPage(listOptions, nil, []interface{}{limit, offset}, "LIMIT $1 OFFSET $2")
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, query+forUpdate, params...)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	products, err := scanProductInventory(rows)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return products, nil
+}
+
+// GetAllProductInventoryAfter is GetAllProductInventory paging by key:
+// it returns the limit products that sort after after, so a page
+// doesn't shift when products are added or removed ahead of it. A nil
+// after starts from the beginning.
+func (d *dbRepo) GetAllProductInventoryAfter(ctx context.Context, listOptions ProductListOptions, after *ProductKey, limit int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+	m := persistence.StartMetric("GetAllProductsAfter")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	query, params, err := productInventoryPage(listOptions, after, []interface{}{limit}, "LIMIT $1")
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, query+forUpdate, params...)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	products, err := scanProductInventory(rows)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return products, nil
+}
+
+// productAvailable totals a product's available stock across locations
+// for filtering and sorting listings on it.
+const productAvailable = `(SELECT COALESCE(SUM(available), 0) FROM product_inventory WHERE sku = p.sku)`
+
+// productSortColumns whitelists what product listings can be ordered
+// by. The requested sort only ever picks one of these; it is never
+// written into the SQL itself.
+var productSortColumns = map[ProductSort]string{
+	"":              "p.sku",
+	SortBySku:       "p.sku",
+	SortByName:      "p.name",
+	SortByAvailable: productAvailable,
+}
+
+// likeEscaper escapes LIKE wildcards so a search matches them literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// productInventoryPage builds the query for a page of product
+// inventory. An inner query picks the page of products, filtered and
+// ordered per listOptions and starting after after when set, and every
+// location of each is joined on in the same order. params holds the
+// already-numbered values paging refers to; the filters are numbered
+// after them.
+func productInventoryPage(listOptions ProductListOptions, after *ProductKey, params []interface{}, paging string) (string, []interface{}, error) {
+	sortColumn, ok := productSortColumns[listOptions.Sort]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown product sort %q: %w", listOptions.Sort, ErrInvalidInput)
+	}
+	dir, cmp := "ASC", ">"
+	if listOptions.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	var conds []string
+	if listOptions.Search != "" {
+		params = append(params, "%"+likeEscaper.Replace(listOptions.Search)+"%")
+		n := "$" + strconv.Itoa(len(params))
+		conds = append(conds, "(p.name ILIKE "+n+" OR p.upc ILIKE "+n+")")
+	}
+	if listOptions.AvailableMin != nil {
+		params = append(params, *listOptions.AvailableMin)
+		conds = append(conds, productAvailable+" >= $"+strconv.Itoa(len(params)))
+	}
+	if listOptions.AvailableMax != nil {
+		params = append(params, *listOptions.AvailableMax)
+		conds = append(conds, productAvailable+" <= $"+strconv.Itoa(len(params)))
+	}
+	if after != nil {
+		var key interface{}
+		switch listOptions.Sort {
+		case SortByName:
+			key = after.Name
+		case SortByAvailable:
+			key = after.Available
+		}
+		if key == nil {
+			params = append(params, after.Sku)
+			conds = append(conds, "p.sku "+cmp+" $"+strconv.Itoa(len(params)))
+		} else {
+			params = append(params, key, after.Sku)
+			conds = append(conds, "("+sortColumn+", p.sku) "+cmp+" ($"+strconv.Itoa(len(params)-1)+", $"+strconv.Itoa(len(params))+")")
+		}
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	return `SELECT p.sku, p.upc, p.name, p.state, p.version, pi.location, pi.available, pi.in_transit, pi.expired, pi.on_hold, pi.quarantined, pi.damaged ` +
+		`FROM (SELECT p.sku, ` + sortColumn + ` AS sort_key FROM products p` + where + ` ORDER BY sort_key ` + dir + `, p.sku ` + dir + ` ` + paging + `) page ` +
+		`JOIN products p ON p.sku = page.sku JOIN product_inventory pi ON pi.sku = p.sku ORDER BY page.sort_key ` + dir + `, p.sku ` + dir + `, pi.location `, params, nil
+}
+
+// scanProductInventory folds rows of (sku, upc, name, state, version,
+// location, available, in_transit, expired, on_hold, quarantined,
+// damaged), ordered by sku, into one ProductInventory per product.
+// A NULL location is a product with nothing to break down, which the
+// as-of reads produce for SKUs the ledger hasn't seen yet.
+func scanProductInventory(rows pgx.Rows) ([]ProductInventory, error) {
+	return foldProductInventory(make([]ProductInventory, 0), rows)
+}
+
+// foldProductInventory is scanProductInventory carrying on from
+// products, so rows continuing its last product are added to it.
+func foldProductInventory(products []ProductInventory, rows pgx.Rows) ([]ProductInventory, error) {
+	for rows.Next() {
+		var (
+			p         Product
+			location  *string
+			available *int64
+			inTransit *int64
+			expired   *int64
+			statuses  [3]*int64
+		)
+		if err := rows.Scan(&p.Sku, &p.Upc, &p.Name, &p.State, &p.Version, &location, &available, &inTransit, &expired, &statuses[0], &statuses[1], &statuses[2]); err != nil {
+			return nil, err
+		}
+		if n := len(products); n == 0 || products[n-1].Sku != p.Sku {
+			products = append(products, ProductInventory{Product: p})
+		}
+		if location != nil && available != nil {
+			products[len(products)-1].Add(*location, *available)
+			if inTransit != nil {
+				products[len(products)-1].AddInTransit(*location, *inTransit)
+			}
+			if expired != nil {
+				products[len(products)-1].AddExpired(*location, *expired)
+			}
+			for i, status := range []StockStatus{StatusOnHold, StatusQuarantined, StatusDamaged} {
+				if statuses[i] != nil {
+					products[len(products)-1].AddStatus(*location, status, *statuses[i])
+				}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// exportFetchSize is how many rows an export fetches from its cursor
+// at a time. Tests shrink it to make rows span fetches.
+var exportFetchSize = 1000
+
+// ExportProductInventory calls fn with every product's inventory in
+// SKU order. The rows are read through a server-side cursor a batch at
+// a time, so only one batch is ever held in memory; the cursor lives
+// in the transaction the options must carry.
+func (d *dbRepo) ExportProductInventory(ctx context.Context, fn func(ProductInventory) error, options ...persistence.QueryOptions) error {
+	m := persistence.StartMetric("ExportProductInventory")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
+
+	_, err := tx.Exec(ctx, `DECLARE inventory_export NO SCROLL CURSOR FOR SELECT p.sku, p.upc, p.name, p.state, p.version, pi.location, pi.available, pi.in_transit, pi.expired, pi.on_hold, pi.quarantined, pi.damaged FROM products p JOIN product_inventory pi ON pi.sku = p.sku ORDER BY p.sku, pi.location`)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+
+	// The last product of a batch is held back, since its remaining
+	// locations may come in the next one.
+	pending := make([]ProductInventory, 0)
+	for {
+		rows, err := tx.Query(ctx, `FETCH FORWARD `+strconv.Itoa(exportFetchSize)+` FROM inventory_export`)
 		if err != nil {
 			m.Complete(err)
-			return err
+			return err
+		}
+		fetched := &countedRows{Rows: rows}
+		pending, err = foldProductInventory(pending, fetched)
+		rows.Close()
+		if err != nil {
+			m.Complete(err)
+			return err
+		}
+		if fetched.n == 0 {
+			break
+		}
+		for _, pi := range pending[:len(pending)-1] {
+			if err = fn(pi); err != nil {
+				m.Complete(err)
+				return err
+			}
+		}
+		pending = append(pending[:0], pending[len(pending)-1])
+	}
+	for _, pi := range pending {
+		if err = fn(pi); err != nil {
+			m.Complete(err)
+			return err
+		}
+	}
+
+	m.Complete(nil)
+	return nil
+}
+
+// countedRows counts the rows Next moves through.
+type countedRows struct {
+	pgx.Rows
+	n int
+}
+
+func (c *countedRows) Next() bool {
+	if c.Rows.Next() {
+		c.n++
+		return true
+	}
+	return false
+}
+
+// ExportReservations calls fn with every reservation matching
+// resOptions, oldest first, read through a server-side cursor like
+// ExportProductInventory.
+func (d *dbRepo) ExportReservations(ctx context.Context, resOptions GetReservationsOptions, fn func(Reservation) error, options ...persistence.QueryOptions) error {
+	m := persistence.StartMetric("ExportReservations")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
+
+	whereClause, params := reservationsWhere(resOptions, nil)
+	_, err := tx.Exec(ctx, `DECLARE reservation_export NO SCROLL CURSOR FOR SELECT `+reservationFields+` FROM reservations `+whereClause+` ORDER BY created ASC, id ASC`, params...)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+
+	for {
+		rows, err := tx.Query(ctx, `FETCH FORWARD `+strconv.Itoa(exportFetchSize)+` FROM reservation_export`)
+		if err != nil {
+			m.Complete(err)
+			return err
+		}
+		fetched := 0
+		for rows.Next() {
+			r := Reservation{}
+			if err = rows.Scan(reservationDest(&r)...); err != nil {
+				rows.Close()
+				m.Complete(err)
+				return err
+			}
+			fetched++
+			if err = fn(r); err != nil {
+				rows.Close()
+				m.Complete(err)
+				return err
+			}
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			m.Complete(err)
+			return err
+		}
+		if fetched == 0 {
+			break
+		}
+	}
+
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) GetProductionEventByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (pe ProductionEvent, err error) {
+	m := persistence.StartMetric("GetProductionEventByRequestID")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	pe = ProductionEvent{}
+	err = tx.QueryRow(ctx, `SELECT id, request_id, sku, location, quantity, COALESCE(lot, ''), expires_at, created FROM production_events WHERE request_id = $1 `+forUpdate, requestID).
+		Scan(&pe.ID, &pe.RequestID, &pe.Sku, &pe.Location, &pe.Quantity, &pe.Lot, &pe.ExpiresAt, &pe.Created)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pe, persistence.ErrNotFound
+		}
+		return pe, err
+	}
+
+	m.Complete(nil)
+	return pe, nil
+}
+
+func (d *dbRepo) SaveProductionEvent(ctx context.Context, event *ProductionEvent, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveProductionEvent")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	insert := `INSERT INTO production_events (request_id, sku, location, quantity, lot, expires_at, created)
+			       VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7) RETURNING id;`
+
+	err := tx.QueryRow(ctx, insert, event.RequestID, event.Sku, event.Location, event.Quantity, event.Lot, event.ExpiresAt, event.Created).Scan(&event.ID)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return persistence.ErrNotFound
+		}
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) GetAdjustmentByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Adjustment, error) {
+	m := persistence.StartMetric("GetAdjustmentByRequestID")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	a := Adjustment{}
+	err := tx.QueryRow(ctx, `SELECT id, request_id, sku, location, quantity, reason, actor, created FROM inventory_adjustments WHERE request_id = $1 `+forUpdate, requestID).
+		Scan(&a.ID, &a.RequestID, &a.Sku, &a.Location, &a.Quantity, &a.Reason, &a.Actor, &a.Created)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return a, persistence.ErrNotFound
+		}
+		return a, err
+	}
+
+	m.Complete(nil)
+	return a, nil
+}
+
+func (d *dbRepo) SaveAdjustment(ctx context.Context, a *Adjustment, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveAdjustment")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	insert := `INSERT INTO inventory_adjustments (request_id, sku, location, quantity, reason, actor, created)
+                      VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+	err := tx.QueryRow(ctx, insert, a.RequestID, a.Sku, a.Location, a.Quantity, a.Reason, a.Actor, a.Created).Scan(&a.ID)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) GetStatusChangeByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (StatusChange, error) {
+	m := persistence.StartMetric("GetStatusChangeByRequestID")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	c := StatusChange{}
+	err := tx.QueryRow(ctx, `SELECT id, request_id, sku, location, from_status, to_status, quantity, COALESCE(lot, ''), reason, actor, created FROM inventory_status_changes WHERE request_id = $1 `+forUpdate, requestID).
+		Scan(&c.ID, &c.RequestID, &c.Sku, &c.Location, &c.From, &c.To, &c.Quantity, &c.Lot, &c.Reason, &c.Actor, &c.Created)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c, persistence.ErrNotFound
+		}
+		return c, err
+	}
+
+	m.Complete(nil)
+	return c, nil
+}
+
+func (d *dbRepo) SaveStatusChange(ctx context.Context, c *StatusChange, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveStatusChange")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	insert := `INSERT INTO inventory_status_changes (request_id, sku, location, from_status, to_status, quantity, lot, reason, actor, created)
+                      VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10) RETURNING id;`
+	err := tx.QueryRow(ctx, insert, c.RequestID, c.Sku, c.Location, c.From, c.To, c.Quantity, c.Lot, c.Reason, c.Actor, c.Created).Scan(&c.ID)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) SaveReservation(ctx context.Context, r *Reservation, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveReservation")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	insert := `INSERT INTO reservations (request_id, requester, sku, location, state, reserved_quantity, requested_quantity, created, expires_at, priority, fill_policy, min_quantity)
+                      VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, version;`
+	err := tx.QueryRow(ctx, insert, r.RequestID, r.Requester, r.Sku, r.Location, r.State, r.ReservedQuantity, r.RequestedQuantity, r.Created, r.ExpiresAt, r.Priority, r.FillPolicy, r.MinQuantity).Scan(&r.ID, &r.Version)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return persistence.ErrNotFound
+		}
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) UpdateReservation(ctx context.Context, ID uint64, state ReserveState, qty int64, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("UpdateReservation")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	update, args := versioned(`UPDATE reservations SET state = $2, reserved_quantity = $3, version = version + 1 WHERE id = $1`, []interface{}{ID, state, qty}, options...)
+	ct, err := tx.Exec(ctx, update, args...)
+	if err == nil {
+		err = versionConflict(ct, options...)
+	}
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+// UpdateReservationShipment records a shipment against a reservation:
+// the new running shipped total and the state that follows from it.
+func (d *dbRepo) UpdateReservationShipment(ctx context.Context, ID uint64, state ReserveState, shipped int64, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("UpdateReservationShipment")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	update, args := versioned(`UPDATE reservations SET state = $2, shipped_quantity = $3, version = version + 1 WHERE id = $1`, []interface{}{ID, state, shipped}, options...)
+	ct, err := tx.Exec(ctx, update, args...)
+	if err == nil {
+		err = versionConflict(ct, options...)
+	}
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) UpdateReservationQuantity(ctx context.Context, ID uint64, state ReserveState, requested, reserved int64, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("UpdateReservationQuantity")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	update, args := versioned(`UPDATE reservations SET state = $2, requested_quantity = $3, reserved_quantity = $4, version = version + 1 WHERE id = $1`, []interface{}{ID, state, requested, reserved}, options...)
+	ct, err := tx.Exec(ctx, update, args...)
+	if err == nil {
+		err = versionConflict(ct, options...)
+	}
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+// versioned makes update conditional on the version carried by options,
+// if any, adding it as the next positional argument.
+func versioned(update string, args []interface{}, options ...persistence.UpdateOptions) (string, []interface{}) {
+	version := persistence.GetExpectedVersion(options...)
+	if version == 0 {
+		return update, args
+	}
+	args = append(args, version)
+	return update + " AND version = $" + strconv.Itoa(len(args)), args
+}
+
+// versionConflict reports a conditional update that matched no row.
+func versionConflict(ct pgconn.CommandTag, options ...persistence.UpdateOptions) error {
+	if persistence.GetExpectedVersion(options...) != 0 && ct.RowsAffected() == 0 {
+		return persistence.ErrVersionConflict
+	}
+	return nil
+}
+
+const reservationFields = "id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version"
+
+// reservationDest returns the Scan destinations matching
+// reservationFields, so the column list and the struct fields can't
+// drift apart across the reservation queries.
+func reservationDest(r *Reservation) []interface{} {
+	return []interface{}{&r.ID, &r.RequestID, &r.Requester, &r.Sku, &r.Location, &r.State, &r.ReservedQuantity, &r.RequestedQuantity, &r.ShippedQuantity, &r.Created, &r.ExpiresAt, &r.Priority, &r.FillPolicy, &r.MinQuantity, &r.Version}
+}
+
+// reservationsWhere builds the WHERE clause filtering reservations by
+// resOptions, numbering its parameters on from those already in params.
+func reservationsWhere(resOptions GetReservationsOptions, params []interface{}) (string, []interface{}) {
+	whereClause := ""
+	first := len(params)
+
+	if resOptions.Sku != "" || resOptions.State != None {
+		whereClause = " WHERE "
+	}
+
+	if resOptions.Sku != "" {
+		if len(params) > first {
+			whereClause += " AND"
+		}
+		params = append(params, resOptions.Sku)
+		whereClause += " sku = $" + strconv.Itoa(len(params))
+	}
+
+	if resOptions.State != None {
+		if len(params) > first {
+			whereClause += " AND"
+		}
+		params = append(params, resOptions.State)
+		whereClause += " state = $" + strconv.Itoa(len(params))
+	}
+
+	return whereClause, params
+}
+
+func (d *dbRepo) GetReservations(ctx context.Context, resOptions GetReservationsOptions, limit, offset int, options ...persistence.QueryOptions) ([]Reservation, error) {
+	m := persistence.StartMetric("GetSkuOpenReserves")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	whereClause, params := reservationsWhere(resOptions, []interface{}{limit, offset})
+
+	reservations := make([]Reservation, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT `+reservationFields+` FROM reservations `+whereClause+` ORDER BY created ASC, id ASC LIMIT $1 OFFSET $2 `+forUpdate,
+		params...)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return reservations, persistence.ErrNotFound
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := Reservation{}
+		err = rows.Scan(reservationDest(&r)...)
+		if err != nil {
+			m.Complete(err)
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+
+	m.Complete(nil)
+	return reservations, nil
+}
+
+// GetReservationsAfter is GetReservations paging by key: it returns the
+// first limit reservations ordered after after, or from the start when
+// after is nil.
+func (d *dbRepo) GetReservationsAfter(ctx context.Context, resOptions GetReservationsOptions, after *ReservationKey, limit int, options ...persistence.QueryOptions) ([]Reservation, error) {
+	m := persistence.StartMetric("GetReservationsAfter")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	whereClause, params := reservationsWhere(resOptions, []interface{}{limit})
+	if after != nil {
+		if whereClause == "" {
+			whereClause = " WHERE "
+		} else {
+			whereClause += " AND"
+		}
+		params = append(params, after.Created, after.ID)
+		whereClause += " (created, id) > ($" + strconv.Itoa(len(params)-1) + ", $" + strconv.Itoa(len(params)) + ")"
+	}
+
+	reservations := make([]Reservation, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT `+reservationFields+` FROM reservations `+whereClause+` ORDER BY created ASC, id ASC LIMIT $1 `+forUpdate,
+		params...)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := Reservation{}
+		err = rows.Scan(reservationDest(&r)...)
+		if err != nil {
+			m.Complete(err)
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+
+	m.Complete(nil)
+	return reservations, nil
+}
+
+func (d *dbRepo) GetReservationByRequestID(ctx context.Context, requestId string, options ...persistence.QueryOptions) (Reservation, error) {
+	m := persistence.StartMetric("GetReservationByRequestID")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	r := Reservation{}
+	err := tx.QueryRow(ctx,
+		`SELECT `+reservationFields+` FROM reservations WHERE request_id = $1 `+forUpdate,
+		requestId).Scan(reservationDest(&r)...)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r, persistence.ErrNotFound
+		}
+		return r, err
+	}
+
+	m.Complete(nil)
+	return r, nil
+}
+
+func (d *dbRepo) GetReservation(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Reservation, error) {
+	m := persistence.StartMetric("GetReservation")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	r := Reservation{}
+	err := tx.QueryRow(ctx,
+		`SELECT `+reservationFields+` FROM reservations WHERE id = $1 `+forUpdate, ID).
+		Scan(reservationDest(&r)...)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r, persistence.ErrNotFound
+		}
+		return r, err
+	}
+
+	m.Complete(nil)
+	return r, nil
+}
+
+// GetExpiredReservations returns up to limit Open or Closed
+// reservations whose expires_at is at or before asOf, oldest deadline
+// first. Reservations without a deadline, or that have started
+// shipping, are never returned.
+func (d *dbRepo) GetExpiredReservations(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Reservation, error) {
+	m := persistence.StartMetric("GetExpiredReservations")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	reservations := make([]Reservation, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT `+reservationFields+` FROM reservations WHERE expires_at <= $1 AND state IN ($2, $3) AND shipped_quantity = 0 ORDER BY expires_at ASC LIMIT $4 `+forUpdate,
+		asOf, Open, Closed, limit)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := Reservation{}
+		if err = rows.Scan(reservationDest(&r)...); err != nil {
+			m.Complete(err)
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return reservations, nil
+}
+
+// GetBackorders sums, per SKU, the open reservations still short of
+// what they asked for, biggest shortfall first. An empty sku covers
+// every SKU. The state is written into the SQL rather than bound so
+// the planner can match res_backorder_idx, which is partial on it.
+func (d *dbRepo) GetBackorders(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Backorder, error) {
+	m := persistence.StartMetric("GetBackorders")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
+
+	params := []interface{}{limit, offset}
+	whereClause := ""
+	if sku != "" {
+		whereClause = " AND sku = $3"
+		params = append(params, sku)
+	}
+
+	backorders := make([]Backorder, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT sku, COUNT(*), SUM(requested_quantity), SUM(reserved_quantity), SUM(requested_quantity - reserved_quantity), MIN(created) FROM reservations WHERE state = 'Open' AND reserved_quantity < requested_quantity`+whereClause+` GROUP BY sku ORDER BY SUM(requested_quantity - reserved_quantity) DESC, sku LIMIT $1 OFFSET $2`,
+		params...)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b := Backorder{}
+		if err = rows.Scan(&b.Sku, &b.OpenReservations, &b.Requested, &b.Reserved, &b.Shortfall, &b.OldestCreated); err != nil {
+			m.Complete(err)
+			return nil, err
+		}
+		backorders = append(backorders, b)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return backorders, nil
+}
+
+func (d *dbRepo) SaveShipment(ctx context.Context, shipment *Shipment, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveShipment")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	insert := `INSERT INTO shipments (request_id, reservation_id, sku, quantity, created)
+                      VALUES ($1, $2, $3, $4, $5) RETURNING id;`
+	err := tx.QueryRow(ctx, insert, shipment.RequestID, shipment.ReservationID, shipment.Sku, shipment.Quantity, shipment.Created).Scan(&shipment.ID)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return persistence.ErrNotFound
+		}
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) GetShipmentByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Shipment, error) {
+	m := persistence.StartMetric("GetShipmentByRequestID")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	sh := Shipment{}
+	err := tx.QueryRow(ctx,
+		`SELECT id, request_id, reservation_id, sku, quantity, created FROM shipments WHERE request_id = $1 `+forUpdate,
+		requestID).Scan(&sh.ID, &sh.RequestID, &sh.ReservationID, &sh.Sku, &sh.Quantity, &sh.Created)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sh, persistence.ErrNotFound
+		}
+		return sh, err
+	}
+
+	m.Complete(nil)
+	return sh, nil
+}
+
+func (d *dbRepo) SaveInventoryMovement(ctx context.Context, mv *InventoryMovement, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveInventoryMovement")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	// request_id is stored as NULL rather than "" when the change has
+	// no causing request, so the column reads honestly in ad-hoc SQL.
+	// The same goes for lot when it concerns no lot.
+	var requestID, lot *string
+	if mv.RequestID != "" {
+		requestID = &mv.RequestID
+	}
+	if mv.Lot != "" {
+		lot = &mv.Lot
+	}
+
+	insert := `INSERT INTO inventory_movements (sku, location, delta, reason, request_id, reservation_id, lot, actor, balance, created)
+                      VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id;`
+	err := tx.QueryRow(ctx, insert, mv.Sku, mv.Location, mv.Delta, mv.Reason, requestID, mv.ReservationID, lot, mv.Actor, mv.Balance, mv.Created).Scan(&mv.ID)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+// GetInventoryMovements returns a page of a SKU's ledger in the order
+// the movements were written, so balances can be rebuilt by summing
+// deltas front to back.
+func (d *dbRepo) GetInventoryMovements(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]InventoryMovement, error) {
+	m := persistence.StartMetric("GetInventoryMovements")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	movements := make([]InventoryMovement, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT id, sku, location, delta, reason, COALESCE(request_id, ''), reservation_id, COALESCE(lot, ''), actor, balance, created FROM inventory_movements WHERE sku = $1 ORDER BY id ASC LIMIT $2 OFFSET $3 `+forUpdate,
+		sku, limit, offset)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		mv := InventoryMovement{}
+		if err = rows.Scan(&mv.ID, &mv.Sku, &mv.Location, &mv.Delta, &mv.Reason, &mv.RequestID, &mv.ReservationID, &mv.Lot, &mv.Actor, &mv.Balance, &mv.Created); err != nil {
+			m.Complete(err)
+			return nil, err
+		}
+		movements = append(movements, mv)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return movements, nil
+}
+
+const transferFields = "id, request_id, sku, from_location, to_location, quantity, state, actor, created, updated"
+
+// transferDest returns the Scan destinations matching transferFields.
+func transferDest(t *Transfer) []interface{} {
+	return []interface{}{&t.ID, &t.RequestID, &t.Sku, &t.From, &t.To, &t.Quantity, &t.State, &t.Actor, &t.Created, &t.Updated}
+}
+
+func (d *dbRepo) SaveTransfer(ctx context.Context, t *Transfer, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveTransfer")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	insert := `INSERT INTO inventory_transfers (request_id, sku, from_location, to_location, quantity, state, actor, created, updated)
+                      VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;`
+	err := tx.QueryRow(ctx, insert, t.RequestID, t.Sku, t.From, t.To, t.Quantity, t.State, t.Actor, t.Created, t.Updated).Scan(&t.ID)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) UpdateTransfer(ctx context.Context, ID uint64, state TransferState, updated time.Time, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("UpdateTransfer")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	_, err := tx.Exec(ctx, `UPDATE inventory_transfers SET state = $2, updated = $3 WHERE id = $1;`, ID, state, updated)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) GetTransfer(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Transfer, error) {
+	m := persistence.StartMetric("GetTransfer")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	t := Transfer{}
+	err := tx.QueryRow(ctx, `SELECT `+transferFields+` FROM inventory_transfers WHERE id = $1 `+forUpdate, ID).
+		Scan(transferDest(&t)...)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return t, persistence.ErrNotFound
+		}
+		return t, err
+	}
+
+	m.Complete(nil)
+	return t, nil
+}
+
+func (d *dbRepo) GetTransferByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Transfer, error) {
+	m := persistence.StartMetric("GetTransferByRequestID")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	t := Transfer{}
+	err := tx.QueryRow(ctx, `SELECT `+transferFields+` FROM inventory_transfers WHERE request_id = $1 `+forUpdate, requestID).
+		Scan(transferDest(&t)...)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return t, persistence.ErrNotFound
+		}
+		return t, err
+	}
+
+	m.Complete(nil)
+	return t, nil
+}
+
+// GetTransfers returns a page of a SKU's transfers, oldest first.
+func (d *dbRepo) GetTransfers(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Transfer, error) {
+	m := persistence.StartMetric("GetTransfers")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	transfers := make([]Transfer, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT `+transferFields+` FROM inventory_transfers WHERE sku = $1 ORDER BY id ASC LIMIT $2 OFFSET $3 `+forUpdate,
+		sku, limit, offset)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := Transfer{}
+		if err = rows.Scan(transferDest(&t)...); err != nil {
+			m.Complete(err)
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return transfers, nil
+}
+
+// GetBillOfMaterials returns sku's components ordered by component
+// SKU. A product that isn't a kit has none, which is not an error.
+func (d *dbRepo) GetBillOfMaterials(ctx context.Context, sku string, options ...persistence.QueryOptions) (BillOfMaterials, error) {
+	m := persistence.StartMetric("GetBillOfMaterials")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	bom := BillOfMaterials{Sku: sku, Components: make([]BOMComponent, 0)}
+	rows, err := tx.Query(ctx,
+		`SELECT component_sku, quantity FROM bill_of_materials WHERE parent_sku = $1 ORDER BY component_sku ASC `+forUpdate,
+		sku)
+	if err != nil {
+		m.Complete(err)
+		return bom, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c := BOMComponent{}
+		if err = rows.Scan(&c.Sku, &c.Quantity); err != nil {
+			m.Complete(err)
+			return bom, err
+		}
+		bom.Components = append(bom.Components, c)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return bom, err
+	}
+
+	m.Complete(nil)
+	return bom, nil
+}
+
+// SaveBillOfMaterials replaces bom.Sku's components with bom.Components.
+// Saving no components removes the bill of materials.
+func (d *dbRepo) SaveBillOfMaterials(ctx context.Context, bom BillOfMaterials, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveBillOfMaterials")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM bill_of_materials WHERE parent_sku = $1;`, bom.Sku); err != nil {
+		m.Complete(err)
+		return err
+	}
+	if len(bom.Components) == 0 {
+		m.Complete(nil)
+		return nil
+	}
+
+	skus := make([]string, len(bom.Components))
+	quantities := make([]int64, len(bom.Components))
+	for i, c := range bom.Components {
+		skus[i] = c.Sku
+		quantities[i] = c.Quantity
+	}
+	insert := `INSERT INTO bill_of_materials (parent_sku, component_sku, quantity)
+                    SELECT $1, c.sku, c.quantity FROM unnest($2::text[], $3::bigint[]) AS c (sku, quantity);`
+	if _, err := tx.Exec(ctx, insert, bom.Sku, skus, quantities); err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+// GetLots returns every lot of sku at every location, including lots
+// with nothing left, which a returned reservation may still hand stock
+// back to.
+func (d *dbRepo) GetLots(ctx context.Context, sku string, options ...persistence.QueryOptions) ([]Lot, error) {
+	m := persistence.StartMetric("GetLots")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	rows, err := tx.Query(ctx,
+		`SELECT `+lotFields+` FROM inventory_lots WHERE sku = $1 ORDER BY location, expires_at ASC NULLS LAST, lot `+forUpdate,
+		sku)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots, err := scanLots(rows)
+	m.Complete(err)
+	return lots, err
+}
+
+// GetExpiredLots returns up to limit lots still holding available stock
+// whose expires_at is at or before asOf, oldest expiry first.
+func (d *dbRepo) GetExpiredLots(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Lot, error) {
+	m := persistence.StartMetric("GetExpiredLots")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	rows, err := tx.Query(ctx,
+		`SELECT `+lotFields+` FROM inventory_lots WHERE expires_at <= $1 AND available > 0 ORDER BY expires_at ASC LIMIT $2 `+forUpdate,
+		asOf, limit)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots, err := scanLots(rows)
+	m.Complete(err)
+	return lots, err
+}
+
+const lotFields = `sku, location, lot, expires_at, available, expired`
+
+func scanLots(rows pgx.Rows) ([]Lot, error) {
+	lots := make([]Lot, 0)
+	for rows.Next() {
+		l := Lot{}
+		if err := rows.Scan(&l.Sku, &l.Location, &l.Lot, &l.ExpiresAt, &l.Available, &l.Expired); err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return lots, nil
+}
+
+// SaveLots writes lots, inserting the ones new to their SKU and
+// location. A lot's expiry date is fixed when it is first produced and
+// never rewritten.
+func (d *dbRepo) SaveLots(ctx context.Context, lots []Lot, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveLots")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	skus := make([]string, len(lots))
+	locations := make([]string, len(lots))
+	names := make([]string, len(lots))
+	expiresAt := make([]*time.Time, len(lots))
+	available := make([]int64, len(lots))
+	expired := make([]int64, len(lots))
+	for i, l := range lots {
+		skus[i], locations[i], names[i], expiresAt[i] = l.Sku, l.Location, l.Lot, l.ExpiresAt
+		available[i], expired[i] = l.Available, l.Expired
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO inventory_lots (sku, location, lot, expires_at, available, expired)
+		     SELECT l.sku, l.location, l.lot, l.expires_at, l.available, l.expired FROM unnest($1::text[], $2::text[], $3::text[], $4::timestamptz[], $5::bigint[], $6::bigint[]) AS l(sku, location, lot, expires_at, available, expired)
+		ON CONFLICT (sku, location, lot) DO UPDATE SET available = EXCLUDED.available, expired = EXCLUDED.expired;`,
+		skus, locations, names, expiresAt, available, expired)
+	m.Complete(err)
+	return err
+}
+
+// GetReservationLots returns how much of each lot a reservation still
+// holds.
+func (d *dbRepo) GetReservationLots(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) ([]LotDraw, error) {
+	m := persistence.StartMetric("GetReservationLots")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	draws := make([]LotDraw, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT lot, quantity FROM reservation_lots WHERE reservation_id = $1 AND quantity > 0 ORDER BY lot `+forUpdate,
+		reservationID)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := LotDraw{}
+		if err = rows.Scan(&d.Lot, &d.Quantity); err != nil {
+			m.Complete(err)
+			return nil, err
+		}
+		draws = append(draws, d)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
+	m.Complete(nil)
+	return draws, nil
+}
+
+// SaveReservationLots adds draws to what the reservation has already
+// drawn from each lot. A negative quantity hands stock back.
+func (d *dbRepo) SaveReservationLots(ctx context.Context, reservationID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveReservationLots")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	lots := make([]string, len(draws))
+	quantities := make([]int64, len(draws))
+	for i, d := range draws {
+		lots[i], quantities[i] = d.Lot, d.Quantity
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO reservation_lots (reservation_id, lot, quantity)
+		     SELECT $1, d.lot, d.quantity FROM unnest($2::text[], $3::bigint[]) AS d(lot, quantity)
+		ON CONFLICT (reservation_id, lot) DO UPDATE SET quantity = reservation_lots.quantity + EXCLUDED.quantity;`,
+		reservationID, lots, quantities)
+	m.Complete(err)
+	return err
+}
+
+// GetTransferLots returns how much of each lot a transfer took from
+// its source location.
+func (d *dbRepo) GetTransferLots(ctx context.Context, transferID uint64, options ...persistence.QueryOptions) ([]LotDraw, error) {
+	m := persistence.StartMetric("GetTransferLots")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	draws := make([]LotDraw, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT lot, quantity FROM transfer_lots WHERE transfer_id = $1 ORDER BY lot `+forUpdate,
+		transferID)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := LotDraw{}
+		if err = rows.Scan(&d.Lot, &d.Quantity); err != nil {
+			m.Complete(err)
+			return nil, err
 		}
+		draws = append(draws, d)
 	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
 	m.Complete(nil)
-	return nil
+	return draws, nil
 }
 
-func (d *dbRepo) SaveProductInventory(ctx context.Context, productInventory ProductInventory, options ...persistence.UpdateOptions) error {
-	m := persistence.StartMetric("SaveProductInventory")
+// SaveTransferLots records the lots a transfer took its stock from.
+func (d *dbRepo) SaveTransferLots(ctx context.Context, transferID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveTransferLots")
 	tx := persistence.GetUpdateOptions(d.conn, options...)
 
-	ct, err := tx.Exec(ctx, `
-		UPDATE product_inventory
-           SET available = $2
-         WHERE sku = $1;`,
-		productInventory.Sku, productInventory.Available)
-	if err != nil {
-		m.Complete(nil)
-		return err
+	lots := make([]string, len(draws))
+	quantities := make([]int64, len(draws))
+	for i, d := range draws {
+		lots[i], quantities[i] = d.Lot, d.Quantity
 	}
-	if ct.RowsAffected() == 0 {
-		insert := `INSERT INTO product_inventory (sku, available)
-                      VALUES ($1, $2);`
-		_, err := tx.Exec(ctx, insert, productInventory.Sku, productInventory.Available)
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO transfer_lots (transfer_id, lot, quantity)
+		     SELECT $1, d.lot, d.quantity FROM unnest($2::text[], $3::bigint[]) AS d(lot, quantity);`,
+		transferID, lots, quantities)
+	m.Complete(err)
+	return err
+}
+
+// GetLotRecipients pages over the reservations of sku that drew stock
+// from lot and still hold some of it, oldest first.
+func (d *dbRepo) GetLotRecipients(ctx context.Context, sku, lot string, limit, offset int, options ...persistence.QueryOptions) ([]LotRecipient, error) {
+	m := persistence.StartMetric("GetLotRecipients")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	recipients := make([]LotRecipient, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT r.id, r.request_id, r.requester, r.location, r.state, rl.quantity, r.shipped_quantity FROM reservation_lots rl JOIN reservations r ON r.id = rl.reservation_id WHERE r.sku = $1 AND rl.lot = $2 AND rl.quantity > 0 ORDER BY r.id LIMIT $3 OFFSET $4 `+forUpdate,
+		sku, lot, limit, offset)
+	if err != nil {
 		m.Complete(err)
-		if err != nil {
-			return err
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := LotRecipient{}
+		if err = rows.Scan(&r.ReservationID, &r.RequestID, &r.Requester, &r.Location, &r.State, &r.Quantity, &r.ShippedQuantity); err != nil {
+			m.Complete(err)
+			return nil, err
 		}
+		recipients = append(recipients, r)
 	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
+	}
+
 	m.Complete(nil)
-	return nil
+	return recipients, nil
 }
 
-func (d *dbRepo) GetProduct(ctx context.Context, sku string, options ...persistence.QueryOptions) (Product, error) {
-	m := persistence.StartMetric("GetProduct")
+func (d *dbRepo) GetReorderPolicy(ctx context.Context, sku string, options ...persistence.QueryOptions) (ReorderPolicy, error) {
+	m := persistence.StartMetric("GetReorderPolicy")
 	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
 
-	product := Product{}
-	err := tx.QueryRow(ctx, `SELECT sku, upc, name FROM products WHERE sku = $1 `+forUpdate, sku).
-		Scan(&product.Sku, &product.Upc, &product.Name)
+	p := ReorderPolicy{}
+	err := tx.QueryRow(ctx, `SELECT sku, reorder_point, target_level, low_since FROM inventory_reorder_policies WHERE sku = $1 `+forUpdate, sku).
+		Scan(&p.Sku, &p.ReorderPoint, &p.TargetLevel, &p.LowSince)
 	if err != nil {
 		m.Complete(err)
 		if errors.Is(err, pgx.ErrNoRows) {
-			return product, persistence.ErrNotFound
+			return p, persistence.ErrNotFound
 		}
-		return product, err
+		return p, err
 	}
 
 	m.Complete(nil)
-	return product, nil
+	return p, nil
 }
 
-func (d *dbRepo) GetProductInventory(ctx context.Context, sku string, options ...persistence.QueryOptions) (ProductInventory, error) {
-	m := persistence.StartMetric("GetProductInventory")
-	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+// SaveReorderPolicy creates or replaces sku's reorder point and target
+// level. LowSince is left alone; whether the SKU is low against the new
+// settings is for the next UpdateLowStock to decide.
+func (d *dbRepo) SaveReorderPolicy(ctx context.Context, p ReorderPolicy, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveReorderPolicy")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	upsert := `INSERT INTO inventory_reorder_policies (sku, reorder_point, target_level) VALUES ($1, $2, $3)
+                    ON CONFLICT (sku) DO UPDATE SET reorder_point = EXCLUDED.reorder_point, target_level = EXCLUDED.target_level;`
+	if _, err := tx.Exec(ctx, upsert, p.Sku, p.ReorderPoint, p.TargetLevel); err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) DeleteReorderPolicy(ctx context.Context, sku string, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("DeleteReorderPolicy")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
 
-	productInventory := ProductInventory{}
-	err := tx.QueryRow(ctx, `SELECT p.sku, p.upc, p.name, pi.available FROM products p, product_inventory pi WHERE p.sku = $1 AND p.sku = pi.sku `+forUpdate, sku).
-		Scan(&productInventory.Sku, &productInventory.Upc, &productInventory.Name, &productInventory.Available)
+	ct, err := tx.Exec(ctx, `DELETE FROM inventory_reorder_policies WHERE sku = $1;`, sku)
 	if err != nil {
 		m.Complete(err)
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		m.Complete(persistence.ErrNotFound)
+		return persistence.ErrNotFound
+	}
+	m.Complete(nil)
+	return nil
+}
+
+// UpdateLowStock only matches the policy row when available stock is on
+// the other side of the reorder point from what low_since records, so a
+// SKU that stays low or stays stocked isn't written at all. Concurrent
+// callers serialize on the row and re-check the condition, so only one
+// of them sees a given crossing.
+func (d *dbRepo) UpdateLowStock(ctx context.Context, sku string, available int64, at time.Time, options ...persistence.UpdateOptions) (ReorderPolicy, bool, error) {
+	m := persistence.StartMetric("UpdateLowStock")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	p := ReorderPolicy{}
+	update := `UPDATE inventory_reorder_policies SET low_since = CASE WHEN $2 < reorder_point THEN $3::timestamptz END
+                    WHERE sku = $1 AND (low_since IS NULL) = ($2 < reorder_point)
+                    RETURNING sku, reorder_point, target_level, low_since;`
+	err := tx.QueryRow(ctx, update, sku, available, at).Scan(&p.Sku, &p.ReorderPoint, &p.TargetLevel, &p.LowSince)
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return productInventory, persistence.ErrNotFound
+			m.Complete(nil)
+			return ReorderPolicy{}, false, nil
 		}
-		return productInventory, err
+		m.Complete(err)
+		return ReorderPolicy{}, false, err
 	}
-
 	m.Complete(nil)
-	return productInventory, nil
+	return p, true, nil
 }
 
-func (d *dbRepo) GetAllProductInventory(ctx context.Context, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
-	m := persistence.StartMetric("GetAllProducts")
-	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+// GetLowStock pages over the SKUs whose available stock, summed across
+// their locations, is below their reorder point right now.
+func (d *dbRepo) GetLowStock(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]LowStock, error) {
+	m := persistence.StartMetric("GetLowStock")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
 
-	products := make([]ProductInventory, 0)
+	low := make([]LowStock, 0)
 	rows, err := tx.Query(ctx,
-		`SELECT p.sku, p.upc, p.name, pi.available FROM products p, product_inventory pi WHERE p.sku = pi.sku ORDER BY p.sku LIMIT $1 OFFSET $2 `+forUpdate,
+		`SELECT r.sku, r.reorder_point, r.target_level, r.low_since, COALESCE(SUM(pi.available), 0) FROM inventory_reorder_policies r LEFT JOIN product_inventory pi ON pi.sku = r.sku GROUP BY r.sku HAVING COALESCE(SUM(pi.available), 0) < r.reorder_point ORDER BY r.sku LIMIT $1 OFFSET $2`,
 		limit, offset)
 	if err != nil {
 		m.Complete(err)
-		if errors.Is(err, pgx.ErrNoRows) {
-			return products, persistence.ErrNotFound
-		}
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		product := ProductInventory{}
-		err = rows.Scan(&product.Sku, &product.Upc, &product.Name, &product.Available)
-		if err != nil {
+		var (
+			p         ReorderPolicy
+			available int64
+		)
+		if err = rows.Scan(&p.Sku, &p.ReorderPoint, &p.TargetLevel, &p.LowSince, &available); err != nil {
 			m.Complete(err)
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, persistence.ErrNotFound
-			}
 			return nil, err
 		}
-		products = append(products, product)
+		low = append(low, NewLowStock(p, available))
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
 	}
 
 	m.Complete(nil)
-	return products, nil
+	return low, nil
 }
 
-func (d *dbRepo) GetProductionEventByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (pe ProductionEvent, err error) {
-	m := persistence.StartMetric("GetProductionEventByRequestID")
+const plannedOrderFields = "id, request_id, sku, quantity, expected_date, state, COALESCE(production_request_id, ''), actor, created, updated"
+
+// plannedOrderDest returns the Scan destinations matching plannedOrderFields.
+func plannedOrderDest(o *PlannedOrder) []interface{} {
+	return []interface{}{&o.ID, &o.RequestID, &o.Sku, &o.Quantity, &o.ExpectedDate, &o.State, &o.ProductionRequestID, &o.Actor, &o.Created, &o.Updated}
+}
+
+func (d *dbRepo) GetPlannedOrderByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (PlannedOrder, error) {
+	m := persistence.StartMetric("GetPlannedOrderByRequestID")
 	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
 
-	pe = ProductionEvent{}
-	err = tx.QueryRow(ctx, `SELECT id, request_id, sku, quantity, created FROM production_events WHERE request_id = $1 `+forUpdate, requestID).
-		Scan(&pe.ID, &pe.RequestID, &pe.Sku, &pe.Quantity, &pe.Created)
+	o := PlannedOrder{}
+	err := tx.QueryRow(ctx, `SELECT `+plannedOrderFields+` FROM planned_orders WHERE request_id = $1 `+forUpdate, requestID).
+		Scan(plannedOrderDest(&o)...)
 	if err != nil {
 		m.Complete(err)
 		if errors.Is(err, pgx.ErrNoRows) {
-			return pe, persistence.ErrNotFound
+			return o, persistence.ErrNotFound
 		}
-		return pe, err
+		return o, err
 	}
 
 	m.Complete(nil)
-	return pe, nil
+	return o, nil
 }
 
-func (d *dbRepo) SaveProductionEvent(ctx context.Context, event *ProductionEvent, options ...persistence.UpdateOptions) error {
-	m := persistence.StartMetric("SaveProductionEvent")
-	tx := persistence.GetUpdateOptions(d.conn, options...)
-
-	insert := `INSERT INTO production_events (request_id, sku, quantity, created)
-			       VALUES ($1, $2, $3, $4) RETURNING id;`
+// GetScheduledOrders returns a page of sku's Scheduled planned orders,
+// earliest expected first. The state is written into the SQL so the
+// planner can match planned_orders_scheduled_idx.
+func (d *dbRepo) GetScheduledOrders(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]PlannedOrder, error) {
+	m := persistence.StartMetric("GetScheduledOrders")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
 
-	err := tx.QueryRow(ctx, insert, event.RequestID, event.Sku, event.Quantity, event.Created).Scan(&event.ID)
+	orders := make([]PlannedOrder, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT `+plannedOrderFields+` FROM planned_orders WHERE sku = $1 AND state = 'Scheduled' ORDER BY expected_date ASC, id ASC LIMIT $2 OFFSET $3 `+forUpdate,
+		sku, limit, offset)
 	if err != nil {
 		m.Complete(err)
-		if errors.Is(err, pgx.ErrNoRows) {
-			return persistence.ErrNotFound
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		o := PlannedOrder{}
+		if err = rows.Scan(plannedOrderDest(&o)...); err != nil {
+			m.Complete(err)
+			return nil, err
 		}
-		return err
+		orders = append(orders, o)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
 	}
+
 	m.Complete(nil)
-	return nil
+	return orders, nil
 }
 
-func (d *dbRepo) SaveReservation(ctx context.Context, r *Reservation, options ...persistence.UpdateOptions) error {
-	m := persistence.StartMetric("SaveReservation")
+func (d *dbRepo) SavePlannedOrder(ctx context.Context, o *PlannedOrder, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SavePlannedOrder")
 	tx := persistence.GetUpdateOptions(d.conn, options...)
 
-	insert := `INSERT INTO reservations (request_id, requester, sku, state, reserved_quantity, requested_quantity, created)
-                      VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
-	err := tx.QueryRow(ctx, insert, r.RequestID, r.Requester, r.Sku, r.State, r.ReservedQuantity, r.RequestedQuantity, r.Created).Scan(&r.ID)
+	insert := `INSERT INTO planned_orders (request_id, sku, quantity, expected_date, state, actor, created, updated)
+                      VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;`
+	err := tx.QueryRow(ctx, insert, o.RequestID, o.Sku, o.Quantity, o.ExpectedDate, o.State, o.Actor, o.Created, o.Updated).Scan(&o.ID)
 	if err != nil {
 		m.Complete(err)
-		if errors.Is(err, pgx.ErrNoRows) {
-			return persistence.ErrNotFound
-		}
 		return err
 	}
 	m.Complete(nil)
 	return nil
 }
 
-func (d *dbRepo) UpdateReservation(ctx context.Context, ID uint64, state ReserveState, qty int64, options ...persistence.UpdateOptions) error {
-	m := persistence.StartMetric("UpdateReservation")
+func (d *dbRepo) UpdatePlannedOrder(ctx context.Context, ID uint64, state PlannedOrderState, productionRequestID string, updated time.Time, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("UpdatePlannedOrder")
 	tx := persistence.GetUpdateOptions(d.conn, options...)
 
-	update := `UPDATE reservations SET state = $2, reserved_quantity = $3 WHERE id=$1;`
-	_, err := tx.Exec(ctx, update, ID, state, qty)
-	m.Complete(err)
+	_, err := tx.Exec(ctx, `UPDATE planned_orders SET state = $2, production_request_id = NULLIF($3, ''), updated = $4 WHERE id = $1;`, ID, state, productionRequestID, updated)
 	if err != nil {
+		m.Complete(err)
 		return err
 	}
 	m.Complete(nil)
 	return nil
 }
 
-const reservationFields = "id, request_id, requester, sku, state, reserved_quantity, requested_quantity, created"
+const returnFields = "id, request_id, sku, location, reservation_id, requester, quantity, outcome, COALESCE(lot, ''), reason, actor, created"
 
-func (d *dbRepo) GetReservations(ctx context.Context, resOptions GetReservationsOptions, limit, offset int, options ...persistence.QueryOptions) ([]Reservation, error) {
-	m := persistence.StartMetric("GetSkuOpenReserves")
+// returnDest returns the Scan destinations matching returnFields.
+func returnDest(r *Return) []interface{} {
+	return []interface{}{&r.ID, &r.RequestID, &r.Sku, &r.Location, &r.ReservationID, &r.Requester, &r.Quantity, &r.Outcome, &r.Lot, &r.Reason, &r.Actor, &r.Created}
+}
+
+func (d *dbRepo) GetReturnByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Return, error) {
+	m := persistence.StartMetric("GetReturnByRequestID")
 	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
 
-	params := make([]interface{}, 0)
-	params = append(params, limit)
-	params = append(params, offset)
+	r := Return{}
+	err := tx.QueryRow(ctx, `SELECT `+returnFields+` FROM inventory_returns WHERE request_id = $1 `+forUpdate, requestID).
+		Scan(returnDest(&r)...)
+	if err != nil {
+		m.Complete(err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r, persistence.ErrNotFound
+		}
+		return r, err
+	}
+
+	m.Complete(nil)
+	return r, nil
+}
 
-	whereClause := ""
-	paramIdx := 2
+// GetReturns returns a page of sku's returns, oldest first.
+func (d *dbRepo) GetReturns(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Return, error) {
+	m := persistence.StartMetric("GetReturns")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
 
-	if resOptions.Sku != "" || resOptions.State != None {
-		whereClause = " WHERE "
+	returns := make([]Return, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT `+returnFields+` FROM inventory_returns WHERE sku = $1 ORDER BY id ASC LIMIT $2 OFFSET $3 `+forUpdate,
+		sku, limit, offset)
+	if err != nil {
+		m.Complete(err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	if resOptions.Sku != "" {
-		if paramIdx > 2 {
-			whereClause += " AND"
+	for rows.Next() {
+		r := Return{}
+		if err = rows.Scan(returnDest(&r)...); err != nil {
+			m.Complete(err)
+			return nil, err
 		}
-		paramIdx++
-		whereClause += " sku = $" + strconv.Itoa(paramIdx)
-		params = append(params, resOptions.Sku)
+		returns = append(returns, r)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
 	}
 
-	if resOptions.State != None {
-		if paramIdx > 2 {
-			whereClause += " AND"
-		}
-		paramIdx++
-		whereClause += " state = $" + strconv.Itoa(paramIdx)
-		params = append(params, resOptions.State)
+	m.Complete(nil)
+	return returns, nil
+}
+
+func (d *dbRepo) GetReturnedQuantity(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) (int64, error) {
+	m := persistence.StartMetric("GetReturnedQuantity")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
+
+	var returned int64
+	err := tx.QueryRow(ctx, `SELECT COALESCE(SUM(quantity), 0) FROM inventory_returns WHERE reservation_id = $1`, reservationID).
+		Scan(&returned)
+	if err != nil {
+		m.Complete(err)
+		return 0, err
 	}
 
-	reservations := make([]Reservation, 0)
-	rows, err := tx.Query(ctx,
-		`SELECT `+reservationFields+` FROM reservations `+whereClause+` ORDER BY created ASC LIMIT $1 OFFSET $2 `+forUpdate,
-		params...)
+	m.Complete(nil)
+	return returned, nil
+}
+
+func (d *dbRepo) SaveReturn(ctx context.Context, r *Return, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveReturn")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	insert := `INSERT INTO inventory_returns (request_id, sku, location, reservation_id, requester, quantity, outcome, lot, reason, actor, created)
+                      VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11) RETURNING id;`
+	err := tx.QueryRow(ctx, insert, r.RequestID, r.Sku, r.Location, r.ReservationID, r.Requester, r.Quantity, r.Outcome, r.Lot, r.Reason, r.Actor, r.Created).Scan(&r.ID)
+	if err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+const requesterLimitFields = "requester, class, max_reserved_per_sku, max_open_reservations, daily_cap"
+
+func requesterLimitsDest(l *RequesterLimits) []interface{} {
+	return []interface{}{&l.Requester, &l.Class, &l.MaxReservedPerSku, &l.MaxOpenReservations, &l.DailyCap}
+}
+
+func (d *dbRepo) GetRequesterLimits(ctx context.Context, requester string, options ...persistence.QueryOptions) (RequesterLimits, error) {
+	m := persistence.StartMetric("GetRequesterLimits")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	l := RequesterLimits{}
+	err := tx.QueryRow(ctx, `SELECT `+requesterLimitFields+` FROM requester_limits WHERE requester = $1 `+forUpdate, requester).
+		Scan(requesterLimitsDest(&l)...)
 	if err != nil {
 		m.Complete(err)
 		if errors.Is(err, pgx.ErrNoRows) {
-			return reservations, persistence.ErrNotFound
+			return l, persistence.ErrNotFound
 		}
+		return l, err
+	}
+
+	m.Complete(nil)
+	return l, nil
+}
+
+func (d *dbRepo) GetAllRequesterLimits(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]RequesterLimits, error) {
+	m := persistence.StartMetric("GetAllRequesterLimits")
+	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+
+	all := make([]RequesterLimits, 0)
+	rows, err := tx.Query(ctx,
+		`SELECT `+requesterLimitFields+` FROM requester_limits ORDER BY requester LIMIT $1 OFFSET $2 `+forUpdate,
+		limit, offset)
+	if err != nil {
+		m.Complete(err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		r := Reservation{}
-		err = rows.Scan(&r.ID, &r.RequestID, &r.Requester, &r.Sku, &r.State, &r.ReservedQuantity, &r.RequestedQuantity, &r.Created)
-		if err != nil {
+		l := RequesterLimits{}
+		if err = rows.Scan(requesterLimitsDest(&l)...); err != nil {
 			m.Complete(err)
 			return nil, err
 		}
-		reservations = append(reservations, r)
+		all = append(all, l)
+	}
+	if err = rows.Err(); err != nil {
+		m.Complete(err)
+		return nil, err
 	}
 
 	m.Complete(nil)
-	return reservations, nil
+	return all, nil
 }
 
-func (d *dbRepo) GetReservationByRequestID(ctx context.Context, requestId string, options ...persistence.QueryOptions) (Reservation, error) {
-	m := persistence.StartMetric("GetReservationByRequestID")
-	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+// GetRequesterUsage counts a reservation as outstanding while it is
+// Open or Closed, for what it asked for less what has shipped. The
+// units reserved since since come from requester_demand, netted per
+// reservation so lowering one only gives back what was asked for in
+// the same period, and leave out reservations since cancelled or
+// expired.
+func (d *dbRepo) GetRequesterUsage(ctx context.Context, requester, sku string, since time.Time, options ...persistence.QueryOptions) (RequesterUsage, error) {
+	m := persistence.StartMetric("GetRequesterUsage")
+	tx, _ := persistence.GetQueryOptions(d.conn, options...)
 
-	r := Reservation{}
-	err := tx.QueryRow(ctx,
-		`SELECT `+reservationFields+` FROM reservations WHERE request_id = $1 `+forUpdate,
-		requestId).Scan(&r.ID, &r.RequestID, &r.Requester, &r.Sku, &r.State, &r.ReservedQuantity, &r.RequestedQuantity, &r.Created)
+	u := RequesterUsage{}
+	query := `SELECT COALESCE(SUM(requested_quantity - shipped_quantity) FILTER (WHERE sku = $2 AND state IN ('Open', 'Closed')), 0),
+                     COUNT(*) FILTER (WHERE state IN ('Open', 'Closed')),
+                     (SELECT COALESCE(SUM(GREATEST(n.quantity, 0)), 0)::BIGINT FROM (SELECT SUM(rd.quantity) AS quantity FROM requester_demand rd JOIN reservations r ON r.id = rd.reservation_id
+                       WHERE rd.requester = $1 AND rd.created >= $3 AND r.state NOT IN ('Cancelled', 'Expired') GROUP BY rd.reservation_id) n)
+                FROM reservations WHERE requester = $1`
+	err := tx.QueryRow(ctx, query, requester, sku, since).Scan(&u.ReservedForSku, &u.OpenReservations, &u.ReservedToday)
 	if err != nil {
 		m.Complete(err)
-		if errors.Is(err, pgx.ErrNoRows) {
-			return r, persistence.ErrNotFound
-		}
-		return r, err
+		return u, err
 	}
 
 	m.Complete(nil)
-	return r, nil
+	return u, nil
 }
 
-func (d *dbRepo) GetReservation(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Reservation, error) {
-	m := persistence.StartMetric("GetReservation")
-	tx, forUpdate := persistence.GetQueryOptions(d.conn, options...)
+// SaveRequesterDemand records that requester changed what reservationID
+// asks for by quantity at at: its whole quantity when it is made, and
+// the difference, negative when lowered, each time it changes.
+func (d *dbRepo) SaveRequesterDemand(ctx context.Context, requester string, reservationID uint64, quantity int64, at time.Time, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveRequesterDemand")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
 
-	r := Reservation{}
-	err := tx.QueryRow(ctx,
-		`SELECT `+reservationFields+` FROM reservations WHERE id = $1 `+forUpdate, ID).
-		Scan(&r.ID, &r.RequestID, &r.Requester, &r.Sku, &r.State, &r.ReservedQuantity, &r.RequestedQuantity, &r.Created)
+	insert := `INSERT INTO requester_demand (requester, reservation_id, quantity, created) VALUES ($1, $2, $3, $4);`
+	if _, err := tx.Exec(ctx, insert, requester, reservationID, quantity, at); err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) SaveRequesterLimits(ctx context.Context, l RequesterLimits, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("SaveRequesterLimits")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	upsert := `INSERT INTO requester_limits (requester, class, max_reserved_per_sku, max_open_reservations, daily_cap) VALUES ($1, $2, $3, $4, $5)
+                    ON CONFLICT (requester) DO UPDATE SET class = EXCLUDED.class, max_reserved_per_sku = EXCLUDED.max_reserved_per_sku,
+                    max_open_reservations = EXCLUDED.max_open_reservations, daily_cap = EXCLUDED.daily_cap;`
+	if _, err := tx.Exec(ctx, upsert, l.Requester, l.Class, l.MaxReservedPerSku, l.MaxOpenReservations, l.DailyCap); err != nil {
+		m.Complete(err)
+		return err
+	}
+	m.Complete(nil)
+	return nil
+}
+
+func (d *dbRepo) DeleteRequesterLimits(ctx context.Context, requester string, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("DeleteRequesterLimits")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
+
+	ct, err := tx.Exec(ctx, `DELETE FROM requester_limits WHERE requester = $1;`, requester)
 	if err != nil {
 		m.Complete(err)
-		if errors.Is(err, pgx.ErrNoRows) {
-			return r, persistence.ErrNotFound
-		}
-		return r, err
+		return err
 	}
+	if ct.RowsAffected() == 0 {
+		m.Complete(persistence.ErrNotFound)
+		return persistence.ErrNotFound
+	}
+	m.Complete(nil)
+	return nil
+}
+
+// LockRequester takes a transaction-scoped advisory lock keyed on the
+// requester. Requesters with no reservations yet have no row to lock,
+// and locking a requester_limits row would serialize every requester
+// sharing the defaults.
+func (d *dbRepo) LockRequester(ctx context.Context, requester string, options ...persistence.UpdateOptions) error {
+	m := persistence.StartMetric("LockRequester")
+	tx := persistence.GetUpdateOptions(d.conn, options...)
 
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('requester_limits:' || $1));`, requester); err != nil {
+		m.Complete(err)
+		return err
+	}
 	m.Complete(nil)
-	return r, nil
+	return nil
 }
 
 func (d *dbRepo) BeginTransaction(ctx context.Context) (persistence.Transaction, error) {
@@ -2,6 +2,7 @@ package inventory
 
 import (
 	"context"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/sksmith/go-micro-example/internal/platform/persistence"
@@ -29,8 +30,19 @@ type Transactional interface {
 type Repository interface {
 	ProductionEventRepository
 	ReservationRepository
+	ShipmentRepository
 	InventoryRepository
+	MovementRepository
+	AdjustmentRepository
+	StatusChangeRepository
+	TransferRepository
 	ProductRepository
+	BOMRepository
+	LotRepository
+	ReorderRepository
+	PlannedOrderRepository
+	ReturnRepository
+	RequesterLimitRepository
 }
 
 type ProductionEventRepository interface {
@@ -43,32 +55,167 @@ type ProductionEventRepository interface {
 type ReservationRepository interface {
 	Transactional
 	GetReservations(ctx context.Context, resOptions GetReservationsOptions, limit, offset int, options ...persistence.QueryOptions) ([]Reservation, error)
+	GetReservationsAfter(ctx context.Context, resOptions GetReservationsOptions, after *ReservationKey, limit int, options ...persistence.QueryOptions) ([]Reservation, error)
 	GetReservationByRequestID(ctx context.Context, requestId string, options ...persistence.QueryOptions) (Reservation, error)
 	GetReservation(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Reservation, error)
+	GetExpiredReservations(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Reservation, error)
+	GetBackorders(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Backorder, error)
+	// ExportReservations calls fn with every reservation matching
+	// resOptions through a cursor, which needs the options' Tx.
+	ExportReservations(ctx context.Context, resOptions GetReservationsOptions, fn func(Reservation) error, options ...persistence.QueryOptions) error
 
 	SaveReservation(ctx context.Context, reservation *Reservation, options ...persistence.UpdateOptions) error
 	UpdateReservation(ctx context.Context, ID uint64, state ReserveState, qty int64, options ...persistence.UpdateOptions) error
+	UpdateReservationShipment(ctx context.Context, ID uint64, state ReserveState, shipped int64, options ...persistence.UpdateOptions) error
+	UpdateReservationQuantity(ctx context.Context, ID uint64, state ReserveState, requested, reserved int64, options ...persistence.UpdateOptions) error
+}
+
+type ShipmentRepository interface {
+	Transactional
+	GetShipmentByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Shipment, error)
+
+	SaveShipment(ctx context.Context, shipment *Shipment, options ...persistence.UpdateOptions) error
 }
 
 type InventoryRepository interface {
 	Transactional
 	GetProductInventory(ctx context.Context, sku string, options ...persistence.QueryOptions) (pi ProductInventory, err error)
-	GetAllProductInventory(ctx context.Context, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error)
+	GetAllProductInventory(ctx context.Context, listOptions ProductListOptions, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error)
+	GetAllProductInventoryAfter(ctx context.Context, listOptions ProductListOptions, after *ProductKey, limit int, options ...persistence.QueryOptions) ([]ProductInventory, error)
+	GetProductInventoryAsOf(ctx context.Context, sku string, asOf time.Time, options ...persistence.QueryOptions) (ProductInventory, error)
+	GetAllProductInventoryAsOf(ctx context.Context, asOf time.Time, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error)
+	GetProductCreated(ctx context.Context, sku string, options ...persistence.QueryOptions) (time.Time, error)
+	GetInventoryHistoryStart(ctx context.Context, options ...persistence.QueryOptions) (time.Time, error)
+	// ExportProductInventory calls fn with every product's inventory
+	// through a cursor, which needs the options' Tx.
+	ExportProductInventory(ctx context.Context, fn func(ProductInventory) error, options ...persistence.QueryOptions) error
 
 	SaveProductInventory(ctx context.Context, productInventory ProductInventory, options ...persistence.UpdateOptions) error
 }
 
+type MovementRepository interface {
+	Transactional
+	GetInventoryMovements(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]InventoryMovement, error)
+
+	SaveInventoryMovement(ctx context.Context, movement *InventoryMovement, options ...persistence.UpdateOptions) error
+}
+
+type AdjustmentRepository interface {
+	Transactional
+	GetAdjustmentByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Adjustment, error)
+
+	SaveAdjustment(ctx context.Context, adjustment *Adjustment, options ...persistence.UpdateOptions) error
+}
+
+type StatusChangeRepository interface {
+	Transactional
+	GetStatusChangeByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (StatusChange, error)
+
+	SaveStatusChange(ctx context.Context, change *StatusChange, options ...persistence.UpdateOptions) error
+}
+
+type TransferRepository interface {
+	Transactional
+	GetTransfer(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Transfer, error)
+	GetTransferByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Transfer, error)
+	GetTransfers(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Transfer, error)
+
+	SaveTransfer(ctx context.Context, transfer *Transfer, options ...persistence.UpdateOptions) error
+	UpdateTransfer(ctx context.Context, ID uint64, state TransferState, updated time.Time, options ...persistence.UpdateOptions) error
+}
+
 type ProductRepository interface {
 	Transactional
 	GetProduct(ctx context.Context, sku string, options ...persistence.QueryOptions) (Product, error)
+	GetConflictingProducts(ctx context.Context, skus, upcs []string, options ...persistence.QueryOptions) ([]Product, error)
 
 	SaveProduct(ctx context.Context, product Product, options ...persistence.UpdateOptions) error
+	// ImportProducts writes new products with their opening stock and
+	// its ledger entries in bulk.
+	ImportProducts(ctx context.Context, products []ProductImport, actor string, at time.Time, options ...persistence.UpdateOptions) error
+}
+
+type BOMRepository interface {
+	Transactional
+	GetBillOfMaterials(ctx context.Context, sku string, options ...persistence.QueryOptions) (BillOfMaterials, error)
+
+	SaveBillOfMaterials(ctx context.Context, bom BillOfMaterials, options ...persistence.UpdateOptions) error
+}
+
+type LotRepository interface {
+	Transactional
+	GetLots(ctx context.Context, sku string, options ...persistence.QueryOptions) ([]Lot, error)
+	GetExpiredLots(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Lot, error)
+	GetReservationLots(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) ([]LotDraw, error)
+	GetTransferLots(ctx context.Context, transferID uint64, options ...persistence.QueryOptions) ([]LotDraw, error)
+	GetLotRecipients(ctx context.Context, sku, lot string, limit, offset int, options ...persistence.QueryOptions) ([]LotRecipient, error)
+
+	SaveLots(ctx context.Context, lots []Lot, options ...persistence.UpdateOptions) error
+	SaveReservationLots(ctx context.Context, reservationID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error
+	SaveTransferLots(ctx context.Context, transferID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error
+}
+
+type ReorderRepository interface {
+	Transactional
+	GetReorderPolicy(ctx context.Context, sku string, options ...persistence.QueryOptions) (ReorderPolicy, error)
+	GetLowStock(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]LowStock, error)
+
+	SaveReorderPolicy(ctx context.Context, policy ReorderPolicy, options ...persistence.UpdateOptions) error
+	DeleteReorderPolicy(ctx context.Context, sku string, options ...persistence.UpdateOptions) error
+	// UpdateLowStock records whether sku's available stock is below its
+	// reorder point and returns the policy only if that flipped it. A
+	// returned policy with LowSince set has just crossed below.
+	UpdateLowStock(ctx context.Context, sku string, available int64, at time.Time, options ...persistence.UpdateOptions) (ReorderPolicy, bool, error)
+}
+
+type PlannedOrderRepository interface {
+	Transactional
+	GetPlannedOrderByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (PlannedOrder, error)
+	// GetScheduledOrders pages over sku's Scheduled planned orders,
+	// earliest expected first.
+	GetScheduledOrders(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]PlannedOrder, error)
+
+	SavePlannedOrder(ctx context.Context, order *PlannedOrder, options ...persistence.UpdateOptions) error
+	UpdatePlannedOrder(ctx context.Context, ID uint64, state PlannedOrderState, productionRequestID string, updated time.Time, options ...persistence.UpdateOptions) error
+}
+
+type ReturnRepository interface {
+	Transactional
+	GetReturnByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Return, error)
+	GetReturns(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Return, error)
+	// GetReturnedQuantity totals the units already returned against a
+	// reservation.
+	GetReturnedQuantity(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) (int64, error)
+
+	SaveReturn(ctx context.Context, ret *Return, options ...persistence.UpdateOptions) error
+}
+
+type RequesterLimitRepository interface {
+	Transactional
+	GetRequesterLimits(ctx context.Context, requester string, options ...persistence.QueryOptions) (RequesterLimits, error)
+	GetAllRequesterLimits(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]RequesterLimits, error)
+	// GetRequesterUsage totals requester's outstanding reservations,
+	// those for sku, and the units it has reserved or raised its
+	// reservations by since since.
+	GetRequesterUsage(ctx context.Context, requester, sku string, since time.Time, options ...persistence.QueryOptions) (RequesterUsage, error)
+	// SaveRequesterDemand records a change of quantity to what one of
+	// requester's reservations asks for, which GetRequesterUsage sums.
+	SaveRequesterDemand(ctx context.Context, requester string, reservationID uint64, quantity int64, at time.Time, options ...persistence.UpdateOptions) error
+
+	SaveRequesterLimits(ctx context.Context, limits RequesterLimits, options ...persistence.UpdateOptions) error
+	DeleteRequesterLimits(ctx context.Context, requester string, options ...persistence.UpdateOptions) error
+	// LockRequester holds a lock on requester until tx ends, so
+	// concurrent reservations by one requester are checked against its
+	// limits one at a time, whatever SKU they are for.
+	LockRequester(ctx context.Context, requester string, options ...persistence.UpdateOptions) error
 }
 
 // InventoryPublisher is the seam the service uses to emit
-// inventory/reservation events. The concrete *InventoryQueue in
+// inventory/reservation/product events. The concrete *InventoryQueue in
 // transport_queue.go satisfies it; tests pass in fakes.
 type InventoryPublisher interface {
 	PublishInventory(ctx context.Context, productInventory ProductInventory) error
 	PublishReservation(ctx context.Context, reservation Reservation) error
+	PublishProduct(ctx context.Context, product Product) error
+	PublishLowStock(ctx context.Context, lowStock LowStock) error
 }
@@ -2,6 +2,7 @@ package inventory
 
 import (
 	"context"
+	"time"
 
 	"github.com/sksmith/go-micro-example/internal/platform/persistence"
 )
@@ -12,16 +13,83 @@ type MockRepo struct {
 
 	GetReservationFunc            func(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Reservation, error)
 	GetReservationsFunc           func(ctx context.Context, resOptions GetReservationsOptions, limit, offset int, options ...persistence.QueryOptions) ([]Reservation, error)
+	GetReservationsAfterFunc      func(ctx context.Context, resOptions GetReservationsOptions, after *ReservationKey, limit int, options ...persistence.QueryOptions) ([]Reservation, error)
 	GetReservationByRequestIDFunc func(ctx context.Context, requestId string, options ...persistence.QueryOptions) (Reservation, error)
 	UpdateReservationFunc         func(ctx context.Context, ID uint64, state ReserveState, qty int64, options ...persistence.UpdateOptions) error
 	SaveReservationFunc           func(ctx context.Context, reservation *Reservation, options ...persistence.UpdateOptions) error
+	GetExpiredReservationsFunc    func(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Reservation, error)
+	GetBackordersFunc             func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Backorder, error)
+	UpdateReservationShipmentFunc func(ctx context.Context, ID uint64, state ReserveState, shipped int64, options ...persistence.UpdateOptions) error
+	UpdateReservationQuantityFunc func(ctx context.Context, ID uint64, state ReserveState, requested, reserved int64, options ...persistence.UpdateOptions) error
+
+	GetShipmentByRequestIDFunc func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Shipment, error)
+	SaveShipmentFunc           func(ctx context.Context, shipment *Shipment, options ...persistence.UpdateOptions) error
 
 	GetProductFunc  func(ctx context.Context, sku string, options ...persistence.QueryOptions) (Product, error)
 	SaveProductFunc func(ctx context.Context, product Product, options ...persistence.UpdateOptions) error
 
-	GetProductInventoryFunc    func(ctx context.Context, sku string, options ...persistence.QueryOptions) (ProductInventory, error)
-	GetAllProductInventoryFunc func(ctx context.Context, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error)
-	SaveProductInventoryFunc   func(ctx context.Context, productInventory ProductInventory, options ...persistence.UpdateOptions) error
+	ExportProductInventoryFunc func(ctx context.Context, fn func(ProductInventory) error, options ...persistence.QueryOptions) error
+	ExportReservationsFunc     func(ctx context.Context, resOptions GetReservationsOptions, fn func(Reservation) error, options ...persistence.QueryOptions) error
+
+	GetConflictingProductsFunc func(ctx context.Context, skus, upcs []string, options ...persistence.QueryOptions) ([]Product, error)
+	ImportProductsFunc         func(ctx context.Context, products []ProductImport, actor string, at time.Time, options ...persistence.UpdateOptions) error
+
+	GetProductInventoryFunc         func(ctx context.Context, sku string, options ...persistence.QueryOptions) (ProductInventory, error)
+	GetAllProductInventoryFunc      func(ctx context.Context, listOptions ProductListOptions, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error)
+	GetAllProductInventoryAfterFunc func(ctx context.Context, listOptions ProductListOptions, after *ProductKey, limit int, options ...persistence.QueryOptions) ([]ProductInventory, error)
+	SaveProductInventoryFunc        func(ctx context.Context, productInventory ProductInventory, options ...persistence.UpdateOptions) error
+
+	GetProductInventoryAsOfFunc    func(ctx context.Context, sku string, asOf time.Time, options ...persistence.QueryOptions) (ProductInventory, error)
+	GetAllProductInventoryAsOfFunc func(ctx context.Context, asOf time.Time, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error)
+	GetProductCreatedFunc          func(ctx context.Context, sku string, options ...persistence.QueryOptions) (time.Time, error)
+	GetInventoryHistoryStartFunc   func(ctx context.Context, options ...persistence.QueryOptions) (time.Time, error)
+
+	GetAdjustmentByRequestIDFunc   func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Adjustment, error)
+	SaveAdjustmentFunc             func(ctx context.Context, adjustment *Adjustment, options ...persistence.UpdateOptions) error
+	GetStatusChangeByRequestIDFunc func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (StatusChange, error)
+	SaveStatusChangeFunc           func(ctx context.Context, change *StatusChange, options ...persistence.UpdateOptions) error
+
+	GetTransferFunc            func(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Transfer, error)
+	GetTransferByRequestIDFunc func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Transfer, error)
+	GetTransfersFunc           func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Transfer, error)
+	SaveTransferFunc           func(ctx context.Context, transfer *Transfer, options ...persistence.UpdateOptions) error
+	UpdateTransferFunc         func(ctx context.Context, ID uint64, state TransferState, updated time.Time, options ...persistence.UpdateOptions) error
+
+	GetInventoryMovementsFunc func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]InventoryMovement, error)
+	SaveInventoryMovementFunc func(ctx context.Context, movement *InventoryMovement, options ...persistence.UpdateOptions) error
+
+	GetBillOfMaterialsFunc  func(ctx context.Context, sku string, options ...persistence.QueryOptions) (BillOfMaterials, error)
+	SaveBillOfMaterialsFunc func(ctx context.Context, bom BillOfMaterials, options ...persistence.UpdateOptions) error
+
+	GetLotsFunc             func(ctx context.Context, sku string, options ...persistence.QueryOptions) ([]Lot, error)
+	GetExpiredLotsFunc      func(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Lot, error)
+	GetReservationLotsFunc  func(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) ([]LotDraw, error)
+	GetTransferLotsFunc     func(ctx context.Context, transferID uint64, options ...persistence.QueryOptions) ([]LotDraw, error)
+	GetLotRecipientsFunc    func(ctx context.Context, sku, lot string, limit, offset int, options ...persistence.QueryOptions) ([]LotRecipient, error)
+	SaveLotsFunc            func(ctx context.Context, lots []Lot, options ...persistence.UpdateOptions) error
+	SaveReservationLotsFunc func(ctx context.Context, reservationID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error
+	SaveTransferLotsFunc    func(ctx context.Context, transferID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error
+
+	GetPlannedOrderByRequestIDFunc func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (PlannedOrder, error)
+	GetScheduledOrdersFunc         func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]PlannedOrder, error)
+	SavePlannedOrderFunc           func(ctx context.Context, order *PlannedOrder, options ...persistence.UpdateOptions) error
+	UpdatePlannedOrderFunc         func(ctx context.Context, ID uint64, state PlannedOrderState, productionRequestID string, updated time.Time, options ...persistence.UpdateOptions) error
+	GetReturnByRequestIDFunc       func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Return, error)
+	GetReturnsFunc                 func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Return, error)
+	GetReturnedQuantityFunc        func(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) (int64, error)
+	SaveReturnFunc                 func(ctx context.Context, ret *Return, options ...persistence.UpdateOptions) error
+	GetRequesterLimitsFunc         func(ctx context.Context, requester string, options ...persistence.QueryOptions) (RequesterLimits, error)
+	GetAllRequesterLimitsFunc      func(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]RequesterLimits, error)
+	GetRequesterUsageFunc          func(ctx context.Context, requester, sku string, since time.Time, options ...persistence.QueryOptions) (RequesterUsage, error)
+	SaveRequesterDemandFunc        func(ctx context.Context, requester string, reservationID uint64, quantity int64, at time.Time, options ...persistence.UpdateOptions) error
+	SaveRequesterLimitsFunc        func(ctx context.Context, limits RequesterLimits, options ...persistence.UpdateOptions) error
+	DeleteRequesterLimitsFunc      func(ctx context.Context, requester string, options ...persistence.UpdateOptions) error
+	LockRequesterFunc              func(ctx context.Context, requester string, options ...persistence.UpdateOptions) error
+	GetReorderPolicyFunc           func(ctx context.Context, sku string, options ...persistence.QueryOptions) (ReorderPolicy, error)
+	GetLowStockFunc                func(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]LowStock, error)
+	SaveReorderPolicyFunc          func(ctx context.Context, policy ReorderPolicy, options ...persistence.UpdateOptions) error
+	DeleteReorderPolicyFunc        func(ctx context.Context, sku string, options ...persistence.UpdateOptions) error
+	UpdateLowStockFunc             func(ctx context.Context, sku string, available int64, at time.Time, options ...persistence.UpdateOptions) (ReorderPolicy, bool, error)
 
 	BeginTransactionFunc func(ctx context.Context) (persistence.Transaction, error)
 
@@ -29,14 +97,71 @@ type MockRepo struct {
 	SaveProductionEventCalls           int
 	GetReservationCalls                int
 	GetReservationsCalls               int
+	GetReservationsAfterCalls          int
 	GetReservationByRequestIDCalls     int
 	UpdateReservationCalls             int
 	SaveReservationCalls               int
+	GetExpiredReservationsCalls        int
+	GetBackordersCalls                 int
+	UpdateReservationShipmentCalls     int
+	UpdateReservationQuantityCalls     int
+	GetShipmentByRequestIDCalls        int
+	SaveShipmentCalls                  int
 	GetProductCalls                    int
 	SaveProductCalls                   int
+	ExportProductInventoryCalls        int
+	ExportReservationsCalls            int
+	GetConflictingProductsCalls        int
+	ImportProductsCalls                int
 	GetProductInventoryCalls           int
 	GetAllProductInventoryCalls        int
+	GetAllProductInventoryAfterCalls   int
 	SaveProductInventoryCalls          int
+	GetProductInventoryAsOfCalls       int
+	GetAllProductInventoryAsOfCalls    int
+	GetProductCreatedCalls             int
+	GetInventoryHistoryStartCalls      int
+	GetAdjustmentByRequestIDCalls      int
+	SaveAdjustmentCalls                int
+	GetStatusChangeByRequestIDCalls    int
+	SaveStatusChangeCalls              int
+	GetTransferCalls                   int
+	GetTransferByRequestIDCalls        int
+	GetTransfersCalls                  int
+	SaveTransferCalls                  int
+	UpdateTransferCalls                int
+	GetInventoryMovementsCalls         int
+	SaveInventoryMovementCalls         int
+	GetBillOfMaterialsCalls            int
+	SaveBillOfMaterialsCalls           int
+	GetLotsCalls                       int
+	GetExpiredLotsCalls                int
+	GetReservationLotsCalls            int
+	GetTransferLotsCalls               int
+	GetLotRecipientsCalls              int
+	SaveLotsCalls                      int
+	SaveReservationLotsCalls           int
+	SaveTransferLotsCalls              int
+	GetPlannedOrderByRequestIDCalls    int
+	GetScheduledOrdersCalls            int
+	SavePlannedOrderCalls              int
+	UpdatePlannedOrderCalls            int
+	GetReturnByRequestIDCalls          int
+	GetReturnsCalls                    int
+	GetReturnedQuantityCalls           int
+	SaveReturnCalls                    int
+	GetRequesterLimitsCalls            int
+	GetAllRequesterLimitsCalls         int
+	GetRequesterUsageCalls             int
+	SaveRequesterDemandCalls           int
+	SaveRequesterLimitsCalls           int
+	DeleteRequesterLimitsCalls         int
+	LockRequesterCalls                 int
+	GetReorderPolicyCalls              int
+	GetLowStockCalls                   int
+	SaveReorderPolicyCalls             int
+	DeleteReorderPolicyCalls           int
+	UpdateLowStockCalls                int
 	BeginTransactionCalls              int
 }
 
@@ -70,6 +195,41 @@ func (r *MockRepo) GetReservations(ctx context.Context, resOptions GetReservatio
 	return r.GetReservationsFunc(ctx, resOptions, limit, offset, options...)
 }
 
+func (r *MockRepo) GetReservationsAfter(ctx context.Context, resOptions GetReservationsOptions, after *ReservationKey, limit int, options ...persistence.QueryOptions) ([]Reservation, error) {
+	r.GetReservationsAfterCalls++
+	return r.GetReservationsAfterFunc(ctx, resOptions, after, limit, options...)
+}
+
+func (r *MockRepo) GetBackorders(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Backorder, error) {
+	r.GetBackordersCalls++
+	return r.GetBackordersFunc(ctx, sku, limit, offset, options...)
+}
+
+func (r *MockRepo) GetExpiredReservations(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Reservation, error) {
+	r.GetExpiredReservationsCalls++
+	return r.GetExpiredReservationsFunc(ctx, asOf, limit, options...)
+}
+
+func (r *MockRepo) UpdateReservationQuantity(ctx context.Context, ID uint64, state ReserveState, requested, reserved int64, options ...persistence.UpdateOptions) error {
+	r.UpdateReservationQuantityCalls++
+	return r.UpdateReservationQuantityFunc(ctx, ID, state, requested, reserved, options...)
+}
+
+func (r *MockRepo) UpdateReservationShipment(ctx context.Context, ID uint64, state ReserveState, shipped int64, options ...persistence.UpdateOptions) error {
+	r.UpdateReservationShipmentCalls++
+	return r.UpdateReservationShipmentFunc(ctx, ID, state, shipped, options...)
+}
+
+func (r *MockRepo) GetShipmentByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Shipment, error) {
+	r.GetShipmentByRequestIDCalls++
+	return r.GetShipmentByRequestIDFunc(ctx, requestID, options...)
+}
+
+func (r *MockRepo) SaveShipment(ctx context.Context, shipment *Shipment, options ...persistence.UpdateOptions) error {
+	r.SaveShipmentCalls++
+	return r.SaveShipmentFunc(ctx, shipment, options...)
+}
+
 func (r *MockRepo) SaveProduct(ctx context.Context, product Product, options ...persistence.UpdateOptions) error {
 	r.SaveProductCalls++
 	return r.SaveProductFunc(ctx, product, options...)
@@ -80,6 +240,26 @@ func (r *MockRepo) GetProduct(ctx context.Context, sku string, options ...persis
 	return r.GetProductFunc(ctx, sku, options...)
 }
 
+func (r *MockRepo) GetConflictingProducts(ctx context.Context, skus, upcs []string, options ...persistence.QueryOptions) ([]Product, error) {
+	r.GetConflictingProductsCalls++
+	return r.GetConflictingProductsFunc(ctx, skus, upcs, options...)
+}
+
+func (r *MockRepo) ImportProducts(ctx context.Context, products []ProductImport, actor string, at time.Time, options ...persistence.UpdateOptions) error {
+	r.ImportProductsCalls++
+	return r.ImportProductsFunc(ctx, products, actor, at, options...)
+}
+
+func (r *MockRepo) ExportProductInventory(ctx context.Context, fn func(ProductInventory) error, options ...persistence.QueryOptions) error {
+	r.ExportProductInventoryCalls++
+	return r.ExportProductInventoryFunc(ctx, fn, options...)
+}
+
+func (r *MockRepo) ExportReservations(ctx context.Context, resOptions GetReservationsOptions, fn func(Reservation) error, options ...persistence.QueryOptions) error {
+	r.ExportReservationsCalls++
+	return r.ExportReservationsFunc(ctx, resOptions, fn, options...)
+}
+
 func (r *MockRepo) GetProductInventory(ctx context.Context, sku string, options ...persistence.QueryOptions) (ProductInventory, error) {
 	r.GetProductInventoryCalls++
 	return r.GetProductInventoryFunc(ctx, sku, options...)
@@ -90,9 +270,239 @@ func (r *MockRepo) SaveProductInventory(ctx context.Context, productInventory Pr
 	return r.SaveProductInventoryFunc(ctx, productInventory, options...)
 }
 
-func (r *MockRepo) GetAllProductInventory(ctx context.Context, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+func (r *MockRepo) GetAllProductInventory(ctx context.Context, listOptions ProductListOptions, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
 	r.GetAllProductInventoryCalls++
-	return r.GetAllProductInventoryFunc(ctx, limit, offset, options...)
+	return r.GetAllProductInventoryFunc(ctx, listOptions, limit, offset, options...)
+}
+
+func (r *MockRepo) GetAllProductInventoryAfter(ctx context.Context, listOptions ProductListOptions, after *ProductKey, limit int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+	r.GetAllProductInventoryAfterCalls++
+	return r.GetAllProductInventoryAfterFunc(ctx, listOptions, after, limit, options...)
+}
+
+func (r *MockRepo) GetProductInventoryAsOf(ctx context.Context, sku string, asOf time.Time, options ...persistence.QueryOptions) (ProductInventory, error) {
+	r.GetProductInventoryAsOfCalls++
+	return r.GetProductInventoryAsOfFunc(ctx, sku, asOf, options...)
+}
+
+func (r *MockRepo) GetAllProductInventoryAsOf(ctx context.Context, asOf time.Time, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+	r.GetAllProductInventoryAsOfCalls++
+	return r.GetAllProductInventoryAsOfFunc(ctx, asOf, limit, offset, options...)
+}
+
+func (r *MockRepo) GetProductCreated(ctx context.Context, sku string, options ...persistence.QueryOptions) (time.Time, error) {
+	r.GetProductCreatedCalls++
+	return r.GetProductCreatedFunc(ctx, sku, options...)
+}
+
+func (r *MockRepo) GetInventoryHistoryStart(ctx context.Context, options ...persistence.QueryOptions) (time.Time, error) {
+	r.GetInventoryHistoryStartCalls++
+	return r.GetInventoryHistoryStartFunc(ctx, options...)
+}
+
+func (r *MockRepo) GetAdjustmentByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Adjustment, error) {
+	r.GetAdjustmentByRequestIDCalls++
+	return r.GetAdjustmentByRequestIDFunc(ctx, requestID, options...)
+}
+
+func (r *MockRepo) SaveAdjustment(ctx context.Context, adjustment *Adjustment, options ...persistence.UpdateOptions) error {
+	r.SaveAdjustmentCalls++
+	return r.SaveAdjustmentFunc(ctx, adjustment, options...)
+}
+
+func (r *MockRepo) GetStatusChangeByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (StatusChange, error) {
+	r.GetStatusChangeByRequestIDCalls++
+	return r.GetStatusChangeByRequestIDFunc(ctx, requestID, options...)
+}
+
+func (r *MockRepo) SaveStatusChange(ctx context.Context, change *StatusChange, options ...persistence.UpdateOptions) error {
+	r.SaveStatusChangeCalls++
+	return r.SaveStatusChangeFunc(ctx, change, options...)
+}
+
+func (r *MockRepo) GetTransfer(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Transfer, error) {
+	r.GetTransferCalls++
+	return r.GetTransferFunc(ctx, ID, options...)
+}
+
+func (r *MockRepo) GetTransferByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Transfer, error) {
+	r.GetTransferByRequestIDCalls++
+	return r.GetTransferByRequestIDFunc(ctx, requestID, options...)
+}
+
+func (r *MockRepo) GetTransfers(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Transfer, error) {
+	r.GetTransfersCalls++
+	return r.GetTransfersFunc(ctx, sku, limit, offset, options...)
+}
+
+func (r *MockRepo) SaveTransfer(ctx context.Context, transfer *Transfer, options ...persistence.UpdateOptions) error {
+	r.SaveTransferCalls++
+	return r.SaveTransferFunc(ctx, transfer, options...)
+}
+
+func (r *MockRepo) UpdateTransfer(ctx context.Context, ID uint64, state TransferState, updated time.Time, options ...persistence.UpdateOptions) error {
+	r.UpdateTransferCalls++
+	return r.UpdateTransferFunc(ctx, ID, state, updated, options...)
+}
+
+func (r *MockRepo) GetInventoryMovements(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]InventoryMovement, error) {
+	r.GetInventoryMovementsCalls++
+	return r.GetInventoryMovementsFunc(ctx, sku, limit, offset, options...)
+}
+
+func (r *MockRepo) SaveInventoryMovement(ctx context.Context, movement *InventoryMovement, options ...persistence.UpdateOptions) error {
+	r.SaveInventoryMovementCalls++
+	return r.SaveInventoryMovementFunc(ctx, movement, options...)
+}
+
+func (r *MockRepo) GetBillOfMaterials(ctx context.Context, sku string, options ...persistence.QueryOptions) (BillOfMaterials, error) {
+	r.GetBillOfMaterialsCalls++
+	return r.GetBillOfMaterialsFunc(ctx, sku, options...)
+}
+
+func (r *MockRepo) SaveBillOfMaterials(ctx context.Context, bom BillOfMaterials, options ...persistence.UpdateOptions) error {
+	r.SaveBillOfMaterialsCalls++
+	return r.SaveBillOfMaterialsFunc(ctx, bom, options...)
+}
+
+func (r *MockRepo) GetLots(ctx context.Context, sku string, options ...persistence.QueryOptions) ([]Lot, error) {
+	r.GetLotsCalls++
+	return r.GetLotsFunc(ctx, sku, options...)
+}
+
+func (r *MockRepo) GetExpiredLots(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Lot, error) {
+	r.GetExpiredLotsCalls++
+	return r.GetExpiredLotsFunc(ctx, asOf, limit, options...)
+}
+
+func (r *MockRepo) GetReservationLots(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) ([]LotDraw, error) {
+	r.GetReservationLotsCalls++
+	return r.GetReservationLotsFunc(ctx, reservationID, options...)
+}
+
+func (r *MockRepo) GetTransferLots(ctx context.Context, transferID uint64, options ...persistence.QueryOptions) ([]LotDraw, error) {
+	r.GetTransferLotsCalls++
+	return r.GetTransferLotsFunc(ctx, transferID, options...)
+}
+
+func (r *MockRepo) GetLotRecipients(ctx context.Context, sku, lot string, limit, offset int, options ...persistence.QueryOptions) ([]LotRecipient, error) {
+	r.GetLotRecipientsCalls++
+	return r.GetLotRecipientsFunc(ctx, sku, lot, limit, offset, options...)
+}
+
+func (r *MockRepo) SaveLots(ctx context.Context, lots []Lot, options ...persistence.UpdateOptions) error {
+	r.SaveLotsCalls++
+	return r.SaveLotsFunc(ctx, lots, options...)
+}
+
+func (r *MockRepo) SaveReservationLots(ctx context.Context, reservationID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error {
+	r.SaveReservationLotsCalls++
+	return r.SaveReservationLotsFunc(ctx, reservationID, draws, options...)
+}
+
+func (r *MockRepo) SaveTransferLots(ctx context.Context, transferID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error {
+	r.SaveTransferLotsCalls++
+	return r.SaveTransferLotsFunc(ctx, transferID, draws, options...)
+}
+
+func (r *MockRepo) GetPlannedOrderByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (PlannedOrder, error) {
+	r.GetPlannedOrderByRequestIDCalls++
+	return r.GetPlannedOrderByRequestIDFunc(ctx, requestID, options...)
+}
+
+func (r *MockRepo) GetScheduledOrders(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]PlannedOrder, error) {
+	r.GetScheduledOrdersCalls++
+	return r.GetScheduledOrdersFunc(ctx, sku, limit, offset, options...)
+}
+
+func (r *MockRepo) SavePlannedOrder(ctx context.Context, order *PlannedOrder, options ...persistence.UpdateOptions) error {
+	r.SavePlannedOrderCalls++
+	return r.SavePlannedOrderFunc(ctx, order, options...)
+}
+
+func (r *MockRepo) UpdatePlannedOrder(ctx context.Context, ID uint64, state PlannedOrderState, productionRequestID string, updated time.Time, options ...persistence.UpdateOptions) error {
+	r.UpdatePlannedOrderCalls++
+	return r.UpdatePlannedOrderFunc(ctx, ID, state, productionRequestID, updated, options...)
+}
+
+func (r *MockRepo) GetReturnByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Return, error) {
+	r.GetReturnByRequestIDCalls++
+	return r.GetReturnByRequestIDFunc(ctx, requestID, options...)
+}
+
+func (r *MockRepo) GetReturns(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Return, error) {
+	r.GetReturnsCalls++
+	return r.GetReturnsFunc(ctx, sku, limit, offset, options...)
+}
+
+func (r *MockRepo) GetReturnedQuantity(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) (int64, error) {
+	r.GetReturnedQuantityCalls++
+	return r.GetReturnedQuantityFunc(ctx, reservationID, options...)
+}
+
+func (r *MockRepo) SaveReturn(ctx context.Context, ret *Return, options ...persistence.UpdateOptions) error {
+	r.SaveReturnCalls++
+	return r.SaveReturnFunc(ctx, ret, options...)
+}
+
+func (r *MockRepo) GetRequesterLimits(ctx context.Context, requester string, options ...persistence.QueryOptions) (RequesterLimits, error) {
+	r.GetRequesterLimitsCalls++
+	return r.GetRequesterLimitsFunc(ctx, requester, options...)
+}
+
+func (r *MockRepo) GetAllRequesterLimits(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]RequesterLimits, error) {
+	r.GetAllRequesterLimitsCalls++
+	return r.GetAllRequesterLimitsFunc(ctx, limit, offset, options...)
+}
+
+func (r *MockRepo) GetRequesterUsage(ctx context.Context, requester, sku string, since time.Time, options ...persistence.QueryOptions) (RequesterUsage, error) {
+	r.GetRequesterUsageCalls++
+	return r.GetRequesterUsageFunc(ctx, requester, sku, since, options...)
+}
+
+func (r *MockRepo) SaveRequesterDemand(ctx context.Context, requester string, reservationID uint64, quantity int64, at time.Time, options ...persistence.UpdateOptions) error {
+	r.SaveRequesterDemandCalls++
+	return r.SaveRequesterDemandFunc(ctx, requester, reservationID, quantity, at, options...)
+}
+
+func (r *MockRepo) SaveRequesterLimits(ctx context.Context, limits RequesterLimits, options ...persistence.UpdateOptions) error {
+	r.SaveRequesterLimitsCalls++
+	return r.SaveRequesterLimitsFunc(ctx, limits, options...)
+}
+
+func (r *MockRepo) DeleteRequesterLimits(ctx context.Context, requester string, options ...persistence.UpdateOptions) error {
+	r.DeleteRequesterLimitsCalls++
+	return r.DeleteRequesterLimitsFunc(ctx, requester, options...)
+}
+
+func (r *MockRepo) LockRequester(ctx context.Context, requester string, options ...persistence.UpdateOptions) error {
+	r.LockRequesterCalls++
+	return r.LockRequesterFunc(ctx, requester, options...)
+}
+
+func (r *MockRepo) GetReorderPolicy(ctx context.Context, sku string, options ...persistence.QueryOptions) (ReorderPolicy, error) {
+	r.GetReorderPolicyCalls++
+	return r.GetReorderPolicyFunc(ctx, sku, options...)
+}
+
+func (r *MockRepo) GetLowStock(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]LowStock, error) {
+	r.GetLowStockCalls++
+	return r.GetLowStockFunc(ctx, limit, offset, options...)
+}
+
+func (r *MockRepo) SaveReorderPolicy(ctx context.Context, policy ReorderPolicy, options ...persistence.UpdateOptions) error {
+	r.SaveReorderPolicyCalls++
+	return r.SaveReorderPolicyFunc(ctx, policy, options...)
+}
+
+func (r *MockRepo) DeleteReorderPolicy(ctx context.Context, sku string, options ...persistence.UpdateOptions) error {
+	r.DeleteReorderPolicyCalls++
+	return r.DeleteReorderPolicyFunc(ctx, sku, options...)
+}
+
+func (r *MockRepo) UpdateLowStock(ctx context.Context, sku string, available int64, at time.Time, options ...persistence.UpdateOptions) (ReorderPolicy, bool, error) {
+	r.UpdateLowStockCalls++
+	return r.UpdateLowStockFunc(ctx, sku, available, at, options...)
 }
 
 func (r *MockRepo) BeginTransaction(ctx context.Context) (persistence.Transaction, error) {
@@ -122,13 +532,184 @@ func NewMockRepo() *MockRepo {
 		GetReservationsFunc: func(ctx context.Context, resOptions GetReservationsOptions, limit, offset int, options ...persistence.QueryOptions) ([]Reservation, error) {
 			return nil, nil
 		},
+		GetReservationsAfterFunc: func(ctx context.Context, resOptions GetReservationsOptions, after *ReservationKey, limit int, options ...persistence.QueryOptions) ([]Reservation, error) {
+			return nil, nil
+		},
+		GetBackordersFunc: func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Backorder, error) {
+			return []Backorder{}, nil
+		},
+		GetExpiredReservationsFunc: func(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Reservation, error) {
+			return nil, nil
+		},
+		UpdateReservationShipmentFunc: func(ctx context.Context, ID uint64, state ReserveState, shipped int64, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		UpdateReservationQuantityFunc: func(ctx context.Context, ID uint64, state ReserveState, requested, reserved int64, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetShipmentByRequestIDFunc: func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Shipment, error) {
+			return Shipment{}, nil
+		},
+		SaveShipmentFunc: func(ctx context.Context, shipment *Shipment, options ...persistence.UpdateOptions) error {
+			return nil
+		},
 		SaveProductFunc: func(ctx context.Context, product Product, options ...persistence.UpdateOptions) error { return nil },
 		GetProductFunc: func(ctx context.Context, sku string, options ...persistence.QueryOptions) (Product, error) {
 			return Product{}, nil
 		},
-		GetAllProductInventoryFunc: func(ctx context.Context, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+		GetConflictingProductsFunc: func(ctx context.Context, skus, upcs []string, options ...persistence.QueryOptions) ([]Product, error) {
+			return []Product{}, nil
+		},
+		ImportProductsFunc: func(ctx context.Context, products []ProductImport, actor string, at time.Time, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		ExportProductInventoryFunc: func(ctx context.Context, fn func(ProductInventory) error, options ...persistence.QueryOptions) error {
+			return nil
+		},
+		ExportReservationsFunc: func(ctx context.Context, resOptions GetReservationsOptions, fn func(Reservation) error, options ...persistence.QueryOptions) error {
+			return nil
+		},
+		GetAllProductInventoryFunc: func(ctx context.Context, listOptions ProductListOptions, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+			return nil, nil
+		},
+		GetAllProductInventoryAfterFunc: func(ctx context.Context, listOptions ProductListOptions, after *ProductKey, limit int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+			return nil, nil
+		},
+		GetProductInventoryAsOfFunc: func(ctx context.Context, sku string, asOf time.Time, options ...persistence.QueryOptions) (ProductInventory, error) {
+			return ProductInventory{}, nil
+		},
+		GetAllProductInventoryAsOfFunc: func(ctx context.Context, asOf time.Time, limit int, offset int, options ...persistence.QueryOptions) ([]ProductInventory, error) {
+			return nil, nil
+		},
+		GetProductCreatedFunc: func(ctx context.Context, sku string, options ...persistence.QueryOptions) (time.Time, error) {
+			return time.Time{}, nil
+		},
+		GetInventoryHistoryStartFunc: func(ctx context.Context, options ...persistence.QueryOptions) (time.Time, error) {
+			return time.Time{}, nil
+		},
+		GetAdjustmentByRequestIDFunc: func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Adjustment, error) {
+			return Adjustment{}, persistence.ErrNotFound
+		},
+		SaveAdjustmentFunc: func(ctx context.Context, adjustment *Adjustment, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetStatusChangeByRequestIDFunc: func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (StatusChange, error) {
+			return StatusChange{}, persistence.ErrNotFound
+		},
+		SaveStatusChangeFunc: func(ctx context.Context, change *StatusChange, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetTransferFunc: func(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (Transfer, error) {
+			return Transfer{}, nil
+		},
+		GetTransferByRequestIDFunc: func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Transfer, error) {
+			return Transfer{}, persistence.ErrNotFound
+		},
+		GetTransfersFunc: func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Transfer, error) {
+			return nil, nil
+		},
+		SaveTransferFunc: func(ctx context.Context, transfer *Transfer, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		UpdateTransferFunc: func(ctx context.Context, ID uint64, state TransferState, updated time.Time, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetInventoryMovementsFunc: func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]InventoryMovement, error) {
+			return nil, nil
+		},
+		SaveInventoryMovementFunc: func(ctx context.Context, movement *InventoryMovement, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetBillOfMaterialsFunc: func(ctx context.Context, sku string, options ...persistence.QueryOptions) (BillOfMaterials, error) {
+			return BillOfMaterials{Sku: sku}, nil
+		},
+		SaveBillOfMaterialsFunc: func(ctx context.Context, bom BillOfMaterials, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetLotsFunc: func(ctx context.Context, sku string, options ...persistence.QueryOptions) ([]Lot, error) {
 			return nil, nil
 		},
+		GetExpiredLotsFunc: func(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]Lot, error) {
+			return nil, nil
+		},
+		GetReservationLotsFunc: func(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) ([]LotDraw, error) {
+			return nil, nil
+		},
+		GetTransferLotsFunc: func(ctx context.Context, transferID uint64, options ...persistence.QueryOptions) ([]LotDraw, error) {
+			return nil, nil
+		},
+		GetLotRecipientsFunc: func(ctx context.Context, sku, lot string, limit, offset int, options ...persistence.QueryOptions) ([]LotRecipient, error) {
+			return nil, nil
+		},
+		SaveLotsFunc: func(ctx context.Context, lots []Lot, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		SaveReservationLotsFunc: func(ctx context.Context, reservationID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		SaveTransferLotsFunc: func(ctx context.Context, transferID uint64, draws []LotDraw, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetPlannedOrderByRequestIDFunc: func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (PlannedOrder, error) {
+			return PlannedOrder{}, persistence.ErrNotFound
+		},
+		GetScheduledOrdersFunc: func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]PlannedOrder, error) {
+			return []PlannedOrder{}, nil
+		},
+		SavePlannedOrderFunc: func(ctx context.Context, order *PlannedOrder, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		UpdatePlannedOrderFunc: func(ctx context.Context, ID uint64, state PlannedOrderState, productionRequestID string, updated time.Time, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetReturnByRequestIDFunc: func(ctx context.Context, requestID string, options ...persistence.QueryOptions) (Return, error) {
+			return Return{}, persistence.ErrNotFound
+		},
+		GetReturnsFunc: func(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]Return, error) {
+			return []Return{}, nil
+		},
+		GetReturnedQuantityFunc: func(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) (int64, error) {
+			return 0, nil
+		},
+		SaveReturnFunc: func(ctx context.Context, ret *Return, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetRequesterLimitsFunc: func(ctx context.Context, requester string, options ...persistence.QueryOptions) (RequesterLimits, error) {
+			return RequesterLimits{}, persistence.ErrNotFound
+		},
+		GetAllRequesterLimitsFunc: func(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]RequesterLimits, error) {
+			return []RequesterLimits{}, nil
+		},
+		GetRequesterUsageFunc: func(ctx context.Context, requester, sku string, since time.Time, options ...persistence.QueryOptions) (RequesterUsage, error) {
+			return RequesterUsage{}, nil
+		},
+		SaveRequesterDemandFunc: func(ctx context.Context, requester string, reservationID uint64, quantity int64, at time.Time, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		SaveRequesterLimitsFunc: func(ctx context.Context, limits RequesterLimits, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		DeleteRequesterLimitsFunc: func(ctx context.Context, requester string, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		LockRequesterFunc: func(ctx context.Context, requester string, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		GetReorderPolicyFunc: func(ctx context.Context, sku string, options ...persistence.QueryOptions) (ReorderPolicy, error) {
+			return ReorderPolicy{}, persistence.ErrNotFound
+		},
+		GetLowStockFunc: func(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]LowStock, error) {
+			return []LowStock{}, nil
+		},
+		SaveReorderPolicyFunc: func(ctx context.Context, policy ReorderPolicy, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		DeleteReorderPolicyFunc: func(ctx context.Context, sku string, options ...persistence.UpdateOptions) error {
+			return nil
+		},
+		UpdateLowStockFunc: func(ctx context.Context, sku string, available int64, at time.Time, options ...persistence.UpdateOptions) (ReorderPolicy, bool, error) {
+			return ReorderPolicy{}, false, nil
+		},
 		BeginTransactionFunc: func(ctx context.Context) (persistence.Transaction, error) {
 			return persistence.NewMockTransaction(), nil
 		},
@@ -2,6 +2,7 @@ package inventory_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/pashagolub/pgxmock/v4"
@@ -29,8 +30,8 @@ func TestGetProductSQLInjectionPayloadsAreBoundParameters(t *testing.T) {
 			repo, mock := newRepo(t)
 			mock.ExpectQuery(selectProduct).
 				WithArgs(payload).
-				WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name"}).
-					AddRow(payload, "upc", "name"))
+				WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version"}).
+					AddRow(payload, "upc", "name", inventory.ProductActive, int64(1)))
 
 			if _, err := repo.GetProduct(context.Background(), payload); err != nil {
 				t.Fatalf("GetProduct(%q): %v", payload, err)
@@ -57,7 +58,7 @@ func TestGetReservationsSQLInjectionPayloadsAreBoundParameters(t *testing.T) {
 			mock.ExpectQuery(listReservationsBySku).
 				WithArgs(10, 0, payload).
 				WillReturnRows(pgxmock.NewRows([]string{
-					"id", "request_id", "requester", "sku",
+					"id", "request_id", "requester", "sku", "location",
 					"state", "reserved_quantity", "requested_quantity", "created",
 				}))
 
@@ -75,3 +76,43 @@ func TestGetReservationsSQLInjectionPayloadsAreBoundParameters(t *testing.T) {
 		})
 	}
 }
+
+// TestListProductsSearchPayloadsAreBoundParameters: the search term
+// reaches pgx as a bound LIKE pattern, not as SQL text.
+func TestListProductsSearchPayloadsAreBoundParameters(t *testing.T) {
+	for _, payload := range sqlInjectionPayloads {
+		t.Run(payload, func(t *testing.T) {
+			repo, mock := newRepo(t)
+			mock.ExpectQuery(selectInventorySearch).
+				WithArgs(10, 0, pgxmock.AnyArg()).
+				WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "available", "in_transit", "expired", "on_hold", "quarantined", "damaged"}))
+
+			_, err := repo.GetAllProductInventory(context.Background(), inventory.ProductListOptions{Search: payload}, 10, 0)
+			if err != nil {
+				t.Fatalf("GetAllProductInventory(%q): %v", payload, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("payload %q reached SQL un-parameterised: %v", payload, err)
+			}
+		})
+	}
+}
+
+// TestListProductsSortIsWhitelisted: a sort that isn't one of the
+// known columns is refused before any SQL is built, so it can never
+// be spliced into ORDER BY.
+func TestListProductsSortIsWhitelisted(t *testing.T) {
+	for _, payload := range sqlInjectionPayloads {
+		t.Run(payload, func(t *testing.T) {
+			repo, mock := newRepo(t)
+
+			_, err := repo.GetAllProductInventory(context.Background(), inventory.ProductListOptions{Sort: inventory.ProductSort(payload)}, 10, 0)
+			if !errors.Is(err, inventory.ErrInvalidInput) {
+				t.Errorf("sort %q: got err=%v, want ErrInvalidInput", payload, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("sort %q queried the database: %v", payload, err)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package inventory_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -18,15 +20,72 @@ type repoT interface {
 	SaveProduct(ctx context.Context, p inventory.Product, options ...persistence.UpdateOptions) error
 	SaveProductInventory(ctx context.Context, pi inventory.ProductInventory, options ...persistence.UpdateOptions) error
 	GetProduct(ctx context.Context, sku string, options ...persistence.QueryOptions) (inventory.Product, error)
+	GetConflictingProducts(ctx context.Context, skus, upcs []string, options ...persistence.QueryOptions) ([]inventory.Product, error)
+	ExportProductInventory(ctx context.Context, fn func(inventory.ProductInventory) error, options ...persistence.QueryOptions) error
+	ExportReservations(ctx context.Context, resOptions inventory.GetReservationsOptions, fn func(inventory.Reservation) error, options ...persistence.QueryOptions) error
+	ImportProducts(ctx context.Context, products []inventory.ProductImport, actor string, at time.Time, options ...persistence.UpdateOptions) error
 	GetProductInventory(ctx context.Context, sku string, options ...persistence.QueryOptions) (inventory.ProductInventory, error)
-	GetAllProductInventory(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]inventory.ProductInventory, error)
+	GetAllProductInventory(ctx context.Context, listOptions inventory.ProductListOptions, limit, offset int, options ...persistence.QueryOptions) ([]inventory.ProductInventory, error)
+	GetAllProductInventoryAfter(ctx context.Context, listOptions inventory.ProductListOptions, after *inventory.ProductKey, limit int, options ...persistence.QueryOptions) ([]inventory.ProductInventory, error)
+	GetProductInventoryAsOf(ctx context.Context, sku string, asOf time.Time, options ...persistence.QueryOptions) (inventory.ProductInventory, error)
+	GetAllProductInventoryAsOf(ctx context.Context, asOf time.Time, limit, offset int, options ...persistence.QueryOptions) ([]inventory.ProductInventory, error)
+	GetProductCreated(ctx context.Context, sku string, options ...persistence.QueryOptions) (time.Time, error)
+	GetInventoryHistoryStart(ctx context.Context, options ...persistence.QueryOptions) (time.Time, error)
 	GetProductionEventByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (inventory.ProductionEvent, error)
 	SaveProductionEvent(ctx context.Context, e *inventory.ProductionEvent, options ...persistence.UpdateOptions) error
 	SaveReservation(ctx context.Context, r *inventory.Reservation, options ...persistence.UpdateOptions) error
 	UpdateReservation(ctx context.Context, ID uint64, state inventory.ReserveState, qty int64, options ...persistence.UpdateOptions) error
 	GetReservations(ctx context.Context, resOptions inventory.GetReservationsOptions, limit, offset int, options ...persistence.QueryOptions) ([]inventory.Reservation, error)
+	GetReservationsAfter(ctx context.Context, resOptions inventory.GetReservationsOptions, after *inventory.ReservationKey, limit int, options ...persistence.QueryOptions) ([]inventory.Reservation, error)
 	GetReservationByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (inventory.Reservation, error)
 	GetReservation(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (inventory.Reservation, error)
+	GetExpiredReservations(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]inventory.Reservation, error)
+	GetBackorders(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]inventory.Backorder, error)
+	UpdateReservationShipment(ctx context.Context, ID uint64, state inventory.ReserveState, shipped int64, options ...persistence.UpdateOptions) error
+	UpdateReservationQuantity(ctx context.Context, ID uint64, state inventory.ReserveState, requested, reserved int64, options ...persistence.UpdateOptions) error
+	SaveShipment(ctx context.Context, shipment *inventory.Shipment, options ...persistence.UpdateOptions) error
+	GetShipmentByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (inventory.Shipment, error)
+	GetAdjustmentByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (inventory.Adjustment, error)
+	SaveAdjustment(ctx context.Context, a *inventory.Adjustment, options ...persistence.UpdateOptions) error
+	GetStatusChangeByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (inventory.StatusChange, error)
+	SaveStatusChange(ctx context.Context, c *inventory.StatusChange, options ...persistence.UpdateOptions) error
+	SaveInventoryMovement(ctx context.Context, mv *inventory.InventoryMovement, options ...persistence.UpdateOptions) error
+	GetInventoryMovements(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]inventory.InventoryMovement, error)
+	SaveTransfer(ctx context.Context, t *inventory.Transfer, options ...persistence.UpdateOptions) error
+	UpdateTransfer(ctx context.Context, ID uint64, state inventory.TransferState, updated time.Time, options ...persistence.UpdateOptions) error
+	GetTransfer(ctx context.Context, ID uint64, options ...persistence.QueryOptions) (inventory.Transfer, error)
+	GetTransferByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (inventory.Transfer, error)
+	GetTransfers(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]inventory.Transfer, error)
+	GetBillOfMaterials(ctx context.Context, sku string, options ...persistence.QueryOptions) (inventory.BillOfMaterials, error)
+	SaveBillOfMaterials(ctx context.Context, bom inventory.BillOfMaterials, options ...persistence.UpdateOptions) error
+	GetLots(ctx context.Context, sku string, options ...persistence.QueryOptions) ([]inventory.Lot, error)
+	GetExpiredLots(ctx context.Context, asOf time.Time, limit int, options ...persistence.QueryOptions) ([]inventory.Lot, error)
+	GetReservationLots(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) ([]inventory.LotDraw, error)
+	GetLotRecipients(ctx context.Context, sku, lot string, limit, offset int, options ...persistence.QueryOptions) ([]inventory.LotRecipient, error)
+	SaveLots(ctx context.Context, lots []inventory.Lot, options ...persistence.UpdateOptions) error
+	SaveReservationLots(ctx context.Context, reservationID uint64, draws []inventory.LotDraw, options ...persistence.UpdateOptions) error
+	GetTransferLots(ctx context.Context, transferID uint64, options ...persistence.QueryOptions) ([]inventory.LotDraw, error)
+	SaveTransferLots(ctx context.Context, transferID uint64, draws []inventory.LotDraw, options ...persistence.UpdateOptions) error
+	GetReorderPolicy(ctx context.Context, sku string, options ...persistence.QueryOptions) (inventory.ReorderPolicy, error)
+	GetLowStock(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]inventory.LowStock, error)
+	SaveReorderPolicy(ctx context.Context, policy inventory.ReorderPolicy, options ...persistence.UpdateOptions) error
+	DeleteReorderPolicy(ctx context.Context, sku string, options ...persistence.UpdateOptions) error
+	UpdateLowStock(ctx context.Context, sku string, available int64, at time.Time, options ...persistence.UpdateOptions) (inventory.ReorderPolicy, bool, error)
+	GetPlannedOrderByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (inventory.PlannedOrder, error)
+	GetScheduledOrders(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]inventory.PlannedOrder, error)
+	SavePlannedOrder(ctx context.Context, order *inventory.PlannedOrder, options ...persistence.UpdateOptions) error
+	UpdatePlannedOrder(ctx context.Context, ID uint64, state inventory.PlannedOrderState, productionRequestID string, updated time.Time, options ...persistence.UpdateOptions) error
+	GetReturnByRequestID(ctx context.Context, requestID string, options ...persistence.QueryOptions) (inventory.Return, error)
+	GetReturns(ctx context.Context, sku string, limit, offset int, options ...persistence.QueryOptions) ([]inventory.Return, error)
+	GetReturnedQuantity(ctx context.Context, reservationID uint64, options ...persistence.QueryOptions) (int64, error)
+	SaveReturn(ctx context.Context, ret *inventory.Return, options ...persistence.UpdateOptions) error
+	GetRequesterLimits(ctx context.Context, requester string, options ...persistence.QueryOptions) (inventory.RequesterLimits, error)
+	GetAllRequesterLimits(ctx context.Context, limit, offset int, options ...persistence.QueryOptions) ([]inventory.RequesterLimits, error)
+	GetRequesterUsage(ctx context.Context, requester, sku string, since time.Time, options ...persistence.QueryOptions) (inventory.RequesterUsage, error)
+	SaveRequesterDemand(ctx context.Context, requester string, reservationID uint64, quantity int64, at time.Time, options ...persistence.UpdateOptions) error
+	SaveRequesterLimits(ctx context.Context, limits inventory.RequesterLimits, options ...persistence.UpdateOptions) error
+	DeleteRequesterLimits(ctx context.Context, requester string, options ...persistence.UpdateOptions) error
+	LockRequester(ctx context.Context, requester string, options ...persistence.UpdateOptions) error
 	BeginTransaction(ctx context.Context) (persistence.Transaction, error)
 }
 
@@ -45,36 +104,112 @@ func newRepo(t *testing.T) (repoT, pgxmock.PgxConnIface) {
 // QueryMatcherRegexp is substring-permissive; without anchors a typo
 // like "proudcts" would still match.
 const (
-	updateProduct          = `^\s*UPDATE products\s+SET upc = \$2, name = \$3\s+WHERE sku = \$1;?\s*$`
-	insertProduct          = `^\s*INSERT INTO products \(sku, upc, name\)\s+VALUES \(\$1, \$2, \$3\);?\s*$`
-	updateProductInventory = `^\s*UPDATE product_inventory\s+SET available = \$2\s+WHERE sku = \$1;?\s*$`
-	insertProductInventory = `^INSERT INTO product_inventory \(sku, available\)\s+VALUES \(\$1, \$2\);?\s*$`
-
-	selectProduct          = `^SELECT sku, upc, name FROM products WHERE sku = \$1\s*$`
-	selectProductInventory = `^SELECT p\.sku, p\.upc, p\.name, pi\.available FROM products p, product_inventory pi WHERE p\.sku = \$1 AND p\.sku = pi\.sku\s*$`
-	selectAllInventory     = `^SELECT p\.sku, p\.upc, p\.name, pi\.available FROM products p, product_inventory pi WHERE p\.sku = pi\.sku ORDER BY p\.sku LIMIT \$1 OFFSET \$2\s*$`
-
-	insertProductionEvent  = `^INSERT INTO production_events \(request_id, sku, quantity, created\)\s+VALUES \(\$1, \$2, \$3, \$4\) RETURNING id;?\s*$`
-	selectProductionEvent  = `^SELECT id, request_id, sku, quantity, created FROM production_events\s+WHERE request_id = \$1\s*$`
-	insertReservation      = `^INSERT INTO reservations \(request_id, requester, sku, state, reserved_quantity, requested_quantity, created\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\) RETURNING id;?\s*$`
-	updateReservationStmt  = `^UPDATE reservations SET state = \$2, reserved_quantity = \$3 WHERE id=\$1;?\s*$`
-	selectReservationByID  = `^SELECT id, request_id, requester, sku, state, reserved_quantity, requested_quantity, created FROM reservations WHERE id = \$1\s*$`
-	selectReservationByReq = `^SELECT id, request_id, requester, sku, state, reserved_quantity, requested_quantity, created FROM reservations WHERE request_id = \$1\s*$`
+	updateProduct          = `^\s*UPDATE products\s+SET upc = \$2, name = \$3, state = \$4, version = version \+ 1\s+WHERE sku = \$1 AND version = \$5;?\s*$`
+	insertProduct          = `^\s*INSERT INTO products \(sku, upc, name, state\)\s+VALUES \(\$1, \$2, \$3, \$4\)\s+ON CONFLICT \(sku\) DO NOTHING;?\s*$`
+	bumpProductVersion     = `^UPDATE products SET version = version \+ 1 WHERE sku = \$1$`
+	bumpProductVersionIf   = `^UPDATE products SET version = version \+ 1 WHERE sku = \$1 AND version = \$2$`
+	upsertProductInventory = `^\s*INSERT INTO product_inventory \(sku, location, available, in_transit, expired, on_hold, quarantined, damaged\)\s+SELECT \$1, l\.location, l\.available, l\.in_transit, l\.expired, l\.on_hold, l\.quarantined, l\.damaged FROM unnest\(\$2::text\[\], \$3::bigint\[\], \$4::bigint\[\], \$5::bigint\[\], \$6::bigint\[\], \$7::bigint\[\], \$8::bigint\[\]\) AS l\(location, available, in_transit, expired, on_hold, quarantined, damaged\)\s+ON CONFLICT \(sku, location\) DO UPDATE SET available = EXCLUDED\.available, in_transit = EXCLUDED\.in_transit, expired = EXCLUDED\.expired, on_hold = EXCLUDED\.on_hold, quarantined = EXCLUDED\.quarantined, damaged = EXCLUDED\.damaged, version = product_inventory\.version \+ 1\s+WHERE \(product_inventory\.available, product_inventory\.in_transit, product_inventory\.expired, product_inventory\.on_hold, product_inventory\.quarantined, product_inventory\.damaged\) IS DISTINCT FROM \(EXCLUDED\.available, EXCLUDED\.in_transit, EXCLUDED\.expired, EXCLUDED\.on_hold, EXCLUDED\.quarantined, EXCLUDED\.damaged\);?\s*$`
+
+	selectProduct                = `^SELECT sku, upc, name, state, version FROM products WHERE sku = \$1\s*$`
+	selectProductInventory       = `^SELECT p\.sku, p\.upc, p\.name, p\.state, p\.version, pi\.location, pi\.available, pi\.in_transit, pi\.expired, pi\.on_hold, pi\.quarantined, pi\.damaged FROM products p JOIN product_inventory pi ON pi\.sku = p\.sku WHERE p\.sku = \$1 ORDER BY pi\.location\s*$`
+	inventoryColumns             = `p\.sku, p\.upc, p\.name, p\.state, p\.version, pi\.location, pi\.available, pi\.in_transit, pi\.expired, pi\.on_hold, pi\.quarantined, pi\.damaged`
+	inventoryPageJoin            = `JOIN products p ON p\.sku = page\.sku JOIN product_inventory pi ON pi\.sku = p\.sku`
+	productAvailable             = `\(SELECT COALESCE\(SUM\(available\), 0\) FROM product_inventory WHERE sku = p\.sku\)`
+	selectAllInventory           = `^SELECT ` + inventoryColumns + ` FROM \(SELECT p\.sku, p\.sku AS sort_key FROM products p ORDER BY sort_key ASC, p\.sku ASC LIMIT \$1 OFFSET \$2\) page ` + inventoryPageJoin + ` ORDER BY page\.sort_key ASC, p\.sku ASC, pi\.location\s*$`
+	selectAllInventoryAfter      = `^SELECT ` + inventoryColumns + ` FROM \(SELECT p\.sku, p\.sku AS sort_key FROM products p WHERE p\.sku > \$2 ORDER BY sort_key ASC, p\.sku ASC LIMIT \$1\) page ` + inventoryPageJoin + ` ORDER BY page\.sort_key ASC, p\.sku ASC, pi\.location\s*$`
+	selectInventoryFiltered      = `^SELECT ` + inventoryColumns + ` FROM \(SELECT p\.sku, ` + productAvailable + ` AS sort_key FROM products p WHERE \(p\.name ILIKE \$3 OR p\.upc ILIKE \$3\) AND ` + productAvailable + ` >= \$4 AND ` + productAvailable + ` <= \$5 ORDER BY sort_key DESC, p\.sku DESC LIMIT \$1 OFFSET \$2\) page ` + inventoryPageJoin + ` ORDER BY page\.sort_key DESC, p\.sku DESC, pi\.location\s*$`
+	selectInventorySearch        = `^SELECT ` + inventoryColumns + ` FROM \(SELECT p\.sku, p\.sku AS sort_key FROM products p WHERE \(p\.name ILIKE \$3 OR p\.upc ILIKE \$3\) ORDER BY sort_key ASC, p\.sku ASC LIMIT \$1 OFFSET \$2\) page ` + inventoryPageJoin + ` ORDER BY page\.sort_key ASC, p\.sku ASC, pi\.location\s*$`
+	selectInventoryByNameAfter   = `^SELECT ` + inventoryColumns + ` FROM \(SELECT p\.sku, p\.name AS sort_key FROM products p WHERE \(p\.name, p\.sku\) > \(\$2, \$3\) ORDER BY sort_key ASC, p\.sku ASC LIMIT \$1\) page ` + inventoryPageJoin + ` ORDER BY page\.sort_key ASC, p\.sku ASC, pi\.location\s*$`
+	selectInventoryAvailableDesc = `^SELECT ` + inventoryColumns + ` FROM \(SELECT p\.sku, ` + productAvailable + ` AS sort_key FROM products p WHERE ` + productAvailable + ` <= \$2 AND \(` + productAvailable + `, p\.sku\) < \(\$3, \$4\) ORDER BY sort_key DESC, p\.sku DESC LIMIT \$1\) page ` + inventoryPageJoin + ` ORDER BY page\.sort_key DESC, p\.sku DESC, pi\.location\s*$`
+	locationBalancesAsOf         = `LEFT JOIN LATERAL \(SELECT DISTINCT ON \(m\.location\) m\.location, m\.balance FROM inventory_movements m WHERE m\.sku = p\.sku AND m\.created <= \$1 ORDER BY m\.location, m\.created DESC, m\.id DESC\) b ON TRUE`
+	selectInventoryAsOf          = `^SELECT p\.sku, p\.upc, p\.name, p\.state, p\.version, b\.location, b\.balance, 0, 0, 0, 0, 0 FROM products p ` + locationBalancesAsOf + ` WHERE p\.sku = \$2 ORDER BY b\.location$`
+	selectAllInventoryAsOf       = `^SELECT p\.sku, p\.upc, p\.name, p\.state, p\.version, b\.location, b\.balance, 0, 0, 0, 0, 0 FROM \(SELECT sku, upc, name, state, version FROM products WHERE created <= \$1 ORDER BY sku LIMIT \$2 OFFSET \$3\) p ` + locationBalancesAsOf + ` ORDER BY p\.sku, b\.location$`
+	selectProductCreated         = `^SELECT created FROM products WHERE sku = \$1$`
+	selectInventoryHistoryStart  = `^SELECT MIN\(created\) FROM products$`
+
+	insertProductionEvent   = `^INSERT INTO production_events \(request_id, sku, location, quantity, lot, expires_at, created\)\s+VALUES \(\$1, \$2, \$3, \$4, NULLIF\(\$5, ''\), \$6, \$7\) RETURNING id;?\s*$`
+	selectProductionEvent   = `^SELECT id, request_id, sku, location, quantity, COALESCE\(lot, ''\), expires_at, created FROM production_events\s+WHERE request_id = \$1\s*$`
+	insertReservation       = `^INSERT INTO reservations \(request_id, requester, sku, location, state, reserved_quantity, requested_quantity, created, expires_at, priority, fill_policy, min_quantity\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10, \$11, \$12\) RETURNING id, version;?\s*$`
+	updateReservationStmt   = `^UPDATE reservations SET state = \$2, reserved_quantity = \$3, version = version \+ 1 WHERE id = \$1$`
+	updateReservationStmtIf = `^UPDATE reservations SET state = \$2, reserved_quantity = \$3, version = version \+ 1 WHERE id = \$1 AND version = \$4$`
+	selectReservationByID   = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations WHERE id = \$1\s*$`
+	selectReservationByReq  = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations WHERE request_id = \$1\s*$`
 
 	// Reservation list patterns vary by what filters are present.
-	listReservationsBare   = `^SELECT id, request_id, requester, sku, state, reserved_quantity, requested_quantity, created FROM reservations\s+ORDER BY created ASC LIMIT \$1 OFFSET \$2\s*$`
-	listReservationsBySku  = `^SELECT id, request_id, requester, sku, state, reserved_quantity, requested_quantity, created FROM reservations  WHERE  sku = \$3 ORDER BY created ASC LIMIT \$1 OFFSET \$2\s*$`
-	listReservationsByBoth = `^SELECT id, request_id, requester, sku, state, reserved_quantity, requested_quantity, created FROM reservations  WHERE  sku = \$3 AND state = \$4 ORDER BY created ASC LIMIT \$1 OFFSET \$2\s*$`
+	listReservationsBare       = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations\s+ORDER BY created ASC, id ASC LIMIT \$1 OFFSET \$2\s*$`
+	listReservationsBySku      = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations  WHERE  sku = \$3 ORDER BY created ASC, id ASC LIMIT \$1 OFFSET \$2\s*$`
+	listReservationsByBoth     = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations  WHERE  sku = \$3 AND state = \$4 ORDER BY created ASC, id ASC LIMIT \$1 OFFSET \$2\s*$`
+	listReservationsAfter      = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations  WHERE  \(created, id\) > \(\$2, \$3\) ORDER BY created ASC, id ASC LIMIT \$1\s*$`
+	listReservationsBySkuAfter = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations  WHERE  sku = \$2 AND \(created, id\) > \(\$3, \$4\) ORDER BY created ASC, id ASC LIMIT \$1\s*$`
+	listReservationsFirstPage  = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations\s+ORDER BY created ASC, id ASC LIMIT \$1\s*$`
+	updateReservationShipment  = `^UPDATE reservations SET state = \$2, shipped_quantity = \$3, version = version \+ 1 WHERE id = \$1$`
+	updateReservationQuantity  = `^UPDATE reservations SET state = \$2, requested_quantity = \$3, reserved_quantity = \$4, version = version \+ 1 WHERE id = \$1$`
+	insertShipment             = `^INSERT INTO shipments \(request_id, reservation_id, sku, quantity, created\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5\) RETURNING id;?\s*$`
+	selectShipmentByReq        = `^SELECT id, request_id, reservation_id, sku, quantity, created FROM shipments WHERE request_id = \$1\s*$`
+	insertAdjustment           = `^INSERT INTO inventory_adjustments \(request_id, sku, location, quantity, reason, actor, created\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\) RETURNING id;?\s*$`
+	selectAdjustmentByReq      = `^SELECT id, request_id, sku, location, quantity, reason, actor, created FROM inventory_adjustments WHERE request_id = \$1\s*$`
+	insertStatusChange         = `^INSERT INTO inventory_status_changes \(request_id, sku, location, from_status, to_status, quantity, lot, reason, actor, created\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, NULLIF\(\$7, ''\), \$8, \$9, \$10\) RETURNING id;?\s*$`
+	selectStatusChangeByReq    = `^SELECT id, request_id, sku, location, from_status, to_status, quantity, COALESCE\(lot, ''\), reason, actor, created FROM inventory_status_changes WHERE request_id = \$1\s*$`
+	insertInventoryMovement    = `^INSERT INTO inventory_movements \(sku, location, delta, reason, request_id, reservation_id, lot, actor, balance, created\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10\) RETURNING id;?\s*$`
+	listInventoryMovements     = `^SELECT id, sku, location, delta, reason, COALESCE\(request_id, ''\), reservation_id, COALESCE\(lot, ''\), actor, balance, created FROM inventory_movements WHERE sku = \$1 ORDER BY id ASC LIMIT \$2 OFFSET \$3\s*$`
+	insertTransfer             = `^INSERT INTO inventory_transfers \(request_id, sku, from_location, to_location, quantity, state, actor, created, updated\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9\) RETURNING id;?\s*$`
+	updateTransfer             = `^UPDATE inventory_transfers SET state = \$2, updated = \$3 WHERE id = \$1;?\s*$`
+	selectTransferByID         = `^SELECT id, request_id, sku, from_location, to_location, quantity, state, actor, created, updated FROM inventory_transfers WHERE id = \$1\s*$`
+	listTransfers              = `^SELECT id, request_id, sku, from_location, to_location, quantity, state, actor, created, updated FROM inventory_transfers WHERE sku = \$1 ORDER BY id ASC LIMIT \$2 OFFSET \$3\s*$`
+	selectBillOfMaterials      = `^SELECT component_sku, quantity FROM bill_of_materials WHERE parent_sku = \$1 ORDER BY component_sku ASC\s*$`
+	deleteBillOfMaterials      = `^DELETE FROM bill_of_materials WHERE parent_sku = \$1;?\s*$`
+	insertBillOfMaterials      = `^INSERT INTO bill_of_materials \(parent_sku, component_sku, quantity\)\s+SELECT \$1, c\.sku, c\.quantity FROM unnest\(\$2::text\[\], \$3::bigint\[\]\) AS c \(sku, quantity\);?\s*$`
+	selectLots                 = `^SELECT sku, location, lot, expires_at, available, expired FROM inventory_lots WHERE sku = \$1 ORDER BY location, expires_at ASC NULLS LAST, lot FOR UPDATE\s*$`
+	selectExpiredLots          = `^SELECT sku, location, lot, expires_at, available, expired FROM inventory_lots WHERE expires_at <= \$1 AND available > 0 ORDER BY expires_at ASC LIMIT \$2\s*$`
+	upsertLots                 = `^\s*INSERT INTO inventory_lots \(sku, location, lot, expires_at, available, expired\)\s+SELECT l\.sku, l\.location, l\.lot, l\.expires_at, l\.available, l\.expired FROM unnest\(\$1::text\[\], \$2::text\[\], \$3::text\[\], \$4::timestamptz\[\], \$5::bigint\[\], \$6::bigint\[\]\) AS l\(sku, location, lot, expires_at, available, expired\)\s+ON CONFLICT \(sku, location, lot\) DO UPDATE SET available = EXCLUDED\.available, expired = EXCLUDED\.expired;?\s*$`
+	selectReservationLots      = `^SELECT lot, quantity FROM reservation_lots WHERE reservation_id = \$1 AND quantity > 0 ORDER BY lot\s*$`
+	upsertReservationLots      = `^\s*INSERT INTO reservation_lots \(reservation_id, lot, quantity\)\s+SELECT \$1, d\.lot, d\.quantity FROM unnest\(\$2::text\[\], \$3::bigint\[\]\) AS d\(lot, quantity\)\s+ON CONFLICT \(reservation_id, lot\) DO UPDATE SET quantity = reservation_lots\.quantity \+ EXCLUDED\.quantity;?\s*$`
+	selectTransferLots         = `^SELECT lot, quantity FROM transfer_lots WHERE transfer_id = \$1 ORDER BY lot\s*$`
+	insertTransferLots         = `^\s*INSERT INTO transfer_lots \(transfer_id, lot, quantity\)\s+SELECT \$1, d\.lot, d\.quantity FROM unnest\(\$2::text\[\], \$3::bigint\[\]\) AS d\(lot, quantity\);?\s*$`
+	selectLotRecipients        = `^SELECT r\.id, r\.request_id, r\.requester, r\.location, r\.state, rl\.quantity, r\.shipped_quantity FROM reservation_lots rl JOIN reservations r ON r\.id = rl\.reservation_id WHERE r\.sku = \$1 AND rl\.lot = \$2 AND rl\.quantity > 0 ORDER BY r\.id LIMIT \$3 OFFSET \$4\s*$`
+	selectReorderPolicy        = `^SELECT sku, reorder_point, target_level, low_since FROM inventory_reorder_policies WHERE sku = \$1\s*$`
+	upsertReorderPolicy        = `^INSERT INTO inventory_reorder_policies \(sku, reorder_point, target_level\) VALUES \(\$1, \$2, \$3\)\s+ON CONFLICT \(sku\) DO UPDATE SET reorder_point = EXCLUDED\.reorder_point, target_level = EXCLUDED\.target_level;?\s*$`
+	deleteReorderPolicy        = `^DELETE FROM inventory_reorder_policies WHERE sku = \$1;?\s*$`
+	updateLowStock             = `^UPDATE inventory_reorder_policies SET low_since = CASE WHEN \$2 < reorder_point THEN \$3::timestamptz END\s+WHERE sku = \$1 AND \(low_since IS NULL\) = \(\$2 < reorder_point\)\s+RETURNING sku, reorder_point, target_level, low_since;?\s*$`
+	selectLowStock             = `^SELECT r\.sku, r\.reorder_point, r\.target_level, r\.low_since, COALESCE\(SUM\(pi\.available\), 0\) FROM inventory_reorder_policies r LEFT JOIN product_inventory pi ON pi\.sku = r\.sku GROUP BY r\.sku HAVING COALESCE\(SUM\(pi\.available\), 0\) < r\.reorder_point ORDER BY r\.sku LIMIT \$1 OFFSET \$2\s*$`
+	backordersSelect           = `^SELECT sku, COUNT\(\*\), SUM\(requested_quantity\), SUM\(reserved_quantity\), SUM\(requested_quantity - reserved_quantity\), MIN\(created\) FROM reservations WHERE state = 'Open' AND reserved_quantity < requested_quantity`
+	backordersOrder            = ` GROUP BY sku ORDER BY SUM\(requested_quantity - reserved_quantity\) DESC, sku LIMIT \$1 OFFSET \$2\s*$`
+	listBackorders             = backordersSelect + backordersOrder
+	listBackordersBySku        = backordersSelect + ` AND sku = \$3` + backordersOrder
+	plannedOrderColumns        = `id, request_id, sku, quantity, expected_date, state, COALESCE\(production_request_id, ''\), actor, created, updated`
+	selectPlannedOrderByReq    = `^SELECT ` + plannedOrderColumns + ` FROM planned_orders WHERE request_id = \$1\s*$`
+	listScheduledOrders        = `^SELECT ` + plannedOrderColumns + ` FROM planned_orders WHERE sku = \$1 AND state = 'Scheduled' ORDER BY expected_date ASC, id ASC LIMIT \$2 OFFSET \$3\s*$`
+	insertPlannedOrder         = `^INSERT INTO planned_orders \(request_id, sku, quantity, expected_date, state, actor, created, updated\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8\) RETURNING id;?\s*$`
+	updatePlannedOrder         = `^UPDATE planned_orders SET state = \$2, production_request_id = NULLIF\(\$3, ''\), updated = \$4 WHERE id = \$1;?\s*$`
+	returnColumns              = `id, request_id, sku, location, reservation_id, requester, quantity, outcome, COALESCE\(lot, ''\), reason, actor, created`
+	selectReturnByReq          = `^SELECT ` + returnColumns + ` FROM inventory_returns WHERE request_id = \$1\s*$`
+	listReturns                = `^SELECT ` + returnColumns + ` FROM inventory_returns WHERE sku = \$1 ORDER BY id ASC LIMIT \$2 OFFSET \$3\s*$`
+	selectReturnedQuantity     = `^SELECT COALESCE\(SUM\(quantity\), 0\) FROM inventory_returns WHERE reservation_id = \$1\s*$`
+	insertReturn               = `^INSERT INTO inventory_returns \(request_id, sku, location, reservation_id, requester, quantity, outcome, lot, reason, actor, created\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, NULLIF\(\$8, ''\), \$9, \$10, \$11\) RETURNING id;?\s*$`
+	requesterLimitColumns      = `requester, class, max_reserved_per_sku, max_open_reservations, daily_cap`
+	selectRequesterLimits      = `^SELECT ` + requesterLimitColumns + ` FROM requester_limits WHERE requester = \$1\s*$`
+	listRequesterLimits        = `^SELECT ` + requesterLimitColumns + ` FROM requester_limits ORDER BY requester LIMIT \$1 OFFSET \$2\s*$`
+	selectRequesterUsage       = `^SELECT COALESCE\(SUM\(requested_quantity - shipped_quantity\) FILTER \(WHERE sku = \$2 AND state IN \('Open', 'Closed'\)\), 0\),\s+COUNT\(\*\) FILTER \(WHERE state IN \('Open', 'Closed'\)\),\s+\(SELECT COALESCE\(SUM\(GREATEST\(n\.quantity, 0\)\), 0\)::BIGINT FROM \(SELECT SUM\(rd\.quantity\) AS quantity FROM requester_demand rd JOIN reservations r ON r\.id = rd\.reservation_id\s+WHERE rd\.requester = \$1 AND rd\.created >= \$3 AND r\.state NOT IN \('Cancelled', 'Expired'\) GROUP BY rd\.reservation_id\) n\)\s+FROM reservations WHERE requester = \$1\s*$`
+	insertRequesterDemand      = `^INSERT INTO requester_demand \(requester, reservation_id, quantity, created\) VALUES \(\$1, \$2, \$3, \$4\);?\s*$`
+	upsertRequesterLimits      = `^INSERT INTO requester_limits \(requester, class, max_reserved_per_sku, max_open_reservations, daily_cap\) VALUES \(\$1, \$2, \$3, \$4, \$5\)\s+ON CONFLICT \(requester\) DO UPDATE SET class = EXCLUDED\.class, max_reserved_per_sku = EXCLUDED\.max_reserved_per_sku,\s+max_open_reservations = EXCLUDED\.max_open_reservations, daily_cap = EXCLUDED\.daily_cap;?\s*$`
+	deleteRequesterLimits      = `^DELETE FROM requester_limits WHERE requester = \$1;?\s*$`
+	lockRequester              = `^SELECT pg_advisory_xact_lock\(hashtext\('requester_limits:' \|\| \$1\)\);?\s*$`
+	selectConflictingProducts  = `^SELECT sku, upc, name, state, version FROM products WHERE sku = ANY\(\$1\) OR upc = ANY\(\$2\) ORDER BY sku\s*$`
+	declareInventoryExport     = `^DECLARE inventory_export NO SCROLL CURSOR FOR SELECT p\.sku, p\.upc, p\.name, p\.state, p\.version, pi\.location, pi\.available, pi\.in_transit, pi\.expired, pi\.on_hold, pi\.quarantined, pi\.damaged FROM products p JOIN product_inventory pi ON pi\.sku = p\.sku ORDER BY p\.sku, pi\.location$`
+	fetchInventoryExport       = `^FETCH FORWARD 2 FROM inventory_export$`
+	declareReservationExport   = `^DECLARE reservation_export NO SCROLL CURSOR FOR SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations\s+WHERE\s+sku = \$1 AND state = \$2 ORDER BY created ASC, id ASC$`
+	fetchReservationExport     = `^FETCH FORWARD 2 FROM reservation_export$`
+	listExpiredReservations    = `^SELECT id, request_id, requester, sku, location, state, reserved_quantity, requested_quantity, shipped_quantity, created, expires_at, priority, fill_policy, min_quantity, version FROM reservations WHERE expires_at <= \$1 AND state IN \(\$2, \$3\) AND shipped_quantity = 0 ORDER BY expires_at ASC LIMIT \$4\s*$`
 )
 
 func TestRepositorySaveProduct(t *testing.T) {
-	p := inventory.Product{Sku: "sku1", Upc: "upc1", Name: "name1"}
+	p := inventory.Product{Sku: "sku1", Upc: "upc1", Name: "name1", State: inventory.ProductActive}
 
-	t.Run("update affects a row, no insert", func(t *testing.T) {
+	t.Run("without a version the product is inserted", func(t *testing.T) {
 		repo, mock := newRepo(t)
-		mock.ExpectExec(updateProduct).
-			WithArgs(p.Sku, p.Upc, p.Name).
-			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mock.ExpectExec(insertProduct).
+			WithArgs(p.Sku, p.Upc, p.Name, p.State).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 		if err := repo.SaveProduct(context.Background(), p); err != nil {
 			t.Errorf("unexpected error: %v", err)
@@ -84,16 +219,24 @@ func TestRepositorySaveProduct(t *testing.T) {
 		}
 	})
 
-	t.Run("update finds nothing, insert is issued", func(t *testing.T) {
+	t.Run("insert of an existing product is a version conflict", func(t *testing.T) {
 		repo, mock := newRepo(t)
-		mock.ExpectExec(updateProduct).
-			WithArgs(p.Sku, p.Upc, p.Name).
-			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
 		mock.ExpectExec(insertProduct).
-			WithArgs(p.Sku, p.Upc, p.Name).
-			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			WithArgs(p.Sku, p.Upc, p.Name, p.State).
+			WillReturnResult(pgxmock.NewResult("INSERT", 0))
 
-		if err := repo.SaveProduct(context.Background(), p); err != nil {
+		if err := repo.SaveProduct(context.Background(), p); !errors.Is(err, persistence.ErrVersionConflict) {
+			t.Errorf("expected ErrVersionConflict, got %v", err)
+		}
+	})
+
+	t.Run("with a version the product is updated at that version", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(updateProduct).
+			WithArgs(p.Sku, p.Upc, p.Name, p.State, int64(3)).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+		if err := repo.SaveProduct(context.Background(), p, persistence.UpdateOptions{Tx: mock, Version: 3}); err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
 		if err := mock.ExpectationsWereMet(); err != nil {
@@ -101,29 +244,46 @@ func TestRepositorySaveProduct(t *testing.T) {
 		}
 	})
 
-	t.Run("update error propagates without falling through to insert", func(t *testing.T) {
+	t.Run("update that matches nothing is a version conflict", func(t *testing.T) {
 		repo, mock := newRepo(t)
 		mock.ExpectExec(updateProduct).
-			WithArgs(p.Sku, p.Upc, p.Name).
-			WillReturnError(errors.New("boom"))
+			WithArgs(p.Sku, p.Upc, p.Name, p.State, int64(3)).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
 
-		if err := repo.SaveProduct(context.Background(), p); err == nil {
-			t.Error("expected error, got nil")
+		if err := repo.SaveProduct(context.Background(), p, persistence.UpdateOptions{Tx: mock, Version: 3}); !errors.Is(err, persistence.ErrVersionConflict) {
+			t.Errorf("expected ErrVersionConflict, got %v", err)
 		}
-		if err := mock.ExpectationsWereMet(); err != nil {
-			t.Errorf("unmet expectations: %v", err)
+	})
+
+	t.Run("error propagates", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(insertProduct).
+			WithArgs(p.Sku, p.Upc, p.Name, p.State).
+			WillReturnError(errors.New("boom"))
+
+		if err := repo.SaveProduct(context.Background(), p); err == nil || errors.Is(err, persistence.ErrVersionConflict) {
+			t.Errorf("expected the driver error, got %v", err)
 		}
 	})
 }
 
 func TestRepositorySaveProductInventory(t *testing.T) {
-	pi := inventory.ProductInventory{Product: inventory.Product{Sku: "sku1"}, Available: 5}
+	pi := inventory.ProductInventory{Product: inventory.Product{Sku: "sku1"}}
+	pi.Add("east", 5)
+	pi.Add("west", 2)
+	pi.AddInTransit("west", 3)
+	pi.AddExpired("west", 1)
+	pi.AddStatus("east", inventory.StatusOnHold, 4)
+	pi.AddStatus("west", inventory.StatusDamaged, 2)
 
-	t.Run("update succeeds", func(t *testing.T) {
+	t.Run("product version is bumped and every location upserted in one statement", func(t *testing.T) {
 		repo, mock := newRepo(t)
-		mock.ExpectExec(updateProductInventory).
-			WithArgs(pi.Sku, pi.Available).
+		mock.ExpectExec(bumpProductVersion).
+			WithArgs(pi.Sku).
 			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mock.ExpectExec(upsertProductInventory).
+			WithArgs(pi.Sku, []string{"east", "west"}, []int64{5, 2}, []int64{0, 3}, []int64{0, 1}, []int64{4, 0}, []int64{0, 0}, []int64{0, 2}).
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
 
 		if err := repo.SaveProductInventory(context.Background(), pi); err != nil {
 			t.Errorf("unexpected error: %v", err)
@@ -133,22 +293,34 @@ func TestRepositorySaveProductInventory(t *testing.T) {
 		}
 	})
 
-	t.Run("update finds nothing, insert is issued", func(t *testing.T) {
+	t.Run("product moved on from the expected version is a conflict", func(t *testing.T) {
 		repo, mock := newRepo(t)
-		mock.ExpectExec(updateProductInventory).
-			WithArgs(pi.Sku, pi.Available).
+		mock.ExpectExec(bumpProductVersionIf).
+			WithArgs(pi.Sku, int64(6)).
 			WillReturnResult(pgxmock.NewResult("UPDATE", 0))
-		mock.ExpectExec(insertProductInventory).
-			WithArgs(pi.Sku, pi.Available).
-			WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
-		if err := repo.SaveProductInventory(context.Background(), pi); err != nil {
-			t.Errorf("unexpected error: %v", err)
+		err := repo.SaveProductInventory(context.Background(), pi, persistence.UpdateOptions{Tx: mock, Version: 6})
+		if !errors.Is(err, persistence.ErrVersionConflict) {
+			t.Errorf("expected ErrVersionConflict, got %v", err)
 		}
 		if err := mock.ExpectationsWereMet(); err != nil {
 			t.Errorf("unmet expectations: %v", err)
 		}
 	})
+
+	t.Run("error propagates", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(bumpProductVersion).
+			WithArgs(pi.Sku).
+			WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mock.ExpectExec(upsertProductInventory).
+			WithArgs(pi.Sku, []string{"east", "west"}, []int64{5, 2}, []int64{0, 3}, []int64{0, 1}, []int64{4, 0}, []int64{0, 0}, []int64{0, 2}).
+			WillReturnError(errors.New("boom"))
+
+		if err := repo.SaveProductInventory(context.Background(), pi); err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
 }
 
 func TestRepositoryGetProduct(t *testing.T) {
@@ -156,14 +328,14 @@ func TestRepositoryGetProduct(t *testing.T) {
 		repo, mock := newRepo(t)
 		mock.ExpectQuery(selectProduct).
 			WithArgs("sku1").
-			WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name"}).
-				AddRow("sku1", "upc1", "name1"))
+			WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version"}).
+				AddRow("sku1", "upc1", "name1", inventory.ProductActive, int64(4)))
 
 		got, err := repo.GetProduct(context.Background(), "sku1")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		want := inventory.Product{Sku: "sku1", Upc: "upc1", Name: "name1"}
+		want := inventory.Product{Sku: "sku1", Upc: "upc1", Name: "name1", State: inventory.ProductActive, Version: 4}
 		if got != want {
 			t.Errorf("got=%+v want=%+v", got, want)
 		}
@@ -183,37 +355,205 @@ func TestRepositoryGetProduct(t *testing.T) {
 }
 
 func TestRepositoryGetProductInventory(t *testing.T) {
-	t.Run("hit returns inventory", func(t *testing.T) {
+	t.Run("locations are folded into one inventory", func(t *testing.T) {
 		repo, mock := newRepo(t)
 		mock.ExpectQuery(selectProductInventory).
 			WithArgs("sku1").
-			WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "available"}).
-				AddRow("sku1", "upc1", "name1", int64(7)))
+			WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "available", "in_transit", "expired", "on_hold", "quarantined", "damaged"}).
+				AddRow("sku1", "upc1", "name1", inventory.ProductActive, int64(2), ptr("east"), ptr(int64(7)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0))).
+				AddRow("sku1", "upc1", "name1", inventory.ProductActive, int64(2), ptr("west"), ptr(int64(3)), ptr(int64(4)), ptr(int64(2)), ptr(int64(5)), ptr(int64(1)), ptr(int64(0)))).
+			RowsWillBeClosed()
 
 		got, err := repo.GetProductInventory(context.Background(), "sku1")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		if got.Sku != "sku1" || got.Available != 7 {
+		want := []inventory.LocationInventory{{Location: "east", Available: 7}, {Location: "west", Available: 3, InTransit: 4, Expired: 2, OnHold: 5, Quarantined: 1}}
+		if got.Sku != "sku1" || got.Available != 10 || got.InTransit != 4 || got.Expired != 2 || got.OnHold != 5 || got.Quarantined != 1 || !reflect.DeepEqual(got.Locations, want) {
 			t.Errorf("unexpected result: %+v", got)
 		}
 	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectProductInventory).
+			WithArgs("missing").
+			WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "available", "in_transit", "expired", "on_hold", "quarantined", "damaged"})).
+			RowsWillBeClosed()
+
+		_, err := repo.GetProductInventory(context.Background(), "missing")
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
 }
 
 func TestRepositoryGetAllProductInventory(t *testing.T) {
 	repo, mock := newRepo(t)
 	mock.ExpectQuery(selectAllInventory).
 		WithArgs(10, 0).
-		WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "available"}).
-			AddRow("a", "ua", "na", int64(1)).
-			AddRow("b", "ub", "nb", int64(2))).
+		WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "available", "in_transit", "expired", "on_hold", "quarantined", "damaged"}).
+			AddRow("a", "ua", "na", inventory.ProductActive, int64(2), ptr("east"), ptr(int64(1)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0))).
+			AddRow("a", "ua", "na", inventory.ProductActive, int64(2), ptr("west"), ptr(int64(4)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0))).
+			AddRow("b", "ub", "nb", inventory.ProductActive, int64(2), ptr("east"), ptr(int64(2)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)))).
 		RowsWillBeClosed()
 
-	got, err := repo.GetAllProductInventory(context.Background(), 10, 0)
+	got, err := repo.GetAllProductInventory(context.Background(), inventory.ProductListOptions{}, 10, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if len(got) != 2 || got[0].Sku != "a" || got[1].Sku != "b" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if got[0].Available != 5 || len(got[0].Locations) != 2 || got[1].Available != 2 {
+		t.Errorf("unexpected totals: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetAllProductInventoryAfter(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectQuery(selectAllInventoryAfter).
+		WithArgs(10, "a").
+		WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "available", "in_transit", "expired", "on_hold", "quarantined", "damaged"}).
+			AddRow("b", "ub", "nb", inventory.ProductActive, int64(2), ptr("east"), ptr(int64(2)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0))).
+			AddRow("b", "ub", "nb", inventory.ProductActive, int64(2), ptr("west"), ptr(int64(3)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)))).
+		RowsWillBeClosed()
+
+	got, err := repo.GetAllProductInventoryAfter(context.Background(), inventory.ProductListOptions{}, &inventory.ProductKey{Sku: "a"}, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].Sku != "b" || got[0].Available != 5 || len(got[0].Locations) != 2 {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetAllProductInventoryFilters(t *testing.T) {
+	emptyRows := func() *pgxmock.Rows {
+		return pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "available", "in_transit", "expired", "on_hold", "quarantined", "damaged"})
+	}
+	min, max := int64(1), int64(9)
+
+	t.Run("search and available bounds", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectInventoryFiltered).
+			WithArgs(10, 0, "%wid%", min, max).
+			WillReturnRows(emptyRows()).
+			RowsWillBeClosed()
+
+		_, err := repo.GetAllProductInventory(context.Background(), inventory.ProductListOptions{Search: "wid", AvailableMin: &min, AvailableMax: &max, Sort: inventory.SortByAvailable, Desc: true}, 10, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("search escapes LIKE wildcards", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectInventorySearch).
+			WithArgs(10, 0, `%50\%\_off\\%`).
+			WillReturnRows(emptyRows()).
+			RowsWillBeClosed()
+
+		_, err := repo.GetAllProductInventory(context.Background(), inventory.ProductListOptions{Search: `50%_off\`}, 10, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("name keyset compares name then sku", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectInventoryByNameAfter).
+			WithArgs(10, "Widget", "sku3").
+			WillReturnRows(emptyRows()).
+			RowsWillBeClosed()
+
+		_, err := repo.GetAllProductInventoryAfter(context.Background(), inventory.ProductListOptions{Sort: inventory.SortByName}, &inventory.ProductKey{Sku: "sku3", Name: "Widget", Available: 4}, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("descending available keyset seeks below the key", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectInventoryAvailableDesc).
+			WithArgs(10, max, int64(4), "sku3").
+			WillReturnRows(emptyRows()).
+			RowsWillBeClosed()
+
+		_, err := repo.GetAllProductInventoryAfter(context.Background(), inventory.ProductListOptions{AvailableMax: &max, Sort: inventory.SortByAvailable, Desc: true}, &inventory.ProductKey{Sku: "sku3", Name: "Widget", Available: 4}, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRepositoryGetProductInventoryAsOf(t *testing.T) {
+	asOf := time.Date(2026, time.January, 31, 23, 59, 59, 0, time.UTC)
+
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectInventoryAsOf).
+			WithArgs(asOf, "sku1").
+			WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "balance", "in_transit", "expired", "on_hold", "quarantined", "damaged"}).
+				AddRow("sku1", "upc1", "n1", inventory.ProductActive, int64(2), ptr("default"), ptr(int64(4)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)))).
+			RowsWillBeClosed()
+
+		got, err := repo.GetProductInventoryAsOf(context.Background(), "sku1", asOf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Sku != "sku1" || got.Available != 4 || got.AvailableAt("default") != 4 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectInventoryAsOf).
+			WithArgs(asOf, "missing").
+			WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "balance", "in_transit", "expired", "on_hold", "quarantined", "damaged"})).
+			RowsWillBeClosed()
+
+		_, err := repo.GetProductInventoryAsOf(context.Background(), "missing", asOf)
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryGetAllProductInventoryAsOf(t *testing.T) {
+	repo, mock := newRepo(t)
+	asOf := time.Date(2026, time.January, 31, 23, 59, 59, 0, time.UTC)
+	mock.ExpectQuery(selectAllInventoryAsOf).
+		WithArgs(asOf, 10, 0).
+		WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version", "location", "balance", "in_transit", "expired", "on_hold", "quarantined", "damaged"}).
+			AddRow("sku1", "upc1", "n1", inventory.ProductActive, int64(2), ptr("default"), ptr(int64(4)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0))).
+			AddRow("sku2", "upc2", "n2", inventory.ProductActive, int64(2), (*string)(nil), (*int64)(nil), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)))).
+		RowsWillBeClosed()
+
+	got, err := repo.GetAllProductInventoryAsOf(context.Background(), asOf, 10, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].Available != 4 || got[1].Available != 0 || len(got[1].Locations) != 0 {
 		t.Errorf("unexpected result: %+v", got)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -221,12 +561,75 @@ func TestRepositoryGetAllProductInventory(t *testing.T) {
 	}
 }
 
+func TestRepositoryGetProductCreated(t *testing.T) {
+	created := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectProductCreated).
+			WithArgs("sku1").
+			WillReturnRows(pgxmock.NewRows([]string{"created"}).AddRow(created))
+
+		got, err := repo.GetProductCreated(context.Background(), "sku1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !got.Equal(created) {
+			t.Errorf("created got=%v want=%v", got, created)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectProductCreated).
+			WithArgs("missing").
+			WillReturnRows(pgxmock.NewRows([]string{"created"}))
+
+		_, err := repo.GetProductCreated(context.Background(), "missing")
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryGetInventoryHistoryStart(t *testing.T) {
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectInventoryHistoryStart).
+			WillReturnRows(pgxmock.NewRows([]string{"min"}).AddRow(&start))
+
+		got, err := repo.GetInventoryHistoryStart(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !got.Equal(start) {
+			t.Errorf("start got=%v want=%v", got, start)
+		}
+	})
+
+	t.Run("no products maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectInventoryHistoryStart).
+			WillReturnRows(pgxmock.NewRows([]string{"min"}).AddRow((*time.Time)(nil)))
+
+		_, err := repo.GetInventoryHistoryStart(context.Background())
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func ptr[T any](v T) *T { return &v }
+
 func TestRepositorySaveProductionEvent(t *testing.T) {
 	t.Run("RETURNING id is scanned back into the event", func(t *testing.T) {
 		repo, mock := newRepo(t)
-		ev := &inventory.ProductionEvent{RequestID: "req1", Sku: "sku1", Quantity: 4, Created: time.Unix(0, 0).UTC()}
+		expiresAt := time.Unix(3600, 0).UTC()
+		ev := &inventory.ProductionEvent{RequestID: "req1", Sku: "sku1", Location: "east", Quantity: 4, Lot: "L1", ExpiresAt: &expiresAt, Created: time.Unix(0, 0).UTC()}
 		mock.ExpectQuery(insertProductionEvent).
-			WithArgs(ev.RequestID, ev.Sku, ev.Quantity, ev.Created).
+			WithArgs(ev.RequestID, ev.Sku, ev.Location, ev.Quantity, ev.Lot, ev.ExpiresAt, ev.Created).
 			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(42)))
 
 		if err := repo.SaveProductionEvent(context.Background(), ev); err != nil {
@@ -243,14 +646,14 @@ func TestRepositoryGetProductionEventByRequestID(t *testing.T) {
 	created := time.Unix(0, 0).UTC()
 	mock.ExpectQuery(selectProductionEvent).
 		WithArgs("req1").
-		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "sku", "quantity", "created"}).
-			AddRow(uint64(7), "req1", "sku1", int64(3), created))
+		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "sku", "location", "quantity", "lot", "expires_at", "created"}).
+			AddRow(uint64(7), "req1", "sku1", "east", int64(3), "L1", (*time.Time)(nil), created))
 
 	got, err := repo.GetProductionEventByRequestID(context.Background(), "req1")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if got.ID != 7 || got.RequestID != "req1" {
+	if got.ID != 7 || got.RequestID != "req1" || got.Lot != "L1" {
 		t.Errorf("unexpected result: %+v", got)
 	}
 }
@@ -267,11 +670,11 @@ func TestRepositoryGetProductionEventByRequestID_ForUpdate(t *testing.T) {
 	repo, mock := newRepo(t)
 	mock.ExpectBegin()
 	created := time.Unix(0, 0).UTC()
-	pattern := `^SELECT id, request_id, sku, quantity, created FROM production_events WHERE request_id = \$1 FOR UPDATE\s*$`
+	pattern := `^SELECT id, request_id, sku, location, quantity, COALESCE\(lot, ''\), expires_at, created FROM production_events WHERE request_id = \$1 FOR UPDATE\s*$`
 	mock.ExpectQuery(pattern).
 		WithArgs("req1").
-		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "sku", "quantity", "created"}).
-			AddRow(uint64(7), "req1", "sku1", int64(3), created))
+		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "sku", "location", "quantity", "lot", "expires_at", "created"}).
+			AddRow(uint64(7), "req1", "sku1", "east", int64(3), "", (*time.Time)(nil), created))
 
 	tx, err := repo.BeginTransaction(context.Background())
 	if err != nil {
@@ -293,18 +696,19 @@ func TestRepositoryGetProductionEventByRequestID_ForUpdate(t *testing.T) {
 func TestRepositorySaveReservation(t *testing.T) {
 	repo, mock := newRepo(t)
 	r := &inventory.Reservation{
-		RequestID: "req1", Requester: "x", Sku: "sku1",
-		State: inventory.Open, ReservedQuantity: 0, RequestedQuantity: 5, Created: time.Unix(0, 0).UTC(),
+		RequestID: "req1", Requester: "x", Sku: "sku1", Location: "east",
+		State: inventory.Open, ReservedQuantity: 0, RequestedQuantity: 5, Created: time.Unix(0, 0).UTC(), Priority: 5,
+		FillPolicy: inventory.FillMinimum, MinQuantity: 2,
 	}
 	mock.ExpectQuery(insertReservation).
-		WithArgs(r.RequestID, r.Requester, r.Sku, r.State, r.ReservedQuantity, r.RequestedQuantity, r.Created).
-		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(99)))
+		WithArgs(r.RequestID, r.Requester, r.Sku, r.Location, r.State, r.ReservedQuantity, r.RequestedQuantity, r.Created, r.ExpiresAt, r.Priority, r.FillPolicy, r.MinQuantity).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "version"}).AddRow(uint64(99), int64(1)))
 
 	if err := repo.SaveReservation(context.Background(), r); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if r.ID != 99 {
-		t.Errorf("expected ID=99, got %d", r.ID)
+	if r.ID != 99 || r.Version != 1 {
+		t.Errorf("expected ID=99 version=1, got %d version=%d", r.ID, r.Version)
 	}
 }
 
@@ -319,9 +723,37 @@ func TestRepositoryUpdateReservation(t *testing.T) {
 	}
 }
 
+func TestRepositoryUpdateReservationAtVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{name: "still at the version", affected: 1},
+		{name: "moved on is a conflict", affected: 0, wantErr: persistence.ErrVersionConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo, mock := newRepo(t)
+			mock.ExpectExec(updateReservationStmtIf).
+				WithArgs(uint64(7), inventory.Cancelled, int64(0), int64(4)).
+				WillReturnResult(pgxmock.NewResult("UPDATE", test.affected))
+
+			err := repo.UpdateReservation(context.Background(), 7, inventory.Cancelled, 0, persistence.UpdateOptions{Tx: mock, Version: 4})
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("err got=%v want=%v", err, test.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
 func TestRepositoryGetReservations(t *testing.T) {
 	emptyRows := func() *pgxmock.Rows {
-		return pgxmock.NewRows([]string{"id", "request_id", "requester", "sku", "state", "reserved_quantity", "requested_quantity", "created"})
+		return pgxmock.NewRows([]string{"id", "request_id", "requester", "sku", "location", "state", "reserved_quantity", "requested_quantity", "shipped_quantity", "created", "expires_at", "priority", "fill_policy", "min_quantity", "version"})
 	}
 
 	t.Run("no filters builds bare LIMIT/OFFSET query", func(t *testing.T) {
@@ -376,13 +808,72 @@ func TestRepositoryGetReservations(t *testing.T) {
 	})
 }
 
+func TestRepositoryGetReservationsAfter(t *testing.T) {
+	created := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	emptyRows := func() *pgxmock.Rows {
+		return pgxmock.NewRows([]string{"id", "request_id", "requester", "sku", "location", "state", "reserved_quantity", "requested_quantity", "shipped_quantity", "created", "expires_at", "priority", "fill_policy", "min_quantity", "version"})
+	}
+
+	t.Run("first page has no key predicate", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(listReservationsFirstPage).
+			WithArgs(10).
+			WillReturnRows(emptyRows().
+				AddRow(uint64(7), "req1", "x", "sku1", "east", inventory.Open, int64(0), int64(5), int64(0), created, (*time.Time)(nil), 0, inventory.FillPartial, int64(0), int64(1))).
+			RowsWillBeClosed()
+
+		got, err := repo.GetReservationsAfter(context.Background(), inventory.GetReservationsOptions{}, nil, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 || got[0].ID != 7 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("key seeks past created and id", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(listReservationsAfter).
+			WithArgs(10, created, uint64(7)).
+			WillReturnRows(emptyRows()).
+			RowsWillBeClosed()
+
+		_, err := repo.GetReservationsAfter(context.Background(), inventory.GetReservationsOptions{}, &inventory.ReservationKey{Created: created, ID: 7}, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("key follows the filters", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(listReservationsBySkuAfter).
+			WithArgs(10, "sku1", created, uint64(7)).
+			WillReturnRows(emptyRows()).
+			RowsWillBeClosed()
+
+		_, err := repo.GetReservationsAfter(context.Background(), inventory.GetReservationsOptions{Sku: "sku1"}, &inventory.ReservationKey{Created: created, ID: 7}, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
 func TestRepositoryGetReservation(t *testing.T) {
 	repo, mock := newRepo(t)
 	created := time.Unix(0, 0).UTC()
 	mock.ExpectQuery(selectReservationByID).
 		WithArgs(uint64(7)).
-		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "requester", "sku", "state", "reserved_quantity", "requested_quantity", "created"}).
-			AddRow(uint64(7), "req1", "x", "sku1", inventory.Open, int64(0), int64(5), created))
+		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "requester", "sku", "location", "state", "reserved_quantity", "requested_quantity", "shipped_quantity", "created", "expires_at", "priority", "fill_policy", "min_quantity", "version"}).
+			AddRow(uint64(7), "req1", "x", "sku1", "east", inventory.Open, int64(0), int64(5), int64(0), created, (*time.Time)(nil), 0, inventory.FillPartial, int64(0), int64(3)))
 
 	got, err := repo.GetReservation(context.Background(), 7)
 	if err != nil {
@@ -398,8 +889,8 @@ func TestRepositoryGetReservationByRequestID(t *testing.T) {
 	created := time.Unix(0, 0).UTC()
 	mock.ExpectQuery(selectReservationByReq).
 		WithArgs("req1").
-		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "requester", "sku", "state", "reserved_quantity", "requested_quantity", "created"}).
-			AddRow(uint64(7), "req1", "x", "sku1", inventory.Open, int64(0), int64(5), created))
+		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "requester", "sku", "location", "state", "reserved_quantity", "requested_quantity", "shipped_quantity", "created", "expires_at", "priority", "fill_policy", "min_quantity", "version"}).
+			AddRow(uint64(7), "req1", "x", "sku1", "east", inventory.Open, int64(0), int64(5), int64(0), created, (*time.Time)(nil), 0, inventory.FillPartial, int64(0), int64(3)))
 
 	got, err := repo.GetReservationByRequestID(context.Background(), "req1")
 	if err != nil {
@@ -410,30 +901,1245 @@ func TestRepositoryGetReservationByRequestID(t *testing.T) {
 	}
 }
 
-func TestRepositoryBeginTransaction(t *testing.T) {
-	t.Run("delegates to conn.Begin", func(t *testing.T) {
+func TestRepositoryGetExpiredReservations(t *testing.T) {
+	repo, mock := newRepo(t)
+	created := time.Unix(0, 0).UTC()
+	expiresAt := created.Add(time.Minute)
+	asOf := created.Add(time.Hour)
+	mock.ExpectQuery(listExpiredReservations).
+		WithArgs(asOf, inventory.Open, inventory.Closed, 100).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "requester", "sku", "location", "state", "reserved_quantity", "requested_quantity", "shipped_quantity", "created", "expires_at", "priority", "fill_policy", "min_quantity", "version"}).
+			AddRow(uint64(7), "req1", "x", "sku1", "east", inventory.Open, int64(2), int64(5), int64(0), created, &expiresAt, 2, inventory.FillMinimum, int64(2), int64(3))).
+		RowsWillBeClosed()
+
+	got, err := repo.GetExpiredReservations(context.Background(), asOf, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].ExpiresAt == nil || !got[0].ExpiresAt.Equal(expiresAt) || got[0].Priority != 2 || got[0].MinimumFill() != 2 {
+		t.Errorf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetBackorders(t *testing.T) {
+	oldest := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	cols := []string{"sku", "count", "requested", "reserved", "shortfall", "oldest"}
+
+	t.Run("every sku", func(t *testing.T) {
 		repo, mock := newRepo(t)
-		mock.ExpectBegin()
+		mock.ExpectQuery(listBackorders).
+			WithArgs(50, 0).
+			WillReturnRows(pgxmock.NewRows(cols).
+				AddRow("sku1", int64(2), int64(10), int64(4), int64(6), oldest).
+				AddRow("sku2", int64(1), int64(3), int64(0), int64(3), oldest)).
+			RowsWillBeClosed()
 
-		tx, err := repo.BeginTransaction(context.Background())
+		got, err := repo.GetBackorders(context.Background(), "", 50, 0)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		if tx == nil {
-			t.Fatal("expected non-nil transaction")
+		want := []inventory.Backorder{
+			{Sku: "sku1", OpenReservations: 2, Requested: 10, Reserved: 4, Shortfall: 6, OldestCreated: oldest},
+			{Sku: "sku2", OpenReservations: 1, Requested: 3, Shortfall: 3, OldestCreated: oldest},
 		}
-		if err := mock.ExpectationsWereMet(); err != nil {
-			t.Errorf("unmet expectations: %v", err)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got=%+v want=%+v", got, want)
+		}
+	})
+
+	t.Run("one sku", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(listBackordersBySku).
+			WithArgs(50, 0, "sku1").
+			WillReturnRows(pgxmock.NewRows(cols))
+
+		got, err := repo.GetBackorders(context.Background(), "sku1", 50, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRepositoryUpdateReservationShipment(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectExec(updateReservationShipment).
+		WithArgs(uint64(7), inventory.Fulfilled, int64(5)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := repo.UpdateReservationShipment(context.Background(), 7, inventory.Fulfilled, 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryUpdateReservationQuantity(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectExec(updateReservationQuantity).
+		WithArgs(uint64(7), inventory.Open, int64(12), int64(10)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := repo.UpdateReservationQuantity(context.Background(), 7, inventory.Open, 12, 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositorySaveShipment(t *testing.T) {
+	repo, mock := newRepo(t)
+	sh := &inventory.Shipment{RequestID: "ship1", ReservationID: 7, Sku: "sku1", Quantity: 2, Created: time.Unix(0, 0).UTC()}
+	mock.ExpectQuery(insertShipment).
+		WithArgs(sh.RequestID, sh.ReservationID, sh.Sku, sh.Quantity, sh.Created).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(11)))
+
+	if err := repo.SaveShipment(context.Background(), sh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sh.ID != 11 {
+		t.Errorf("expected ID=11, got %d", sh.ID)
+	}
+}
+
+func TestRepositoryGetShipmentByRequestID(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		created := time.Unix(0, 0).UTC()
+		mock.ExpectQuery(selectShipmentByReq).
+			WithArgs("ship1").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "reservation_id", "sku", "quantity", "created"}).
+				AddRow(uint64(11), "ship1", uint64(7), "sku1", int64(2), created))
+
+		got, err := repo.GetShipmentByRequestID(context.Background(), "ship1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ID != 11 || got.ReservationID != 7 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectShipmentByReq).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.GetShipmentByRequestID(context.Background(), "missing")
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositorySaveAdjustment(t *testing.T) {
+	repo, mock := newRepo(t)
+	a := &inventory.Adjustment{RequestID: "adj1", Sku: "sku1", Location: "east", Quantity: -2, Reason: inventory.AdjustDamage, Actor: "carol", Created: time.Unix(0, 0).UTC()}
+	mock.ExpectQuery(insertAdjustment).
+		WithArgs(a.RequestID, a.Sku, a.Location, a.Quantity, a.Reason, a.Actor, a.Created).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(5)))
+
+	if err := repo.SaveAdjustment(context.Background(), a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.ID != 5 {
+		t.Errorf("expected ID=5, got %d", a.ID)
+	}
+}
+
+func TestRepositoryGetAdjustmentByRequestID(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		created := time.Unix(0, 0).UTC()
+		mock.ExpectQuery(selectAdjustmentByReq).
+			WithArgs("adj1").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "sku", "location", "quantity", "reason", "actor", "created"}).
+				AddRow(uint64(5), "adj1", "sku1", "east", int64(-2), inventory.AdjustDamage, "carol", created))
+
+		got, err := repo.GetAdjustmentByRequestID(context.Background(), "adj1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ID != 5 || got.Quantity != -2 || got.Reason != inventory.AdjustDamage {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectAdjustmentByReq).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.GetAdjustmentByRequestID(context.Background(), "missing")
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositorySaveStatusChange(t *testing.T) {
+	repo, mock := newRepo(t)
+	c := &inventory.StatusChange{RequestID: "hold1", Sku: "sku1", Location: "east", From: inventory.StatusAvailable, To: inventory.StatusOnHold, Quantity: 3, Lot: "L1", Reason: "QA sample", Actor: "carol", Created: time.Unix(0, 0).UTC()}
+	mock.ExpectQuery(insertStatusChange).
+		WithArgs(c.RequestID, c.Sku, c.Location, c.From, c.To, c.Quantity, c.Lot, c.Reason, c.Actor, c.Created).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(6)))
+
+	if err := repo.SaveStatusChange(context.Background(), c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.ID != 6 {
+		t.Errorf("expected ID=6, got %d", c.ID)
+	}
+}
+
+func TestRepositoryGetStatusChangeByRequestID(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		created := time.Unix(0, 0).UTC()
+		mock.ExpectQuery(selectStatusChangeByReq).
+			WithArgs("hold1").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "sku", "location", "from_status", "to_status", "quantity", "lot", "reason", "actor", "created"}).
+				AddRow(uint64(6), "hold1", "sku1", "east", inventory.StatusAvailable, inventory.StatusOnHold, int64(3), "", "QA sample", "carol", created))
+
+		got, err := repo.GetStatusChangeByRequestID(context.Background(), "hold1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := inventory.StatusChange{ID: 6, RequestID: "hold1", Sku: "sku1", Location: "east", From: inventory.StatusAvailable, To: inventory.StatusOnHold, Quantity: 3, Reason: "QA sample", Actor: "carol", Created: created}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got=%+v want=%+v", got, want)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectStatusChangeByReq).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.GetStatusChangeByRequestID(context.Background(), "missing")
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositorySaveInventoryMovement(t *testing.T) {
+	t.Run("production without a reservation", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mv := &inventory.InventoryMovement{Sku: "sku1", Location: "east", Delta: 5, Reason: inventory.MovementProduction, RequestID: "prod1", Actor: "alice", Balance: 5, Created: time.Unix(0, 0).UTC()}
+		requestID := "prod1"
+		mock.ExpectQuery(insertInventoryMovement).
+			WithArgs(mv.Sku, mv.Location, mv.Delta, mv.Reason, &requestID, (*uint64)(nil), (*string)(nil), mv.Actor, mv.Balance, mv.Created).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(3)))
+
+		if err := repo.SaveInventoryMovement(context.Background(), mv); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if mv.ID != 3 {
+			t.Errorf("expected ID=3, got %d", mv.ID)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("empty request id is stored as null", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		rsvID := uint64(7)
+		mv := &inventory.InventoryMovement{Sku: "sku1", Location: "east", Delta: 2, Reason: inventory.MovementExpiry, ReservationID: &rsvID, Actor: "system", Balance: 9, Created: time.Unix(0, 0).UTC()}
+		mock.ExpectQuery(insertInventoryMovement).
+			WithArgs(mv.Sku, mv.Location, mv.Delta, mv.Reason, (*string)(nil), &rsvID, (*string)(nil), mv.Actor, mv.Balance, mv.Created).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(4)))
+
+		if err := repo.SaveInventoryMovement(context.Background(), mv); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("lot expiry keeps the lot out of request id", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mv := &inventory.InventoryMovement{Sku: "sku1", Location: "east", Delta: -4, Reason: inventory.MovementLotExpiry, Lot: "LOT-42", Actor: "system", Balance: 1, Created: time.Unix(0, 0).UTC()}
+		lot := "LOT-42"
+		mock.ExpectQuery(insertInventoryMovement).
+			WithArgs(mv.Sku, mv.Location, mv.Delta, mv.Reason, (*string)(nil), (*uint64)(nil), &lot, mv.Actor, mv.Balance, mv.Created).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(5)))
+
+		if err := repo.SaveInventoryMovement(context.Background(), mv); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRepositoryGetInventoryMovements(t *testing.T) {
+	repo, mock := newRepo(t)
+	created := time.Unix(0, 0).UTC()
+	rsvID := uint64(7)
+	mock.ExpectQuery(listInventoryMovements).
+		WithArgs("sku1", 50, 0).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "sku", "location", "delta", "reason", "request_id", "reservation_id", "lot", "actor", "balance", "created"}).
+			AddRow(uint64(1), "sku1", "east", int64(5), inventory.MovementProduction, "prod1", (*uint64)(nil), "L1", "alice", int64(5), created).
+			AddRow(uint64(2), "sku1", "east", int64(-3), inventory.MovementReservation, "rsv1", &rsvID, "", "alice", int64(2), created)).
+		RowsWillBeClosed()
+
+	got, err := repo.GetInventoryMovements(context.Background(), "sku1", 50, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].ReservationID != nil || got[0].Lot != "L1" || got[1].ReservationID == nil || *got[1].ReservationID != 7 || got[1].Balance != 2 {
+		t.Errorf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositorySaveTransfer(t *testing.T) {
+	repo, mock := newRepo(t)
+	created := time.Unix(0, 0).UTC()
+	tr := &inventory.Transfer{RequestID: "xfer1", Sku: "sku1", From: "east", To: "west", Quantity: 4, State: inventory.TransferRequested, Actor: "dave", Created: created, Updated: created}
+	mock.ExpectQuery(insertTransfer).
+		WithArgs(tr.RequestID, tr.Sku, tr.From, tr.To, tr.Quantity, tr.State, tr.Actor, tr.Created, tr.Updated).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(8)))
+
+	if err := repo.SaveTransfer(context.Background(), tr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tr.ID != 8 {
+		t.Errorf("expected ID=8, got %d", tr.ID)
+	}
+}
+
+func TestRepositoryUpdateTransfer(t *testing.T) {
+	repo, mock := newRepo(t)
+	updated := time.Unix(60, 0).UTC()
+	mock.ExpectExec(updateTransfer).
+		WithArgs(uint64(8), inventory.TransferReceived, updated).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := repo.UpdateTransfer(context.Background(), 8, inventory.TransferReceived, updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetTransfer(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		created := time.Unix(0, 0).UTC()
+		mock.ExpectQuery(selectTransferByID).
+			WithArgs(uint64(8)).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "sku", "from_location", "to_location", "quantity", "state", "actor", "created", "updated"}).
+				AddRow(uint64(8), "xfer1", "sku1", "east", "west", int64(4), inventory.TransferInTransit, "dave", created, created))
+
+		got, err := repo.GetTransfer(context.Background(), 8)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.From != "east" || got.To != "west" || got.State != inventory.TransferInTransit {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectTransferByID).
+			WithArgs(uint64(9)).
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.GetTransfer(context.Background(), 9)
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryGetTransfers(t *testing.T) {
+	repo, mock := newRepo(t)
+	created := time.Unix(0, 0).UTC()
+	mock.ExpectQuery(listTransfers).
+		WithArgs("sku1", 50, 0).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "sku", "from_location", "to_location", "quantity", "state", "actor", "created", "updated"}).
+			AddRow(uint64(8), "xfer1", "sku1", "east", "west", int64(4), inventory.TransferReceived, "dave", created, created).
+			AddRow(uint64(9), "xfer2", "sku1", "west", "east", int64(1), inventory.TransferRequested, "dave", created, created)).
+		RowsWillBeClosed()
+
+	got, err := repo.GetTransfers(context.Background(), "sku1", 50, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 8 || got[1].State != inventory.TransferRequested {
+		t.Errorf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetBillOfMaterials(t *testing.T) {
+	t.Run("components in sku order", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectBillOfMaterials).
+			WithArgs("kit").
+			WillReturnRows(pgxmock.NewRows([]string{"component_sku", "quantity"}).
+				AddRow("bolt", int64(2)).
+				AddRow("nut", int64(4))).
+			RowsWillBeClosed()
+
+		got, err := repo.GetBillOfMaterials(context.Background(), "kit")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Sku != "kit" || len(got.Components) != 2 || got.Components[1] != (inventory.BOMComponent{Sku: "nut", Quantity: 4}) {
+			t.Errorf("unexpected result: %+v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("no rows is an empty bill of materials", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectBillOfMaterials).
+			WithArgs("bolt").
+			WillReturnRows(pgxmock.NewRows([]string{"component_sku", "quantity"}))
+
+		got, err := repo.GetBillOfMaterials(context.Background(), "bolt")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Sku != "bolt" || len(got.Components) != 0 {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+}
+
+func TestRepositorySaveBillOfMaterials(t *testing.T) {
+	t.Run("replaces the components", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(deleteBillOfMaterials).
+			WithArgs("kit").
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		mock.ExpectExec(insertBillOfMaterials).
+			WithArgs("kit", []string{"bolt", "nut"}, []int64{2, 4}).
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+
+		bom := inventory.BillOfMaterials{Sku: "kit", Components: []inventory.BOMComponent{{Sku: "bolt", Quantity: 2}, {Sku: "nut", Quantity: 4}}}
+		if err := repo.SaveBillOfMaterials(context.Background(), bom); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("no components only deletes", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(deleteBillOfMaterials).
+			WithArgs("kit").
+			WillReturnResult(pgxmock.NewResult("DELETE", 2))
+
+		if err := repo.SaveBillOfMaterials(context.Background(), inventory.BillOfMaterials{Sku: "kit"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRepositoryBeginTransaction(t *testing.T) {
+	t.Run("delegates to conn.Begin", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectBegin()
+
+		tx, err := repo.BeginTransaction(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tx == nil {
+			t.Fatal("expected non-nil transaction")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
 		}
 	})
 
 	t.Run("Begin error propagates", func(t *testing.T) {
 		repo, mock := newRepo(t)
-		mock.ExpectBegin().WillReturnError(errors.New("boom"))
+		mock.ExpectBegin().WillReturnError(errors.New("boom"))
+
+		_, err := repo.BeginTransaction(context.Background())
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	})
+}
+
+func TestRepositoryGetLots(t *testing.T) {
+	repo, mock := newRepo(t)
+	expiresAt := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectLots).
+		WithArgs("sku1").
+		WillReturnRows(pgxmock.NewRows([]string{"sku", "location", "lot", "expires_at", "available", "expired"}).
+			AddRow("sku1", "east", "L1", &expiresAt, int64(4), int64(0)).
+			AddRow("sku1", "east", "L2", (*time.Time)(nil), int64(2), int64(0))).
+		RowsWillBeClosed()
+
+	tx, err := repo.BeginTransaction(context.Background())
+	if err != nil {
+		t.Fatalf("BeginTransaction: %v", err)
+	}
+	got, err := repo.GetLots(context.Background(), "sku1", persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []inventory.Lot{
+		{Sku: "sku1", Location: "east", Lot: "L1", ExpiresAt: &expiresAt, Available: 4},
+		{Sku: "sku1", Location: "east", Lot: "L2", Available: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%+v want=%+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetExpiredLots(t *testing.T) {
+	repo, mock := newRepo(t)
+	asOf := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+	expiresAt := asOf.Add(-time.Hour)
+	mock.ExpectQuery(selectExpiredLots).
+		WithArgs(asOf, 100).
+		WillReturnRows(pgxmock.NewRows([]string{"sku", "location", "lot", "expires_at", "available", "expired"}).
+			AddRow("sku1", "east", "L1", &expiresAt, int64(4), int64(0))).
+		RowsWillBeClosed()
+
+	got, err := repo.GetExpiredLots(context.Background(), asOf, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].Lot != "L1" || got[0].Available != 4 {
+		t.Errorf("unexpected result: %+v", got)
+	}
+}
+
+func TestRepositorySaveLots(t *testing.T) {
+	repo, mock := newRepo(t)
+	expiresAt := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(upsertLots).
+		WithArgs([]string{"sku1", "sku1"}, []string{"east", "east"}, []string{"L1", "L2"}, []*time.Time{&expiresAt, nil}, []int64{0, 2}, []int64{4, 0}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+
+	lots := []inventory.Lot{
+		{Sku: "sku1", Location: "east", Lot: "L1", ExpiresAt: &expiresAt, Expired: 4},
+		{Sku: "sku1", Location: "east", Lot: "L2", Available: 2},
+	}
+	if err := repo.SaveLots(context.Background(), lots); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryReservationLots(t *testing.T) {
+	t.Run("draws are added to what the reservation holds", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(upsertReservationLots).
+			WithArgs(uint64(9), []string{"L1", "L2"}, []int64{3, -1}).
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+
+		draws := []inventory.LotDraw{{Lot: "L1", Quantity: 3}, {Lot: "L2", Quantity: -1}}
+		if err := repo.SaveReservationLots(context.Background(), 9, draws); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("held lots are read back", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectReservationLots).
+			WithArgs(uint64(9)).
+			WillReturnRows(pgxmock.NewRows([]string{"lot", "quantity"}).
+				AddRow("L1", int64(3))).
+			RowsWillBeClosed()
+
+		got, err := repo.GetReservationLots(context.Background(), 9)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, []inventory.LotDraw{{Lot: "L1", Quantity: 3}}) {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+}
+
+func TestRepositoryTransferLots(t *testing.T) {
+	t.Run("lots taken are recorded", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(insertTransferLots).
+			WithArgs(uint64(4), []string{"L1", "L2"}, []int64{3, 1}).
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+
+		draws := []inventory.LotDraw{{Lot: "L1", Quantity: 3}, {Lot: "L2", Quantity: 1}}
+		if err := repo.SaveTransferLots(context.Background(), 4, draws); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("lots taken are read back", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectTransferLots).
+			WithArgs(uint64(4)).
+			WillReturnRows(pgxmock.NewRows([]string{"lot", "quantity"}).
+				AddRow("L1", int64(3))).
+			RowsWillBeClosed()
+
+		got, err := repo.GetTransferLots(context.Background(), 4)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, []inventory.LotDraw{{Lot: "L1", Quantity: 3}}) {
+			t.Errorf("unexpected result: %+v", got)
+		}
+	})
+}
+
+func TestRepositoryGetLotRecipients(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectQuery(selectLotRecipients).
+		WithArgs("sku1", "L1", 50, 0).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "request_id", "requester", "location", "state", "quantity", "shipped_quantity"}).
+			AddRow(uint64(9), "req9", "alice", "east", inventory.Closed, int64(3), int64(2))).
+		RowsWillBeClosed()
+
+	got, err := repo.GetLotRecipients(context.Background(), "sku1", "L1", 50, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []inventory.LotRecipient{{ReservationID: 9, RequestID: "req9", Requester: "alice", Location: "east", State: inventory.Closed, Quantity: 3, ShippedQuantity: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%+v want=%+v", got, want)
+	}
+}
+
+func TestRepositoryGetReorderPolicy(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectReorderPolicy).
+			WithArgs("sku1").
+			WillReturnRows(pgxmock.NewRows([]string{"sku", "reorder_point", "target_level", "low_since"}).
+				AddRow("sku1", int64(10), int64(50), (*time.Time)(nil)))
+
+		got, err := repo.GetReorderPolicy(context.Background(), "sku1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := inventory.ReorderPolicy{Sku: "sku1", ReorderPoint: 10, TargetLevel: 50}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got=%+v want=%+v", got, want)
+		}
+	})
+
+	t.Run("no policy is not found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectReorderPolicy).
+			WithArgs("sku1").
+			WillReturnError(pgx.ErrNoRows)
+
+		if _, err := repo.GetReorderPolicy(context.Background(), "sku1"); !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("got err=%v want ErrNotFound", err)
+		}
+	})
+}
+
+func TestRepositorySaveReorderPolicy(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectExec(upsertReorderPolicy).
+		WithArgs("sku1", int64(10), int64(50)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	if err := repo.SaveReorderPolicy(context.Background(), inventory.ReorderPolicy{Sku: "sku1", ReorderPoint: 10, TargetLevel: 50}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryDeleteReorderPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		wantErr  error
+	}{
+		{name: "deleted", affected: 1},
+		{name: "no policy is not found", affected: 0, wantErr: persistence.ErrNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo, mock := newRepo(t)
+			mock.ExpectExec(deleteReorderPolicy).
+				WithArgs("sku1").
+				WillReturnResult(pgxmock.NewResult("DELETE", test.affected))
+
+			err := repo.DeleteReorderPolicy(context.Background(), "sku1")
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got err=%v want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepositoryUpdateLowStock(t *testing.T) {
+	at := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("crossing below returns the policy", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(updateLowStock).
+			WithArgs("sku1", int64(4), at).
+			WillReturnRows(pgxmock.NewRows([]string{"sku", "reorder_point", "target_level", "low_since"}).
+				AddRow("sku1", int64(10), int64(50), &at))
+
+		got, flipped, err := repo.UpdateLowStock(context.Background(), "sku1", 4, at)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := inventory.ReorderPolicy{Sku: "sku1", ReorderPoint: 10, TargetLevel: 50, LowSince: &at}
+		if !flipped || !reflect.DeepEqual(got, want) {
+			t.Errorf("got=%+v flipped=%v want=%+v", got, flipped, want)
+		}
+	})
+
+	t.Run("no change matches no row", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(updateLowStock).
+			WithArgs("sku1", int64(3), at).
+			WillReturnError(pgx.ErrNoRows)
+
+		_, flipped, err := repo.UpdateLowStock(context.Background(), "sku1", 3, at)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if flipped {
+			t.Error("expected no flip")
+		}
+	})
+}
+
+func TestRepositoryGetLowStock(t *testing.T) {
+	repo, mock := newRepo(t)
+	lowSince := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(selectLowStock).
+		WithArgs(50, 0).
+		WillReturnRows(pgxmock.NewRows([]string{"sku", "reorder_point", "target_level", "low_since", "available"}).
+			AddRow("sku1", int64(10), int64(50), &lowSince, int64(4))).
+		RowsWillBeClosed()
+
+	got, err := repo.GetLowStock(context.Background(), 50, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []inventory.LowStock{{Sku: "sku1", Available: 4, ReorderPoint: 10, TargetLevel: 50, SuggestedQuantity: 46, LowSince: &lowSince}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%+v want=%+v", got, want)
+	}
+}
+
+var plannedOrderCols = []string{"id", "request_id", "sku", "quantity", "expected_date", "state", "production_request_id", "actor", "created", "updated"}
+
+func TestRepositoryGetPlannedOrderByRequestID(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		expected := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+		created := time.Unix(0, 0).UTC()
+		mock.ExpectQuery(selectPlannedOrderByReq).
+			WithArgs("plan1").
+			WillReturnRows(pgxmock.NewRows(plannedOrderCols).
+				AddRow(uint64(3), "plan1", "sku1", int64(20), expected, inventory.PlannedOrderCompleted, "prod1", "dave", created, created))
 
-		_, err := repo.BeginTransaction(context.Background())
-		if err == nil {
-			t.Error("expected error, got nil")
+		got, err := repo.GetPlannedOrderByRequestID(context.Background(), "plan1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := inventory.PlannedOrder{ID: 3, RequestID: "plan1", Sku: "sku1", Quantity: 20, ExpectedDate: expected, State: inventory.PlannedOrderCompleted, ProductionRequestID: "prod1", Actor: "dave", Created: created, Updated: created}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got=%+v want=%+v", got, want)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectPlannedOrderByReq).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.GetPlannedOrderByRequestID(context.Background(), "missing")
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryGetScheduledOrders(t *testing.T) {
+	repo, mock := newRepo(t)
+	first := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 7)
+	created := time.Unix(0, 0).UTC()
+	mock.ExpectQuery(listScheduledOrders).
+		WithArgs("sku1", 100, 0).
+		WillReturnRows(pgxmock.NewRows(plannedOrderCols).
+			AddRow(uint64(3), "plan1", "sku1", int64(20), first, inventory.PlannedOrderScheduled, "", "dave", created, created).
+			AddRow(uint64(4), "plan2", "sku1", int64(5), second, inventory.PlannedOrderScheduled, "", "dave", created, created)).
+		RowsWillBeClosed()
+
+	got, err := repo.GetScheduledOrders(context.Background(), "sku1", 100, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].RequestID != "plan1" || !got[1].ExpectedDate.Equal(second) {
+		t.Errorf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositorySavePlannedOrder(t *testing.T) {
+	repo, mock := newRepo(t)
+	created := time.Unix(0, 0).UTC()
+	o := &inventory.PlannedOrder{RequestID: "plan1", Sku: "sku1", Quantity: 20, ExpectedDate: created.AddDate(0, 0, 7), State: inventory.PlannedOrderScheduled, Actor: "dave", Created: created, Updated: created}
+	mock.ExpectQuery(insertPlannedOrder).
+		WithArgs(o.RequestID, o.Sku, o.Quantity, o.ExpectedDate, o.State, o.Actor, o.Created, o.Updated).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(3)))
+
+	if err := repo.SavePlannedOrder(context.Background(), o); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if o.ID != 3 {
+		t.Errorf("expected ID=3, got %d", o.ID)
+	}
+}
+
+func TestRepositoryUpdatePlannedOrder(t *testing.T) {
+	repo, mock := newRepo(t)
+	updated := time.Unix(60, 0).UTC()
+	mock.ExpectExec(updatePlannedOrder).
+		WithArgs(uint64(3), inventory.PlannedOrderCompleted, "prod1", updated).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := repo.UpdatePlannedOrder(context.Background(), 3, inventory.PlannedOrderCompleted, "prod1", updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+var returnCols = []string{"id", "request_id", "sku", "location", "reservation_id", "requester", "quantity", "outcome", "lot", "reason", "actor", "created"}
+
+func TestRepositoryGetReturnByRequestID(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		created := time.Unix(0, 0).UTC()
+		resID := uint64(42)
+		mock.ExpectQuery(selectReturnByReq).
+			WithArgs("rma1").
+			WillReturnRows(pgxmock.NewRows(returnCols).
+				AddRow(uint64(3), "rma1", "sku1", "default", &resID, "alice", int64(2), inventory.ReturnRestock, "L1", "wrong size", "dave", created))
+
+		got, err := repo.GetReturnByRequestID(context.Background(), "rma1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := inventory.Return{ID: 3, RequestID: "rma1", Sku: "sku1", Location: "default", ReservationID: &resID, Requester: "alice", Quantity: 2, Outcome: inventory.ReturnRestock, Lot: "L1", Reason: "wrong size", Actor: "dave", Created: created}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got=%+v want=%+v", got, want)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectReturnByReq).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.GetReturnByRequestID(context.Background(), "missing")
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryGetReturns(t *testing.T) {
+	repo, mock := newRepo(t)
+	created := time.Unix(0, 0).UTC()
+	resID := uint64(42)
+	mock.ExpectQuery(listReturns).
+		WithArgs("sku1", 50, 0).
+		WillReturnRows(pgxmock.NewRows(returnCols).
+			AddRow(uint64(3), "rma1", "sku1", "default", &resID, "alice", int64(2), inventory.ReturnRestock, "", "", "dave", created).
+			AddRow(uint64(4), "rma2", "sku1", "default", (*uint64)(nil), "walk-in", int64(1), inventory.ReturnScrap, "", "broken", "dave", created)).
+		RowsWillBeClosed()
+
+	got, err := repo.GetReturns(context.Background(), "sku1", 50, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].ReservationID == nil || *got[0].ReservationID != 42 || got[1].ReservationID != nil || got[1].Outcome != inventory.ReturnScrap {
+		t.Errorf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetReturnedQuantity(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectQuery(selectReturnedQuantity).
+		WithArgs(uint64(42)).
+		WillReturnRows(pgxmock.NewRows([]string{"sum"}).AddRow(int64(3)))
+
+	got, err := repo.GetReturnedQuantity(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 3 {
+		t.Errorf("expected 3, got %d", got)
+	}
+}
+
+func TestRepositorySaveReturn(t *testing.T) {
+	repo, mock := newRepo(t)
+	created := time.Unix(0, 0).UTC()
+	r := &inventory.Return{RequestID: "rma2", Sku: "sku1", Location: "default", Requester: "walk-in", Quantity: 1, Outcome: inventory.ReturnQuarantine, Reason: "opened", Actor: "dave", Created: created}
+	mock.ExpectQuery(insertReturn).
+		WithArgs(r.RequestID, r.Sku, r.Location, r.ReservationID, r.Requester, r.Quantity, r.Outcome, r.Lot, r.Reason, r.Actor, r.Created).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(uint64(4)))
+
+	if err := repo.SaveReturn(context.Background(), r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.ID != 4 {
+		t.Errorf("expected ID=4, got %d", r.ID)
+	}
+}
+
+var requesterLimitCols = []string{"requester", "class", "max_reserved_per_sku", "max_open_reservations", "daily_cap"}
+
+func TestRepositoryGetRequesterLimits(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectRequesterLimits).
+			WithArgs("acme").
+			WillReturnRows(pgxmock.NewRows(requesterLimitCols).AddRow("acme", "wholesale", int64(50), int64(5), int64(200)))
+
+		got, err := repo.GetRequesterLimits(context.Background(), "acme")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := inventory.RequesterLimits{Requester: "acme", Class: "wholesale", MaxReservedPerSku: 50, MaxOpenReservations: 5, DailyCap: 200}
+		if got != want {
+			t.Errorf("got=%+v want=%+v", got, want)
+		}
+	})
+
+	t.Run("no rows maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectQuery(selectRequesterLimits).
+			WithArgs("missing").
+			WillReturnError(pgx.ErrNoRows)
+
+		_, err := repo.GetRequesterLimits(context.Background(), "missing")
+		if !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryGetAllRequesterLimits(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectQuery(listRequesterLimits).
+		WithArgs(50, 0).
+		WillReturnRows(pgxmock.NewRows(requesterLimitCols).
+			AddRow("*", "default", int64(0), int64(10), int64(0)).
+			AddRow("acme", "wholesale", int64(50), int64(5), int64(200))).
+		RowsWillBeClosed()
+
+	got, err := repo.GetAllRequesterLimits(context.Background(), 50, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].Requester != "*" || got[1].DailyCap != 200 {
+		t.Errorf("unexpected result: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetRequesterUsage(t *testing.T) {
+	repo, mock := newRepo(t)
+	since := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(selectRequesterUsage).
+		WithArgs("acme", "sku1", since).
+		WillReturnRows(pgxmock.NewRows([]string{"sku", "open", "today"}).AddRow(int64(12), int64(3), int64(40)))
+
+	got, err := repo.GetRequesterUsage(context.Background(), "acme", "sku1", since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := inventory.RequesterUsage{ReservedForSku: 12, OpenReservations: 3, ReservedToday: 40}
+	if got != want {
+		t.Errorf("got=%+v want=%+v", got, want)
+	}
+}
+
+func TestRepositorySaveRequesterDemand(t *testing.T) {
+	repo, mock := newRepo(t)
+	at := time.Date(2026, time.April, 1, 9, 30, 0, 0, time.UTC)
+	mock.ExpectExec(insertRequesterDemand).
+		WithArgs("acme", uint64(7), int64(-3), at).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	if err := repo.SaveRequesterDemand(context.Background(), "acme", 7, -3, at); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositorySaveRequesterLimits(t *testing.T) {
+	repo, mock := newRepo(t)
+	l := inventory.RequesterLimits{Requester: "acme", Class: "wholesale", MaxReservedPerSku: 50, MaxOpenReservations: 5, DailyCap: 200}
+	mock.ExpectExec(upsertRequesterLimits).
+		WithArgs(l.Requester, l.Class, l.MaxReservedPerSku, l.MaxOpenReservations, l.DailyCap).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	if err := repo.SaveRequesterLimits(context.Background(), l); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryDeleteRequesterLimits(t *testing.T) {
+	t.Run("deleted", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(deleteRequesterLimits).
+			WithArgs("acme").
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+		if err := repo.DeleteRequesterLimits(context.Background(), "acme"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("nothing to delete maps to ErrNotFound", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(deleteRequesterLimits).
+			WithArgs("missing").
+			WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+		if err := repo.DeleteRequesterLimits(context.Background(), "missing"); !errors.Is(err, persistence.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryLockRequester(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectExec(lockRequester).
+		WithArgs("acme").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	if err := repo.LockRequester(context.Background(), "acme"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryGetConflictingProducts(t *testing.T) {
+	repo, mock := newRepo(t)
+	mock.ExpectQuery(selectConflictingProducts).
+		WithArgs([]string{"sku1", "sku2"}, []string{"upc1", "upc2"}).
+		WillReturnRows(pgxmock.NewRows([]string{"sku", "upc", "name", "state", "version"}).
+			AddRow("other", "upc2", "Other", inventory.ProductActive, int64(3)))
+
+	got, err := repo.GetConflictingProducts(context.Background(), []string{"sku1", "sku2"}, []string{"upc1", "upc2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []inventory.Product{{Sku: "other", Upc: "upc2", Name: "Other", State: inventory.ProductActive, Version: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("products got=%+v want=%+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryImportProducts(t *testing.T) {
+	rows := []inventory.ProductImport{
+		{Line: 2, Sku: "sku1", Upc: "upc1", Name: "Widget", Quantity: 10, Location: "default"},
+		{Line: 3, Sku: "sku2", Upc: "upc2", Name: "Gadget", Location: "east"},
+	}
+
+	t.Run("copies products, stock and opening movements", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectCopyFrom(pgx.Identifier{"products"}, []string{"sku", "upc", "name", "state"}).WillReturnResult(2)
+		mock.ExpectCopyFrom(pgx.Identifier{"product_inventory"}, []string{"sku", "location", "available"}).WillReturnResult(2)
+		mock.ExpectCopyFrom(pgx.Identifier{"inventory_movements"}, []string{"sku", "location", "delta", "reason", "actor", "balance", "created"}).WillReturnResult(1)
+
+		if err := repo.ImportProducts(context.Background(), rows, "alice", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("a failed copy stops the import", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		wantErr := errors.New("duplicate key value violates unique constraint")
+		mock.ExpectCopyFrom(pgx.Identifier{"products"}, []string{"sku", "upc", "name", "state"}).WillReturnError(wantErr)
+
+		if err := repo.ImportProducts(context.Background(), rows, "alice", time.Now()); !errors.Is(err, wantErr) {
+			t.Fatalf("err got=%v want=%v", err, wantErr)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+}
+
+func TestRepositoryExportProductInventory(t *testing.T) {
+	defer inventory.SetExportFetchSizeForTest(2)()
+	columns := []string{"sku", "upc", "name", "state", "version", "location", "available", "in_transit", "expired", "on_hold", "quarantined", "damaged"}
+
+	repo, mock := newRepo(t)
+	mock.ExpectExec(declareInventoryExport).WillReturnResult(pgxmock.NewResult("DECLARE CURSOR", 0))
+	// sku2's locations straddle the first two fetches.
+	mock.ExpectQuery(fetchInventoryExport).WillReturnRows(pgxmock.NewRows(columns).
+		AddRow("sku1", "upc1", "Widget", inventory.ProductActive, int64(1), ptr("default"), ptr(int64(5)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0))).
+		AddRow("sku2", "upc2", "Gadget", inventory.ProductActive, int64(2), ptr("default"), ptr(int64(1)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0))))
+	mock.ExpectQuery(fetchInventoryExport).WillReturnRows(pgxmock.NewRows(columns).
+		AddRow("sku2", "upc2", "Gadget", inventory.ProductActive, int64(2), ptr("east"), ptr(int64(3)), ptr(int64(2)), ptr(int64(0)), ptr(int64(1)), ptr(int64(0)), ptr(int64(0))).
+		AddRow("sku3", "upc3", "Gizmo", inventory.ProductActive, int64(1), ptr("default"), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0)), ptr(int64(0))))
+	mock.ExpectQuery(fetchInventoryExport).WillReturnRows(pgxmock.NewRows(columns))
+
+	var got []inventory.ProductInventory
+	err := repo.ExportProductInventory(context.Background(), func(pi inventory.ProductInventory) error {
+		got = append(got, pi)
+		return nil
+	}, persistence.QueryOptions{Tx: mock})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []inventory.ProductInventory{
+		{Product: inventory.Product{Sku: "sku1", Upc: "upc1", Name: "Widget", State: inventory.ProductActive, Version: 1}, Available: 5,
+			Locations: []inventory.LocationInventory{{Location: "default", Available: 5}}},
+		{Product: inventory.Product{Sku: "sku2", Upc: "upc2", Name: "Gadget", State: inventory.ProductActive, Version: 2}, Available: 4, InTransit: 2, OnHold: 1,
+			Locations: []inventory.LocationInventory{{Location: "default", Available: 1}, {Location: "east", Available: 3, InTransit: 2, OnHold: 1}}},
+		{Product: inventory.Product{Sku: "sku3", Upc: "upc3", Name: "Gizmo", State: inventory.ProductActive, Version: 1},
+			Locations: []inventory.LocationInventory{{Location: "default"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exported\n got:%+v\nwant:%+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestRepositoryExportReservations(t *testing.T) {
+	defer inventory.SetExportFetchSizeForTest(2)()
+	columns := []string{"id", "request_id", "requester", "sku", "location", "state", "reserved_quantity", "requested_quantity", "shipped_quantity", "created", "expires_at", "priority", "fill_policy", "min_quantity", "version"}
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	row := func(id uint64) []interface{} {
+		return []interface{}{id, fmt.Sprintf("req%d", id), "acme", "sku1", "default", inventory.Open, int64(0), int64(5), int64(0), created, nil, 0, inventory.FillPartial, int64(0), int64(1)}
+	}
+
+	t.Run("reads every fetch", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(declareReservationExport).
+			WithArgs("sku1", inventory.Open).
+			WillReturnResult(pgxmock.NewResult("DECLARE CURSOR", 0))
+		mock.ExpectQuery(fetchReservationExport).WillReturnRows(pgxmock.NewRows(columns).AddRow(row(1)...).AddRow(row(2)...))
+		mock.ExpectQuery(fetchReservationExport).WillReturnRows(pgxmock.NewRows(columns).AddRow(row(3)...))
+		mock.ExpectQuery(fetchReservationExport).WillReturnRows(pgxmock.NewRows(columns))
+
+		var got []uint64
+		err := repo.ExportReservations(context.Background(), inventory.GetReservationsOptions{Sku: "sku1", State: inventory.Open}, func(r inventory.Reservation) error {
+			got = append(got, r.ID)
+			return nil
+		}, persistence.QueryOptions{Tx: mock})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, []uint64{1, 2, 3}) {
+			t.Errorf("exported ids got=%v want=[1 2 3]", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	t.Run("stops at the first error from fn", func(t *testing.T) {
+		repo, mock := newRepo(t)
+		mock.ExpectExec(declareReservationExport).
+			WithArgs("sku1", inventory.Open).
+			WillReturnResult(pgxmock.NewResult("DECLARE CURSOR", 0))
+		mock.ExpectQuery(fetchReservationExport).WillReturnRows(pgxmock.NewRows(columns).AddRow(row(1)...).AddRow(row(2)...))
+
+		wantErr := errors.New("client went away")
+		calls := 0
+		err := repo.ExportReservations(context.Background(), inventory.GetReservationsOptions{Sku: "sku1", State: inventory.Open}, func(r inventory.Reservation) error {
+			calls++
+			return wantErr
+		}, persistence.QueryOptions{Tx: mock})
+		if !errors.Is(err, wantErr) {
+			t.Fatalf("err got=%v want=%v", err, wantErr)
+		}
+		if calls != 1 {
+			t.Errorf("fn calls got=%d want=1", calls)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
 		}
 	})
 }
@@ -4,16 +4,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/auth"
 	"github.com/sksmith/go-micro-example/internal/platform/cache"
 	"github.com/sksmith/go-micro-example/internal/platform/observability"
 	"github.com/sksmith/go-micro-example/internal/platform/persistence"
+	"github.com/sksmith/go-micro-example/internal/user"
 	"go.opentelemetry.io/otel/attribute"
 )
 
@@ -21,6 +26,11 @@ import (
 // span in this package (DSN-004b).
 const tracerName = "inventory.Service"
 
+// DefaultLocation is where stock lands when a request doesn't name a
+// location and no other default has been configured. Migration
+// 000010 moved every pre-existing balance here.
+const DefaultLocation = "default"
+
 // ErrInvalidInput is the sentinel for validation failures produced
 // by this package's service methods (missing fields, out-of-range
 // values, etc.). The API layer maps anything wrapping this sentinel
@@ -28,13 +38,73 @@ const tracerName = "inventory.Service"
 // client-facing detail.
 var ErrInvalidInput = errors.New("invalid input")
 
+// ErrInsufficientStock is returned by an immediate-mode Reserve when the
+// stock to cover it isn't available right now.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrProductState is returned when a product's lifecycle state rules
+// out a write: production or reservations against a product that isn't
+// Active, any change to an Archived product, or archiving one that
+// still holds stock or reservations.
+var ErrProductState = errors.New("product state does not allow this")
+
+// ErrBeforeHistory is returned by the as-of reads when asOf falls
+// before the inventory ledger has anything to rebuild from: before the
+// product was created, or before the ledger was introduced.
+var ErrBeforeHistory = errors.New("asOf is before the inventory history begins")
+
+// ComponentShortageError is returned by Produce when the stock at the
+// production location can't cover a kit's components. It lists every
+// short component, not just the first, and wraps ErrInsufficientStock.
+type ComponentShortageError struct {
+	Sku       string
+	Location  string
+	Shortages []ComponentShortage
+}
+
+func (e *ComponentShortageError) Error() string {
+	short := make([]string, len(e.Shortages))
+	for i, c := range e.Shortages {
+		short[i] = fmt.Sprintf("%s needs %d, %d available", c.Sku, c.Required, c.Available)
+	}
+	return fmt.Sprintf("not enough components at %q to produce %q (%s): %v", e.Location, e.Sku, strings.Join(short, "; "), ErrInsufficientStock)
+}
+
+func (e *ComponentShortageError) Unwrap() error { return ErrInsufficientStock }
+
+// ErrLimitExceeded is returned when a reservation would take its
+// requester past one of its RequesterLimits.
+var ErrLimitExceeded = errors.New("requester limit exceeded")
+
+// RequesterLimitError is returned by Reserve and Modify when a
+// reservation would take its requester past Limit. It says which limit
+// was hit and by how much, and wraps ErrLimitExceeded.
+type RequesterLimitError struct {
+	Requester string
+	Class     string
+	Sku       string
+	Limit     RequesterLimit
+	Max       int64
+	Current   int64
+	Requested int64
+}
+
+func (e *RequesterLimitError) Error() string {
+	return fmt.Sprintf("requester %q has %d against its %s limit of %d and asked for %d more: %v", e.Requester, e.Current, e.Limit, e.Max, e.Requested, ErrLimitExceeded)
+}
+
+func (e *RequesterLimitError) Unwrap() error { return ErrLimitExceeded }
+
 func NewService(repo Repository, q InventoryPublisher) *service {
 	log.Info().Msg("creating inventory service...")
 	return &service{
-		repo:            repo,
-		queue:           q,
-		inventorySubs:   make(map[InventorySubID]chan<- ProductInventory),
-		reservationSubs: make(map[ReservationsSubID]chan<- Reservation),
+		repo:             repo,
+		queue:            q,
+		defaultLocation:  DefaultLocation,
+		locationStrategy: LocationMostAvailable,
+		allocation:       FIFOAllocation{},
+		inventorySubs:    make(map[InventorySubID]chan<- ProductInventory),
+		reservationSubs:  make(map[ReservationsSubID]chan<- Reservation),
 	}
 }
 
@@ -43,7 +113,10 @@ func NewService(repo Repository, q InventoryPublisher) *service {
 // when the emitter is nil — Kafka is parallel to AMQP, not a
 // replacement for it.
 type EventEmitter interface {
-	EmitProductQuantityChanged(ctx context.Context, sku string, available int64) error
+	EmitProductQuantityChanged(ctx context.Context, pi ProductInventory) error
+	EmitProductChanged(ctx context.Context, product Product) error
+	EmitTransferChanged(ctx context.Context, t Transfer) error
+	EmitLowStock(ctx context.Context, lowStock LowStock) error
 }
 
 // SetEventEmitter swaps in the optional Kafka emitter. Passing nil
@@ -62,12 +135,58 @@ type GetReservationsOptions struct {
 	State ReserveState
 }
 
+// ReservationKey is a reservation's place in the (created, id) order
+// reservation listings use; a keyset page starts after one.
+type ReservationKey struct {
+	Created time.Time
+	ID      uint64
+}
+
+// ProductSort is a column product inventory listings can be ordered by.
+// Ties are broken by SKU.
+type ProductSort string
+
+const (
+	SortBySku       ProductSort = "sku"
+	SortByName      ProductSort = "name"
+	SortByAvailable ProductSort = "available"
+)
+
+// ProductListOptions narrows and orders a product inventory listing.
+// Search matches a substring of the name or UPC, and AvailableMin and
+// AvailableMax bound total available stock inclusively when set.
+type ProductListOptions struct {
+	Search       string
+	AvailableMin *int64
+	AvailableMax *int64
+	Sort         ProductSort
+	Desc         bool
+}
+
+// ProductKey is a product's place in a listing's order; a keyset page
+// starts after one. Only the sort column and Sku are compared.
+type ProductKey struct {
+	Sku       string
+	Name      string
+	Available int64
+}
+
 type service struct {
 	repo            Repository
 	queue           InventoryPublisher
 	emitter         EventEmitter
 	cache           cache.Cache
 	cacheTTL        time.Duration
+	reservationTTL  time.Duration
+	defaultLocation string
+	// locationStrategy and locationPriority choose the location for
+	// reservations that don't ask for one.
+	locationStrategy LocationStrategy
+	locationPriority []string
+	// allocation shares stock between open reservations in
+	// FillReserves; skuAllocation overrides it for individual SKUs.
+	allocation      AllocationStrategy
+	skuAllocation   map[string]AllocationStrategy
 	subsMu          sync.Mutex
 	inventorySubs   map[InventorySubID]chan<- ProductInventory
 	reservationSubs map[ReservationsSubID]chan<- Reservation
@@ -87,31 +206,95 @@ func (s *service) SetCache(c cache.Cache, ttl time.Duration) {
 	}
 }
 
+// SetReservationTTL sets the hold applied to reservations whose
+// request doesn't specify ttlSeconds. ttl<=0 means such reservations
+// never expire.
+func (s *service) SetReservationTTL(ttl time.Duration) {
+	s.reservationTTL = ttl
+}
+
+// SetDefaultLocation sets the location production, adjustments and
+// new products use when the request doesn't name one. An empty
+// location keeps DefaultLocation.
+func (s *service) SetDefaultLocation(location string) {
+	if location == "" {
+		location = DefaultLocation
+	}
+	s.defaultLocation = location
+}
+
+// SetLocationStrategy sets how Reserve picks a location when the
+// request has no preference. priority is the location order used by
+// LocationPriority and ignored otherwise.
+func (s *service) SetLocationStrategy(strategy LocationStrategy, priority []string) {
+	s.locationStrategy = strategy
+	s.locationPriority = priority
+}
+
+// SetAllocationStrategy sets how FillReserves shares stock between
+// open reservations. perSku overrides the global strategy for the
+// SKUs it names. A nil global strategy keeps FIFO.
+func (s *service) SetAllocationStrategy(global AllocationStrategy, perSku map[string]AllocationStrategy) {
+	if global == nil {
+		global = FIFOAllocation{}
+	}
+	s.allocation = global
+	s.skuAllocation = perSku
+}
+
+func (s *service) allocationFor(sku string) AllocationStrategy {
+	if strategy, ok := s.skuAllocation[sku]; ok {
+		return strategy
+	}
+	return s.allocation
+}
+
 // productCacheKey is the per-SKU key under which ProductInventory is
-// cached. The "v1" suffix is the global invalidation lever — bumping
+// cached. The "v3" suffix is the global invalidation lever — bumping
 // it drops every cached entry without touching Redis directly, which
 // matters when the cached shape changes (DSN-020).
-func productCacheKey(sku string) string { return "inv:product:" + sku + ":v1" }
-
-func (s *service) CreateProduct(ctx context.Context, product Product) (err error) {
+func productCacheKey(sku string) string { return "inv:product:" + sku + ":v3" }
+
+// CreateProduct registers product, Active and with no stock at the
+// default location, and returns the stored product along with whether
+// this call created it. Creating a product that already exists is a
+// no-op unless ctx carries an Absent precondition, which makes it a
+// conflict. With a Version precondition it instead updates the existing
+// product's UPC and name, provided it is still at that version.
+func (s *service) CreateProduct(ctx context.Context, product Product) (pi ProductInventory, created bool, err error) {
 	const funcName = "CreateProduct"
 	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
 		attribute.String("inventory.sku", product.Sku),
 	)
 	defer func() { end(err) }()
 
+	pc := PreconditionFrom(ctx)
+	if pc.Version != 0 {
+		pi, err = s.UpdateProduct(ctx, product.Sku, ProductUpdate{Upc: product.Upc, Name: product.Name})
+		return pi, false, err
+	}
+	// The HTTP handler has already checked this, but products also
+	// arrive over the queue.
+	if err = validateNewProduct(product); err != nil {
+		return ProductInventory{}, false, fmt.Errorf("%v: %w", err, ErrInvalidInput)
+	}
+	product.State = ProductActive
+
 	dbProduct, err := s.repo.GetProduct(ctx, product.Sku)
 	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
-		return fmt.Errorf("get existing product: %w", err)
+		return ProductInventory{}, false, fmt.Errorf("get existing product: %w", err)
 	}
 	if err == nil {
+		if pc.Absent {
+			return ProductInventory{}, false, fmt.Errorf("product %q already exists: %w", product.Sku, persistence.ErrVersionConflict)
+		}
 		log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", dbProduct.Sku).Msg("product already exists")
-		return nil
+		return s.existingProduct(ctx, product.Sku)
 	}
 
 	tx, err := s.repo.BeginTransaction(ctx)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return ProductInventory{}, false, fmt.Errorf("begin transaction: %w", err)
 	}
 	defer func() {
 		if err != nil {
@@ -121,20 +304,377 @@ func (s *service) CreateProduct(ctx context.Context, product Product) (err error
 
 	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", product.Sku).Msg("creating product")
 	if err = s.repo.SaveProduct(ctx, product, persistence.UpdateOptions{Tx: tx}); err != nil {
-		return fmt.Errorf("save product: %w", err)
+		if errors.Is(err, persistence.ErrVersionConflict) && !pc.Absent {
+			// Another request created the SKU since the lookup above,
+			// which is no different from finding it already there.
+			rollback(ctx, tx, nil)
+			return s.existingProduct(ctx, product.Sku)
+		}
+		return ProductInventory{}, false, fmt.Errorf("save product: %w", err)
 	}
 
 	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", product.Sku).Msg("creating product inventory")
-	pi := ProductInventory{Product: product}
+	pi = ProductInventory{Product: product}
+	pi.Add(s.defaultLocation, 0)
 
 	if err = s.repo.SaveProductInventory(ctx, pi, persistence.UpdateOptions{Tx: tx}); err != nil {
-		return fmt.Errorf("save product inventory: %w", err)
+		return ProductInventory{}, false, fmt.Errorf("save product inventory: %w", err)
+	}
+
+	// Re-read inside the transaction for the version the database
+	// assigned, which the caller needs for its next If-Match.
+	if pi, err = s.repo.GetProductInventory(ctx, product.Sku, persistence.QueryOptions{Tx: tx}); err != nil {
+		return ProductInventory{}, false, fmt.Errorf("get created product inventory: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return ProductInventory{}, false, fmt.Errorf("commit create-product transaction: %w", err)
+	}
+
+	return pi, true, nil
+}
+
+// existingProduct is CreateProduct's answer for a SKU that was already
+// there: the stored product, and not created.
+func (s *service) existingProduct(ctx context.Context, sku string) (ProductInventory, bool, error) {
+	pi, err := s.repo.GetProductInventory(ctx, sku)
+	if err != nil {
+		return ProductInventory{}, false, fmt.Errorf("get existing product inventory: %w", err)
+	}
+	return pi, false, nil
+}
+
+// importBatchSize is how many import rows are checked and copied in
+// one transaction.
+const importBatchSize = 1000
+
+// ProductImportReader reads the rows of a bulk import one at a time.
+// Read returns io.EOF after the last row and an *ImportRowError for a
+// row it couldn't parse, which the import reports before reading on.
+// Any other error ends the import.
+type ProductImportReader interface {
+	Read() (ProductImport, error)
+}
+
+// ImportProducts creates the products read from rows along with their
+// opening stock, in batches that each commit on their own. Rows that
+// fail validation, repeat a SKU or UPC seen earlier in the import or
+// name one the database already holds are left out and reported in
+// the result instead. A dry run makes the same checks without writing
+// anything. If a batch fails to write, the batches before it stay
+// imported; running the import again reports their rows as existing.
+func (s *service) ImportProducts(ctx context.Context, rows ProductImportReader, dryRun bool) (result ImportResult, err error) {
+	const funcName = "ImportProducts"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.Bool("inventory.dry_run", dryRun),
+	)
+	defer func() { end(err) }()
+
+	result = ImportResult{DryRun: dryRun, Errors: []ImportRowError{}}
+	actor := actorFrom(ctx)
+	now := time.Now()
+	skus := make(map[string]int)
+	upcs := make(map[string]int)
+	batch := make([]ProductImport, 0, importBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		imported, rejected, err := s.importBatch(ctx, batch, dryRun, actor, now)
+		if err != nil {
+			return fmt.Errorf("import rows %d to %d: %w", batch[0].Line, batch[len(batch)-1].Line, err)
+		}
+		result.Imported += imported
+		result.Errors = append(result.Errors, rejected...)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		row, rerr := rows.Read()
+		if errors.Is(rerr, io.EOF) {
+			break
+		}
+		var rowErr *ImportRowError
+		if errors.As(rerr, &rowErr) {
+			result.Rows++
+			result.Errors = append(result.Errors, *rowErr)
+			continue
+		}
+		if rerr != nil {
+			return result, fmt.Errorf("read import: %w", rerr)
+		}
+
+		result.Rows++
+		row.Location = s.locationOrDefault(row.Location)
+		if rerr = validateProductImport(row); rerr != nil {
+			result.Errors = append(result.Errors, ImportRowError{Line: row.Line, Sku: row.Sku, Detail: rerr.Error()})
+			continue
+		}
+		if line, ok := skus[row.Sku]; ok {
+			result.Errors = append(result.Errors, ImportRowError{Line: row.Line, Sku: row.Sku, Detail: fmt.Sprintf("sku already imported on line %d", line)})
+			continue
+		}
+		if line, ok := upcs[row.Upc]; ok {
+			result.Errors = append(result.Errors, ImportRowError{Line: row.Line, Sku: row.Sku, Detail: fmt.Sprintf("upc already imported on line %d", line)})
+			continue
+		}
+		skus[row.Sku] = row.Line
+		upcs[row.Upc] = row.Line
+
+		batch = append(batch, row)
+		if len(batch) == importBatchSize {
+			if err = flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err = flush(); err != nil {
+		return result, err
+	}
+	// Rows left out by a batch are only found once it's checked, after
+	// later rows may already have been rejected.
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+
+	log.Ctx(ctx).Info().
+		Str("func", funcName).
+		Bool("dryRun", dryRun).
+		Int("rows", result.Rows).
+		Int("imported", result.Imported).
+		Int("rejected", len(result.Errors)).
+		Msg("imported products")
+	return result, nil
+}
+
+// importBatch leaves out the rows of batch whose SKU or UPC is already
+// taken and, unless it's a dry run, copies the rest in one transaction.
+// It returns how many rows were (or would be) imported and why the
+// others weren't.
+func (s *service) importBatch(ctx context.Context, batch []ProductImport, dryRun bool, actor string, at time.Time) (imported int, rejected []ImportRowError, err error) {
+	var tx persistence.Transaction
+	if !dryRun {
+		tx, err = s.repo.BeginTransaction(ctx)
+		if err != nil {
+			return 0, nil, fmt.Errorf("begin transaction: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				rollback(ctx, tx, err)
+			}
+		}()
+	}
+
+	skus := make([]string, len(batch))
+	upcs := make([]string, len(batch))
+	for i, row := range batch {
+		skus[i] = row.Sku
+		upcs[i] = row.Upc
+	}
+	var existing []Product
+	if tx != nil {
+		existing, err = s.repo.GetConflictingProducts(ctx, skus, upcs, persistence.QueryOptions{Tx: tx})
+	} else {
+		existing, err = s.repo.GetConflictingProducts(ctx, skus, upcs)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("get existing products: %w", err)
+	}
+	takenSkus := make(map[string]bool, len(existing))
+	takenUpcs := make(map[string]bool, len(existing))
+	for _, p := range existing {
+		takenSkus[p.Sku] = true
+		takenUpcs[p.Upc] = true
+	}
+
+	rows := make([]ProductImport, 0, len(batch))
+	for _, row := range batch {
+		switch {
+		case takenSkus[row.Sku]:
+			rejected = append(rejected, ImportRowError{Line: row.Line, Sku: row.Sku, Detail: "sku already exists"})
+		case takenUpcs[row.Upc]:
+			rejected = append(rejected, ImportRowError{Line: row.Line, Sku: row.Sku, Detail: "upc already belongs to another product"})
+		default:
+			rows = append(rows, row)
+		}
+	}
+	if dryRun || len(rows) == 0 {
+		rollback(ctx, tx, nil)
+		return len(rows), rejected, nil
+	}
+
+	if err = s.repo.ImportProducts(ctx, rows, actor, at, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return 0, nil, fmt.Errorf("copy products: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, nil, fmt.Errorf("commit import transaction: %w", err)
+	}
+	return len(rows), rejected, nil
+}
+
+// reservedSkus are the collection routes registered beside /{sku}. A
+// product with one of these SKUs could be created but never read,
+// adjusted or archived, since the fixed route would always win.
+var reservedSkus = map[string]bool{
+	"export":    true,
+	"import":    true,
+	"low-stock": true,
+	"subscribe": true,
+}
+
+// validateNewProduct checks the fields every new product needs.
+func validateNewProduct(p Product) error {
+	if p.Upc == "" || p.Name == "" || p.Sku == "" {
+		return errors.New("missing required field(s)")
+	}
+	if reservedSkus[p.Sku] {
+		return fmt.Errorf("sku %q is reserved", p.Sku)
+	}
+	return nil
+}
+
+// validateProductImport checks an import row the way a single product
+// create is checked, and that it fits the columns it's copied into,
+// since one oversized value would fail the copy of its whole batch.
+func validateProductImport(row ProductImport) error {
+	if err := validateNewProduct(Product{Sku: row.Sku, Upc: row.Upc, Name: row.Name}); err != nil {
+		return err
+	}
+	if row.Quantity < 0 {
+		return errors.New("quantity cannot be negative")
+	}
+	switch {
+	case len(row.Sku) > 50:
+		return errors.New("sku is longer than 50 characters")
+	case len(row.Upc) > 50:
+		return errors.New("upc is longer than 50 characters")
+	case len(row.Name) > 100:
+		return errors.New("name is longer than 100 characters")
+	case len(row.Location) > 50:
+		return errors.New("location is longer than 50 characters")
+	}
+	return nil
+}
+
+// UpdateProduct changes a product's UPC, name or lifecycle state. A
+// product moves freely between Active and Discontinued, but can only be
+// Archived once it holds no stock and no reservations waiting to be
+// filled or shipped, after which it can't change again.
+func (s *service) UpdateProduct(ctx context.Context, sku string, pu ProductUpdate) (pi ProductInventory, err error) {
+	const funcName = "UpdateProduct"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.String("inventory.state", string(pu.State)),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", sku).
+		Str("upc", pu.Upc).
+		Str("name", pu.Name).
+		Str("state", string(pu.State)).
+		Msg("updating product")
+
+	if err = validateProductUpdate(pu); err != nil {
+		return ProductInventory{}, err
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return ProductInventory{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	pi, err = s.repo.GetProductInventory(ctx, sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return ProductInventory{}, fmt.Errorf("get product inventory for %q: %w", sku, err)
+	}
+	if version := PreconditionFrom(ctx).Version; version != 0 && version != pi.Version {
+		return ProductInventory{}, fmt.Errorf("product %q is at version %d, not %d: %w", sku, pi.Version, version, persistence.ErrVersionConflict)
+	}
+	if pi.State == ProductArchived {
+		return ProductInventory{}, fmt.Errorf("product %q is archived: %w", sku, ErrProductState)
+	}
+
+	product := pi.Product
+	if product.State == "" {
+		product.State = ProductActive
+	}
+	if pu.Upc != "" {
+		product.Upc = pu.Upc
+	}
+	if pu.Name != "" {
+		product.Name = pu.Name
+	}
+	if pu.State != "" {
+		product.State = pu.State
+	}
+	if product == pi.Product {
+		rollback(ctx, tx, nil)
+		log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("product unchanged")
+		return pi, nil
 	}
 
+	if product.State == ProductArchived {
+		if err = s.checkArchivable(ctx, tx, pi); err != nil {
+			return ProductInventory{}, err
+		}
+	}
+
+	if err = s.repo.SaveProduct(ctx, product, persistence.UpdateOptions{Tx: tx, Version: pi.Version}); err != nil {
+		return ProductInventory{}, fmt.Errorf("save product: %w", err)
+	}
+	product.Version++
+	pi.Product = product
+
 	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit create-product transaction: %w", err)
+		return ProductInventory{}, fmt.Errorf("commit update-product transaction: %w", err)
+	}
+
+	s.invalidateProduct(ctx, sku)
+	if err = s.publishProduct(ctx, product); err != nil {
+		return ProductInventory{}, fmt.Errorf("publish product: %w", err)
+	}
+
+	return pi, nil
+}
+
+func validateProductUpdate(pu ProductUpdate) error {
+	if pu == (ProductUpdate{}) {
+		return fmt.Errorf("nothing to update: %w", ErrInvalidInput)
+	}
+	switch pu.State {
+	case "", ProductActive, ProductDiscontinued, ProductArchived:
+		return nil
+	default:
+		return fmt.Errorf("invalid product state %q: %w", pu.State, ErrInvalidInput)
 	}
+}
 
+// checkArchivable refuses to archive a product that still holds stock,
+// here, on its way between locations or held back pending a decision,
+// or that has reservations waiting to be filled or shipped.
+func (s *service) checkArchivable(ctx context.Context, tx persistence.Transaction, pi ProductInventory) error {
+	if pi.Available != 0 || pi.InTransit != 0 {
+		return fmt.Errorf("product %q still holds %d available and %d in transit: %w", pi.Sku, pi.Available, pi.InTransit, ErrProductState)
+	}
+	if pi.OnHold != 0 || pi.Quarantined != 0 {
+		return fmt.Errorf("product %q still holds %d on hold and %d quarantined: %w", pi.Sku, pi.OnHold, pi.Quarantined, ErrProductState)
+	}
+	for _, state := range []ReserveState{Open, Closed} {
+		rsv, err := s.repo.GetReservations(ctx, GetReservationsOptions{Sku: pi.Sku, State: state}, 1, 0, persistence.QueryOptions{Tx: tx})
+		if err != nil {
+			return fmt.Errorf("get %s reservations for %q: %w", state, pi.Sku, err)
+		}
+		if len(rsv) > 0 {
+			return fmt.Errorf("product %q still has %s reservations: %w", pi.Sku, state, ErrProductState)
+		}
+	}
 	return nil
 }
 
@@ -144,6 +684,7 @@ func (s *service) Produce(ctx context.Context, product Product, pr ProductionReq
 		attribute.String("inventory.sku", product.Sku),
 		attribute.String("request_id", pr.RequestID),
 		attribute.Int64("inventory.quantity", pr.Quantity),
+		attribute.String("inventory.location", pr.Location),
 	)
 	defer func() { end(err) }()
 
@@ -152,6 +693,7 @@ func (s *service) Produce(ctx context.Context, product Product, pr ProductionReq
 		Str("sku", product.Sku).
 		Str("requestId", pr.RequestID).
 		Int64("quantity", pr.Quantity).
+		Str("location", pr.Location).
 		Msg("producing inventory")
 
 	if pr.RequestID == "" {
@@ -160,6 +702,9 @@ func (s *service) Produce(ctx context.Context, product Product, pr ProductionReq
 	if pr.Quantity < 1 {
 		return fmt.Errorf("quantity must be greater than zero: %w", ErrInvalidInput)
 	}
+	if err = validateLot(pr, time.Now()); err != nil {
+		return err
+	}
 
 	event, err := s.repo.GetProductionEventByRequestID(ctx, pr.RequestID)
 	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
@@ -174,7 +719,10 @@ func (s *service) Produce(ctx context.Context, product Product, pr ProductionReq
 	event = ProductionEvent{
 		RequestID: pr.RequestID,
 		Sku:       product.Sku,
+		Location:  s.locationOrDefault(pr.Location),
 		Quantity:  pr.Quantity,
+		Lot:       pr.Lot,
+		ExpiresAt: pr.ExpiresAt,
 		Created:   time.Now(),
 	}
 
@@ -196,122 +744,2642 @@ func (s *service) Produce(ctx context.Context, product Product, pr ProductionReq
 	if err != nil {
 		return fmt.Errorf("failed to get product inventory: %w", err)
 	}
+	if !productInventory.Active() {
+		return fmt.Errorf("product %q is %s and can't be produced: %w", product.Sku, productInventory.State, ErrProductState)
+	}
+	if pr.PlannedRequestID != "" {
+		if err = s.completePlannedOrder(ctx, tx, event, pr.PlannedRequestID); err != nil {
+			return err
+		}
+	}
 
-	productInventory.Available += event.Quantity
-	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx}); err != nil {
+	components, err := s.consumeComponents(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	lots, err := s.lockLots(ctx, tx, &productInventory, event.Created)
+	if err != nil {
+		return err
+	}
+	if event.Lot != "" {
+		if err = lots.add(event.Location, event.Lot, event.ExpiresAt, event.Quantity, event.Created); err != nil {
+			return err
+		}
+	}
+
+	productInventory.Add(event.Location, event.Quantity)
+	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx, Version: PreconditionFrom(ctx).Version}); err != nil {
 		return fmt.Errorf("failed to add production to product: %w", err)
 	}
+	productInventory.Version++
+	if err = s.saveLots(ctx, tx, lots); err != nil {
+		return err
+	}
+
+	mv := InventoryMovement{Location: event.Location, Delta: event.Quantity, Reason: MovementProduction, RequestID: pr.RequestID, Lot: event.Lot}
+	if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+		return fmt.Errorf("record production movement: %w", err)
+	}
 
 	if err = tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit production transaction: %w", err)
 	}
 
+	for _, component := range components {
+		if err = s.publishInventory(ctx, component); err != nil {
+			return fmt.Errorf("failed to publish component inventory: %w", err)
+		}
+	}
 	err = s.publishInventory(ctx, productInventory)
 	if err != nil {
 		return fmt.Errorf("failed to publish inventory: %w", err)
 	}
 
-	if err = s.FillReserves(ctx, product); err != nil {
-		return fmt.Errorf("failed to fill reserves after production: %w", err)
-	}
+	if err = s.FillReserves(ctx, product); err != nil {
+		return fmt.Errorf("failed to fill reserves after production: %w", err)
+	}
+
+	return nil
+}
+
+// consumeComponents takes the components of event's product out of
+// stock at the production location inside tx and returns their updated
+// inventory. A product without a bill of materials consumes nothing.
+// Components are locked in SKU order so concurrent kits sharing them
+// queue up rather than deadlock.
+func (s *service) consumeComponents(ctx context.Context, tx persistence.Transaction, event ProductionEvent) ([]ProductInventory, error) {
+	bom, err := s.repo.GetBillOfMaterials(ctx, event.Sku, persistence.QueryOptions{Tx: tx})
+	if err != nil {
+		return nil, fmt.Errorf("get bill of materials for %q: %w", event.Sku, err)
+	}
+
+	components := make([]ProductInventory, 0, len(bom.Components))
+	lots := make([]*lotSet, 0, len(bom.Components))
+	var shortages []ComponentShortage
+	for _, c := range bom.Components {
+		pi, err := s.repo.GetProductInventory(ctx, c.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+		if err != nil {
+			return nil, fmt.Errorf("get component inventory for %q: %w", c.Sku, err)
+		}
+		ls, err := s.lockLots(ctx, tx, &pi, event.Created)
+		if err != nil {
+			return nil, err
+		}
+		need := c.Quantity * event.Quantity
+		if held := pi.AvailableAt(event.Location); held < need {
+			shortages = append(shortages, ComponentShortage{Sku: c.Sku, Required: need, Available: held})
+			continue
+		}
+		ls.take(event.Location, need, event.Created)
+		pi.Add(event.Location, -need)
+		components = append(components, pi)
+		lots = append(lots, ls)
+	}
+	if len(shortages) > 0 {
+		return nil, &ComponentShortageError{Sku: event.Sku, Location: event.Location, Shortages: shortages}
+	}
+
+	for i := range components {
+		if err = s.repo.SaveProductInventory(ctx, components[i], persistence.UpdateOptions{Tx: tx}); err != nil {
+			return nil, fmt.Errorf("consume component %q: %w", components[i].Sku, err)
+		}
+		components[i].Version++
+		if err = s.saveLots(ctx, tx, lots[i]); err != nil {
+			return nil, err
+		}
+
+		mv := InventoryMovement{Location: event.Location, Delta: -bom.Components[i].Quantity * event.Quantity, Reason: MovementAssembly, RequestID: event.RequestID}
+		if err = s.recordMovement(ctx, tx, components[i], mv); err != nil {
+			return nil, fmt.Errorf("record assembly movement: %w", err)
+		}
+	}
+	return components, nil
+}
+
+// GetBillOfMaterials returns the components of a kit. A product that
+// isn't one has no bill of materials and gets persistence.ErrNotFound.
+func (s *service) GetBillOfMaterials(ctx context.Context, sku string) (bom BillOfMaterials, err error) {
+	const funcName = "GetBillOfMaterials"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("getting bill of materials")
+
+	bom, err = s.repo.GetBillOfMaterials(ctx, sku)
+	if err != nil {
+		return BillOfMaterials{}, err
+	}
+	if len(bom.Components) == 0 {
+		return BillOfMaterials{}, persistence.ErrNotFound
+	}
+	return bom, nil
+}
+
+// SetBillOfMaterials replaces the components of bom.Sku. Every
+// component must be an existing product and no component may, however
+// indirectly, be made from the kit itself. An empty component list
+// turns the kit back into a plain product.
+func (s *service) SetBillOfMaterials(ctx context.Context, bom BillOfMaterials) (out BillOfMaterials, err error) {
+	const funcName = "SetBillOfMaterials"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", bom.Sku),
+		attribute.Int("inventory.components", len(bom.Components)),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", bom.Sku).Int("components", len(bom.Components)).Msg("setting bill of materials")
+
+	if err = validateBillOfMaterials(bom); err != nil {
+		return BillOfMaterials{}, err
+	}
+	components := append([]BOMComponent{}, bom.Components...)
+	sort.Slice(components, func(i, j int) bool { return components[i].Sku < components[j].Sku })
+	bom.Components = components
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return BillOfMaterials{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	// Locking the kit's inventory serialises the change with Produce,
+	// which reads the bill of materials under the same lock.
+	pi, err := s.repo.GetProductInventory(ctx, bom.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return BillOfMaterials{}, fmt.Errorf("get product inventory for %q: %w", bom.Sku, err)
+	}
+	if pi.State == ProductArchived {
+		return BillOfMaterials{}, fmt.Errorf("product %q is archived: %w", bom.Sku, ErrProductState)
+	}
+
+	for _, c := range bom.Components {
+		if _, err = s.repo.GetProduct(ctx, c.Sku, persistence.QueryOptions{Tx: tx}); err != nil {
+			if errors.Is(err, persistence.ErrNotFound) {
+				return BillOfMaterials{}, fmt.Errorf("component %q does not exist: %w", c.Sku, ErrInvalidInput)
+			}
+			return BillOfMaterials{}, fmt.Errorf("get component %q: %w", c.Sku, err)
+		}
+		if err = s.checkNotMadeFrom(ctx, tx, c.Sku, bom.Sku); err != nil {
+			return BillOfMaterials{}, err
+		}
+	}
+
+	if err = s.repo.SaveBillOfMaterials(ctx, bom, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return BillOfMaterials{}, fmt.Errorf("save bill of materials: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return BillOfMaterials{}, fmt.Errorf("commit bill of materials transaction: %w", err)
+	}
+	return bom, nil
+}
+
+func validateBillOfMaterials(bom BillOfMaterials) error {
+	seen := make(map[string]bool, len(bom.Components))
+	for _, c := range bom.Components {
+		if c.Sku == "" {
+			return fmt.Errorf("component sku is required: %w", ErrInvalidInput)
+		}
+		if c.Sku == bom.Sku {
+			return fmt.Errorf("%q cannot be a component of itself: %w", c.Sku, ErrInvalidInput)
+		}
+		if c.Quantity < 1 {
+			return fmt.Errorf("component %q quantity must be greater than zero: %w", c.Sku, ErrInvalidInput)
+		}
+		if seen[c.Sku] {
+			return fmt.Errorf("component %q is listed more than once: %w", c.Sku, ErrInvalidInput)
+		}
+		seen[c.Sku] = true
+	}
+	return nil
+}
+
+// checkNotMadeFrom walks down component's own bill of materials and
+// fails if kit turns up anywhere in it, which would make producing
+// either one consume the other forever.
+func (s *service) checkNotMadeFrom(ctx context.Context, tx persistence.Transaction, component, kit string) error {
+	pending := []string{component}
+	visited := map[string]bool{component: true}
+	for len(pending) > 0 {
+		sku := pending[0]
+		pending = pending[1:]
+		bom, err := s.repo.GetBillOfMaterials(ctx, sku, persistence.QueryOptions{Tx: tx})
+		if err != nil {
+			return fmt.Errorf("get bill of materials for %q: %w", sku, err)
+		}
+		for _, c := range bom.Components {
+			if c.Sku == kit {
+				return fmt.Errorf("component %q is itself made from %q: %w", component, kit, ErrInvalidInput)
+			}
+			if !visited[c.Sku] {
+				visited[c.Sku] = true
+				pending = append(pending, c.Sku)
+			}
+		}
+	}
+	return nil
+}
+
+// Buildable is a dry run of producing a kit at location: the most units
+// the component stock there could build right now, broken down by
+// component. Nothing is locked, so a real production may still come up
+// short if stock moves in between. A product that isn't a kit gets
+// persistence.ErrNotFound.
+func (s *service) Buildable(ctx context.Context, sku, location string) (b Buildable, err error) {
+	const funcName = "Buildable"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.String("inventory.location", location),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Str("location", location).Msg("calculating buildable quantity")
+
+	bom, err := s.GetBillOfMaterials(ctx, sku)
+	if err != nil {
+		return Buildable{}, err
+	}
+
+	b = Buildable{Sku: sku, Location: s.locationOrDefault(location), Components: make([]BuildableComponent, 0, len(bom.Components))}
+	for i, c := range bom.Components {
+		pi, err := s.repo.GetProductInventory(ctx, c.Sku)
+		if err != nil {
+			return Buildable{}, fmt.Errorf("get component inventory for %q: %w", c.Sku, err)
+		}
+		held := pi.AvailableAt(b.Location)
+		bc := BuildableComponent{Sku: c.Sku, Quantity: c.Quantity, Available: held, Buildable: held / c.Quantity}
+		if i == 0 || bc.Buildable < b.Quantity {
+			b.Quantity = bc.Buildable
+		}
+		b.Components = append(b.Components, bc)
+	}
+	return b, nil
+}
+
+// Adjust applies a manual correction to a SKU's available stock. The
+// request ID makes retries safe: replaying one returns the original
+// adjustment without applying it twice. Negative adjustments that
+// would take Available below zero are rejected outright rather than
+// clamped, so the recorded correction always matches what was counted.
+func (s *service) Adjust(ctx context.Context, product Product, ar AdjustmentRequest) (adj Adjustment, err error) {
+	const funcName = "Adjust"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", product.Sku),
+		attribute.String("request_id", ar.RequestID),
+		attribute.Int64("inventory.quantity", ar.Quantity),
+		attribute.String("inventory.reason", string(ar.Reason)),
+		attribute.String("inventory.location", ar.Location),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", product.Sku).
+		Str("requestId", ar.RequestID).
+		Int64("quantity", ar.Quantity).
+		Str("reason", string(ar.Reason)).
+		Str("location", ar.Location).
+		Msg("adjusting inventory")
+
+	if err = validateAdjustmentRequest(ar); err != nil {
+		return Adjustment{}, err
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return Adjustment{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	productInventory, err := s.repo.GetProductInventory(ctx, product.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Adjustment{}, fmt.Errorf("get product inventory for %q: %w", product.Sku, err)
+	}
+
+	// Looked up after locking the inventory row so two concurrent
+	// retries of the same request serialize here instead of both
+	// missing and racing on the unique constraint.
+	existing, err := s.repo.GetAdjustmentByRequestID(ctx, ar.RequestID, persistence.QueryOptions{Tx: tx})
+	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
+		return Adjustment{}, fmt.Errorf("get adjustment %q: %w", ar.RequestID, err)
+	}
+	if existing.RequestID != "" {
+		if existing.Sku != product.Sku {
+			return Adjustment{}, fmt.Errorf("request id %q already used for sku %q: %w", ar.RequestID, existing.Sku, ErrInvalidInput)
+		}
+		rollback(ctx, tx, nil)
+		log.Ctx(ctx).Debug().Str("func", funcName).Str("requestId", ar.RequestID).Msg("adjustment already applied")
+		return existing, nil
+	}
+
+	if productInventory.State == ProductArchived {
+		return Adjustment{}, fmt.Errorf("product %q is archived: %w", product.Sku, ErrProductState)
+	}
+
+	now := time.Now()
+	lots, err := s.lockLots(ctx, tx, &productInventory, now)
+	if err != nil {
+		return Adjustment{}, err
+	}
+
+	location := s.locationOrDefault(ar.Location)
+	if held := productInventory.AvailableAt(location); held+ar.Quantity < 0 {
+		return Adjustment{}, fmt.Errorf("adjustment of %d would take available at %q (%d) below zero: %w", ar.Quantity, location, held, ErrInvalidInput)
+	}
+
+	adj = Adjustment{
+		RequestID: ar.RequestID,
+		Sku:       product.Sku,
+		Location:  location,
+		Quantity:  ar.Quantity,
+		Reason:    ar.Reason,
+		Actor:     actorFrom(ctx),
+		Created:   now,
+	}
+	if err = s.repo.SaveAdjustment(ctx, &adj, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Adjustment{}, fmt.Errorf("save adjustment: %w", err)
+	}
+
+	// Found stock can't be told apart by lot, so only shrinkage touches
+	// the lots.
+	if ar.Quantity < 0 {
+		lots.take(location, -ar.Quantity, now)
+	}
+	productInventory.Add(location, ar.Quantity)
+	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx, Version: PreconditionFrom(ctx).Version}); err != nil {
+		return Adjustment{}, fmt.Errorf("save product inventory: %w", err)
+	}
+	productInventory.Version++
+	if err = s.saveLots(ctx, tx, lots); err != nil {
+		return Adjustment{}, err
+	}
+
+	mv := InventoryMovement{Location: location, Delta: ar.Quantity, Reason: MovementReason(ar.Reason), RequestID: ar.RequestID}
+	if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+		return Adjustment{}, fmt.Errorf("record adjustment movement: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Adjustment{}, fmt.Errorf("commit adjustment transaction: %w", err)
+	}
+
+	if err = s.publishInventory(ctx, productInventory); err != nil {
+		return Adjustment{}, fmt.Errorf("publish inventory: %w", err)
+	}
+
+	// Found stock may be able to satisfy waiting reservations.
+	if ar.Quantity > 0 {
+		if err = s.FillReserves(ctx, product); err != nil {
+			return Adjustment{}, fmt.Errorf("fill reserves after adjustment: %w", err)
+		}
+	}
+
+	return adj, nil
+}
+
+func validateAdjustmentRequest(ar AdjustmentRequest) error {
+	if ar.RequestID == "" {
+		return fmt.Errorf("request id is required: %w", ErrInvalidInput)
+	}
+	if ar.Quantity == 0 {
+		return fmt.Errorf("quantity must not be zero: %w", ErrInvalidInput)
+	}
+	switch ar.Reason {
+	case AdjustCycleCount:
+	case AdjustDamage, AdjustShrinkage:
+		if ar.Quantity > 0 {
+			return fmt.Errorf("%s adjustments must be negative: %w", ar.Reason, ErrInvalidInput)
+		}
+	case AdjustFound:
+		if ar.Quantity < 0 {
+			return fmt.Errorf("%s adjustments must be positive: %w", ar.Reason, ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("unknown adjustment reason %q: %w", ar.Reason, ErrInvalidInput)
+	}
+	return nil
+}
+
+// ChangeStatus moves stock of product at a location from one stock
+// status to another, such as putting a production run on hold for QA
+// or releasing it again. Stock leaving Available leaves its lots too;
+// only moves into or out of Available touch the ledger, which follows
+// Available alone. The request ID makes retries safe, as with Adjust.
+func (s *service) ChangeStatus(ctx context.Context, product Product, sr StatusChangeRequest) (change StatusChange, err error) {
+	const funcName = "ChangeStatus"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", product.Sku),
+		attribute.String("request_id", sr.RequestID),
+		attribute.String("inventory.from", string(sr.From)),
+		attribute.String("inventory.to", string(sr.To)),
+		attribute.Int64("inventory.quantity", sr.Quantity),
+		attribute.String("inventory.location", sr.Location),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", product.Sku).
+		Str("requestId", sr.RequestID).
+		Str("from", string(sr.From)).
+		Str("to", string(sr.To)).
+		Int64("quantity", sr.Quantity).
+		Str("location", sr.Location).
+		Msg("changing stock status")
+
+	if err = validateStatusChangeRequest(sr); err != nil {
+		return StatusChange{}, err
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return StatusChange{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	productInventory, err := s.repo.GetProductInventory(ctx, product.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return StatusChange{}, fmt.Errorf("get product inventory for %q: %w", product.Sku, err)
+	}
+
+	existing, err := s.repo.GetStatusChangeByRequestID(ctx, sr.RequestID, persistence.QueryOptions{Tx: tx})
+	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
+		return StatusChange{}, fmt.Errorf("get status change %q: %w", sr.RequestID, err)
+	}
+	if existing.RequestID != "" {
+		if existing.Sku != product.Sku {
+			return StatusChange{}, fmt.Errorf("request id %q already used for sku %q: %w", sr.RequestID, existing.Sku, ErrInvalidInput)
+		}
+		rollback(ctx, tx, nil)
+		log.Ctx(ctx).Debug().Str("func", funcName).Str("requestId", sr.RequestID).Msg("status change already applied")
+		return existing, nil
+	}
+
+	if productInventory.State == ProductArchived {
+		return StatusChange{}, fmt.Errorf("product %q is archived: %w", product.Sku, ErrProductState)
+	}
+
+	now := time.Now()
+	lots, err := s.lockLots(ctx, tx, &productInventory, now)
+	if err != nil {
+		return StatusChange{}, err
+	}
+
+	location := s.locationOrDefault(sr.Location)
+	if held := productInventory.StatusAt(location, sr.From); held < sr.Quantity {
+		return StatusChange{}, fmt.Errorf("%s at %q holds %d, not %d: %w", sr.From, location, held, sr.Quantity, ErrInvalidInput)
+	}
+
+	switch {
+	case sr.From == StatusAvailable && sr.Lot != "":
+		err = lots.takeLot(location, sr.Lot, sr.Quantity)
+	case sr.From == StatusAvailable:
+		lots.take(location, sr.Quantity, now)
+	case sr.To == StatusAvailable && sr.Lot != "":
+		err = lots.putBack(location, sr.Lot, sr.Quantity, now)
+	}
+	if err != nil {
+		return StatusChange{}, err
+	}
+
+	change = StatusChange{
+		RequestID: sr.RequestID,
+		Sku:       product.Sku,
+		Location:  location,
+		From:      sr.From,
+		To:        sr.To,
+		Quantity:  sr.Quantity,
+		Lot:       sr.Lot,
+		Reason:    sr.Reason,
+		Actor:     actorFrom(ctx),
+		Created:   now,
+	}
+	if err = s.repo.SaveStatusChange(ctx, &change, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return StatusChange{}, fmt.Errorf("save status change: %w", err)
+	}
+
+	productInventory.AddStatus(location, sr.From, -sr.Quantity)
+	productInventory.AddStatus(location, sr.To, sr.Quantity)
+	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx, Version: PreconditionFrom(ctx).Version}); err != nil {
+		return StatusChange{}, fmt.Errorf("save product inventory: %w", err)
+	}
+	productInventory.Version++
+	if err = s.saveLots(ctx, tx, lots); err != nil {
+		return StatusChange{}, err
+	}
+
+	if delta := statusDelta(sr); delta != 0 {
+		mv := InventoryMovement{Location: location, Delta: delta, Reason: MovementStatusChange, RequestID: sr.RequestID}
+		if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+			return StatusChange{}, fmt.Errorf("record status change movement: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return StatusChange{}, fmt.Errorf("commit status change transaction: %w", err)
+	}
+
+	if err = s.publishInventory(ctx, productInventory); err != nil {
+		return StatusChange{}, fmt.Errorf("publish inventory: %w", err)
+	}
+
+	// Released stock may be able to satisfy waiting reservations.
+	if sr.To == StatusAvailable {
+		if err = s.FillReserves(ctx, product); err != nil {
+			return StatusChange{}, fmt.Errorf("fill reserves after status change: %w", err)
+		}
+	}
+
+	return change, nil
+}
+
+func validateStatusChangeRequest(sr StatusChangeRequest) error {
+	if sr.RequestID == "" {
+		return fmt.Errorf("request id is required: %w", ErrInvalidInput)
+	}
+	if sr.Quantity < 1 {
+		return fmt.Errorf("quantity must be greater than zero: %w", ErrInvalidInput)
+	}
+	if sr.Reason == "" {
+		return fmt.Errorf("reason is required: %w", ErrInvalidInput)
+	}
+	for _, status := range []StockStatus{sr.From, sr.To} {
+		if _, err := ParseStockStatus(string(status)); err != nil {
+			return err
+		}
+	}
+	if sr.From == sr.To {
+		return fmt.Errorf("stock is already %s: %w", sr.To, ErrInvalidInput)
+	}
+	if sr.Lot != "" && sr.From != StatusAvailable && sr.To != StatusAvailable {
+		return fmt.Errorf("lots only track available stock, not %s or %s: %w", sr.From, sr.To, ErrInvalidInput)
+	}
+	return nil
+}
+
+// statusDelta is how much a status change moves Available by.
+func statusDelta(sr StatusChangeRequest) int64 {
+	switch {
+	case sr.From == StatusAvailable:
+		return -sr.Quantity
+	case sr.To == StatusAvailable:
+		return sr.Quantity
+	default:
+		return 0
+	}
+}
+
+// Transfer moves stock of product between two locations. The quantity
+// leaves the source location's available stock straight away and is
+// held as in-transit stock at the destination until ReceiveTransfer
+// credits it there. Lot-tracked stock is taken first-expiring-first,
+// and the lots it came from are recorded with the transfer. The
+// request ID makes retries safe, as with Adjust.
+func (s *service) Transfer(ctx context.Context, product Product, tr TransferRequest) (t Transfer, err error) {
+	const funcName = "Transfer"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", product.Sku),
+		attribute.String("request_id", tr.RequestID),
+		attribute.Int64("inventory.quantity", tr.Quantity),
+		attribute.String("inventory.from", tr.From),
+		attribute.String("inventory.to", tr.To),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", product.Sku).
+		Str("requestId", tr.RequestID).
+		Int64("quantity", tr.Quantity).
+		Str("from", tr.From).
+		Str("to", tr.To).
+		Msg("transferring inventory")
+
+	if err = validateTransferRequest(tr); err != nil {
+		return Transfer{}, err
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	productInventory, err := s.repo.GetProductInventory(ctx, product.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Transfer{}, fmt.Errorf("get product inventory for %q: %w", product.Sku, err)
+	}
+
+	existing, err := s.repo.GetTransferByRequestID(ctx, tr.RequestID, persistence.QueryOptions{Tx: tx})
+	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
+		return Transfer{}, fmt.Errorf("get transfer %q: %w", tr.RequestID, err)
+	}
+	if existing.RequestID != "" {
+		if existing.Sku != product.Sku {
+			return Transfer{}, fmt.Errorf("request id %q already used for sku %q: %w", tr.RequestID, existing.Sku, ErrInvalidInput)
+		}
+		rollback(ctx, tx, nil)
+		log.Ctx(ctx).Debug().Str("func", funcName).Str("requestId", tr.RequestID).Msg("transfer already requested")
+		return existing, nil
+	}
+
+	now := time.Now()
+	lots, err := s.lockLots(ctx, tx, &productInventory, now)
+	if err != nil {
+		return Transfer{}, err
+	}
+
+	if held := productInventory.AvailableAt(tr.From); held < tr.Quantity {
+		return Transfer{}, fmt.Errorf("cannot transfer %d from %q, only %d available: %w", tr.Quantity, tr.From, held, ErrInvalidInput)
+	}
+
+	t = Transfer{
+		RequestID: tr.RequestID,
+		Sku:       product.Sku,
+		From:      tr.From,
+		To:        tr.To,
+		Quantity:  tr.Quantity,
+		State:     TransferRequested,
+		Actor:     actorFrom(ctx),
+		Created:   now,
+		Updated:   now,
+	}
+	if err = s.repo.SaveTransfer(ctx, &t, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Transfer{}, fmt.Errorf("save transfer: %w", err)
+	}
+
+	draws := lots.take(tr.From, tr.Quantity, now)
+	productInventory.Add(tr.From, -tr.Quantity)
+	productInventory.AddInTransit(tr.To, tr.Quantity)
+	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx, Version: PreconditionFrom(ctx).Version}); err != nil {
+		return Transfer{}, fmt.Errorf("save product inventory: %w", err)
+	}
+	productInventory.Version++
+	if err = s.saveLots(ctx, tx, lots); err != nil {
+		return Transfer{}, err
+	}
+	if err = s.saveTransferLots(ctx, tx, t.ID, draws); err != nil {
+		return Transfer{}, err
+	}
+
+	mv := InventoryMovement{Location: tr.From, Delta: -tr.Quantity, Reason: MovementTransferOut, RequestID: tr.RequestID}
+	if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+		return Transfer{}, fmt.Errorf("record transfer movement: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Transfer{}, fmt.Errorf("commit transfer transaction: %w", err)
+	}
+
+	if err = s.publishInventory(ctx, productInventory); err != nil {
+		return Transfer{}, fmt.Errorf("publish inventory: %w", err)
+	}
+	s.publishTransfer(ctx, t)
+
+	return t, nil
+}
+
+func validateTransferRequest(tr TransferRequest) error {
+	if tr.RequestID == "" {
+		return fmt.Errorf("request id is required: %w", ErrInvalidInput)
+	}
+	if tr.Quantity < 1 {
+		return fmt.Errorf("quantity must be greater than zero: %w", ErrInvalidInput)
+	}
+	if tr.From == "" || tr.To == "" {
+		return fmt.Errorf("from and to locations are required: %w", ErrInvalidInput)
+	}
+	if tr.From == tr.To {
+		return fmt.Errorf("cannot transfer from %q to itself: %w", tr.From, ErrInvalidInput)
+	}
+	return nil
+}
+
+// DispatchTransfer marks a Requested transfer as having left its
+// source location. Stock levels don't change: the quantity has been
+// in transit since the transfer was created. Dispatching a transfer
+// that is already In-Transit returns it unchanged.
+func (s *service) DispatchTransfer(ctx context.Context, ID uint64) (t Transfer, err error) {
+	const funcName = "DispatchTransfer"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.transfer_id", strconv.FormatUint(ID, 10)),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Uint64("id", ID).Msg("dispatching transfer")
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	t, err = s.repo.GetTransfer(ctx, ID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Transfer{}, fmt.Errorf("get transfer %d: %w", ID, err)
+	}
+	if t.State == TransferInTransit {
+		rollback(ctx, tx, nil)
+		return t, nil
+	}
+	if t.State != TransferRequested {
+		return Transfer{}, fmt.Errorf("transfer %d is %s; only Requested transfers can be dispatched: %w", ID, t.State, ErrInvalidInput)
+	}
+
+	t.State = TransferInTransit
+	t.Updated = time.Now()
+	if err = s.repo.UpdateTransfer(ctx, t.ID, t.State, t.Updated, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Transfer{}, fmt.Errorf("update transfer %d: %w", ID, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Transfer{}, fmt.Errorf("commit dispatch transaction: %w", err)
+	}
+
+	s.publishTransfer(ctx, t)
+	return t, nil
+}
+
+// ReceiveTransfer lands an In-Transit transfer at its destination:
+// the quantity moves out of the destination's in-transit stock into
+// its available stock, and FillReserves runs so reservations waiting
+// on that location can pick it up. Stock the transfer took from lots
+// goes back into the same lots at the destination, keeping their
+// expiry dates, so it still expires and can still be recalled; any of
+// it whose date passed in transit expires as it arrives. Receiving a
+// transfer twice returns it unchanged.
+func (s *service) ReceiveTransfer(ctx context.Context, ID uint64) (t Transfer, err error) {
+	const funcName = "ReceiveTransfer"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.transfer_id", strconv.FormatUint(ID, 10)),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Uint64("id", ID).Msg("receiving transfer")
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	t, err = s.repo.GetTransfer(ctx, ID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Transfer{}, fmt.Errorf("get transfer %d: %w", ID, err)
+	}
+	if t.State == TransferReceived {
+		rollback(ctx, tx, nil)
+		return t, nil
+	}
+	if t.State != TransferInTransit {
+		return Transfer{}, fmt.Errorf("transfer %d is %s; only In-Transit transfers can be received: %w", ID, t.State, ErrInvalidInput)
+	}
+
+	productInventory, err := s.repo.GetProductInventory(ctx, t.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Transfer{}, fmt.Errorf("get product inventory for %q: %w", t.Sku, err)
+	}
+
+	now := time.Now()
+	lots, err := s.lockLots(ctx, tx, &productInventory, now)
+	if err != nil {
+		return Transfer{}, err
+	}
+	draws, err := s.repo.GetTransferLots(ctx, t.ID, persistence.QueryOptions{Tx: tx})
+	if err != nil {
+		return Transfer{}, fmt.Errorf("get lots taken by transfer %d: %w", ID, err)
+	}
+	for _, d := range draws {
+		var expiresAt *time.Time
+		if from := lots.find(t.From, d.Lot); from != nil {
+			expiresAt = from.ExpiresAt
+		}
+		lots.arrive(t.To, d.Lot, expiresAt, d.Quantity)
+	}
+
+	productInventory.AddInTransit(t.To, -t.Quantity)
+	productInventory.Add(t.To, t.Quantity)
+	mv := InventoryMovement{Location: t.To, Delta: t.Quantity, Reason: MovementTransferIn, RequestID: t.RequestID}
+	if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+		return Transfer{}, fmt.Errorf("record transfer movement: %w", err)
+	}
+	if err = s.expireLots(ctx, tx, &productInventory, lots, now); err != nil {
+		return Transfer{}, err
+	}
+
+	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Transfer{}, fmt.Errorf("save product inventory: %w", err)
+	}
+	productInventory.Version++
+	if err = s.saveLots(ctx, tx, lots); err != nil {
+		return Transfer{}, err
+	}
+
+	t.State = TransferReceived
+	t.Updated = now
+	if err = s.repo.UpdateTransfer(ctx, t.ID, t.State, t.Updated, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Transfer{}, fmt.Errorf("update transfer %d: %w", ID, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Transfer{}, fmt.Errorf("commit receive transaction: %w", err)
+	}
+
+	if err = s.publishInventory(ctx, productInventory); err != nil {
+		return Transfer{}, fmt.Errorf("publish inventory: %w", err)
+	}
+	s.publishTransfer(ctx, t)
+
+	if err = s.FillReserves(ctx, productInventory.Product); err != nil {
+		return Transfer{}, fmt.Errorf("fill reserves after transfer: %w", err)
+	}
+
+	return t, nil
+}
+
+func (s *service) Reserve(ctx context.Context, rr ReservationRequest) (res Reservation, err error) {
+	const funcName = "Reserve"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", rr.Sku),
+		attribute.String("request_id", rr.RequestID),
+		attribute.String("inventory.requester", rr.Requester),
+		attribute.Int64("inventory.quantity", rr.Quantity),
+		attribute.String("inventory.location", rr.Location),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("requestID", rr.RequestID).
+		Str("sku", rr.Sku).
+		Str("requester", rr.Requester).
+		Int64("quantity", rr.Quantity).
+		Str("location", rr.Location).
+		Msg("reserving inventory")
+
+	if err := validateReservationRequest(rr); err != nil {
+		return Reservation{}, err
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+	if err != nil {
+		return Reservation{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	pi, err := s.repo.GetProductInventory(ctx, rr.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Reservation{}, fmt.Errorf("get product inventory %q: %w", rr.Sku, err)
+	}
+
+	res, err = s.repo.GetReservationByRequestID(ctx, rr.RequestID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
+		return Reservation{}, fmt.Errorf("get reservation by request id %q: %w", rr.RequestID, err)
+	}
+	if res.RequestID != "" {
+		log.Ctx(ctx).Debug().Str("func", funcName).Str("requestId", rr.RequestID).Msg("reservation already exists, returning it")
+		rollback(ctx, tx, nil)
+		return res, nil
+	}
+	if !pi.Active() {
+		return Reservation{}, fmt.Errorf("product %q is %s and can't be reserved: %w", rr.Sku, pi.State, ErrProductState)
+	}
+	now := time.Now()
+	if err = s.checkRequesterLimits(ctx, tx, rr.Requester, rr.Sku, rr.Quantity, true, now); err != nil {
+		return Reservation{}, err
+	}
+
+	res = Reservation{
+		RequestID:         rr.RequestID,
+		Requester:         rr.Requester,
+		Sku:               rr.Sku,
+		Location:          rr.Location,
+		State:             Open,
+		RequestedQuantity: rr.Quantity,
+		Created:           now,
+		Priority:          rr.Priority,
+		FillPolicy:        FillPartial,
+		MinQuantity:       rr.MinQuantity,
+	}
+	if rr.FillPolicy != "" {
+		res.FillPolicy = rr.FillPolicy
+	}
+	if res.Location == "" {
+		res.Location = s.pickLocation(pi, rr.Quantity)
+	}
+	if ttl := s.reservationHold(rr); ttl > 0 {
+		expiresAt := res.Created.Add(ttl)
+		res.ExpiresAt = &expiresAt
+	}
+
+	// Immediate reservations take their stock here, inside the same
+	// transaction, rather than queueing behind FillReserves. Any stock
+	// still available has already been offered to the open
+	// reservations that could use it.
+	immediate := rr.Mode == ReserveImmediate
+	var (
+		lots  *lotSet
+		draws []LotDraw
+	)
+	if immediate {
+		if lots, err = s.lockLots(ctx, tx, &pi, res.Created); err != nil {
+			return Reservation{}, err
+		}
+		held := pi.AvailableAt(res.Location)
+		if held < rr.Quantity {
+			return Reservation{}, fmt.Errorf("%d of %q requested at %s but %d available: %w", rr.Quantity, rr.Sku, res.Location, held, ErrInsufficientStock)
+		}
+		draws = lots.take(res.Location, rr.Quantity, res.Created)
+		pi.Add(res.Location, -rr.Quantity)
+		res.ReservedQuantity = rr.Quantity
+		res.State = Closed
+	}
+
+	if err = s.repo.SaveReservation(ctx, &res, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Reservation{}, fmt.Errorf("save reservation: %w", err)
+	}
+	if err = s.repo.SaveRequesterDemand(ctx, res.Requester, res.ID, res.RequestedQuantity, now, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Reservation{}, fmt.Errorf("save requester demand: %w", err)
+	}
+
+	if immediate {
+		if err = s.repo.SaveProductInventory(ctx, pi, persistence.UpdateOptions{Tx: tx}); err != nil {
+			return Reservation{}, fmt.Errorf("save product inventory: %w", err)
+		}
+		pi.Version++
+		if err = s.saveLots(ctx, tx, lots); err != nil {
+			return Reservation{}, err
+		}
+		if err = s.saveReservationLots(ctx, tx, res.ID, draws); err != nil {
+			return Reservation{}, err
+		}
+		mv := InventoryMovement{Location: res.Location, Delta: -res.ReservedQuantity, Reason: MovementReservation, RequestID: res.RequestID, ReservationID: &res.ID}
+		if err = s.recordMovement(ctx, tx, pi, mv); err != nil {
+			return Reservation{}, fmt.Errorf("record reservation movement: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Reservation{}, fmt.Errorf("commit reserve transaction: %w", err)
+	}
+
+	if immediate {
+		if err = s.publishInventory(ctx, pi); err != nil {
+			return Reservation{}, fmt.Errorf("publish inventory: %w", err)
+		}
+		if err = s.publishReservation(ctx, res); err != nil {
+			return Reservation{}, fmt.Errorf("publish reservation: %w", err)
+		}
+		return res, nil
+	}
+
+	if err = s.FillReserves(ctx, pi.Product); err != nil {
+		return Reservation{}, fmt.Errorf("fill reserves after reserve: %w", err)
+	}
+
+	return res, nil
+}
+
+func validateReservationRequest(rr ReservationRequest) error {
+	if rr.RequestID == "" {
+		return fmt.Errorf("request id is required: %w", ErrInvalidInput)
+	}
+	if rr.Requester == "" {
+		return fmt.Errorf("requester is required: %w", ErrInvalidInput)
+	}
+	if rr.Sku == "" {
+		return fmt.Errorf("sku is required: %w", ErrInvalidInput)
+	}
+	if rr.Quantity < 1 {
+		return fmt.Errorf("quantity is required: %w", ErrInvalidInput)
+	}
+	if rr.TTLSeconds < 0 {
+		return fmt.Errorf("ttl seconds cannot be negative: %w", ErrInvalidInput)
+	}
+	policy, err := ParseFillPolicy(string(rr.FillPolicy))
+	if err != nil {
+		return err
+	}
+	switch {
+	case policy == FillMinimum && (rr.MinQuantity < 1 || rr.MinQuantity > rr.Quantity):
+		return fmt.Errorf("min quantity must be between 1 and quantity: %w", ErrInvalidInput)
+	case policy != FillMinimum && rr.MinQuantity != 0:
+		return fmt.Errorf("min quantity only applies to the minimum fill policy: %w", ErrInvalidInput)
+	}
+	switch rr.Mode {
+	case ReserveQueue, ReserveImmediate, "":
+	default:
+		return fmt.Errorf("invalid reserve mode %q: %w", rr.Mode, ErrInvalidInput)
+	}
+	return nil
+}
+
+// checkRequesterLimits checks that requester reserving quantity more of
+// sku inside tx stays within its RequesterLimits, falling back to the
+// DefaultRequester's. opening is whether that adds a reservation. The
+// requester is locked first so its concurrent reservations, whatever
+// their SKU, are checked one at a time.
+func (s *service) checkRequesterLimits(ctx context.Context, tx persistence.Transaction, requester, sku string, quantity int64, opening bool, now time.Time) error {
+	limits, err := s.repo.GetRequesterLimits(ctx, requester, persistence.QueryOptions{Tx: tx})
+	if errors.Is(err, persistence.ErrNotFound) {
+		limits, err = s.repo.GetRequesterLimits(ctx, DefaultRequester, persistence.QueryOptions{Tx: tx})
+	}
+	if errors.Is(err, persistence.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get limits for requester %q: %w", requester, err)
+	}
+	if limits.Unlimited() {
+		return nil
+	}
+
+	if err = s.repo.LockRequester(ctx, requester, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return fmt.Errorf("lock requester %q: %w", requester, err)
+	}
+	y, m, d := now.UTC().Date()
+	usage, err := s.repo.GetRequesterUsage(ctx, requester, sku, time.Date(y, m, d, 0, 0, 0, 0, time.UTC), persistence.QueryOptions{Tx: tx})
+	if err != nil {
+		return fmt.Errorf("get usage for requester %q: %w", requester, err)
+	}
+
+	le := &RequesterLimitError{Requester: requester, Class: limits.Class, Sku: sku, Requested: quantity}
+	switch {
+	case limits.MaxReservedPerSku > 0 && usage.ReservedForSku+quantity > limits.MaxReservedPerSku:
+		le.Limit, le.Max, le.Current = LimitReservedPerSku, limits.MaxReservedPerSku, usage.ReservedForSku
+	case opening && limits.MaxOpenReservations > 0 && usage.OpenReservations >= limits.MaxOpenReservations:
+		le.Limit, le.Max, le.Current, le.Requested = LimitOpenReservations, limits.MaxOpenReservations, usage.OpenReservations, 1
+	case limits.DailyCap > 0 && usage.ReservedToday+quantity > limits.DailyCap:
+		le.Limit, le.Max, le.Current = LimitDailyCap, limits.DailyCap, usage.ReservedToday
+	default:
+		return nil
+	}
+
+	ensureLimitMetrics()
+	limitRejections.WithLabelValues(le.Class, string(le.Limit)).Inc()
+	log.Ctx(ctx).Info().Str("requester", requester).Str("class", le.Class).Str("sku", sku).Str("limit", string(le.Limit)).Msg("reservation rejected by requester limit")
+	return le
+}
+
+// locationOrDefault resolves an optional request location.
+func (s *service) locationOrDefault(location string) string {
+	if location == "" {
+		return s.defaultLocation
+	}
+	return location
+}
+
+// pickLocation chooses where a reservation for qty of pi should draw
+// from when the request didn't say. With nothing in stock anywhere
+// the reservation waits at the default location (or the first
+// priority location) for production to arrive.
+func (s *service) pickLocation(pi ProductInventory, qty int64) string {
+	if s.locationStrategy == LocationPriority && len(s.locationPriority) > 0 {
+		for _, l := range s.locationPriority {
+			if pi.AvailableAt(l) >= qty {
+				return l
+			}
+		}
+		for _, l := range s.locationPriority {
+			if pi.AvailableAt(l) > 0 {
+				return l
+			}
+		}
+		return s.locationPriority[0]
+	}
+
+	best := LocationInventory{Location: s.defaultLocation}
+	for _, l := range pi.Locations {
+		if l.Available > best.Available {
+			best = l
+		}
+	}
+	return best.Location
+}
+
+// reservationHold is how long a new reservation may hold stock: the
+// request's own TTL when set, otherwise the service default. Zero
+// means no expiry.
+func (s *service) reservationHold(rr ReservationRequest) time.Duration {
+	if rr.TTLSeconds > 0 {
+		return time.Duration(rr.TTLSeconds) * time.Second
+	}
+	return s.reservationTTL
+}
+
+// Cancel backs out a reservation. Any quantity already set aside is
+// returned to the SKU's available inventory in the same transaction
+// that moves the reservation to Cancelled, and FillReserves then
+// runs so other open reservations can pick up the freed stock.
+// Cancelling a reservation that is already Cancelled or Expired is a
+// no-op that returns the stored reservation, which keeps DELETE
+// idempotent. A Fulfilled reservation has nothing left to release and
+// can't be cancelled; a partially shipped one releases only its
+// unshipped remainder.
+func (s *service) Cancel(ctx context.Context, ID uint64) (res Reservation, err error) {
+	const funcName = "Cancel"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.reservation_id", strconv.FormatUint(ID, 10)),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Uint64("id", ID).Msg("cancelling reservation")
+
+	res, productInventory, released, err := s.releaseReservation(ctx, ID, Cancelled, func(r Reservation) bool {
+		return r.State == Open || r.State == Closed
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+	if !released && res.State == Fulfilled {
+		return Reservation{}, fmt.Errorf("reservation %d is already fulfilled: %w", ID, ErrInvalidInput)
+	}
+	if !released {
+		log.Ctx(ctx).Debug().Str("func", funcName).Uint64("id", ID).Str("state", string(res.State)).Msg("reservation already released, returning it")
+		return res, nil
+	}
+
+	if err = s.FillReserves(ctx, productInventory.Product); err != nil {
+		return Reservation{}, fmt.Errorf("fill reserves after cancel: %w", err)
+	}
+
+	return res, nil
+}
+
+// Modify changes an Open or Closed reservation's requested quantity.
+// Lowering it below what the reservation holds hands the surplus back
+// to available stock; raising it past what it holds reopens the
+// reservation. Either way FillReserves then runs so freed stock, or
+// the reopened reservation, is picked up. The quantity can't drop
+// below what has already shipped, or below a minimum fill policy's
+// MinQuantity.
+func (s *service) Modify(ctx context.Context, ID uint64, ru ReservationUpdate) (res Reservation, err error) {
+	const funcName = "Modify"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.reservation_id", strconv.FormatUint(ID, 10)),
+		attribute.Int64("inventory.quantity", ru.Quantity),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Uint64("id", ID).Int64("quantity", ru.Quantity).Msg("modifying reservation")
+
+	if ru.Quantity < 1 {
+		return Reservation{}, fmt.Errorf("quantity must be greater than zero: %w", ErrInvalidInput)
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+	if err != nil {
+		return Reservation{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	res, err = s.repo.GetReservation(ctx, ID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Reservation{}, fmt.Errorf("get reservation %d: %w", ID, err)
+	}
+	if res.State != Open && res.State != Closed {
+		err = fmt.Errorf("reservation %d is %s; only Open or Closed reservations can change quantity: %w", ID, res.State, ErrInvalidInput)
+		return Reservation{}, err
+	}
+	if ru.Quantity < res.ShippedQuantity {
+		err = fmt.Errorf("quantity %d is below the %d already shipped on reservation %d: %w", ru.Quantity, res.ShippedQuantity, ID, ErrInvalidInput)
+		return Reservation{}, err
+	}
+	if res.FillPolicy == FillMinimum && ru.Quantity < res.MinQuantity {
+		err = fmt.Errorf("quantity %d is below reservation %d's min quantity %d: %w", ru.Quantity, ID, res.MinQuantity, ErrInvalidInput)
+		return Reservation{}, err
+	}
+	if ru.Quantity == res.RequestedQuantity {
+		rollback(ctx, tx, nil)
+		return res, nil
+	}
+	now := time.Now()
+	change := ru.Quantity - res.RequestedQuantity
+	if change > 0 {
+		if err = s.checkRequesterLimits(ctx, tx, res.Requester, res.Sku, change, false, now); err != nil {
+			return Reservation{}, err
+		}
+	}
+
+	var productInventory ProductInventory
+	surplus := res.ReservedQuantity - ru.Quantity
+	if surplus > 0 {
+		productInventory, err = s.repo.GetProductInventory(ctx, res.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+		if err != nil {
+			return Reservation{}, fmt.Errorf("get product inventory for %q: %w", res.Sku, err)
+		}
+		location := s.locationOrDefault(res.Location)
+		var back int64
+		if back, err = s.returnStock(ctx, tx, &productInventory, res, location, surplus); err != nil {
+			return Reservation{}, err
+		}
+		if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx}); err != nil {
+			return Reservation{}, fmt.Errorf("save product inventory: %w", err)
+		}
+		productInventory.Version++
+		if back > 0 {
+			mv := InventoryMovement{Location: location, Delta: back, Reason: MovementReservationChange, RequestID: res.RequestID, ReservationID: &res.ID}
+			if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+				return Reservation{}, fmt.Errorf("record reservation change movement: %w", err)
+			}
+		}
+		res.ReservedQuantity = ru.Quantity
+	}
+
+	res.RequestedQuantity = ru.Quantity
+	switch {
+	case res.ShippedQuantity == res.RequestedQuantity:
+		res.State = Fulfilled
+	case res.ReservedQuantity == res.RequestedQuantity:
+		res.State = Closed
+	default:
+		res.State = Open
+	}
+	if err = s.repo.UpdateReservationQuantity(ctx, res.ID, res.State, res.RequestedQuantity, res.ReservedQuantity, persistence.UpdateOptions{Tx: tx, Version: PreconditionFrom(ctx).Version}); err != nil {
+		return Reservation{}, fmt.Errorf("update reservation %d: %w", res.ID, err)
+	}
+	res.Version++
+	if err = s.repo.SaveRequesterDemand(ctx, res.Requester, res.ID, change, now, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Reservation{}, fmt.Errorf("save requester demand: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Reservation{}, fmt.Errorf("commit modify transaction: %w", err)
+	}
+
+	if surplus > 0 {
+		if err = s.publishInventory(ctx, productInventory); err != nil {
+			return Reservation{}, fmt.Errorf("publish inventory: %w", err)
+		}
+	}
+	if err = s.publishReservation(ctx, res); err != nil {
+		return Reservation{}, fmt.Errorf("publish reservation: %w", err)
+	}
+
+	if surplus > 0 || res.State == Open {
+		if err = s.FillReserves(ctx, Product{Sku: res.Sku}); err != nil {
+			return Reservation{}, fmt.Errorf("fill reserves after modify: %w", err)
+		}
+		if res.State == Open {
+			// FillReserves may have topped the reservation straight
+			// back up; return it as it now stands.
+			if res, err = s.repo.GetReservation(ctx, ID); err != nil {
+				return Reservation{}, fmt.Errorf("get reservation %d: %w", ID, err)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Ship records a physical shipment of quantity against a Closed
+// reservation. Reserved stock already left Available when the
+// reservation was filled, so shipping only consumes the reservation:
+// the shipped total grows and, once everything requested has
+// shipped, the reservation moves to Fulfilled. Partial shipments are
+// allowed up to the unshipped remainder. A repeated RequestID returns
+// the original shipment without shipping again.
+func (s *service) Ship(ctx context.Context, ID uint64, sr ShipmentRequest) (shipment Shipment, err error) {
+	const funcName = "Ship"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.reservation_id", strconv.FormatUint(ID, 10)),
+		attribute.String("request_id", sr.RequestID),
+		attribute.Int64("inventory.quantity", sr.Quantity),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Uint64("id", ID).
+		Str("requestId", sr.RequestID).
+		Int64("quantity", sr.Quantity).
+		Msg("shipping reservation")
+
+	if sr.RequestID == "" {
+		return Shipment{}, fmt.Errorf("request id is required: %w", ErrInvalidInput)
+	}
+	if sr.Quantity < 1 {
+		return Shipment{}, fmt.Errorf("quantity must be greater than zero: %w", ErrInvalidInput)
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+	if err != nil {
+		return Shipment{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	res, err := s.repo.GetReservation(ctx, ID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Shipment{}, fmt.Errorf("get reservation %d: %w", ID, err)
+	}
+
+	shipment, err = s.repo.GetShipmentByRequestID(ctx, sr.RequestID, persistence.QueryOptions{Tx: tx})
+	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
+		return Shipment{}, fmt.Errorf("get shipment by request id %q: %w", sr.RequestID, err)
+	}
+	if err == nil {
+		if shipment.ReservationID != ID {
+			err = fmt.Errorf("request id %q already shipped reservation %d: %w", sr.RequestID, shipment.ReservationID, ErrInvalidInput)
+			return Shipment{}, err
+		}
+		log.Ctx(ctx).Debug().Str("func", funcName).Str("requestId", sr.RequestID).Msg("shipment already exists, returning it")
+		rollback(ctx, tx, nil)
+		return shipment, nil
+	}
+
+	if res.State != Closed {
+		err = fmt.Errorf("reservation %d is %s; only Closed reservations can ship: %w", ID, res.State, ErrInvalidInput)
+		return Shipment{}, err
+	}
+	if remaining := res.ReservedQuantity - res.ShippedQuantity; sr.Quantity > remaining {
+		err = fmt.Errorf("quantity %d exceeds the %d left to ship on reservation %d: %w", sr.Quantity, remaining, ID, ErrInvalidInput)
+		return Shipment{}, err
+	}
+
+	shipment = Shipment{
+		RequestID:     sr.RequestID,
+		ReservationID: res.ID,
+		Sku:           res.Sku,
+		Quantity:      sr.Quantity,
+		Created:       time.Now(),
+	}
+	if err = s.repo.SaveShipment(ctx, &shipment, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Shipment{}, fmt.Errorf("save shipment: %w", err)
+	}
+
+	res.ShippedQuantity += sr.Quantity
+	if res.ShippedQuantity == res.RequestedQuantity {
+		res.State = Fulfilled
+	}
+	if err = s.repo.UpdateReservationShipment(ctx, res.ID, res.State, res.ShippedQuantity, persistence.UpdateOptions{Tx: tx, Version: PreconditionFrom(ctx).Version}); err != nil {
+		return Shipment{}, fmt.Errorf("update reservation %d: %w", res.ID, err)
+	}
+	res.Version++
+
+	if err = tx.Commit(ctx); err != nil {
+		return Shipment{}, fmt.Errorf("commit ship transaction: %w", err)
+	}
+
+	if err = s.publishReservation(ctx, res); err != nil {
+		return Shipment{}, fmt.Errorf("publish reservation: %w", err)
+	}
+
+	return shipment, nil
+}
+
+// RecordReturn takes returned goods of product back at a location and
+// applies the outcome of their inspection. Restocked goods go back into
+// available stock the same way production does: in one transaction
+// with a ledger movement, then published, with waiting reservations
+// filled from them. Quarantined goods are held back from sale and
+// scrapped goods are only recorded. Returns against a reservation are
+// limited to what it has shipped and not had back yet. The request ID
+// makes retries safe, as with Adjust.
+func (s *service) RecordReturn(ctx context.Context, product Product, rr ReturnRequest) (ret Return, err error) {
+	const funcName = "RecordReturn"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", product.Sku),
+		attribute.String("request_id", rr.RequestID),
+		attribute.Int64("inventory.quantity", rr.Quantity),
+		attribute.String("inventory.outcome", string(rr.Outcome)),
+		attribute.String("inventory.location", rr.Location),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", product.Sku).
+		Str("requestId", rr.RequestID).
+		Int64("quantity", rr.Quantity).
+		Str("outcome", string(rr.Outcome)).
+		Uint64("reservationId", rr.ReservationID).
+		Str("location", rr.Location).
+		Msg("recording return")
+
+	if err = validateReturnRequest(rr); err != nil {
+		return Return{}, err
+	}
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return Return{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	// The reservation is locked before the product inventory, the order
+	// releaseReservation and Modify take them in, so a return can't
+	// deadlock against a cancel or modify of the same reservation.
+	var res Reservation
+	if rr.ReservationID != 0 {
+		res, err = s.repo.GetReservation(ctx, rr.ReservationID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+		if errors.Is(err, persistence.ErrNotFound) {
+			return Return{}, fmt.Errorf("reservation %d not found: %w", rr.ReservationID, ErrInvalidInput)
+		}
+		if err != nil {
+			return Return{}, fmt.Errorf("get reservation %d: %w", rr.ReservationID, err)
+		}
+	}
+
+	productInventory, err := s.repo.GetProductInventory(ctx, product.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Return{}, fmt.Errorf("get product inventory for %q: %w", product.Sku, err)
+	}
+
+	existing, err := s.repo.GetReturnByRequestID(ctx, rr.RequestID, persistence.QueryOptions{Tx: tx})
+	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
+		return Return{}, fmt.Errorf("get return %q: %w", rr.RequestID, err)
+	}
+	if existing.RequestID != "" {
+		if existing.Sku != product.Sku {
+			return Return{}, fmt.Errorf("request id %q already used for sku %q: %w", rr.RequestID, existing.Sku, ErrInvalidInput)
+		}
+		rollback(ctx, tx, nil)
+		log.Ctx(ctx).Debug().Str("func", funcName).Str("requestId", rr.RequestID).Msg("return already recorded")
+		return existing, nil
+	}
+
+	ret = Return{
+		RequestID: rr.RequestID,
+		Sku:       product.Sku,
+		Location:  rr.Location,
+		Requester: rr.Requester,
+		Quantity:  rr.Quantity,
+		Outcome:   rr.Outcome,
+		Lot:       rr.Lot,
+		Reason:    rr.Reason,
+		Actor:     actorFrom(ctx),
+		Created:   time.Now(),
+	}
+	if rr.ReservationID != 0 {
+		if err = s.checkReturnable(ctx, tx, res, product.Sku, rr); err != nil {
+			return Return{}, err
+		}
+		ret.ReservationID = &res.ID
+		ret.Requester = res.Requester
+		if ret.Location == "" {
+			ret.Location = res.Location
+		}
+	}
+	ret.Location = s.locationOrDefault(ret.Location)
+
+	if rr.Outcome != ReturnScrap && productInventory.State == ProductArchived {
+		return Return{}, fmt.Errorf("product %q is archived: %w", product.Sku, ErrProductState)
+	}
+
+	if err = s.repo.SaveReturn(ctx, &ret, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Return{}, fmt.Errorf("save return: %w", err)
+	}
+
+	if rr.Outcome == ReturnScrap {
+		if err = tx.Commit(ctx); err != nil {
+			return Return{}, fmt.Errorf("commit return transaction: %w", err)
+		}
+		return ret, nil
+	}
+
+	lots, err := s.lockLots(ctx, tx, &productInventory, ret.Created)
+	if err != nil {
+		return Return{}, err
+	}
+	if ret.Lot != "" {
+		if err = lots.putBack(ret.Location, ret.Lot, ret.Quantity, ret.Created); err != nil {
+			return Return{}, err
+		}
+	}
+
+	status := StatusAvailable
+	if rr.Outcome == ReturnQuarantine {
+		status = StatusQuarantined
+	}
+	productInventory.AddStatus(ret.Location, status, ret.Quantity)
+	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx, Version: PreconditionFrom(ctx).Version}); err != nil {
+		return Return{}, fmt.Errorf("save product inventory: %w", err)
+	}
+	productInventory.Version++
+	if err = s.saveLots(ctx, tx, lots); err != nil {
+		return Return{}, err
+	}
+
+	if status == StatusAvailable {
+		mv := InventoryMovement{Location: ret.Location, Delta: ret.Quantity, Reason: MovementReturn, RequestID: ret.RequestID, ReservationID: ret.ReservationID}
+		if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+			return Return{}, fmt.Errorf("record return movement: %w", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return Return{}, fmt.Errorf("commit return transaction: %w", err)
+	}
+
+	if err = s.publishInventory(ctx, productInventory); err != nil {
+		return Return{}, fmt.Errorf("publish inventory: %w", err)
+	}
+
+	if status == StatusAvailable {
+		if err = s.FillReserves(ctx, product); err != nil {
+			return Return{}, fmt.Errorf("fill reserves after return: %w", err)
+		}
+	}
+
+	return ret, nil
+}
+
+func validateReturnRequest(rr ReturnRequest) error {
+	if rr.RequestID == "" {
+		return fmt.Errorf("request id is required: %w", ErrInvalidInput)
+	}
+	if rr.Quantity < 1 {
+		return fmt.Errorf("quantity must be greater than zero: %w", ErrInvalidInput)
+	}
+	if _, err := ParseReturnOutcome(string(rr.Outcome)); err != nil {
+		return err
+	}
+	if rr.ReservationID == 0 && rr.Requester == "" {
+		return fmt.Errorf("a reservation id or requester is required: %w", ErrInvalidInput)
+	}
+	if rr.Lot != "" && rr.Outcome != ReturnRestock {
+		return fmt.Errorf("lots only track available stock, so only restocked goods can name one: %w", ErrInvalidInput)
+	}
+	return nil
+}
+
+// checkReturnable checks that res, the reservation rr is returned
+// against and already locked inside tx, shipped sku and still has
+// rr.Quantity units out that haven't come back.
+func (s *service) checkReturnable(ctx context.Context, tx persistence.Transaction, res Reservation, sku string, rr ReturnRequest) error {
+	if res.Sku != sku {
+		return fmt.Errorf("reservation %d is for sku %q: %w", res.ID, res.Sku, ErrInvalidInput)
+	}
+	if rr.Requester != "" && rr.Requester != res.Requester {
+		return fmt.Errorf("reservation %d was made by %q, not %q: %w", res.ID, res.Requester, rr.Requester, ErrInvalidInput)
+	}
+
+	returned, err := s.repo.GetReturnedQuantity(ctx, res.ID, persistence.QueryOptions{Tx: tx})
+	if err != nil {
+		return fmt.Errorf("get returned quantity for reservation %d: %w", res.ID, err)
+	}
+	if out := res.ShippedQuantity - returned; rr.Quantity > out {
+		return fmt.Errorf("quantity %d exceeds the %d shipped on reservation %d and not yet returned: %w", rr.Quantity, out, res.ID, ErrInvalidInput)
+	}
+	return nil
+}
+
+// expireBatchSize caps how many overdue reservations
+// ExpireReservations loads per round trip.
+const expireBatchSize = 100
+
+// ExpireReservations releases every Open or Closed reservation whose
+// deadline is at or before now and that hasn't started shipping,
+// moving it to Expired and handing its reserved quantity back to
+// available stock. Each reservation is released in its own
+// transaction so one failure doesn't hold up the rest of the batch's
+// locks; FillReserves then runs once per affected SKU. Returns how
+// many reservations were expired.
+func (s *service) ExpireReservations(ctx context.Context, now time.Time) (expired int, err error) {
+	const funcName = "ExpireReservations"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName)
+	defer func() { end(err) }()
+
+	isOverdue := func(r Reservation) bool {
+		return (r.State == Open || r.State == Closed) && r.ShippedQuantity == 0 && r.ExpiresAt != nil && !r.ExpiresAt.After(now)
+	}
+
+	products := make(map[string]Product)
+	for {
+		overdue, err := s.repo.GetExpiredReservations(ctx, now, expireBatchSize)
+		if err != nil {
+			return expired, fmt.Errorf("get expired reservations: %w", err)
+		}
+
+		releasedInBatch := 0
+		for _, candidate := range overdue {
+			res, productInventory, released, err := s.releaseReservation(ctx, candidate.ID, Expired, isOverdue)
+			if err != nil {
+				return expired, err
+			}
+			if !released {
+				continue
+			}
+			log.Ctx(ctx).Debug().Str("func", funcName).Uint64("id", res.ID).Str("sku", res.Sku).Msg("reservation expired")
+			releasedInBatch++
+			products[res.Sku] = productInventory.Product
+		}
+		expired += releasedInBatch
+
+		// A short page means we've drained the backlog. A page where
+		// nothing could be released means every candidate changed
+		// under us; re-querying would just return the same rows.
+		if len(overdue) < expireBatchSize || releasedInBatch == 0 {
+			break
+		}
+	}
+
+	for sku, product := range products {
+		if err = s.FillReserves(ctx, product); err != nil {
+			return expired, fmt.Errorf("fill reserves for %q after expiry: %w", sku, err)
+		}
+	}
+
+	return expired, nil
+}
+
+// SweepExpiredReservations runs ExpireReservations on a ticker until
+// ctx is canceled. Intended to be started in a goroutine from the
+// composition root.
+func (s *service) SweepExpiredReservations(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		every = time.Minute
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			expired, err := s.ExpireReservations(ctx, time.Now())
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Int("expired", expired).Msg("reservation expiry sweep failed")
+				continue
+			}
+			if expired > 0 {
+				log.Ctx(ctx).Info().Int("expired", expired).Msg("expired overdue reservations")
+			}
+		}
+	}
+}
+
+// ExpireLots moves every lot past its expiry date at now out of
+// Available and into Expired, one SKU per transaction. Expiry only
+// takes stock away, so FillReserves has nothing to do afterwards.
+// Returns how many lots were expired.
+func (s *service) ExpireLots(ctx context.Context, now time.Time) (expired int, err error) {
+	const funcName = "ExpireLots"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName)
+	defer func() { end(err) }()
+
+	for {
+		due, err := s.repo.GetExpiredLots(ctx, now, expireBatchSize)
+		if err != nil {
+			return expired, fmt.Errorf("get expired lots: %w", err)
+		}
+
+		expiredInBatch := 0
+		seen := make(map[string]bool)
+		for _, l := range due {
+			if seen[l.Sku] {
+				continue
+			}
+			seen[l.Sku] = true
+			n, err := s.expireSkuLots(ctx, l.Sku, now)
+			if err != nil {
+				return expired, err
+			}
+			log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", l.Sku).Int("lots", n).Msg("lots expired")
+			expiredInBatch += n
+		}
+		expired += expiredInBatch
+
+		// Same stopping rule as ExpireReservations: a short page drains
+		// the backlog, and a page where nothing expired would only come
+		// back again.
+		if len(due) < expireBatchSize || expiredInBatch == 0 {
+			break
+		}
+	}
+
+	return expired, nil
+}
+
+// expireSkuLots expires sku's lots that are past their date at now in
+// one transaction and publishes the inventory change. Returns how many
+// lots expired.
+func (s *service) expireSkuLots(ctx context.Context, sku string, now time.Time) (n int, err error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	productInventory, err := s.repo.GetProductInventory(ctx, sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return 0, fmt.Errorf("get product inventory for %q: %w", sku, err)
+	}
+	lots, err := s.lockLots(ctx, tx, &productInventory, now)
+	if err != nil {
+		return 0, err
+	}
+	due := lots.dirty()
+	if len(due) == 0 {
+		rollback(ctx, tx, nil)
+		return 0, nil
+	}
+
+	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return 0, fmt.Errorf("save product inventory: %w", err)
+	}
+	productInventory.Version++
+	if err = s.repo.SaveLots(ctx, due, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return 0, fmt.Errorf("save lots for %q: %w", sku, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit lot expiry transaction: %w", err)
+	}
+
+	if err = s.publishInventory(ctx, productInventory); err != nil {
+		return 0, fmt.Errorf("publish inventory: %w", err)
+	}
+	return len(due), nil
+}
+
+// SweepExpiredLots runs ExpireLots on a ticker until ctx is canceled.
+// Intended to be started in a goroutine from the composition root.
+func (s *service) SweepExpiredLots(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		every = time.Minute
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			expired, err := s.ExpireLots(ctx, time.Now())
+			if err != nil {
+				log.Ctx(ctx).Warn().Err(err).Int("expired", expired).Msg("lot expiry sweep failed")
+				continue
+			}
+			if expired > 0 {
+				log.Ctx(ctx).Info().Int("expired", expired).Msg("expired lots past their date")
+			}
+		}
+	}
+}
+
+// releaseReservation moves reservation ID to the terminal state to,
+// returning its reserved quantity to available inventory in a single
+// transaction, then publishes the inventory and reservation changes.
+// eligible is evaluated against the locked row; when it reports
+// false the transaction is rolled back and the stored reservation is
+// returned with released=false. Callers are responsible for running
+// FillReserves afterwards.
+func (s *service) releaseReservation(ctx context.Context, ID uint64, to ReserveState, eligible func(Reservation) bool) (res Reservation, productInventory ProductInventory, released bool, err error) {
+	tx, err := s.repo.BeginTransaction(ctx)
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+	if err != nil {
+		return Reservation{}, ProductInventory{}, false, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	res, err = s.repo.GetReservation(ctx, ID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Reservation{}, ProductInventory{}, false, fmt.Errorf("get reservation %d: %w", ID, err)
+	}
+	if !eligible(res) {
+		rollback(ctx, tx, nil)
+		return res, ProductInventory{}, false, nil
+	}
+
+	productInventory, err = s.repo.GetProductInventory(ctx, res.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return Reservation{}, ProductInventory{}, false, fmt.Errorf("get product inventory for %q: %w", res.Sku, err)
+	}
+
+	// Only the unshipped remainder goes back on the shelf; whatever
+	// has already shipped stays recorded against the reservation.
+	returned := res.ReservedQuantity - res.ShippedQuantity
+	location := s.locationOrDefault(res.Location)
+	if returned > 0 {
+		if returned, err = s.returnStock(ctx, tx, &productInventory, res, location, returned); err != nil {
+			return Reservation{}, ProductInventory{}, false, err
+		}
+	}
+	if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return Reservation{}, ProductInventory{}, false, fmt.Errorf("save product inventory: %w", err)
+	}
+	productInventory.Version++
+
+	if returned > 0 {
+		mv := InventoryMovement{Location: location, Delta: returned, Reason: releaseReason(to), RequestID: res.RequestID, ReservationID: &res.ID}
+		if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+			return Reservation{}, ProductInventory{}, false, fmt.Errorf("record release movement: %w", err)
+		}
+	}
+
+	res.State = to
+	res.ReservedQuantity = res.ShippedQuantity
+	if err = s.repo.UpdateReservation(ctx, res.ID, res.State, res.ReservedQuantity, persistence.UpdateOptions{Tx: tx, Version: PreconditionFrom(ctx).Version}); err != nil {
+		return Reservation{}, ProductInventory{}, false, fmt.Errorf("update reservation %d: %w", res.ID, err)
+	}
+	res.Version++
+
+	if err = tx.Commit(ctx); err != nil {
+		return Reservation{}, ProductInventory{}, false, fmt.Errorf("commit release transaction: %w", err)
+	}
+
+	if err = s.publishInventory(ctx, productInventory); err != nil {
+		return Reservation{}, ProductInventory{}, false, fmt.Errorf("publish inventory: %w", err)
+	}
+	if err = s.publishReservation(ctx, res); err != nil {
+		return Reservation{}, ProductInventory{}, false, fmt.Errorf("publish reservation: %w", err)
+	}
+
+	return res, productInventory, true, nil
+}
+
+// releaseReason maps the state a reservation is released into onto
+// the ledger reason for the stock it hands back.
+func releaseReason(to ReserveState) MovementReason {
+	if to == Expired {
+		return MovementExpiry
+	}
+	return MovementCancellation
+}
+
+// recordMovement appends mv to the SKU's ledger inside tx. The caller
+// has already applied mv.Delta to pi at mv.Location, so the stock held
+// there is the balance the movement leaves behind.
+func (s *service) recordMovement(ctx context.Context, tx persistence.Transaction, pi ProductInventory, mv InventoryMovement) error {
+	mv.Sku = pi.Sku
+	mv.Balance = pi.AvailableAt(mv.Location)
+	mv.Actor = actorFrom(ctx)
+	mv.Created = time.Now()
+	return s.repo.SaveInventoryMovement(ctx, &mv, persistence.UpdateOptions{Tx: tx})
+}
+
+// validateLot checks a production request's lot: an expiry date needs
+// a lot to belong to, and must still be ahead of now.
+func validateLot(pr ProductionRequest, now time.Time) error {
+	if pr.ExpiresAt == nil {
+		return nil
+	}
+	if pr.Lot == "" {
+		return fmt.Errorf("expiresAt requires a lot: %w", ErrInvalidInput)
+	}
+	if !pr.ExpiresAt.After(now) {
+		return fmt.Errorf("lot %q expiresAt must be in the future: %w", pr.Lot, ErrInvalidInput)
+	}
+	return nil
+}
+
+// lockLots locks pi's lots for the rest of tx and expires the ones past
+// their date at now, moving their stock from pi's Available into
+// Expired and recording each on the ledger. Any stock a caller is about
+// to draw is then stock that can still be sold. pi still has to be
+// saved.
+func (s *service) lockLots(ctx context.Context, tx persistence.Transaction, pi *ProductInventory, now time.Time) (*lotSet, error) {
+	lots, err := s.repo.GetLots(ctx, pi.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return nil, fmt.Errorf("get lots for %q: %w", pi.Sku, err)
+	}
+
+	ls := newLotSet(pi.Sku, lots)
+	if err = s.expireLots(ctx, tx, pi, ls, now); err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+// expireLots expires the lots in ls past their date at now, moving
+// their stock from pi's Available into Expired and recording each on
+// the ledger. pi and ls still have to be saved.
+func (s *service) expireLots(ctx context.Context, tx persistence.Transaction, pi *ProductInventory, ls *lotSet, now time.Time) error {
+	for _, l := range ls.expire(now) {
+		pi.Add(l.Location, -l.Available)
+		pi.AddExpired(l.Location, l.Available)
+		mv := InventoryMovement{Location: l.Location, Delta: -l.Available, Reason: MovementLotExpiry, Lot: l.Lot}
+		if err := s.recordMovement(ctx, tx, *pi, mv); err != nil {
+			return fmt.Errorf("record lot expiry movement: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveLots writes the lots changed since ls was loaded or last saved.
+func (s *service) saveLots(ctx context.Context, tx persistence.Transaction, ls *lotSet) error {
+	dirty := ls.dirty()
+	if len(dirty) == 0 {
+		return nil
+	}
+	if err := s.repo.SaveLots(ctx, dirty, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return fmt.Errorf("save lots for %q: %w", ls.sku, err)
+	}
+	return nil
+}
+
+// saveTransferLots records the lots a transfer took stock from.
+func (s *service) saveTransferLots(ctx context.Context, tx persistence.Transaction, transferID uint64, draws []LotDraw) error {
+	if len(draws) == 0 {
+		return nil
+	}
+	if err := s.repo.SaveTransferLots(ctx, transferID, draws, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return fmt.Errorf("save lots taken by transfer %d: %w", transferID, err)
+	}
+	return nil
+}
+
+// saveReservationLots records the lots a reservation drew stock from.
+func (s *service) saveReservationLots(ctx context.Context, tx persistence.Transaction, reservationID uint64, draws []LotDraw) error {
+	if len(draws) == 0 {
+		return nil
+	}
+	if err := s.repo.SaveReservationLots(ctx, reservationID, draws, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return fmt.Errorf("save lots drawn by reservation %d: %w", reservationID, err)
+	}
+	return nil
+}
+
+// returnStock hands qty units held by res back at location: to the lots
+// it drew them from, latest-expiring first, and into pi's Available,
+// except for stock whose lot has expired since, which goes straight to
+// Expired. It returns how much reached Available. pi still has to be
+// saved.
+func (s *service) returnStock(ctx context.Context, tx persistence.Transaction, pi *ProductInventory, res Reservation, location string, qty int64) (int64, error) {
+	draws, err := s.repo.GetReservationLots(ctx, res.ID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return 0, fmt.Errorf("get lots drawn by reservation %d: %w", res.ID, err)
+	}
+	if len(draws) == 0 {
+		pi.Add(location, qty)
+		return qty, nil
+	}
+
+	lots, err := s.repo.GetLots(ctx, pi.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return 0, fmt.Errorf("get lots for %q: %w", pi.Sku, err)
+	}
+	ls := newLotSet(pi.Sku, lots)
+	back, expired := ls.give(location, draws, qty, time.Now())
+	if err = s.saveLots(ctx, tx, ls); err != nil {
+		return 0, err
+	}
+	if err = s.saveReservationLots(ctx, tx, res.ID, negateDraws(back)); err != nil {
+		return 0, err
+	}
+
+	pi.Add(location, qty-expired)
+	pi.AddExpired(location, expired)
+	return qty - expired, nil
+}
+
+// actorFrom names whoever is behind ctx for the ledger: the
+// authenticated user for HTTP calls, "system" for Kafka commands,
+// the expiry sweeper and anything else without a user attached.
+func actorFrom(ctx context.Context) string {
+	if u, ok := ctx.Value(auth.CtxKeyUser).(user.User); ok && u.Username != "" {
+		return u.Username
+	}
+	return "system"
+}
+
+// Precondition is what a conditional request expects of the record it
+// writes, taken from its If-Match and If-None-Match headers.
+type Precondition struct {
+	// Version the record must still be at. Zero accepts any.
+	Version int64
+	// Absent requires that the record doesn't exist yet.
+	Absent bool
+}
+
+type ctxKeyPrecondition struct{}
+
+// WithPrecondition returns a copy of ctx asking the write it is passed
+// to to apply only if p holds. The check is made against the locked
+// record, so it can't race another writer the way a read-then-compare
+// in the handler can.
+func WithPrecondition(ctx context.Context, p Precondition) context.Context {
+	return context.WithValue(ctx, ctxKeyPrecondition{}, p)
+}
+
+func PreconditionFrom(ctx context.Context) Precondition {
+	p, _ := ctx.Value(ctxKeyPrecondition{}).(Precondition)
+	return p
+}
+
+func (s *service) GetAllProductInventory(ctx context.Context, options ProductListOptions, limit, offset int) (out []ProductInventory, err error) {
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, "GetAllProductInventory",
+		attribute.String("inventory.search", options.Search),
+		attribute.String("inventory.sort", string(options.Sort)),
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
+	return s.repo.GetAllProductInventory(ctx, options, limit, offset)
+}
+
+// GetAllProductInventoryAfter pages over products by key rather than
+// offset, starting after after (or the first product when nil).
+func (s *service) GetAllProductInventoryAfter(ctx context.Context, options ProductListOptions, after *ProductKey, limit int) (out []ProductInventory, err error) {
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, "GetAllProductInventoryAfter",
+		attribute.String("inventory.search", options.Search),
+		attribute.String("inventory.sort", string(options.Sort)),
+		attribute.Int("inventory.limit", limit),
+	)
+	defer func() { end(err) }()
+	return s.repo.GetAllProductInventoryAfter(ctx, options, after, limit)
+}
+
+// ExportProductInventory calls fn with every product's inventory in
+// SKU order, read from a single snapshot through a database cursor so
+// the whole set is never held in memory. It stops at the first error fn
+// returns.
+func (s *service) ExportProductInventory(ctx context.Context, fn func(ProductInventory) error) (err error) {
+	const funcName = "ExportProductInventory"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Msg("exporting product inventory")
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	if err = s.repo.ExportProductInventory(ctx, fn, persistence.QueryOptions{Tx: tx}); err != nil {
+		return fmt.Errorf("export product inventory: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit export transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *service) GetInventoryHistory(ctx context.Context, sku string, limit, offset int) (out []InventoryMovement, err error) {
+	const funcName = "GetInventoryHistory"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("getting inventory history")
+
+	return s.repo.GetInventoryMovements(ctx, sku, limit, offset)
+}
+
+func (s *service) GetTransfer(ctx context.Context, ID uint64) (t Transfer, err error) {
+	const funcName = "GetTransfer"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.transfer_id", strconv.FormatUint(ID, 10)),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Uint64("id", ID).Msg("getting transfer")
+
+	return s.repo.GetTransfer(ctx, ID)
+}
+
+func (s *service) GetTransfers(ctx context.Context, sku string, limit, offset int) (out []Transfer, err error) {
+	const funcName = "GetTransfers"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("getting transfers")
+
+	return s.repo.GetTransfers(ctx, sku, limit, offset)
+}
+
+// GetReturns returns a page of a SKU's returns, oldest first.
+func (s *service) GetReturns(ctx context.Context, sku string, limit, offset int) (out []Return, err error) {
+	const funcName = "GetReturns"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("getting returns")
+
+	return s.repo.GetReturns(ctx, sku, limit, offset)
+}
+
+// GetLots returns a SKU's lots that still hold stock, available or
+// expired, first-expiring first.
+func (s *service) GetLots(ctx context.Context, sku string) (out []Lot, err error) {
+	const funcName = "GetLots"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("getting lots")
+
+	lots, err := s.repo.GetLots(ctx, sku)
+	if err != nil {
+		return nil, err
+	}
+	out = make([]Lot, 0, len(lots))
+	for _, l := range newLotSet(sku, lots).lots {
+		if l.Available > 0 || l.Expired > 0 {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+// GetLotRecipients returns a page of the reservations that drew stock
+// from a lot, which is who a recall of it has to reach.
+func (s *service) GetLotRecipients(ctx context.Context, sku, lot string, limit, offset int) (out []LotRecipient, err error) {
+	const funcName = "GetLotRecipients"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.String("inventory.lot", lot),
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Str("lot", lot).Msg("getting lot recipients")
+
+	return s.repo.GetLotRecipients(ctx, sku, lot, limit, offset)
+}
+
+// PlanProduction schedules production of a SKU so that it counts
+// towards available-to-promise from its expected date. Replaying a
+// request ID returns the original planned order.
+func (s *service) PlanProduction(ctx context.Context, product Product, pr PlannedOrderRequest) (o PlannedOrder, err error) {
+	const funcName = "PlanProduction"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", product.Sku),
+		attribute.String("request_id", pr.RequestID),
+		attribute.Int64("inventory.quantity", pr.Quantity),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", product.Sku).
+		Str("requestId", pr.RequestID).
+		Int64("quantity", pr.Quantity).
+		Time("expectedDate", pr.ExpectedDate).
+		Msg("planning production")
+
+	if pr.RequestID == "" {
+		return PlannedOrder{}, fmt.Errorf("request id is required: %w", ErrInvalidInput)
+	}
+	if pr.Quantity < 1 {
+		return PlannedOrder{}, fmt.Errorf("quantity must be greater than zero: %w", ErrInvalidInput)
+	}
+	if pr.ExpectedDate.IsZero() {
+		return PlannedOrder{}, fmt.Errorf("expected date is required: %w", ErrInvalidInput)
+	}
+
+	existing, err := s.repo.GetPlannedOrderByRequestID(ctx, pr.RequestID)
+	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
+		return PlannedOrder{}, fmt.Errorf("get planned order %q: %w", pr.RequestID, err)
+	}
+	if existing.RequestID != "" {
+		if existing.Sku != product.Sku {
+			return PlannedOrder{}, fmt.Errorf("request id %q already used for sku %q: %w", pr.RequestID, existing.Sku, ErrInvalidInput)
+		}
+		log.Ctx(ctx).Debug().Str("func", funcName).Str("requestId", pr.RequestID).Msg("production already planned")
+		return existing, nil
+	}
+
+	current, err := s.repo.GetProduct(ctx, product.Sku)
+	if err != nil {
+		return PlannedOrder{}, fmt.Errorf("get product %q: %w", product.Sku, err)
+	}
+	if !current.Active() {
+		return PlannedOrder{}, fmt.Errorf("product %q is %s and can't be produced: %w", product.Sku, current.State, ErrProductState)
+	}
+
+	now := time.Now()
+	o = PlannedOrder{
+		RequestID:    pr.RequestID,
+		Sku:          product.Sku,
+		Quantity:     pr.Quantity,
+		ExpectedDate: pr.ExpectedDate,
+		State:        PlannedOrderScheduled,
+		Actor:        actorFrom(ctx),
+		Created:      now,
+		Updated:      now,
+	}
+	if err = s.repo.SavePlannedOrder(ctx, &o); err != nil {
+		return PlannedOrder{}, fmt.Errorf("save planned order: %w", err)
+	}
+	return o, nil
+}
+
+// CancelPlannedOrder drops a Scheduled planned order of a SKU, by its
+// request ID, from available-to-promise. Cancelling one that is
+// already Cancelled returns it unchanged.
+func (s *service) CancelPlannedOrder(ctx context.Context, sku, requestID string) (o PlannedOrder, err error) {
+	const funcName = "CancelPlannedOrder"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.String("request_id", requestID),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Str("requestId", requestID).Msg("cancelling planned order")
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return PlannedOrder{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	o, err = s.repo.GetPlannedOrderByRequestID(ctx, requestID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if err != nil {
+		return PlannedOrder{}, fmt.Errorf("get planned order %q: %w", requestID, err)
+	}
+	if o.Sku != sku {
+		return PlannedOrder{}, fmt.Errorf("planned order %q is for sku %q: %w", requestID, o.Sku, persistence.ErrNotFound)
+	}
+	if o.State == PlannedOrderCancelled {
+		rollback(ctx, tx, nil)
+		return o, nil
+	}
+	if o.State != PlannedOrderScheduled {
+		return PlannedOrder{}, fmt.Errorf("planned order %q is %s; only Scheduled orders can be cancelled: %w", requestID, o.State, ErrInvalidInput)
+	}
+
+	o.State = PlannedOrderCancelled
+	o.Updated = time.Now()
+	if err = s.repo.UpdatePlannedOrder(ctx, o.ID, o.State, "", o.Updated, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return PlannedOrder{}, fmt.Errorf("update planned order %q: %w", requestID, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return PlannedOrder{}, fmt.Errorf("commit cancel transaction: %w", err)
+	}
+	return o, nil
+}
+
+// completePlannedOrder marks the Scheduled planned order with
+// requestID as completed by event inside tx. The order is closed
+// whatever quantity was produced; stock that was actually made is
+// counted through Available from here on.
+func (s *service) completePlannedOrder(ctx context.Context, tx persistence.Transaction, event ProductionEvent, requestID string) error {
+	o, err := s.repo.GetPlannedOrderByRequestID(ctx, requestID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	if errors.Is(err, persistence.ErrNotFound) {
+		return fmt.Errorf("planned order %q not found: %w", requestID, ErrInvalidInput)
+	}
+	if err != nil {
+		return fmt.Errorf("get planned order %q: %w", requestID, err)
+	}
+	if o.Sku != event.Sku {
+		return fmt.Errorf("planned order %q is for sku %q: %w", requestID, o.Sku, ErrInvalidInput)
+	}
+	if o.State != PlannedOrderScheduled {
+		return fmt.Errorf("planned order %q is %s; only Scheduled orders can be completed: %w", requestID, o.State, ErrInvalidInput)
+	}
+	if err = s.repo.UpdatePlannedOrder(ctx, o.ID, PlannedOrderCompleted, event.RequestID, event.Created, persistence.UpdateOptions{Tx: tx}); err != nil {
+		return fmt.Errorf("complete planned order %q: %w", requestID, err)
+	}
+	return nil
+}
+
+// GetScheduledOrders returns a page of a SKU's Scheduled planned
+// orders, earliest expected first.
+func (s *service) GetScheduledOrders(ctx context.Context, sku string, limit, offset int) (out []PlannedOrder, err error) {
+	const funcName = "GetScheduledOrders"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("getting scheduled orders")
+
+	return s.repo.GetScheduledOrders(ctx, sku, limit, offset)
+}
+
+// plannedOrderPageSize is how many planned orders AvailableToPromise
+// reads per query while it walks the schedule.
+const plannedOrderPageSize = 100
+
+// AvailableToPromise works out the earliest date quantity more units
+// of sku can be promised. Supply is current Available plus Scheduled
+// planned orders in expected-date order; open reservations are filled
+// oldest first, so their shortfall is taken out of that supply before
+// the new quantity is. Planned orders that are overdue are promised
+// from now. Products that aren't Active can't be reserved or produced,
+// so nothing can be promised for them.
+//
+// The answer is network-wide: Available and the shortfall are pooled
+// across every location. A reservation only fills from its own
+// location, so stock held elsewhere has to be transferred first, and
+// planned orders carry no location until they are produced, so a
+// per-location figure couldn't count them.
+func (s *service) AvailableToPromise(ctx context.Context, sku string, quantity int64) (atp AvailableToPromise, err error) {
+	const funcName = "AvailableToPromise"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.Int64("inventory.quantity", quantity),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Int64("quantity", quantity).Msg("calculating available to promise")
+
+	if quantity < 1 {
+		return AvailableToPromise{}, fmt.Errorf("quantity must be greater than zero: %w", ErrInvalidInput)
+	}
+
+	pi, err := s.repo.GetProductInventory(ctx, sku)
+	if err != nil {
+		return AvailableToPromise{}, fmt.Errorf("get product inventory for %q: %w", sku, err)
+	}
+	if !pi.Active() {
+		return AvailableToPromise{}, fmt.Errorf("product %q is %s and can't be promised: %w", sku, pi.State, ErrProductState)
+	}
+	backorders, err := s.repo.GetBackorders(ctx, sku, 1, 0)
+	if err != nil {
+		return AvailableToPromise{}, fmt.Errorf("get backorders for %q: %w", sku, err)
+	}
+
+	atp = AvailableToPromise{Sku: sku, Quantity: quantity, Available: pi.Available}
+	if len(backorders) > 0 {
+		atp.Backordered = backorders[0].Shortfall
+	}
+
+	now := time.Now()
+	supply := atp.Available - atp.Backordered
+	if supply >= quantity {
+		atp.Promisable = true
+		atp.PromiseDate = &now
+		return atp, nil
+	}
+
+	for offset := 0; ; offset += plannedOrderPageSize {
+		orders, err := s.repo.GetScheduledOrders(ctx, sku, plannedOrderPageSize, offset)
+		if err != nil {
+			return AvailableToPromise{}, fmt.Errorf("get scheduled orders for %q: %w", sku, err)
+		}
+		for _, o := range orders {
+			supply += o.Quantity
+			atp.Scheduled += o.Quantity
+			if supply >= quantity {
+				promise := o.ExpectedDate
+				if promise.Before(now) {
+					promise = now
+				}
+				atp.Promisable = true
+				atp.PromiseDate = &promise
+				return atp, nil
+			}
+		}
+		if len(orders) < plannedOrderPageSize {
+			return atp, nil
+		}
+	}
+}
+
+// GetReorderPolicy returns a SKU's reorder point and target level, or
+// persistence.ErrNotFound if it has none.
+func (s *service) GetReorderPolicy(ctx context.Context, sku string) (p ReorderPolicy, err error) {
+	const funcName = "GetReorderPolicy"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("getting reorder policy")
+
+	return s.repo.GetReorderPolicy(ctx, sku)
+}
+
+// SetReorderPolicy creates or replaces a SKU's reorder point and target
+// level and checks its current stock against them straight away, so a
+// reorder point set above what is on hand alerts without waiting for
+// the next stock change.
+func (s *service) SetReorderPolicy(ctx context.Context, policy ReorderPolicy) (p ReorderPolicy, err error) {
+	const funcName = "SetReorderPolicy"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", policy.Sku),
+		attribute.Int64("inventory.reorder_point", policy.ReorderPoint),
+		attribute.Int64("inventory.target_level", policy.TargetLevel),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", policy.Sku).
+		Int64("reorderPoint", policy.ReorderPoint).
+		Int64("targetLevel", policy.TargetLevel).
+		Msg("setting reorder policy")
+
+	if policy.ReorderPoint < 1 {
+		return ReorderPolicy{}, fmt.Errorf("reorder point must be greater than zero: %w", ErrInvalidInput)
+	}
+	if policy.TargetLevel < policy.ReorderPoint {
+		return ReorderPolicy{}, fmt.Errorf("target level %d is below reorder point %d: %w", policy.TargetLevel, policy.ReorderPoint, ErrInvalidInput)
+	}
+
+	pi, err := s.repo.GetProductInventory(ctx, policy.Sku)
+	if err != nil {
+		return ReorderPolicy{}, fmt.Errorf("get product inventory for %q: %w", policy.Sku, err)
+	}
+	if pi.State == ProductArchived {
+		return ReorderPolicy{}, fmt.Errorf("product %q is archived: %w", policy.Sku, ErrProductState)
+	}
+
+	if err = s.repo.SaveReorderPolicy(ctx, policy); err != nil {
+		return ReorderPolicy{}, fmt.Errorf("save reorder policy: %w", err)
+	}
+	s.checkLowStock(ctx, pi)
+
+	return s.repo.GetReorderPolicy(ctx, policy.Sku)
+}
+
+// DeleteReorderPolicy stops a SKU's low-stock alerts.
+func (s *service) DeleteReorderPolicy(ctx context.Context, sku string) (err error) {
+	const funcName = "DeleteReorderPolicy"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Msg("deleting reorder policy")
+
+	return s.repo.DeleteReorderPolicy(ctx, sku)
+}
+
+// GetLowStock returns a page of the SKUs whose available stock is below
+// their reorder point, with how much would bring each back to its
+// target level.
+func (s *service) GetLowStock(ctx context.Context, limit, offset int) (out []LowStock, err error) {
+	const funcName = "GetLowStock"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Int("limit", limit).Int("offset", offset).Msg("getting low stock")
 
-	return nil
+	return s.repo.GetLowStock(ctx, limit, offset)
 }
 
-func (s *service) Reserve(ctx context.Context, rr ReservationRequest) (res Reservation, err error) {
-	const funcName = "Reserve"
+// GetRequesterLimits returns the limits set for one requester.
+func (s *service) GetRequesterLimits(ctx context.Context, requester string) (l RequesterLimits, err error) {
+	const funcName = "GetRequesterLimits"
 	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
-		attribute.String("inventory.sku", rr.Sku),
-		attribute.String("request_id", rr.RequestID),
-		attribute.String("inventory.requester", rr.Requester),
-		attribute.Int64("inventory.quantity", rr.Quantity),
+		attribute.String("inventory.requester", requester),
 	)
 	defer func() { end(err) }()
 
-	log.Ctx(ctx).Debug().
-		Str("func", funcName).
-		Str("requestID", rr.RequestID).
-		Str("sku", rr.Sku).
-		Str("requester", rr.Requester).
-		Int64("quantity", rr.Quantity).
-		Msg("reserving inventory")
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("requester", requester).Msg("getting requester limits")
 
-	if err := validateReservationRequest(rr); err != nil {
-		return Reservation{}, err
-	}
+	return s.repo.GetRequesterLimits(ctx, requester)
+}
 
-	tx, err := s.repo.BeginTransaction(ctx)
-	defer func() {
-		if err != nil {
-			rollback(ctx, tx, err)
-		}
-	}()
-	if err != nil {
-		return Reservation{}, fmt.Errorf("begin transaction: %w", err)
-	}
+// GetAllRequesterLimits returns a page of requester limits, ordered by
+// requester.
+func (s *service) GetAllRequesterLimits(ctx context.Context, limit, offset int) (out []RequesterLimits, err error) {
+	const funcName = "GetAllRequesterLimits"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
 
-	pr, err := s.repo.GetProduct(ctx, rr.Sku, persistence.QueryOptions{Tx: tx, ForUpdate: true})
-	if err != nil {
-		return Reservation{}, fmt.Errorf("get product %q: %w", rr.Sku, err)
-	}
+	log.Ctx(ctx).Debug().Str("func", funcName).Int("limit", limit).Int("offset", offset).Msg("getting requester limits")
 
-	res, err = s.repo.GetReservationByRequestID(ctx, rr.RequestID, persistence.QueryOptions{Tx: tx, ForUpdate: true})
-	if err != nil && !errors.Is(err, persistence.ErrNotFound) {
-		return Reservation{}, fmt.Errorf("get reservation by request id %q: %w", rr.RequestID, err)
-	}
-	if res.RequestID != "" {
-		log.Ctx(ctx).Debug().Str("func", funcName).Str("requestId", rr.RequestID).Msg("reservation already exists, returning it")
-		rollback(ctx, tx, nil)
-		return res, nil
-	}
+	return s.repo.GetAllRequesterLimits(ctx, limit, offset)
+}
 
-	res = Reservation{
-		RequestID:         rr.RequestID,
-		Requester:         rr.Requester,
-		Sku:               rr.Sku,
-		State:             Open,
-		RequestedQuantity: rr.Quantity,
-		Created:           time.Now(),
-	}
+// SetRequesterLimits creates or replaces a requester's limits. They
+// only apply to reservations made or grown from now on; nothing already
+// reserved is taken back.
+func (s *service) SetRequesterLimits(ctx context.Context, limits RequesterLimits) (l RequesterLimits, err error) {
+	const funcName = "SetRequesterLimits"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.requester", limits.Requester),
+		attribute.String("inventory.class", limits.Class),
+	)
+	defer func() { end(err) }()
 
-	if err = s.repo.SaveReservation(ctx, &res, persistence.UpdateOptions{Tx: tx}); err != nil {
-		return Reservation{}, fmt.Errorf("save reservation: %w", err)
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("requester", limits.Requester).
+		Str("class", limits.Class).
+		Int64("maxReservedPerSku", limits.MaxReservedPerSku).
+		Int64("maxOpenReservations", limits.MaxOpenReservations).
+		Int64("dailyCap", limits.DailyCap).
+		Msg("setting requester limits")
+
+	if limits.Requester == "" {
+		return RequesterLimits{}, fmt.Errorf("requester is required: %w", ErrInvalidInput)
 	}
-
-	if err = tx.Commit(ctx); err != nil {
-		return Reservation{}, fmt.Errorf("commit reserve transaction: %w", err)
+	if limits.MaxReservedPerSku < 0 || limits.MaxOpenReservations < 0 || limits.DailyCap < 0 {
+		return RequesterLimits{}, fmt.Errorf("limits cannot be negative: %w", ErrInvalidInput)
 	}
-
-	if err = s.FillReserves(ctx, pr); err != nil {
-		return Reservation{}, fmt.Errorf("fill reserves after reserve: %w", err)
+	if limits.Class == "" {
+		limits.Class = "default"
 	}
 
-	return res, nil
+	if err = s.repo.SaveRequesterLimits(ctx, limits); err != nil {
+		return RequesterLimits{}, fmt.Errorf("save requester limits: %w", err)
+	}
+	return limits, nil
 }
 
-func validateReservationRequest(rr ReservationRequest) error {
-	if rr.RequestID == "" {
-		return fmt.Errorf("request id is required: %w", ErrInvalidInput)
-	}
-	if rr.Requester == "" {
-		return fmt.Errorf("requester is required: %w", ErrInvalidInput)
-	}
-	if rr.Sku == "" {
-		return fmt.Errorf("sku is required: %w", ErrInvalidInput)
-	}
-	if rr.Quantity < 1 {
-		return fmt.Errorf("quantity is required: %w", ErrInvalidInput)
-	}
-	return nil
+// DeleteRequesterLimits removes a requester's own limits, leaving it
+// under the DefaultRequester's.
+func (s *service) DeleteRequesterLimits(ctx context.Context, requester string) (err error) {
+	const funcName = "DeleteRequesterLimits"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.requester", requester),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("requester", requester).Msg("deleting requester limits")
+
+	return s.repo.DeleteRequesterLimits(ctx, requester)
 }
 
-func (s *service) GetAllProductInventory(ctx context.Context, limit, offset int) (out []ProductInventory, err error) {
-	ctx, end := observability.StartServiceSpan(ctx, tracerName, "GetAllProductInventory",
+// GetAllProductInventoryAsOf returns a page of inventory as it stood
+// at asOf, rebuilt from the movement ledger. Products created after
+// asOf are left out, and an asOf older than every product's history is
+// ErrBeforeHistory rather than an empty page.
+func (s *service) GetAllProductInventoryAsOf(ctx context.Context, asOf time.Time, limit, offset int) (out []ProductInventory, err error) {
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, "GetAllProductInventoryAsOf",
+		attribute.String("inventory.as_of", asOf.Format(time.RFC3339)),
 		attribute.Int("inventory.limit", limit),
 		attribute.Int("inventory.offset", offset),
 	)
 	defer func() { end(err) }()
-	return s.repo.GetAllProductInventory(ctx, limit, offset)
+
+	start, err := s.repo.GetInventoryHistoryStart(ctx)
+	if errors.Is(err, persistence.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get inventory history start: %w", err)
+	}
+	if asOf.Before(start) {
+		return nil, fmt.Errorf("%w: it starts at %s", ErrBeforeHistory, start.UTC().Format(time.RFC3339))
+	}
+
+	return s.repo.GetAllProductInventoryAsOf(ctx, asOf, limit, offset)
 }
 
 func (s *service) GetProduct(ctx context.Context, sku string) (product Product, err error) {
@@ -363,6 +3431,32 @@ func (s *service) GetProductInventory(ctx context.Context, sku string) (product
 	return product, nil
 }
 
+// GetProductInventoryAsOf returns a SKU's inventory as it stood at
+// asOf. Historical reads never touch the cache: entries there only
+// ever describe the current balance, and caching one answer per
+// instant would just fill Redis with keys nobody asks for twice. An
+// asOf before the SKU's history begins is ErrBeforeHistory.
+func (s *service) GetProductInventoryAsOf(ctx context.Context, sku string, asOf time.Time) (product ProductInventory, err error) {
+	const funcName = "GetProductInventoryAsOf"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.String("inventory.as_of", asOf.Format(time.RFC3339)),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Time("asOf", asOf).Msg("getting historical product inventory")
+
+	created, err := s.repo.GetProductCreated(ctx, sku)
+	if err != nil {
+		return product, err
+	}
+	if asOf.Before(created) {
+		return product, fmt.Errorf("%w: %s is tracked from %s", ErrBeforeHistory, sku, created.UTC().Format(time.RFC3339))
+	}
+
+	return s.repo.GetProductInventoryAsOf(ctx, sku, asOf)
+}
+
 func (s *service) GetReservation(ctx context.Context, ID uint64) (rsv Reservation, err error) {
 	const funcName = "GetReservation"
 	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
@@ -406,6 +3500,142 @@ func (s *service) GetReservations(ctx context.Context, options GetReservationsOp
 	return rsv, nil
 }
 
+// GetReservationsAfter pages over reservations matching options by key
+// rather than offset, starting after after (or the first one when nil).
+func (s *service) GetReservationsAfter(ctx context.Context, options GetReservationsOptions, after *ReservationKey, limit int) (rsv []Reservation, err error) {
+	const funcName = "GetReservationsAfter"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", options.Sku),
+		attribute.String("inventory.state", string(options.State)),
+		attribute.Int("inventory.limit", limit),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", options.Sku).
+		Str("state", string(options.State)).
+		Msg("getting reservations")
+
+	return s.repo.GetReservationsAfter(ctx, options, after, limit)
+}
+
+// ExportReservations calls fn with every reservation matching options,
+// oldest first, read like ExportProductInventory.
+func (s *service) ExportReservations(ctx context.Context, options GetReservationsOptions, fn func(Reservation) error) (err error) {
+	const funcName = "ExportReservations"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", options.Sku),
+		attribute.String("inventory.state", string(options.State)),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().
+		Str("func", funcName).
+		Str("sku", options.Sku).
+		Str("state", string(options.State)).
+		Msg("exporting reservations")
+
+	tx, err := s.repo.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			rollback(ctx, tx, err)
+		}
+	}()
+
+	if err = s.repo.ExportReservations(ctx, options, fn, persistence.QueryOptions{Tx: tx}); err != nil {
+		return fmt.Errorf("export reservations: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit export transaction: %w", err)
+	}
+	return nil
+}
+
+// GetBackorders returns a page of per-SKU backorder totals, biggest
+// shortfall first. A non-empty sku limits it to that SKU.
+func (s *service) GetBackorders(ctx context.Context, sku string, limit, offset int) (out []Backorder, err error) {
+	const funcName = "GetBackorders"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName,
+		attribute.String("inventory.sku", sku),
+		attribute.Int("inventory.limit", limit),
+		attribute.Int("inventory.offset", offset),
+	)
+	defer func() { end(err) }()
+
+	log.Ctx(ctx).Debug().Str("func", funcName).Str("sku", sku).Int("limit", limit).Int("offset", offset).Msg("getting backorders")
+
+	out, err = s.repo.GetBackorders(ctx, sku, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range out {
+		out[i].OldestAgeSeconds = int64(now.Sub(out[i].OldestCreated).Seconds())
+	}
+	return out, nil
+}
+
+// backorderPageSize is how many SKUs RefreshBackorderMetrics reads
+// per query.
+const backorderPageSize = 500
+
+// RefreshBackorderMetrics sets the per-SKU backorder gauge from the
+// current backorders. SKUs whose shortfall has cleared since the last
+// refresh are dropped from the gauge.
+func (s *service) RefreshBackorderMetrics(ctx context.Context) (err error) {
+	const funcName = "RefreshBackorderMetrics"
+	ctx, end := observability.StartServiceSpan(ctx, tracerName, funcName)
+	defer func() { end(err) }()
+
+	ensureMetrics()
+
+	var all []Backorder
+	for offset := 0; ; offset += backorderPageSize {
+		page, err := s.repo.GetBackorders(ctx, "", backorderPageSize, offset)
+		if err != nil {
+			return fmt.Errorf("get backorders: %w", err)
+		}
+		all = append(all, page...)
+		if len(page) < backorderPageSize {
+			break
+		}
+	}
+
+	backorderShortfall.Reset()
+	for _, b := range all {
+		backorderShortfall.WithLabelValues(b.Sku).Set(float64(b.Shortfall))
+	}
+	return nil
+}
+
+// ReportBackorders runs RefreshBackorderMetrics on a ticker until ctx
+// is canceled. Intended to be started in a goroutine from the
+// composition root.
+func (s *service) ReportBackorders(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		every = time.Minute
+	}
+	if err := s.RefreshBackorderMetrics(ctx); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("backorder metrics refresh failed")
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := s.RefreshBackorderMetrics(ctx); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Msg("backorder metrics refresh failed")
+			}
+		}
+	}
+}
+
 func (s *service) SubscribeInventory(ch chan<- ProductInventory) (id InventorySubID) {
 	id = InventorySubID(uuid.NewString())
 	s.subsMu.Lock()
@@ -461,7 +3691,7 @@ func (s *service) FillReserves(ctx context.Context, product Product) (err error)
 		return fmt.Errorf("begin transaction: %w", err)
 	}
 
-	openReservations, err := s.repo.GetReservations(ctx, GetReservationsOptions{Sku: product.Sku, State: Open}, 100, 0, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+	openReservations, err := s.openReservations(ctx, tx, product.Sku)
 	if err != nil {
 		return fmt.Errorf("get open reservations for %q: %w", product.Sku, err)
 	}
@@ -471,7 +3701,34 @@ func (s *service) FillReserves(ctx context.Context, product Product) (err error)
 		return fmt.Errorf("get product inventory for %q: %w", product.Sku, err)
 	}
 
-	for _, reservation := range openReservations {
+	// Lots that expired since the sweeper last ran leave Available
+	// before any of it is allocated.
+	now := time.Now()
+	expiredBefore := productInventory.Expired
+	lots, err := s.lockLots(ctx, tx, &productInventory, now)
+	if err != nil {
+		return err
+	}
+	expired := productInventory.Expired != expiredBefore
+	if expired {
+		if err = s.repo.SaveProductInventory(ctx, productInventory, persistence.UpdateOptions{Tx: tx}); err != nil {
+			return fmt.Errorf("save product inventory: %w", err)
+		}
+		productInventory.Version++
+		if err = s.saveLots(ctx, tx, lots); err != nil {
+			return err
+		}
+	}
+
+	filled := false
+	allocations := s.allocate(product.Sku, openReservations, productInventory)
+	for i, reservation := range openReservations {
+		reserveAmount := allocations[i]
+		if reserveAmount <= 0 {
+			continue
+		}
+		location := s.locationOrDefault(reservation.Location)
+
 		var subtx pgx.Tx
 		subtx, err = tx.Begin(ctx)
 		if err != nil {
@@ -488,18 +3745,12 @@ func (s *service) FillReserves(ctx context.Context, product Product) (err error)
 			Str("func", funcName).
 			Str("sku", product.Sku).
 			Str("reservation.RequestID", reservation.RequestID).
+			Str("reservation.Location", reservation.Location).
 			Int64("productInventory.Available", productInventory.Available).
 			Msg("fulfilling reservation")
 
-		if productInventory.Available == 0 {
-			break
-		}
-
-		reserveAmount := reservation.RequestedQuantity - reservation.ReservedQuantity
-		if reserveAmount > productInventory.Available {
-			reserveAmount = productInventory.Available
-		}
-		productInventory.Available -= reserveAmount
+		draws := lots.take(location, reserveAmount, now)
+		productInventory.Add(location, -reserveAmount)
 		reservation.ReservedQuantity += reserveAmount
 
 		if reservation.ReservedQuantity == reservation.RequestedQuantity {
@@ -516,6 +3767,10 @@ func (s *service) FillReserves(ctx context.Context, product Product) (err error)
 		if err != nil {
 			return fmt.Errorf("save product inventory: %w", err)
 		}
+		productInventory.Version++
+		if err = s.saveLots(ctx, tx, lots); err != nil {
+			return err
+		}
 
 		log.Ctx(ctx).Debug().
 			Str("func", funcName).
@@ -528,15 +3783,29 @@ func (s *service) FillReserves(ctx context.Context, product Product) (err error)
 		if err != nil {
 			return fmt.Errorf("update reservation %d: %w", reservation.ID, err)
 		}
+		reservation.Version++
+		if err = s.saveReservationLots(ctx, tx, reservation.ID, draws); err != nil {
+			return err
+		}
+
+		mv := InventoryMovement{Location: location, Delta: -reserveAmount, Reason: MovementReservation, RequestID: reservation.RequestID, ReservationID: &reservation.ID}
+		if err = s.recordMovement(ctx, tx, productInventory, mv); err != nil {
+			return fmt.Errorf("record reservation movement: %w", err)
+		}
 
 		if err = subtx.Commit(ctx); err != nil {
 			return fmt.Errorf("commit sub-transaction: %w", err)
 		}
 
-		err = s.publishInventory(ctx, productInventory)
+		// The low-stock check waits for the outer commit below: it
+		// flips the SKU's alert flag outside this transaction, so it
+		// must not run for stock a rollback would hand back.
+		err = s.announceInventory(ctx, productInventory)
 		if err != nil {
 			return fmt.Errorf("publish inventory: %w", err)
 		}
+		filled = true
+		expired = false
 
 		err = s.publishReservation(ctx, reservation)
 		if err != nil {
@@ -548,16 +3817,113 @@ func (s *service) FillReserves(ctx context.Context, product Product) (err error)
 		return fmt.Errorf("commit fill-reserves transaction: %w", err)
 	}
 
+	if filled {
+		// announceInventory dropped the cached entry before the commit,
+		// so a read in between could have cached the old row again.
+		s.invalidateProduct(ctx, product.Sku)
+		s.checkLowStock(ctx, productInventory)
+	}
+
+	// Nothing was allocated, so expiring lots is the only change
+	// nobody has heard about yet.
+	if expired {
+		if err = s.publishInventory(ctx, productInventory); err != nil {
+			return fmt.Errorf("publish inventory: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// fillReservesPageSize is how many open reservations FillReserves
+// reads per query while collecting every one for a SKU.
+const fillReservesPageSize = 100
+
+// openReservations locks and returns every open reservation for sku,
+// oldest first, a page at a time.
+func (s *service) openReservations(ctx context.Context, tx persistence.Transaction, sku string) ([]Reservation, error) {
+	var open []Reservation
+	for offset := 0; ; offset += fillReservesPageSize {
+		page, err := s.repo.GetReservations(ctx, GetReservationsOptions{Sku: sku, State: Open}, fillReservesPageSize, offset, persistence.QueryOptions{Tx: tx, ForUpdate: true})
+		if err != nil {
+			return nil, err
+		}
+		open = append(open, page...)
+		if len(page) < fillReservesPageSize {
+			return open, nil
+		}
+	}
+}
+
+// allocate decides how much stock each open reservation receives,
+// indexed like open, honouring each reservation's fill policy.
+// Reservations only ever draw from their own location; stock
+// elsewhere has to be transferred before it can fill them, so the
+// strategy runs once per location.
+func (s *service) allocate(sku string, open []Reservation, pi ProductInventory) []int64 {
+	strategy := s.allocationFor(sku)
+
+	var locations []string
+	byLocation := make(map[string][]int)
+	for i, r := range open {
+		location := s.locationOrDefault(r.Location)
+		if _, ok := byLocation[location]; !ok {
+			locations = append(locations, location)
+		}
+		byLocation[location] = append(byLocation[location], i)
+	}
+
+	out := make([]int64, len(open))
+	for _, location := range locations {
+		idx := byLocation[location]
+		available := pi.AvailableAt(location)
+		// A fill below a reservation's minimum is withdrawn and the
+		// strategy re-run without it, so the stock goes to someone who
+		// can take it. Each pass drops at least one reservation.
+		for len(idx) > 0 {
+			group := make([]Reservation, len(idx))
+			for j, i := range idx {
+				group[j] = open[i]
+			}
+			allocated := strategy.Allocate(group, available)
+
+			kept := idx[:0:0]
+			for j, i := range idx {
+				if qty := allocated[j]; qty > 0 && group[j].ReservedQuantity == 0 && qty < group[j].MinimumFill() {
+					continue
+				}
+				kept = append(kept, i)
+			}
+			if len(kept) == len(idx) {
+				for j, i := range idx {
+					out[i] = allocated[j]
+				}
+				break
+			}
+			idx = kept
+		}
+	}
+	return out
+}
+
 func (s *service) publishInventory(ctx context.Context, pi ProductInventory) error {
+	if err := s.announceInventory(ctx, pi); err != nil {
+		return err
+	}
+	s.checkLowStock(ctx, pi)
+	return nil
+}
+
+// announceInventory is publishInventory without the low-stock check,
+// for FillReserves, which publishes before its transaction commits and
+// checks once afterwards.
+func (s *service) announceInventory(ctx context.Context, pi ProductInventory) error {
 	err := s.queue.PublishInventory(ctx, pi)
 	if err != nil {
 		return fmt.Errorf("failed to publish inventory to queue: %w", err)
 	}
 	if s.emitter != nil {
-		if emitErr := s.emitter.EmitProductQuantityChanged(ctx, pi.Sku, pi.Available); emitErr != nil {
+		if emitErr := s.emitter.EmitProductQuantityChanged(ctx, pi); emitErr != nil {
 			// Kafka emission is best-effort alongside AMQP. The
 			// authoritative state is committed; downstream Kafka
 			// consumers will re-sync on the next change. Log and
@@ -567,14 +3933,67 @@ func (s *service) publishInventory(ctx context.Context, pi ProductInventory) err
 	}
 	// DSN-020 cache invalidation: every successful write to inventory
 	// reaches publishInventory after its tx has committed, so this is
-	// the right hook to drop the cached entry. Best-effort: if the
-	// cache is unreachable the per-key TTL becomes the safety net.
-	if s.cache != nil {
-		if delErr := s.cache.Delete(ctx, productCacheKey(pi.Sku)); delErr != nil {
-			log.Ctx(ctx).Warn().Err(delErr).Str("sku", pi.Sku).Msg("cache invalidate failed; TTL will eventually expire stale entry")
+	// the right hook to drop the cached entry. FillReserves announces
+	// before its outer commit and drops the entry again after it.
+	s.invalidateProduct(ctx, pi.Sku)
+	go s.notifyInventorySubscribers(pi)
+	return nil
+}
+
+// checkLowStock alerts purchasing when pi's Available has just fallen
+// below its reorder point. The repository only reports each crossing
+// once, so a SKU that stays low doesn't alert again until it has
+// recovered. Products that aren't Active take no new stock and never
+// alert. It must only run once the stock change has committed, since
+// the flag it flips isn't part of the caller's transaction; like the
+// Kafka emits it is then best-effort, so failures are only logged.
+func (s *service) checkLowStock(ctx context.Context, pi ProductInventory) {
+	if !pi.Active() {
+		return
+	}
+	policy, flipped, err := s.repo.UpdateLowStock(ctx, pi.Sku, pi.Available, time.Now())
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("sku", pi.Sku).Msg("failed to check low stock")
+		return
+	}
+	if !flipped || policy.LowSince == nil {
+		return
+	}
+
+	low := NewLowStock(policy, pi.Available)
+	log.Ctx(ctx).Info().Str("sku", pi.Sku).Int64("available", low.Available).Int64("suggestedQuantity", low.SuggestedQuantity).Msg("stock fell below reorder point")
+	if err = s.queue.PublishLowStock(ctx, low); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("sku", pi.Sku).Msg("failed to publish low stock to queue")
+	}
+	if s.emitter != nil {
+		if err = s.emitter.EmitLowStock(ctx, low); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("sku", pi.Sku).Msg("kafka low stock emit failed")
+		}
+	}
+}
+
+// invalidateProduct drops sku's cached inventory. Best-effort: if the
+// cache is unreachable the per-key TTL becomes the safety net.
+func (s *service) invalidateProduct(ctx context.Context, sku string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Delete(ctx, productCacheKey(sku)); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("sku", sku).Msg("cache invalidate failed; TTL will eventually expire stale entry")
+	}
+}
+
+// publishProduct announces a change to a product's details or
+// lifecycle state on AMQP and, best-effort, on Kafka.
+func (s *service) publishProduct(ctx context.Context, product Product) error {
+	if err := s.queue.PublishProduct(ctx, product); err != nil {
+		return fmt.Errorf("failed to publish product to queue: %w", err)
+	}
+	if s.emitter != nil {
+		if err := s.emitter.EmitProductChanged(ctx, product); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("sku", product.Sku).Str("state", string(product.State)).Msg("kafka product emit failed")
 		}
 	}
-	go s.notifyInventorySubscribers(pi)
 	return nil
 }
 
@@ -587,6 +4006,18 @@ func (s *service) publishReservation(ctx context.Context, r Reservation) error {
 	return nil
 }
 
+// publishTransfer emits a transfer's state change on the optional
+// Kafka emitter. Like the quantity events it is best-effort: the
+// transfer is already committed, so a failed emit is only logged.
+func (s *service) publishTransfer(ctx context.Context, t Transfer) {
+	if s.emitter == nil {
+		return
+	}
+	if err := s.emitter.EmitTransferChanged(ctx, t); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Uint64("id", t.ID).Str("state", string(t.State)).Msg("kafka transfer emit failed")
+	}
+}
+
 func (s *service) notifyInventorySubscribers(pi ProductInventory) {
 	s.subsMu.Lock()
 	subs := make(map[InventorySubID]chan<- ProductInventory, len(s.inventorySubs))
@@ -1,34 +1,193 @@
 package inventory
 
-import "context"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/sksmith/go-micro-example/internal/platform/persistence"
+)
 
 type MockInventoryService struct {
-	ProduceFunc                func(ctx context.Context, product Product, event ProductionRequest) error
-	CreateProductFunc          func(ctx context.Context, product Product) error
-	GetProductFunc             func(ctx context.Context, sku string) (Product, error)
-	GetAllProductInventoryFunc func(ctx context.Context, limit, offset int) ([]ProductInventory, error)
-	GetProductInventoryFunc    func(ctx context.Context, sku string) (ProductInventory, error)
-	SubscribeInventoryFunc     func(ch chan<- ProductInventory) (id InventorySubID)
-	UnsubscribeInventoryFunc   func(id InventorySubID)
-
-	ProduceCalls                int
-	CreateProductCalls          int
-	GetProductCalls             int
-	GetAllProductInventoryCalls int
-	GetProductInventoryCalls    int
-	SubscribeInventoryCalls     int
-	UnsubscribeInventoryCalls   int
+	ProduceFunc                     func(ctx context.Context, product Product, event ProductionRequest) error
+	AdjustFunc                      func(ctx context.Context, product Product, ar AdjustmentRequest) (Adjustment, error)
+	ChangeStatusFunc                func(ctx context.Context, product Product, sr StatusChangeRequest) (StatusChange, error)
+	CreateProductFunc               func(ctx context.Context, product Product) (ProductInventory, bool, error)
+	ImportProductsFunc              func(ctx context.Context, rows ProductImportReader, dryRun bool) (ImportResult, error)
+	UpdateProductFunc               func(ctx context.Context, sku string, pu ProductUpdate) (ProductInventory, error)
+	GetProductFunc                  func(ctx context.Context, sku string) (Product, error)
+	GetAllProductInventoryFunc      func(ctx context.Context, options ProductListOptions, limit, offset int) ([]ProductInventory, error)
+	GetAllProductInventoryAfterFunc func(ctx context.Context, options ProductListOptions, after *ProductKey, limit int) ([]ProductInventory, error)
+	ExportProductInventoryFunc      func(ctx context.Context, fn func(ProductInventory) error) error
+	GetProductInventoryFunc         func(ctx context.Context, sku string) (ProductInventory, error)
+	GetAllProductInventoryAsOfFunc  func(ctx context.Context, asOf time.Time, limit, offset int) ([]ProductInventory, error)
+	GetProductInventoryAsOfFunc     func(ctx context.Context, sku string, asOf time.Time) (ProductInventory, error)
+	GetInventoryHistoryFunc         func(ctx context.Context, sku string, limit, offset int) ([]InventoryMovement, error)
+	TransferFunc                    func(ctx context.Context, product Product, tr TransferRequest) (Transfer, error)
+	DispatchTransferFunc            func(ctx context.Context, ID uint64) (Transfer, error)
+	ReceiveTransferFunc             func(ctx context.Context, ID uint64) (Transfer, error)
+	GetTransferFunc                 func(ctx context.Context, ID uint64) (Transfer, error)
+	GetTransfersFunc                func(ctx context.Context, sku string, limit, offset int) ([]Transfer, error)
+	RecordReturnFunc                func(ctx context.Context, product Product, rr ReturnRequest) (Return, error)
+	GetReturnsFunc                  func(ctx context.Context, sku string, limit, offset int) ([]Return, error)
+	GetBillOfMaterialsFunc          func(ctx context.Context, sku string) (BillOfMaterials, error)
+	SetBillOfMaterialsFunc          func(ctx context.Context, bom BillOfMaterials) (BillOfMaterials, error)
+	BuildableFunc                   func(ctx context.Context, sku, location string) (Buildable, error)
+	GetLotsFunc                     func(ctx context.Context, sku string) ([]Lot, error)
+	GetLotRecipientsFunc            func(ctx context.Context, sku, lot string, limit, offset int) ([]LotRecipient, error)
+	GetReorderPolicyFunc            func(ctx context.Context, sku string) (ReorderPolicy, error)
+	SetReorderPolicyFunc            func(ctx context.Context, policy ReorderPolicy) (ReorderPolicy, error)
+	DeleteReorderPolicyFunc         func(ctx context.Context, sku string) error
+	GetLowStockFunc                 func(ctx context.Context, limit, offset int) ([]LowStock, error)
+	PlanProductionFunc              func(ctx context.Context, product Product, pr PlannedOrderRequest) (PlannedOrder, error)
+	CancelPlannedOrderFunc          func(ctx context.Context, sku, requestID string) (PlannedOrder, error)
+	GetScheduledOrdersFunc          func(ctx context.Context, sku string, limit, offset int) ([]PlannedOrder, error)
+	AvailableToPromiseFunc          func(ctx context.Context, sku string, quantity int64) (AvailableToPromise, error)
+	SubscribeInventoryFunc          func(ch chan<- ProductInventory) (id InventorySubID)
+	UnsubscribeInventoryFunc        func(id InventorySubID)
+
+	ProduceCalls                     int
+	AdjustCalls                      int
+	ChangeStatusCalls                int
+	CreateProductCalls               int
+	ImportProductsCalls              int
+	UpdateProductCalls               int
+	GetProductCalls                  int
+	GetAllProductInventoryCalls      int
+	GetAllProductInventoryAfterCalls int
+	ExportProductInventoryCalls      int
+	GetProductInventoryCalls         int
+	GetAllProductInventoryAsOfCalls  int
+	GetProductInventoryAsOfCalls     int
+	GetInventoryHistoryCalls         int
+	TransferCalls                    int
+	DispatchTransferCalls            int
+	ReceiveTransferCalls             int
+	GetTransferCalls                 int
+	GetTransfersCalls                int
+	RecordReturnCalls                int
+	GetReturnsCalls                  int
+	GetBillOfMaterialsCalls          int
+	SetBillOfMaterialsCalls          int
+	BuildableCalls                   int
+	GetLotsCalls                     int
+	GetLotRecipientsCalls            int
+	GetReorderPolicyCalls            int
+	SetReorderPolicyCalls            int
+	DeleteReorderPolicyCalls         int
+	GetLowStockCalls                 int
+	PlanProductionCalls              int
+	CancelPlannedOrderCalls          int
+	GetScheduledOrdersCalls          int
+	AvailableToPromiseCalls          int
+	SubscribeInventoryCalls          int
+	UnsubscribeInventoryCalls        int
 }
 
 func NewMockInventoryService() *MockInventoryService {
 	return &MockInventoryService{
-		ProduceFunc:       func(ctx context.Context, product Product, event ProductionRequest) error { return nil },
-		CreateProductFunc: func(ctx context.Context, product Product) error { return nil },
-		GetProductFunc:    func(ctx context.Context, sku string) (Product, error) { return Product{}, nil },
-		GetAllProductInventoryFunc: func(ctx context.Context, limit, offset int) ([]ProductInventory, error) {
+		ProduceFunc: func(ctx context.Context, product Product, event ProductionRequest) error { return nil },
+		AdjustFunc: func(ctx context.Context, product Product, ar AdjustmentRequest) (Adjustment, error) {
+			return Adjustment{}, nil
+		},
+		ChangeStatusFunc: func(ctx context.Context, product Product, sr StatusChangeRequest) (StatusChange, error) {
+			return StatusChange{}, nil
+		},
+		CreateProductFunc: func(ctx context.Context, product Product) (ProductInventory, bool, error) {
+			return ProductInventory{Product: product}, true, nil
+		},
+		ImportProductsFunc: func(ctx context.Context, rows ProductImportReader, dryRun bool) (ImportResult, error) {
+			// Reads every row so handler tests see what their file
+			// parsed to: each good row counts as imported.
+			result := ImportResult{DryRun: dryRun, Errors: []ImportRowError{}}
+			for {
+				_, err := rows.Read()
+				if errors.Is(err, io.EOF) {
+					return result, nil
+				}
+				var rowErr *ImportRowError
+				if errors.As(err, &rowErr) {
+					result.Rows++
+					result.Errors = append(result.Errors, *rowErr)
+					continue
+				}
+				if err != nil {
+					return result, err
+				}
+				result.Rows++
+				result.Imported++
+			}
+		},
+		UpdateProductFunc: func(ctx context.Context, sku string, pu ProductUpdate) (ProductInventory, error) {
+			return ProductInventory{}, nil
+		},
+		GetProductFunc: func(ctx context.Context, sku string) (Product, error) { return Product{}, nil },
+		GetAllProductInventoryFunc: func(ctx context.Context, options ProductListOptions, limit, offset int) ([]ProductInventory, error) {
 			return []ProductInventory{}, nil
 		},
-		GetProductInventoryFunc:  func(ctx context.Context, sku string) (ProductInventory, error) { return ProductInventory{}, nil },
+		GetAllProductInventoryAfterFunc: func(ctx context.Context, options ProductListOptions, after *ProductKey, limit int) ([]ProductInventory, error) {
+			return []ProductInventory{}, nil
+		},
+		ExportProductInventoryFunc: func(ctx context.Context, fn func(ProductInventory) error) error { return nil },
+		GetProductInventoryFunc:    func(ctx context.Context, sku string) (ProductInventory, error) { return ProductInventory{}, nil },
+		GetAllProductInventoryAsOfFunc: func(ctx context.Context, asOf time.Time, limit, offset int) ([]ProductInventory, error) {
+			return []ProductInventory{}, nil
+		},
+		GetProductInventoryAsOfFunc: func(ctx context.Context, sku string, asOf time.Time) (ProductInventory, error) {
+			return ProductInventory{}, nil
+		},
+		GetInventoryHistoryFunc: func(ctx context.Context, sku string, limit, offset int) ([]InventoryMovement, error) {
+			return []InventoryMovement{}, nil
+		},
+		TransferFunc: func(ctx context.Context, product Product, tr TransferRequest) (Transfer, error) {
+			return Transfer{}, nil
+		},
+		DispatchTransferFunc: func(ctx context.Context, ID uint64) (Transfer, error) { return Transfer{}, nil },
+		ReceiveTransferFunc:  func(ctx context.Context, ID uint64) (Transfer, error) { return Transfer{}, nil },
+		GetTransferFunc:      func(ctx context.Context, ID uint64) (Transfer, error) { return Transfer{}, nil },
+		GetTransfersFunc: func(ctx context.Context, sku string, limit, offset int) ([]Transfer, error) {
+			return []Transfer{}, nil
+		},
+		RecordReturnFunc: func(ctx context.Context, product Product, rr ReturnRequest) (Return, error) {
+			return Return{RequestID: rr.RequestID, Sku: product.Sku, Requester: rr.Requester, Quantity: rr.Quantity, Outcome: rr.Outcome}, nil
+		},
+		GetReturnsFunc: func(ctx context.Context, sku string, limit, offset int) ([]Return, error) {
+			return []Return{}, nil
+		},
+		GetBillOfMaterialsFunc: func(ctx context.Context, sku string) (BillOfMaterials, error) {
+			return BillOfMaterials{Sku: sku}, nil
+		},
+		SetBillOfMaterialsFunc: func(ctx context.Context, bom BillOfMaterials) (BillOfMaterials, error) {
+			return bom, nil
+		},
+		BuildableFunc: func(ctx context.Context, sku, location string) (Buildable, error) {
+			return Buildable{Sku: sku, Location: location}, nil
+		},
+		GetLotsFunc: func(ctx context.Context, sku string) ([]Lot, error) { return []Lot{}, nil },
+		GetLotRecipientsFunc: func(ctx context.Context, sku, lot string, limit, offset int) ([]LotRecipient, error) {
+			return []LotRecipient{}, nil
+		},
+		GetReorderPolicyFunc: func(ctx context.Context, sku string) (ReorderPolicy, error) {
+			return ReorderPolicy{}, persistence.ErrNotFound
+		},
+		SetReorderPolicyFunc: func(ctx context.Context, policy ReorderPolicy) (ReorderPolicy, error) {
+			return policy, nil
+		},
+		DeleteReorderPolicyFunc: func(ctx context.Context, sku string) error { return nil },
+		GetLowStockFunc:         func(ctx context.Context, limit, offset int) ([]LowStock, error) { return []LowStock{}, nil },
+		PlanProductionFunc: func(ctx context.Context, product Product, pr PlannedOrderRequest) (PlannedOrder, error) {
+			return PlannedOrder{RequestID: pr.RequestID, Sku: product.Sku, Quantity: pr.Quantity, ExpectedDate: pr.ExpectedDate, State: PlannedOrderScheduled}, nil
+		},
+		CancelPlannedOrderFunc: func(ctx context.Context, sku, requestID string) (PlannedOrder, error) {
+			return PlannedOrder{RequestID: requestID, Sku: sku, State: PlannedOrderCancelled}, nil
+		},
+		GetScheduledOrdersFunc: func(ctx context.Context, sku string, limit, offset int) ([]PlannedOrder, error) {
+			return []PlannedOrder{}, nil
+		},
+		AvailableToPromiseFunc: func(ctx context.Context, sku string, quantity int64) (AvailableToPromise, error) {
+			return AvailableToPromise{Sku: sku, Quantity: quantity}, nil
+		},
 		SubscribeInventoryFunc:   func(ch chan<- ProductInventory) (id InventorySubID) { return "" },
 		UnsubscribeInventoryFunc: func(id InventorySubID) {},
 	}
@@ -39,19 +198,49 @@ func (i *MockInventoryService) Produce(ctx context.Context, product Product, eve
 	return i.ProduceFunc(ctx, product, event)
 }
 
-func (i *MockInventoryService) CreateProduct(ctx context.Context, product Product) error {
+func (i *MockInventoryService) Adjust(ctx context.Context, product Product, ar AdjustmentRequest) (Adjustment, error) {
+	i.AdjustCalls++
+	return i.AdjustFunc(ctx, product, ar)
+}
+
+func (i *MockInventoryService) ChangeStatus(ctx context.Context, product Product, sr StatusChangeRequest) (StatusChange, error) {
+	i.ChangeStatusCalls++
+	return i.ChangeStatusFunc(ctx, product, sr)
+}
+
+func (i *MockInventoryService) CreateProduct(ctx context.Context, product Product) (ProductInventory, bool, error) {
 	i.CreateProductCalls++
 	return i.CreateProductFunc(ctx, product)
 }
 
+func (i *MockInventoryService) ImportProducts(ctx context.Context, rows ProductImportReader, dryRun bool) (ImportResult, error) {
+	i.ImportProductsCalls++
+	return i.ImportProductsFunc(ctx, rows, dryRun)
+}
+
+func (i *MockInventoryService) UpdateProduct(ctx context.Context, sku string, pu ProductUpdate) (ProductInventory, error) {
+	i.UpdateProductCalls++
+	return i.UpdateProductFunc(ctx, sku, pu)
+}
+
 func (i *MockInventoryService) GetProduct(ctx context.Context, sku string) (Product, error) {
 	i.GetProductCalls++
 	return i.GetProductFunc(ctx, sku)
 }
 
-func (i *MockInventoryService) GetAllProductInventory(ctx context.Context, limit, offset int) ([]ProductInventory, error) {
+func (i *MockInventoryService) GetAllProductInventory(ctx context.Context, options ProductListOptions, limit, offset int) ([]ProductInventory, error) {
 	i.GetAllProductInventoryCalls++
-	return i.GetAllProductInventoryFunc(ctx, limit, offset)
+	return i.GetAllProductInventoryFunc(ctx, options, limit, offset)
+}
+
+func (i *MockInventoryService) GetAllProductInventoryAfter(ctx context.Context, options ProductListOptions, after *ProductKey, limit int) ([]ProductInventory, error) {
+	i.GetAllProductInventoryAfterCalls++
+	return i.GetAllProductInventoryAfterFunc(ctx, options, after, limit)
+}
+
+func (i *MockInventoryService) ExportProductInventory(ctx context.Context, fn func(ProductInventory) error) error {
+	i.ExportProductInventoryCalls++
+	return i.ExportProductInventoryFunc(ctx, fn)
 }
 
 func (i *MockInventoryService) GetProductInventory(ctx context.Context, sku string) (ProductInventory, error) {
@@ -59,6 +248,121 @@ func (i *MockInventoryService) GetProductInventory(ctx context.Context, sku stri
 	return i.GetProductInventoryFunc(ctx, sku)
 }
 
+func (i *MockInventoryService) GetAllProductInventoryAsOf(ctx context.Context, asOf time.Time, limit, offset int) ([]ProductInventory, error) {
+	i.GetAllProductInventoryAsOfCalls++
+	return i.GetAllProductInventoryAsOfFunc(ctx, asOf, limit, offset)
+}
+
+func (i *MockInventoryService) GetProductInventoryAsOf(ctx context.Context, sku string, asOf time.Time) (ProductInventory, error) {
+	i.GetProductInventoryAsOfCalls++
+	return i.GetProductInventoryAsOfFunc(ctx, sku, asOf)
+}
+
+func (i *MockInventoryService) GetInventoryHistory(ctx context.Context, sku string, limit, offset int) ([]InventoryMovement, error) {
+	i.GetInventoryHistoryCalls++
+	return i.GetInventoryHistoryFunc(ctx, sku, limit, offset)
+}
+
+func (i *MockInventoryService) Transfer(ctx context.Context, product Product, tr TransferRequest) (Transfer, error) {
+	i.TransferCalls++
+	return i.TransferFunc(ctx, product, tr)
+}
+
+func (i *MockInventoryService) DispatchTransfer(ctx context.Context, ID uint64) (Transfer, error) {
+	i.DispatchTransferCalls++
+	return i.DispatchTransferFunc(ctx, ID)
+}
+
+func (i *MockInventoryService) ReceiveTransfer(ctx context.Context, ID uint64) (Transfer, error) {
+	i.ReceiveTransferCalls++
+	return i.ReceiveTransferFunc(ctx, ID)
+}
+
+func (i *MockInventoryService) GetTransfer(ctx context.Context, ID uint64) (Transfer, error) {
+	i.GetTransferCalls++
+	return i.GetTransferFunc(ctx, ID)
+}
+
+func (i *MockInventoryService) GetTransfers(ctx context.Context, sku string, limit, offset int) ([]Transfer, error) {
+	i.GetTransfersCalls++
+	return i.GetTransfersFunc(ctx, sku, limit, offset)
+}
+
+func (i *MockInventoryService) RecordReturn(ctx context.Context, product Product, rr ReturnRequest) (Return, error) {
+	i.RecordReturnCalls++
+	return i.RecordReturnFunc(ctx, product, rr)
+}
+
+func (i *MockInventoryService) GetReturns(ctx context.Context, sku string, limit, offset int) ([]Return, error) {
+	i.GetReturnsCalls++
+	return i.GetReturnsFunc(ctx, sku, limit, offset)
+}
+
+func (i *MockInventoryService) GetBillOfMaterials(ctx context.Context, sku string) (BillOfMaterials, error) {
+	i.GetBillOfMaterialsCalls++
+	return i.GetBillOfMaterialsFunc(ctx, sku)
+}
+
+func (i *MockInventoryService) SetBillOfMaterials(ctx context.Context, bom BillOfMaterials) (BillOfMaterials, error) {
+	i.SetBillOfMaterialsCalls++
+	return i.SetBillOfMaterialsFunc(ctx, bom)
+}
+
+func (i *MockInventoryService) Buildable(ctx context.Context, sku, location string) (Buildable, error) {
+	i.BuildableCalls++
+	return i.BuildableFunc(ctx, sku, location)
+}
+
+func (i *MockInventoryService) GetLots(ctx context.Context, sku string) ([]Lot, error) {
+	i.GetLotsCalls++
+	return i.GetLotsFunc(ctx, sku)
+}
+
+func (i *MockInventoryService) GetLotRecipients(ctx context.Context, sku, lot string, limit, offset int) ([]LotRecipient, error) {
+	i.GetLotRecipientsCalls++
+	return i.GetLotRecipientsFunc(ctx, sku, lot, limit, offset)
+}
+
+func (i *MockInventoryService) GetReorderPolicy(ctx context.Context, sku string) (ReorderPolicy, error) {
+	i.GetReorderPolicyCalls++
+	return i.GetReorderPolicyFunc(ctx, sku)
+}
+
+func (i *MockInventoryService) SetReorderPolicy(ctx context.Context, policy ReorderPolicy) (ReorderPolicy, error) {
+	i.SetReorderPolicyCalls++
+	return i.SetReorderPolicyFunc(ctx, policy)
+}
+
+func (i *MockInventoryService) DeleteReorderPolicy(ctx context.Context, sku string) error {
+	i.DeleteReorderPolicyCalls++
+	return i.DeleteReorderPolicyFunc(ctx, sku)
+}
+
+func (i *MockInventoryService) GetLowStock(ctx context.Context, limit, offset int) ([]LowStock, error) {
+	i.GetLowStockCalls++
+	return i.GetLowStockFunc(ctx, limit, offset)
+}
+
+func (i *MockInventoryService) PlanProduction(ctx context.Context, product Product, pr PlannedOrderRequest) (PlannedOrder, error) {
+	i.PlanProductionCalls++
+	return i.PlanProductionFunc(ctx, product, pr)
+}
+
+func (i *MockInventoryService) CancelPlannedOrder(ctx context.Context, sku, requestID string) (PlannedOrder, error) {
+	i.CancelPlannedOrderCalls++
+	return i.CancelPlannedOrderFunc(ctx, sku, requestID)
+}
+
+func (i *MockInventoryService) GetScheduledOrders(ctx context.Context, sku string, limit, offset int) ([]PlannedOrder, error) {
+	i.GetScheduledOrdersCalls++
+	return i.GetScheduledOrdersFunc(ctx, sku, limit, offset)
+}
+
+func (i *MockInventoryService) AvailableToPromise(ctx context.Context, sku string, quantity int64) (AvailableToPromise, error) {
+	i.AvailableToPromiseCalls++
+	return i.AvailableToPromiseFunc(ctx, sku, quantity)
+}
+
 func (i *MockInventoryService) SubscribeInventory(ch chan<- ProductInventory) (id InventorySubID) {
 	i.SubscribeInventoryCalls++
 	return i.SubscribeInventoryFunc(ch)
@@ -71,16 +375,37 @@ func (i *MockInventoryService) UnsubscribeInventory(id InventorySubID) {
 
 type MockReservationService struct {
 	ReserveFunc func(ctx context.Context, rr ReservationRequest) (Reservation, error)
+	CancelFunc  func(ctx context.Context, ID uint64) (Reservation, error)
+	ModifyFunc  func(ctx context.Context, ID uint64, ru ReservationUpdate) (Reservation, error)
+	ShipFunc    func(ctx context.Context, ID uint64, sr ShipmentRequest) (Shipment, error)
+
+	GetReservationsFunc      func(ctx context.Context, options GetReservationsOptions, limit, offset int) ([]Reservation, error)
+	GetReservationsAfterFunc func(ctx context.Context, options GetReservationsOptions, after *ReservationKey, limit int) ([]Reservation, error)
+	ExportReservationsFunc   func(ctx context.Context, options GetReservationsOptions, fn func(Reservation) error) error
+	GetReservationFunc       func(ctx context.Context, ID uint64) (Reservation, error)
+	GetBackordersFunc        func(ctx context.Context, sku string, limit, offset int) ([]Backorder, error)
 
-	GetReservationsFunc func(ctx context.Context, options GetReservationsOptions, limit, offset int) ([]Reservation, error)
-	GetReservationFunc  func(ctx context.Context, ID uint64) (Reservation, error)
+	GetRequesterLimitsFunc    func(ctx context.Context, requester string) (RequesterLimits, error)
+	GetAllRequesterLimitsFunc func(ctx context.Context, limit, offset int) ([]RequesterLimits, error)
+	SetRequesterLimitsFunc    func(ctx context.Context, limits RequesterLimits) (RequesterLimits, error)
+	DeleteRequesterLimitsFunc func(ctx context.Context, requester string) error
 
 	SubscribeReservationsFunc   func(ch chan<- Reservation) (id ReservationsSubID)
 	UnsubscribeReservationsFunc func(id ReservationsSubID)
 
 	ReserveCalls                 int
+	CancelCalls                  int
+	ModifyCalls                  int
+	ShipCalls                    int
 	GetReservationsCalls         int
+	GetReservationsAfterCalls    int
+	ExportReservationsCalls      int
 	GetReservationCalls          int
+	GetBackordersCalls           int
+	GetRequesterLimitsCalls      int
+	GetAllRequesterLimitsCalls   int
+	SetRequesterLimitsCalls      int
+	DeleteRequesterLimitsCalls   int
 	SubscribeReservationsCalls   int
 	UnsubscribeReservationsCalls int
 }
@@ -88,10 +413,34 @@ type MockReservationService struct {
 func NewMockReservationService() *MockReservationService {
 	return &MockReservationService{
 		ReserveFunc: func(ctx context.Context, rr ReservationRequest) (Reservation, error) { return Reservation{}, nil },
+		CancelFunc:  func(ctx context.Context, ID uint64) (Reservation, error) { return Reservation{}, nil },
+		ModifyFunc: func(ctx context.Context, ID uint64, ru ReservationUpdate) (Reservation, error) {
+			return Reservation{}, nil
+		},
+		ShipFunc: func(ctx context.Context, ID uint64, sr ShipmentRequest) (Shipment, error) { return Shipment{}, nil },
 		GetReservationsFunc: func(ctx context.Context, options GetReservationsOptions, limit, offset int) ([]Reservation, error) {
 			return []Reservation{}, nil
 		},
-		GetReservationFunc:          func(ctx context.Context, ID uint64) (Reservation, error) { return Reservation{}, nil },
+		GetReservationsAfterFunc: func(ctx context.Context, options GetReservationsOptions, after *ReservationKey, limit int) ([]Reservation, error) {
+			return []Reservation{}, nil
+		},
+		ExportReservationsFunc: func(ctx context.Context, options GetReservationsOptions, fn func(Reservation) error) error {
+			return nil
+		},
+		GetReservationFunc: func(ctx context.Context, ID uint64) (Reservation, error) { return Reservation{}, nil },
+		GetBackordersFunc: func(ctx context.Context, sku string, limit, offset int) ([]Backorder, error) {
+			return []Backorder{}, nil
+		},
+		GetRequesterLimitsFunc: func(ctx context.Context, requester string) (RequesterLimits, error) {
+			return RequesterLimits{Requester: requester}, nil
+		},
+		GetAllRequesterLimitsFunc: func(ctx context.Context, limit, offset int) ([]RequesterLimits, error) {
+			return []RequesterLimits{}, nil
+		},
+		SetRequesterLimitsFunc: func(ctx context.Context, limits RequesterLimits) (RequesterLimits, error) {
+			return limits, nil
+		},
+		DeleteRequesterLimitsFunc:   func(ctx context.Context, requester string) error { return nil },
 		SubscribeReservationsFunc:   func(ch chan<- Reservation) (id ReservationsSubID) { return "" },
 		UnsubscribeReservationsFunc: func(id ReservationsSubID) {},
 	}
@@ -102,16 +451,66 @@ func (r *MockReservationService) Reserve(ctx context.Context, rr ReservationRequ
 	return r.ReserveFunc(ctx, rr)
 }
 
+func (r *MockReservationService) Cancel(ctx context.Context, ID uint64) (Reservation, error) {
+	r.CancelCalls++
+	return r.CancelFunc(ctx, ID)
+}
+
+func (r *MockReservationService) Modify(ctx context.Context, ID uint64, ru ReservationUpdate) (Reservation, error) {
+	r.ModifyCalls++
+	return r.ModifyFunc(ctx, ID, ru)
+}
+
+func (r *MockReservationService) Ship(ctx context.Context, ID uint64, sr ShipmentRequest) (Shipment, error) {
+	r.ShipCalls++
+	return r.ShipFunc(ctx, ID, sr)
+}
+
 func (r *MockReservationService) GetReservations(ctx context.Context, options GetReservationsOptions, limit, offset int) ([]Reservation, error) {
 	r.GetReservationsCalls++
 	return r.GetReservationsFunc(ctx, options, limit, offset)
 }
 
+func (r *MockReservationService) GetReservationsAfter(ctx context.Context, options GetReservationsOptions, after *ReservationKey, limit int) ([]Reservation, error) {
+	r.GetReservationsAfterCalls++
+	return r.GetReservationsAfterFunc(ctx, options, after, limit)
+}
+
+func (r *MockReservationService) ExportReservations(ctx context.Context, options GetReservationsOptions, fn func(Reservation) error) error {
+	r.ExportReservationsCalls++
+	return r.ExportReservationsFunc(ctx, options, fn)
+}
+
 func (r *MockReservationService) GetReservation(ctx context.Context, ID uint64) (Reservation, error) {
 	r.GetReservationCalls++
 	return r.GetReservationFunc(ctx, ID)
 }
 
+func (r *MockReservationService) GetBackorders(ctx context.Context, sku string, limit, offset int) ([]Backorder, error) {
+	r.GetBackordersCalls++
+	return r.GetBackordersFunc(ctx, sku, limit, offset)
+}
+
+func (r *MockReservationService) GetRequesterLimits(ctx context.Context, requester string) (RequesterLimits, error) {
+	r.GetRequesterLimitsCalls++
+	return r.GetRequesterLimitsFunc(ctx, requester)
+}
+
+func (r *MockReservationService) GetAllRequesterLimits(ctx context.Context, limit, offset int) ([]RequesterLimits, error) {
+	r.GetAllRequesterLimitsCalls++
+	return r.GetAllRequesterLimitsFunc(ctx, limit, offset)
+}
+
+func (r *MockReservationService) SetRequesterLimits(ctx context.Context, limits RequesterLimits) (RequesterLimits, error) {
+	r.SetRequesterLimitsCalls++
+	return r.SetRequesterLimitsFunc(ctx, limits)
+}
+
+func (r *MockReservationService) DeleteRequesterLimits(ctx context.Context, requester string) error {
+	r.DeleteRequesterLimitsCalls++
+	return r.DeleteRequesterLimitsFunc(ctx, requester)
+}
+
 func (r *MockReservationService) SubscribeReservations(ch chan<- Reservation) (id ReservationsSubID) {
 	r.SubscribeReservationsCalls++
 	return r.SubscribeReservationsFunc(ch)
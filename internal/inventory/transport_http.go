@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/gobwas/ws"
 	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/auth"
 	"github.com/sksmith/go-micro-example/internal/catalog"
 	"github.com/sksmith/go-micro-example/internal/platform/httpx"
 	"github.com/sksmith/go-micro-example/internal/platform/persistence"
@@ -22,11 +25,46 @@ type CtxKey string
 
 type InventoryService interface {
 	Produce(ctx context.Context, product Product, event ProductionRequest) error
-	CreateProduct(ctx context.Context, product Product) error
+	Adjust(ctx context.Context, product Product, ar AdjustmentRequest) (Adjustment, error)
+	ChangeStatus(ctx context.Context, product Product, sr StatusChangeRequest) (StatusChange, error)
+	CreateProduct(ctx context.Context, product Product) (ProductInventory, bool, error)
+	ImportProducts(ctx context.Context, rows ProductImportReader, dryRun bool) (ImportResult, error)
+	UpdateProduct(ctx context.Context, sku string, pu ProductUpdate) (ProductInventory, error)
 
 	GetProduct(ctx context.Context, sku string) (Product, error)
-	GetAllProductInventory(ctx context.Context, limit, offset int) ([]ProductInventory, error)
+	GetAllProductInventory(ctx context.Context, options ProductListOptions, limit, offset int) ([]ProductInventory, error)
+	GetAllProductInventoryAfter(ctx context.Context, options ProductListOptions, after *ProductKey, limit int) ([]ProductInventory, error)
+	ExportProductInventory(ctx context.Context, fn func(ProductInventory) error) error
 	GetProductInventory(ctx context.Context, sku string) (ProductInventory, error)
+	GetAllProductInventoryAsOf(ctx context.Context, asOf time.Time, limit, offset int) ([]ProductInventory, error)
+	GetProductInventoryAsOf(ctx context.Context, sku string, asOf time.Time) (ProductInventory, error)
+	GetInventoryHistory(ctx context.Context, sku string, limit, offset int) ([]InventoryMovement, error)
+
+	Transfer(ctx context.Context, product Product, tr TransferRequest) (Transfer, error)
+	DispatchTransfer(ctx context.Context, ID uint64) (Transfer, error)
+	ReceiveTransfer(ctx context.Context, ID uint64) (Transfer, error)
+	GetTransfer(ctx context.Context, ID uint64) (Transfer, error)
+	GetTransfers(ctx context.Context, sku string, limit, offset int) ([]Transfer, error)
+
+	RecordReturn(ctx context.Context, product Product, rr ReturnRequest) (Return, error)
+	GetReturns(ctx context.Context, sku string, limit, offset int) ([]Return, error)
+
+	GetBillOfMaterials(ctx context.Context, sku string) (BillOfMaterials, error)
+	SetBillOfMaterials(ctx context.Context, bom BillOfMaterials) (BillOfMaterials, error)
+	Buildable(ctx context.Context, sku, location string) (Buildable, error)
+
+	GetLots(ctx context.Context, sku string) ([]Lot, error)
+	GetLotRecipients(ctx context.Context, sku, lot string, limit, offset int) ([]LotRecipient, error)
+
+	GetReorderPolicy(ctx context.Context, sku string) (ReorderPolicy, error)
+	SetReorderPolicy(ctx context.Context, policy ReorderPolicy) (ReorderPolicy, error)
+	DeleteReorderPolicy(ctx context.Context, sku string) error
+	GetLowStock(ctx context.Context, limit, offset int) ([]LowStock, error)
+
+	PlanProduction(ctx context.Context, product Product, pr PlannedOrderRequest) (PlannedOrder, error)
+	CancelPlannedOrder(ctx context.Context, sku, requestID string) (PlannedOrder, error)
+	GetScheduledOrders(ctx context.Context, sku string, limit, offset int) ([]PlannedOrder, error)
+	AvailableToPromise(ctx context.Context, sku string, quantity int64) (AvailableToPromise, error)
 
 	SubscribeInventory(ch chan<- ProductInventory) (id InventorySubID)
 	UnsubscribeInventory(id InventorySubID)
@@ -67,7 +105,23 @@ func (a *InventoryApi) ConfigureRouter(r chi.Router) {
 
 	r.Route("/", func(r chi.Router) {
 		r.With(httpx.Paginate).Get("/", a.List)
-		r.Put("/", a.CreateProduct)
+		// Anyone may create a product, but If-Match makes the PUT an
+		// update of an existing one, which is the same catalogue
+		// change as PATCH /{sku} and so needs the same roles.
+		create := http.HandlerFunc(a.CreateProduct)
+		replace := auth.InventoryManagerOnly(create)
+		r.Put("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("If-Match") != "" {
+				replace.ServeHTTP(w, r)
+				return
+			}
+			create(w, r)
+		})
+		r.With(httpx.Paginate).Get("/low-stock", a.LowStock)
+		r.Get("/export", a.Export)
+		// Bulk import onboards a whole catalogue at once, so it is
+		// limited to admins.
+		r.With(auth.AdminOnly).Post("/import", a.ImportProducts)
 
 		r.Route("/{sku}", func(r chi.Router) {
 			r.Use(a.ProductCtx)
@@ -82,7 +136,33 @@ func (a *InventoryApi) ConfigureRouter(r chi.Router) {
 			} else {
 				r.Put("/productionEvent", prod.ServeHTTP)
 			}
+			// Adjustments correct stock by hand, so they are limited
+			// to admins and inventory managers.
+			adjust := auth.InventoryManagerOnly(http.HandlerFunc(a.CreateAdjustment))
+			if a.idempotency != nil {
+				adjust = a.idempotency(adjust)
+			}
+			r.Method(http.MethodPut, "/adjustment", adjust)
+			// Holding stock back from sale is a QA decision made by
+			// hand, so it shares the adjustment roles too.
+			status := auth.InventoryManagerOnly(http.HandlerFunc(a.ChangeStatus))
+			if a.idempotency != nil {
+				status = a.idempotency(status)
+			}
+			r.Method(http.MethodPut, "/status", status)
 			r.Get("/", a.GetProductInventory)
+			// Renaming and retiring products are catalogue changes
+			// made by hand, so they share the adjustment roles.
+			r.Method(http.MethodPatch, "/", auth.InventoryManagerOnly(http.HandlerFunc(a.UpdateProduct)))
+			r.Method(http.MethodDelete, "/", auth.InventoryManagerOnly(http.HandlerFunc(a.ArchiveProduct)))
+			r.With(httpx.Paginate).Get("/history", a.History)
+			r.Route("/transfer", a.configureTransferRouter)
+			r.Route("/returns", a.configureReturnRouter)
+			r.Route("/bom", a.configureBOMRouter)
+			r.Route("/lots", a.configureLotRouter)
+			r.Route("/reorder", a.configureReorderRouter)
+			r.Route("/planned", a.configurePlannedRouter)
+			r.Get("/atp", a.GetAvailableToPromise)
 		})
 	})
 }
@@ -137,16 +217,26 @@ func streamInventoryToClient(svc InventoryService, writer textWriter) {
 	}
 }
 
-// List returns a page of product inventory.
+// List returns a page of product inventory, optionally searched,
+// bounded by available stock and sorted.
 //
 //	@Summary	List product inventory
 //	@Tags		inventory
 //	@Produce	json
 //	@Param		limit	query		int	false	"max items per page (≤ 200)"	default(50)
 //	@Param		offset	query		int	false	"page offset"					default(0)
+//	@Param		cursor	query		string	false	"keyset cursor from a next link; empty for the first page"
+//	@Param		search	query		string	false	"substring of the product name or UPC"
+//	@Param		available_gte	query	int	false	"minimum total available"
+//	@Param		available_gt	query	int	false	"total available above"
+//	@Param		available_lte	query	int	false	"maximum total available"
+//	@Param		available_lt	query	int	false	"total available below"
+//	@Param		sort	query		string	false	"sku, name or available; prefix with - for descending"	default(sku)
+//	@Param		asOf	query		string	false	"RFC 3339 instant to reconstruct inventory at"
 //	@Success	200		{array}		ProductResponse
 //	@Failure	400		{object}	httpx.Problem
 //	@Failure	401		{object}	httpx.Problem
+//	@Failure	422		{object}	httpx.Problem
 //	@Failure	500		{object}	httpx.Problem
 //	@Header		200		{string}	Link	"RFC 8288 next/prev links"
 //	@Router		/api/v1/inventory [get]
@@ -154,28 +244,80 @@ func streamInventoryToClient(svc InventoryService, writer textWriter) {
 func (a *InventoryApi) List(w http.ResponseWriter, r *http.Request) {
 	p := httpx.PaginationFrom(r.Context())
 
-	products, err := a.service.GetAllProductInventory(r.Context(), p.Limit, p.Offset)
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	options, problem := parseProductListOptions(r.URL.Query())
+	if problem != nil {
+		httpx.Render(w, r, problem)
+		return
+	}
+
+	var products []ProductInventory
+	switch {
+	case asOf != nil && p.Keyset:
+		httpx.Render(w, r, httpx.ValidationProblem(httpx.FieldProblem{Field: "cursor", Detail: "cannot be combined with asOf"}))
+		return
+	case asOf != nil && options != (ProductListOptions{}):
+		httpx.Render(w, r, httpx.ValidationProblem(httpx.FieldProblem{Field: "asOf", Detail: "cannot be combined with search, available or sort"}))
+		return
+	case p.Keyset:
+		var after *ProductKey
+		if after, err = productsAfter(p, options); err != nil {
+			httpx.Render(w, r, httpx.InvalidCursorProblem())
+			return
+		}
+		products, err = a.service.GetAllProductInventoryAfter(r.Context(), options, after, p.Limit)
+	case asOf != nil:
+		products, err = a.service.GetAllProductInventoryAsOf(r.Context(), *asOf, p.Limit, p.Offset)
+	default:
+		products, err = a.service.GetAllProductInventory(r.Context(), options, p.Limit, p.Offset)
+	}
+	if errors.Is(err, ErrBeforeHistory) {
+		httpx.Render(w, r, httpx.UnprocessableEntityProblem(err))
+		return
+	}
 	if err != nil {
 		log.Ctx(r.Context()).Error().Err(err).Int("limit", p.Limit).Int("offset", p.Offset).Msg("failed to list product inventory")
 		httpx.Render(w, r, httpx.InternalServerProblem(err))
 		return
 	}
+	if p.Keyset && len(products) > 0 {
+		p.NextCursor, err = nextProductCursor(options, products[len(products)-1])
+		if err != nil {
+			httpx.Render(w, r, httpx.InternalServerProblem(err))
+			return
+		}
+	}
 
+	list := make([]render.Renderer, 0, len(products))
+	for _, product := range products {
+		list = append(list, &ProductResponse{ProductInventory: product, AsOf: asOf})
+	}
 	httpx.WriteLinkHeader(w, r, p, len(products))
-	httpx.RenderList(w, r, NewProductListResponse(products))
+	httpx.RenderList(w, r, list)
 }
 
-// CreateProduct registers a new product.
+// CreateProduct registers a new product. It answers 201 when the
+// product was created and 200 when it already existed or was replaced
+// under If-Match, either way with the stored product and its ETag.
 //
 //	@Summary	Create a product
 //	@Tags		inventory
 //	@Accept		json
 //	@Produce	json
-//	@Param		product	body		CreateProductRequest	true	"product to create"
-//	@Success	201		{object}	ProductResponse
-//	@Failure	400		{object}	httpx.Problem
-//	@Failure	401		{object}	httpx.Problem
-//	@Failure	500		{object}	httpx.Problem
+//	@Param		product			body		CreateProductRequest	true	"product to create"
+//	@Param		If-Match		header		string					false	"replace the product only if it is at this ETag"
+//	@Param		If-None-Match	header		string					false	"\"*\" to create the product only if it doesn't exist"
+//	@Success	200				{object}	ProductResponse
+//	@Success	201				{object}	ProductResponse
+//	@Failure	400				{object}	httpx.Problem
+//	@Failure	401				{object}	httpx.Problem
+//	@Failure	412				{object}	httpx.Problem
+//	@Failure	500				{object}	httpx.Problem
 //	@Router		/api/v1/inventory [put]
 //	@Security	BearerAuth
 func (a *InventoryApi) CreateProduct(w http.ResponseWriter, r *http.Request) {
@@ -185,18 +327,59 @@ func (a *InventoryApi) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := a.service.CreateProduct(r.Context(), data.Product); err != nil {
+	if r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != "" {
+		current := ""
+		existing, err := a.service.GetProduct(r.Context(), data.Product.Sku)
+		switch {
+		case err == nil:
+			current = httpx.ETag(existing.Version)
+		case !errors.Is(err, persistence.ErrNotFound):
+			log.Ctx(r.Context()).Error().Err(err).Str("sku", data.Product.Sku).Msg("error acquiring product")
+			httpx.Render(w, r, httpx.InternalServerProblem(err))
+			return
+		}
+		if !httpx.CheckPreconditions(w, r, current) {
+			return
+		}
+	}
+
+	pi, created, err := a.service.CreateProduct(conditional(r), data.Product)
+	if err != nil {
 		if errors.Is(err, ErrInvalidInput) {
 			httpx.Render(w, r, httpx.BadRequestProblem(err))
 			return
 		}
+		if errors.Is(err, persistence.ErrVersionConflict) {
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
 		log.Ctx(r.Context()).Error().Err(err).Str("sku", data.Product.Sku).Msg("failed to create product")
 		httpx.Render(w, r, httpx.InternalServerProblem(err))
 		return
 	}
 
-	render.Status(r, http.StatusCreated)
-	httpx.Render(w, r, NewProductResponse(ProductInventory{Product: data.Product}))
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("ETag", httpx.ETag(pi.Version))
+	render.Status(r, status)
+	httpx.Render(w, r, NewProductResponse(pi))
+}
+
+// conditional returns the request's context carrying its preconditions
+// for the service to re-check against the locked record: the version
+// from a single-tag If-Match, and whether If-None-Match is "*".
+func conditional(r *http.Request) context.Context {
+	version, _ := httpx.IfMatchVersion(r)
+	return WithPrecondition(r.Context(), Precondition{
+		Version: version,
+		Absent:  r.Header.Get("If-None-Match") == "*",
+	})
 }
 
 func (a *InventoryApi) ProductCtx(next http.Handler) http.Handler {
@@ -234,10 +417,13 @@ func (a *InventoryApi) ProductCtx(next http.Handler) http.Handler {
 //	@Produce	json
 //	@Param		sku		path		string							true	"product SKU"
 //	@Param		event	body		CreateProductionEventRequest	true	"production event"
+//	@Param		If-Match	header		string	false	"apply only if the SKU is still at this ETag"
 //	@Success	201		{object}	ProductionEventResponse
 //	@Failure	400		{object}	httpx.Problem
 //	@Failure	401		{object}	httpx.Problem
 //	@Failure	404		{object}	httpx.Problem
+//	@Failure	409		{object}	httpx.Problem
+//	@Failure	412		{object}	httpx.Problem
 //	@Failure	500		{object}	httpx.Problem
 //	@Router		/api/v1/inventory/{sku}/productionEvent [put]
 //	@Security	BearerAuth
@@ -250,11 +436,28 @@ func (a *InventoryApi) CreateProductionEvent(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if err := a.service.Produce(r.Context(), product, *data.ProductionRequest); err != nil {
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(product.Version)) {
+		return
+	}
+
+	if err := a.service.Produce(conditional(r), product, *data.ProductionRequest); err != nil {
 		if errors.Is(err, ErrInvalidInput) {
 			httpx.Render(w, r, httpx.BadRequestProblem(err))
 			return
 		}
+		if errors.Is(err, persistence.ErrVersionConflict) {
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
+		var short *ComponentShortageError
+		if errors.As(err, &short) {
+			httpx.Render(w, r, componentShortageProblem(short))
+			return
+		}
 		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Str("requestId", data.ProductionRequest.RequestID).Msg("failed to record production event")
 		httpx.Render(w, r, httpx.InternalServerProblem(err))
 		return
@@ -264,25 +467,233 @@ func (a *InventoryApi) CreateProductionEvent(w http.ResponseWriter, r *http.Requ
 	httpx.Render(w, r, &ProductionEventResponse{})
 }
 
+// CreateAdjustment applies a manual stock correction to a SKU.
+//
+//	@Summary	Adjust inventory
+//	@Tags		inventory
+//	@Accept		json
+//	@Produce	json
+//	@Param		sku			path		string					true	"product SKU"
+//	@Param		adjustment	body		AdjustmentRequestDto	true	"adjustment"
+//	@Param		If-Match	header		string	false	"apply only if the SKU is still at this ETag"
+//	@Success	201			{object}	AdjustmentResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	412		{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/adjustment [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	data := &AdjustmentRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(product.Version)) {
+		return
+	}
+
+	adj, err := a.service.Adjust(conditional(r), product, *data.AdjustmentRequest)
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		if errors.Is(err, persistence.ErrVersionConflict) {
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Str("requestId", data.RequestID).Msg("failed to adjust inventory")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	httpx.Render(w, r, &AdjustmentResponse{Adjustment: adj})
+}
+
+// ChangeStatus moves stock of a SKU between stock statuses, such as
+// putting it on hold or releasing it back to available.
+//
+//	@Summary	Move stock between statuses
+//	@Tags		inventory
+//	@Accept		json
+//	@Produce	json
+//	@Param		sku			path		string					true	"product SKU"
+//	@Param		change		body		StatusChangeRequestDto	true	"status change"
+//	@Param		If-Match	header		string	false	"apply only if the SKU is still at this ETag"
+//	@Success	201			{object}	StatusChangeResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	409			{object}	httpx.Problem
+//	@Failure	412			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/status [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) ChangeStatus(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	data := &StatusChangeRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(product.Version)) {
+		return
+	}
+
+	change, err := a.service.ChangeStatus(conditional(r), product, *data.StatusChangeRequest)
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		if errors.Is(err, persistence.ErrVersionConflict) {
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Str("requestId", data.RequestID).Msg("failed to change stock status")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	httpx.Render(w, r, &StatusChangeResponse{StatusChange: change})
+}
+
+// UpdateProduct changes a product's UPC, name or lifecycle state.
+//
+//	@Summary	Update a product
+//	@Tags		inventory
+//	@Accept		json
+//	@Produce	json
+//	@Param		sku			path		string				true	"product SKU"
+//	@Param		update		body		ProductUpdateDto	true	"fields to change"
+//	@Param		If-Match	header		string				false	"apply only if the SKU is still at this ETag"
+//	@Success	200			{object}	ProductResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	409			{object}	httpx.Problem
+//	@Failure	412			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Header		200			{string}	ETag	"the SKU's new version"
+//	@Router		/api/v1/inventory/{sku} [patch]
+//	@Security	BearerAuth
+func (a *InventoryApi) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	data := &ProductUpdateDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+	a.updateProduct(w, r, *data.ProductUpdate, http.StatusOK)
+}
+
+// ArchiveProduct retires a product that no longer holds stock or
+// reservations. The product stays readable; it just can't change.
+//
+//	@Summary	Archive a product
+//	@Tags		inventory
+//	@Param		sku			path		string	true	"product SKU"
+//	@Param		If-Match	header		string	false	"archive only if the SKU is still at this ETag"
+//	@Success	204
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	409			{object}	httpx.Problem
+//	@Failure	412			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku} [delete]
+//	@Security	BearerAuth
+func (a *InventoryApi) ArchiveProduct(w http.ResponseWriter, r *http.Request) {
+	a.updateProduct(w, r, ProductUpdate{State: ProductArchived}, http.StatusNoContent)
+}
+
+func (a *InventoryApi) updateProduct(w http.ResponseWriter, r *http.Request, pu ProductUpdate, status int) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(product.Version)) {
+		return
+	}
+
+	pi, err := a.service.UpdateProduct(conditional(r), product.Sku, pu)
+	if err != nil {
+		switch {
+		case errors.Is(err, persistence.ErrNotFound):
+			httpx.Render(w, r, httpx.NotFoundProblem())
+		case errors.Is(err, ErrInvalidInput):
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+		case errors.Is(err, ErrProductState):
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+		case errors.Is(err, persistence.ErrVersionConflict):
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+		default:
+			log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Interface("update", pu).Msg("failed to update product")
+			httpx.Render(w, r, httpx.InternalServerProblem(err))
+		}
+		return
+	}
+
+	w.Header().Set("ETag", httpx.ETag(pi.Version))
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	render.Status(r, status)
+	httpx.Render(w, r, NewProductResponse(pi))
+}
+
 // GetProductInventory returns the current inventory for a SKU.
 //
 //	@Summary	Get product inventory
 //	@Tags		inventory
 //	@Produce	json
-//	@Param		sku	path		string	true	"product SKU"
-//	@Success	200	{object}	ProductResponse
-//	@Failure	401	{object}	httpx.Problem
-//	@Failure	404	{object}	httpx.Problem
-//	@Failure	500	{object}	httpx.Problem
+//	@Param		sku		path		string	true	"product SKU"
+//	@Param		asOf	query		string	false	"RFC 3339 instant to reconstruct inventory at"
+//	@Param		If-None-Match	header		string	false	"answer 304 if the SKU is still at this ETag"
+//	@Success	200		{object}	ProductResponse
+//	@Success	304		{string}	string	"not modified"
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	422		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	ETag	"the SKU's current version"
 //	@Router		/api/v1/inventory/{sku} [get]
 //	@Security	BearerAuth
 func (a *InventoryApi) GetProductInventory(w http.ResponseWriter, r *http.Request) {
 	product := r.Context().Value(CtxKeyProduct).(Product)
 
-	res, err := a.service.GetProductInventory(r.Context(), product.Sku)
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	var res ProductInventory
+	if asOf != nil {
+		res, err = a.service.GetProductInventoryAsOf(r.Context(), product.Sku, *asOf)
+	} else {
+		res, err = a.service.GetProductInventory(r.Context(), product.Sku)
+	}
 	if err != nil {
 		if errors.Is(err, persistence.ErrNotFound) {
 			httpx.Render(w, r, httpx.NotFoundProblem())
+		} else if errors.Is(err, ErrBeforeHistory) {
+			httpx.Render(w, r, httpx.UnprocessableEntityProblem(err))
 		} else {
 			log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to get product inventory")
 			httpx.Render(w, r, httpx.InternalServerProblem(err))
@@ -290,12 +701,70 @@ func (a *InventoryApi) GetProductInventory(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	resp := &ProductResponse{ProductInventory: res}
+	// Historical reads describe a state no longer current, so only
+	// the live one has an entity tag to validate against.
+	if asOf == nil {
+		if !httpx.CheckPreconditions(w, r, httpx.ETag(res.Version)) {
+			return
+		}
+		w.Header().Set("ETag", httpx.ETag(res.Version))
+	}
+
+	resp := &ProductResponse{ProductInventory: res, AsOf: asOf}
 	resp.Catalog = a.lookupCatalog(r, product.Sku)
 	render.Status(r, http.StatusOK)
 	httpx.Render(w, r, resp)
 }
 
+// History returns a page of a SKU's inventory ledger, oldest first.
+//
+//	@Summary	List inventory movements for a SKU
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku		path		string	true	"product SKU"
+//	@Param		limit	query		int		false	"max items per page (≤ 200)"	default(50)
+//	@Param		offset	query		int		false	"page offset"					default(0)
+//	@Success	200		{array}		MovementResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	Link	"RFC 8288 next/prev links"
+//	@Router		/api/v1/inventory/{sku}/history [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) History(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+	p := httpx.PaginationFrom(r.Context())
+
+	movements, err := a.service.GetInventoryHistory(r.Context(), product.Sku, p.Limit, p.Offset)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to get inventory history")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.WriteLinkHeader(w, r, p, len(movements))
+	httpx.RenderList(w, r, NewMovementListResponse(movements))
+}
+
+// parseAsOf reads the optional asOf query parameter. A nil result
+// means the caller wants current inventory. Instants in the future
+// are rejected rather than silently answered with today's balance.
+func parseAsOf(r *http.Request) (*time.Time, error) {
+	raw := r.URL.Query().Get("asOf")
+	if raw == "" {
+		return nil, nil
+	}
+	asOf, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("asOf must be an RFC 3339 timestamp: %w", err)
+	}
+	if asOf.After(time.Now()) {
+		return nil, errors.New("asOf must not be in the future")
+	}
+	return &asOf, nil
+}
+
 // lookupCatalog runs the optional outbound enrichment (DSN-018). The
 // catalog client is intentionally best-effort: missing data, 404s,
 // upstream errors, and timeouts all fall through to a nil result so
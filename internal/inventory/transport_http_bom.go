@@ -0,0 +1,165 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/auth"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+	"github.com/sksmith/go-micro-example/internal/platform/persistence"
+)
+
+// configureBOMRouter mounts the bill of materials routes under
+// /inventory/{sku}/bom. Changing what a kit is made of is a catalogue
+// change, so it is limited to admins and inventory managers.
+func (a *InventoryApi) configureBOMRouter(r chi.Router) {
+	r.Get("/", a.GetBillOfMaterials)
+	r.Method(http.MethodPut, "/", auth.InventoryManagerOnly(http.HandlerFunc(a.PutBillOfMaterials)))
+	r.Method(http.MethodDelete, "/", auth.InventoryManagerOnly(http.HandlerFunc(a.DeleteBillOfMaterials)))
+	r.Get("/buildable", a.GetBuildable)
+}
+
+// GetBillOfMaterials returns the components a kit is produced from.
+//
+//	@Summary	Get a SKU's bill of materials
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku	path		string	true	"product SKU"
+//	@Success	200	{object}	BillOfMaterialsResponse
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/bom [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) GetBillOfMaterials(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	bom, err := a.service.GetBillOfMaterials(r.Context(), product.Sku)
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to get bill of materials")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &BillOfMaterialsResponse{BillOfMaterials: bom})
+}
+
+// PutBillOfMaterials replaces the components a kit is produced from.
+//
+//	@Summary	Set a SKU's bill of materials
+//	@Tags		inventory
+//	@Accept		json
+//	@Produce	json
+//	@Param		sku	path		string						true	"product SKU"
+//	@Param		bom	body		BillOfMaterialsRequestDto	true	"components"
+//	@Success	200	{object}	BillOfMaterialsResponse
+//	@Failure	400	{object}	httpx.Problem
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	409	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/bom [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) PutBillOfMaterials(w http.ResponseWriter, r *http.Request) {
+	data := &BillOfMaterialsRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+	a.setBillOfMaterials(w, r, data.Components, http.StatusOK)
+}
+
+// DeleteBillOfMaterials removes a kit's bill of materials, after which
+// producing it consumes no components.
+//
+//	@Summary	Remove a SKU's bill of materials
+//	@Tags		inventory
+//	@Param		sku	path	string	true	"product SKU"
+//	@Success	204
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	409	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/bom [delete]
+//	@Security	BearerAuth
+func (a *InventoryApi) DeleteBillOfMaterials(w http.ResponseWriter, r *http.Request) {
+	a.setBillOfMaterials(w, r, nil, http.StatusNoContent)
+}
+
+func (a *InventoryApi) setBillOfMaterials(w http.ResponseWriter, r *http.Request, components []BOMComponent, status int) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	bom, err := a.service.SetBillOfMaterials(r.Context(), BillOfMaterials{Sku: product.Sku, Components: components})
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to set bill of materials")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	render.Status(r, status)
+	httpx.Render(w, r, &BillOfMaterialsResponse{BillOfMaterials: bom})
+}
+
+// GetBuildable reports how many units of a kit the component stock at a
+// location could produce right now, without producing anything.
+//
+//	@Summary	Dry-run production of a kit
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku			path		string	true	"product SKU"
+//	@Param		location	query		string	false	"location to build at; defaults to the configured default location"
+//	@Success	200			{object}	BuildableResponse
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/bom/buildable [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) GetBuildable(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	b, err := a.service.Buildable(r.Context(), product.Sku, r.URL.Query().Get("location"))
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to calculate buildable quantity")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &BuildableResponse{Buildable: b})
+}
+
+// componentShortageProblem is the 409 for production that ran out of
+// components, with one errors entry per short component.
+func componentShortageProblem(err *ComponentShortageError) *httpx.Problem {
+	p := httpx.ConflictProblem(err)
+	for _, c := range err.Shortages {
+		p.Errors = append(p.Errors, httpx.FieldProblem{
+			Field:  c.Sku,
+			Detail: fmt.Sprintf("needs %d, %d available", c.Required, c.Available),
+		})
+	}
+	return p
+}
@@ -0,0 +1,69 @@
+package inventory
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+)
+
+// productCursor is the key a keyset page of product inventory ends on,
+// along with the order it was listed in so it can't be replayed
+// against a different one.
+type productCursor struct {
+	Sku       string `json:"sku"`
+	Name      string `json:"name,omitempty"`
+	Available int64  `json:"available,omitempty"`
+	Sort      string `json:"sort,omitempty"`
+}
+
+// reservationCursor is the key a keyset page of reservations ends on,
+// matching the (created, id) order they are listed in.
+type reservationCursor struct {
+	Created time.Time `json:"created"`
+	ID      uint64    `json:"id"`
+}
+
+var errCursorSort = errors.New("cursor was issued for a different sort")
+
+// productsAfter decodes where a keyset page of product inventory
+// starts, nil for the first page.
+func productsAfter(p httpx.Pagination, options ProductListOptions) (*ProductKey, error) {
+	if p.After == nil {
+		return nil, nil
+	}
+	var c productCursor
+	if err := p.DecodeCursor(&c); err != nil {
+		return nil, err
+	}
+	if c.Sort != productSortParam(options) {
+		return nil, errCursorSort
+	}
+	return &ProductKey{Sku: c.Sku, Name: c.Name, Available: c.Available}, nil
+}
+
+// nextProductCursor is the cursor for the page after the one ending on
+// last.
+func nextProductCursor(options ProductListOptions, last ProductInventory) (string, error) {
+	c := productCursor{Sku: last.Sku, Sort: productSortParam(options)}
+	switch options.Sort {
+	case SortByName:
+		c.Name = last.Name
+	case SortByAvailable:
+		c.Available = last.Available
+	}
+	return httpx.EncodeCursor(c)
+}
+
+// reservationsAfter decodes where a keyset page of reservations starts,
+// nil for the first page.
+func reservationsAfter(p httpx.Pagination) (*ReservationKey, error) {
+	if p.After == nil {
+		return nil, nil
+	}
+	var c reservationCursor
+	if err := p.DecodeCursor(&c); err != nil {
+		return nil, err
+	}
+	return &ReservationKey{Created: c.Created, ID: c.ID}, nil
+}
@@ -0,0 +1,251 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+)
+
+// exportFormat is how an export's rows are encoded.
+type exportFormat string
+
+const (
+	exportCSV    exportFormat = "csv"
+	exportNDJSON exportFormat = "ndjson"
+)
+
+// exportFlushRows is how many rows an export writes between flushes,
+// so a slow reader sees rows arrive as they are read.
+const exportFlushRows = 1000
+
+var inventoryExportColumns = []string{"sku", "upc", "name", "state", "version", "location", "available", "inTransit", "expired", "onHold", "quarantined", "damaged"}
+
+var reservationExportColumns = []string{"id", "requestId", "requester", "sku", "location", "state", "requestedQuantity", "reservedQuantity", "shippedQuantity", "priority", "fillPolicy", "minQuantity", "created", "expiresAt", "version"}
+
+// negotiateExport picks an export's format from its format parameter
+// or, without one, the first CSV or NDJSON type its Accept header
+// lists. Anything else gets NDJSON.
+func negotiateExport(r *http.Request) (exportFormat, error) {
+	switch f := r.URL.Query().Get("format"); f {
+	case "":
+	case string(exportCSV):
+		return exportCSV, nil
+	case string(exportNDJSON):
+		return exportNDJSON, nil
+	default:
+		return "", errors.New("format must be csv or ndjson")
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return exportCSV, nil
+		case "application/x-ndjson", "application/ndjson":
+			return exportNDJSON, nil
+		}
+	}
+	return exportNDJSON, nil
+}
+
+// exportStream writes an export's rows to the response as they are
+// read. The status and headers only go out with the first row, so a
+// failure before then can still be answered with a problem.
+type exportStream struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	format  exportFormat
+	name    string
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+	started bool
+	rows    int
+}
+
+func newExportStream(w http.ResponseWriter, r *http.Request, format exportFormat, name string, columns []string) *exportStream {
+	return &exportStream{w: w, r: r, format: format, name: name, columns: columns}
+}
+
+func (e *exportStream) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if e.format == exportCSV {
+		e.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		e.w.Header().Set("Content-Disposition", `attachment; filename="`+e.name+`.csv"`)
+		e.w.WriteHeader(http.StatusOK)
+		e.csv = csv.NewWriter(e.w)
+		return e.csv.Write(e.columns)
+	}
+	e.w.Header().Set("Content-Type", "application/x-ndjson")
+	e.w.Header().Set("Content-Disposition", `attachment; filename="`+e.name+`.ndjson"`)
+	e.w.WriteHeader(http.StatusOK)
+	e.json = json.NewEncoder(e.w)
+	return nil
+}
+
+// writeCSV writes one CSV record.
+func (e *exportStream) writeCSV(record []string) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if err := e.csv.Write(record); err != nil {
+		return err
+	}
+	return e.wrote()
+}
+
+// writeJSON writes v as one NDJSON line.
+func (e *exportStream) writeJSON(v interface{}) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if err := e.json.Encode(v); err != nil {
+		return err
+	}
+	return e.wrote()
+}
+
+// wrote counts a row, flushing after the first so the client sees the
+// export start, and then every exportFlushRows.
+func (e *exportStream) wrote() error {
+	e.rows++
+	if e.rows == 1 || e.rows%exportFlushRows == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exportStream) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := http.NewResponseController(e.w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// finish ends the export. A failure before any row went out is
+// answered with a problem; after that, the connection is dropped so the
+// client can't mistake a cut-off export for a complete one.
+func (e *exportStream) finish(err error) {
+	if err == nil {
+		if err = e.start(); err == nil {
+			err = e.flush()
+		}
+		if err == nil {
+			return
+		}
+	}
+	log.Ctx(e.r.Context()).Error().Err(err).Str("export", e.name).Int("rows", e.rows).Msg("failed to export")
+	if !e.started {
+		httpx.Render(e.w, e.r, httpx.InternalServerProblem(err))
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// Export streams every product's inventory, in SKU order, as CSV (one
+// record per location) or NDJSON (one product per line).
+//
+//	@Summary		Export all product inventory
+//	@Description	Streams the whole inventory from a single snapshot instead of a page at a time. The format is taken from the format parameter, then the Accept header, and defaults to NDJSON.
+//	@Tags			inventory
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Param			format	query		string	false	"export format, overriding Accept"	Enums(csv, ndjson)
+//	@Success		200		{array}		ProductInventory
+//	@Failure		400		{object}	httpx.Problem
+//	@Failure		401		{object}	httpx.Problem
+//	@Failure		500		{object}	httpx.Problem
+//	@Router			/api/v1/inventory/export [get]
+//	@Security		BearerAuth
+func (a *InventoryApi) Export(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateExport(r)
+	if err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	out := newExportStream(w, r, format, "inventory", inventoryExportColumns)
+	out.finish(a.service.ExportProductInventory(r.Context(), func(pi ProductInventory) error {
+		if format == exportNDJSON {
+			return out.writeJSON(pi)
+		}
+		for _, l := range pi.Locations {
+			err := out.writeCSV([]string{
+				pi.Sku, pi.Upc, pi.Name, string(pi.State), strconv.FormatInt(pi.Version, 10), l.Location,
+				strconv.FormatInt(l.Available, 10), strconv.FormatInt(l.InTransit, 10), strconv.FormatInt(l.Expired, 10),
+				strconv.FormatInt(l.OnHold, 10), strconv.FormatInt(l.Quarantined, 10), strconv.FormatInt(l.Damaged, 10),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// Export streams every reservation, optionally filtered by sku and
+// state, oldest first, as CSV or NDJSON.
+//
+//	@Summary		Export reservations
+//	@Description	Streams every matching reservation from a single snapshot instead of a page at a time. The format is taken from the format parameter, then the Accept header, and defaults to NDJSON.
+//	@Tags			reservation
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Param			sku		query		string	false	"filter by SKU"
+//	@Param			state	query		string	false	"filter by state"	Enums(Open, Closed, Fulfilled, Cancelled, Expired)
+//	@Param			format	query		string	false	"export format, overriding Accept"	Enums(csv, ndjson)
+//	@Success		200		{array}		Reservation
+//	@Failure		400		{object}	httpx.Problem
+//	@Failure		401		{object}	httpx.Problem
+//	@Failure		500		{object}	httpx.Problem
+//	@Router			/api/v1/reservation/export [get]
+//	@Security		BearerAuth
+func (a *ReservationApi) Export(w http.ResponseWriter, r *http.Request) {
+	format, err := negotiateExport(r)
+	if err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+	sku := r.URL.Query().Get("sku")
+	state, err := ParseReserveState(r.URL.Query().Get("state"))
+	if err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(errors.New("invalid state")))
+		return
+	}
+
+	out := newExportStream(w, r, format, "reservations", reservationExportColumns)
+	out.finish(a.service.ExportReservations(r.Context(), GetReservationsOptions{Sku: sku, State: state}, func(res Reservation) error {
+		if format == exportNDJSON {
+			return out.writeJSON(res)
+		}
+		expiresAt := ""
+		if res.ExpiresAt != nil {
+			expiresAt = res.ExpiresAt.Format(time.RFC3339Nano)
+		}
+		return out.writeCSV([]string{
+			strconv.FormatUint(res.ID, 10), res.RequestID, res.Requester, res.Sku, res.Location, string(res.State),
+			strconv.FormatInt(res.RequestedQuantity, 10), strconv.FormatInt(res.ReservedQuantity, 10), strconv.FormatInt(res.ShippedQuantity, 10),
+			strconv.Itoa(res.Priority), string(res.FillPolicy), strconv.FormatInt(res.MinQuantity, 10),
+			res.Created.Format(time.RFC3339Nano), expiresAt, strconv.FormatInt(res.Version, 10),
+		})
+	}))
+}
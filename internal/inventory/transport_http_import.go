@@ -0,0 +1,201 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+)
+
+// errUnsupportedImport is returned for an import body that is neither
+// CSV nor NDJSON.
+var errUnsupportedImport = errors.New("import body must be text/csv or application/x-ndjson")
+
+// errMalformedImport marks an import body that can't be read any
+// further, as opposed to a single row that can be skipped.
+var errMalformedImport = errors.New("malformed import")
+
+// importColumns are the CSV header names an import understands.
+// location is optional.
+var importColumns = []string{"sku", "upc", "name", "quantity", "location"}
+
+// ImportProducts creates products and their opening stock in bulk from
+// a CSV or NDJSON body, and reports the rows it left out.
+//
+//	@Summary		Bulk import products and opening balances
+//	@Description	Streams rows of sku, upc, name, quantity and an optional location, either as CSV with a header row or as one JSON object per line. Rows are checked like a single create and written in batches; rows that fail, repeat a SKU or UPC, or name an existing one are reported with their line number. With dryRun=true nothing is written.
+//	@Tags			inventory
+//	@Accept			text/csv
+//	@Accept			application/x-ndjson
+//	@Produce		json
+//	@Param			rows	body		string	true	"CSV or NDJSON rows"
+//	@Param			dryRun	query		bool	false	"check the rows without importing them"	default(false)
+//	@Success		200		{object}	ImportResponse
+//	@Failure		400		{object}	httpx.Problem
+//	@Failure		401		{object}	httpx.Problem
+//	@Failure		413		{object}	httpx.Problem
+//	@Failure		415		{object}	httpx.Problem
+//	@Failure		500		{object}	httpx.Problem
+//	@Router			/api/v1/inventory/import [post]
+//	@Security		BearerAuth
+func (a *InventoryApi) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			httpx.Render(w, r, httpx.BadRequestProblem(errors.New("dryRun must be true or false")))
+			return
+		}
+	}
+
+	rows, err := newProductImportReader(r)
+	if err != nil {
+		if errors.Is(err, errUnsupportedImport) {
+			httpx.Render(w, r, httpx.UnsupportedMediaTypeProblem(err))
+			return
+		}
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	result, err := a.service.ImportProducts(r.Context(), rows, dryRun)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpx.Render(w, r, httpx.RequestTooLargeProblem(tooLarge.Limit))
+			return
+		}
+		if errors.Is(err, errMalformedImport) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Bool("dryRun", dryRun).Int("rows", result.Rows).Int("imported", result.Imported).Msg("failed to import products")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &ImportResponse{ImportResult: result})
+}
+
+// newProductImportReader picks the reader for the request's body from
+// its Content-Type.
+func newProductImportReader(r *http.Request) (ProductImportReader, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, errUnsupportedImport
+	}
+	switch mediaType {
+	case "text/csv":
+		return newCSVImportReader(r.Body)
+	case "application/x-ndjson", "application/ndjson":
+		return newNDJSONImportReader(r.Body), nil
+	default:
+		return nil, errUnsupportedImport
+	}
+}
+
+// csvImportReader reads import rows from CSV with a header row naming
+// its columns, in any order.
+type csvImportReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVImportReader(body io.Reader) (*csvImportReader, error) {
+	r := csv.NewReader(body)
+	r.TrimLeadingSpace = true
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("import is empty; a header row is required")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range importColumns[:4] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("header is missing the %q column; want %s", name, strings.Join(importColumns, ","))
+		}
+	}
+	return &csvImportReader{r: r, columns: columns}, nil
+}
+
+func (c *csvImportReader) Read() (ProductImport, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return ProductImport{}, &ImportRowError{Line: parseErr.StartLine, Detail: parseErr.Err.Error()}
+		}
+		return ProductImport{}, err
+	}
+	line, _ := c.r.FieldPos(0)
+
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	row := ProductImport{
+		Line:     line,
+		Sku:      field("sku"),
+		Upc:      field("upc"),
+		Name:     field("name"),
+		Location: field("location"),
+	}
+	if q := field("quantity"); q != "" {
+		if row.Quantity, err = strconv.ParseInt(q, 10, 64); err != nil {
+			return ProductImport{}, &ImportRowError{Line: line, Sku: row.Sku, Detail: fmt.Sprintf("quantity %q is not a whole number", q)}
+		}
+	}
+	return row, nil
+}
+
+// ndjsonImportReader reads import rows from one JSON object per line.
+// Blank lines are skipped.
+type ndjsonImportReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newNDJSONImportReader(body io.Reader) *ndjsonImportReader {
+	return &ndjsonImportReader{s: bufio.NewScanner(body)}
+}
+
+func (n *ndjsonImportReader) Read() (ProductImport, error) {
+	for n.s.Scan() {
+		n.line++
+		text := strings.TrimSpace(n.s.Text())
+		if text == "" {
+			continue
+		}
+		row := ProductImport{}
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			return ProductImport{}, &ImportRowError{Line: n.line, Detail: err.Error()}
+		}
+		row.Line = n.line
+		return row, nil
+	}
+	if err := n.s.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return ProductImport{}, fmt.Errorf("line %d is too long: %w", n.line+1, errMalformedImport)
+		}
+		return ProductImport{}, err
+	}
+	return ProductImport{}, io.EOF
+}
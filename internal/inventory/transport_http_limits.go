@@ -0,0 +1,163 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+	"github.com/sksmith/go-micro-example/internal/platform/persistence"
+)
+
+// configureLimitsRouter mounts the requester limit routes under
+// /reservation/limits. The caller restricts them to admins.
+func (ra *ReservationApi) configureLimitsRouter(r chi.Router) {
+	r.With(httpx.Paginate).Get("/", ra.ListRequesterLimits)
+	r.Get("/{requester}", ra.GetRequesterLimits)
+	r.Put("/{requester}", ra.PutRequesterLimits)
+	r.Delete("/{requester}", ra.DeleteRequesterLimits)
+}
+
+// requesterLimitProblem is the 422 for a reservation its requester's
+// limits refused, with an errors entry naming the limit that was hit.
+func requesterLimitProblem(err *RequesterLimitError) *httpx.Problem {
+	p := httpx.UnprocessableEntityProblem(err)
+	p.Errors = []httpx.FieldProblem{{
+		Field:  string(err.Limit),
+		Detail: fmt.Sprintf("limit %d, %d already held, %d requested", err.Max, err.Current, err.Requested),
+	}}
+	return p
+}
+
+// ListRequesterLimits returns a page of requester limits.
+//
+//	@Summary	List requester limits
+//	@Tags		reservation
+//	@Produce	json
+//	@Param		limit	query		int		false	"max items per page (≤ 200)"	default(50)
+//	@Param		offset	query		int		false	"page offset"					default(0)
+//	@Success	200		{array}		RequesterLimitsResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	Link	"RFC 8288 next/prev links"
+//	@Router		/api/v1/reservation/limits [get]
+//	@Security	BearerAuth
+func (ra *ReservationApi) ListRequesterLimits(w http.ResponseWriter, r *http.Request) {
+	p := httpx.PaginationFrom(r.Context())
+
+	limits, err := ra.service.GetAllRequesterLimits(r.Context(), p.Limit, p.Offset)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("failed to list requester limits")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.WriteLinkHeader(w, r, p, len(limits))
+	httpx.RenderList(w, r, NewRequesterLimitsListResponse(limits))
+}
+
+// GetRequesterLimits returns the limits set for one requester. The
+// defaults for requesters without their own are under "*".
+//
+//	@Summary	Get a requester's limits
+//	@Tags		reservation
+//	@Produce	json
+//	@Param		requester	path		string	true	"requester, or * for the defaults"
+//	@Success	200			{object}	RequesterLimitsResponse
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/reservation/limits/{requester} [get]
+//	@Security	BearerAuth
+func (ra *ReservationApi) GetRequesterLimits(w http.ResponseWriter, r *http.Request) {
+	requester := chi.URLParam(r, "requester")
+
+	limits, err := ra.service.GetRequesterLimits(r.Context(), requester)
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("requester", requester).Msg("failed to get requester limits")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &RequesterLimitsResponse{RequesterLimits: limits})
+}
+
+// PutRequesterLimits sets a requester's reservation limits. A zero
+// limit is unlimited.
+//
+//	@Summary	Set a requester's limits
+//	@Tags		reservation
+//	@Accept		json
+//	@Produce	json
+//	@Param		requester	path		string						true	"requester, or * for the defaults"
+//	@Param		limits		body		RequesterLimitsRequestDto	true	"requester limits"
+//	@Success	200			{object}	RequesterLimitsResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/reservation/limits/{requester} [put]
+//	@Security	BearerAuth
+func (ra *ReservationApi) PutRequesterLimits(w http.ResponseWriter, r *http.Request) {
+	requester := chi.URLParam(r, "requester")
+
+	data := &RequesterLimitsRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	limits, err := ra.service.SetRequesterLimits(r.Context(), RequesterLimits{
+		Requester:           requester,
+		Class:               data.Class,
+		MaxReservedPerSku:   data.MaxReservedPerSku,
+		MaxOpenReservations: data.MaxOpenReservations,
+		DailyCap:            data.DailyCap,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("requester", requester).Msg("failed to set requester limits")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &RequesterLimitsResponse{RequesterLimits: limits})
+}
+
+// DeleteRequesterLimits removes a requester's own limits, leaving it
+// under the defaults.
+//
+//	@Summary	Delete a requester's limits
+//	@Tags		reservation
+//	@Param		requester	path	string	true	"requester, or * for the defaults"
+//	@Success	204
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/reservation/limits/{requester} [delete]
+//	@Security	BearerAuth
+func (ra *ReservationApi) DeleteRequesterLimits(w http.ResponseWriter, r *http.Request) {
+	requester := chi.URLParam(r, "requester")
+
+	if err := ra.service.DeleteRequesterLimits(r.Context(), requester); err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("requester", requester).Msg("failed to delete requester limits")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package inventory
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+)
+
+// maxSearchLength caps `search`; nothing longer can match a product
+// name.
+const maxSearchLength = 100
+
+// parseProductListOptions reads the inventory list's filter and sort
+// params. `sort` names a column, optionally prefixed with "-" for
+// descending order, and the `available_*` bounds are folded into an
+// inclusive range. Every invalid param is reported at once.
+func parseProductListOptions(q url.Values) (ProductListOptions, *httpx.Problem) {
+	var (
+		options ProductListOptions
+		fields  []httpx.FieldProblem
+	)
+
+	options.Search = strings.TrimSpace(q.Get("search"))
+	if len(options.Search) > maxSearchLength {
+		fields = append(fields, httpx.FieldProblem{Field: "search", Detail: fmt.Sprintf("must be at most %d characters", maxSearchLength)})
+	}
+
+	if v := q.Get("sort"); v != "" {
+		sort := ProductSort(strings.TrimPrefix(v, "-"))
+		switch sort {
+		case SortBySku, SortByName, SortByAvailable:
+			options.Sort = sort
+			options.Desc = strings.HasPrefix(v, "-")
+		default:
+			fields = append(fields, httpx.FieldProblem{Field: "sort", Detail: "must be sku, name or available, optionally prefixed with -"})
+		}
+	}
+
+	for _, bound := range []struct {
+		param  string
+		adjust int64
+		dest   **int64
+		tight  func(n, cur int64) bool
+	}{
+		{"available_gte", 0, &options.AvailableMin, func(n, cur int64) bool { return n > cur }},
+		{"available_gt", 1, &options.AvailableMin, func(n, cur int64) bool { return n > cur }},
+		{"available_lte", 0, &options.AvailableMax, func(n, cur int64) bool { return n < cur }},
+		{"available_lt", -1, &options.AvailableMax, func(n, cur int64) bool { return n < cur }},
+	} {
+		v := q.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			fields = append(fields, httpx.FieldProblem{Field: bound.param, Detail: "must be an integer"})
+			continue
+		}
+		// Turning a strict bound inclusive must not wrap around, which
+		// would silently flip the filter into its opposite.
+		if (bound.adjust > 0 && n > math.MaxInt64-bound.adjust) || (bound.adjust < 0 && n < math.MinInt64-bound.adjust) {
+			fields = append(fields, httpx.FieldProblem{Field: bound.param, Detail: "is out of range"})
+			continue
+		}
+		n += bound.adjust
+		if *bound.dest == nil || bound.tight(n, **bound.dest) {
+			*bound.dest = &n
+		}
+	}
+
+	if len(fields) > 0 {
+		return ProductListOptions{}, httpx.ValidationProblem(fields...)
+	}
+	return options, nil
+}
+
+// productSortParam is the `sort` param that asks for options' order,
+// empty for the default of SKU ascending.
+func productSortParam(options ProductListOptions) string {
+	if options.Sort == "" || (options.Sort == SortBySku && !options.Desc) {
+		return ""
+	}
+	if options.Desc {
+		return "-" + string(options.Sort)
+	}
+	return string(options.Sort)
+}
@@ -0,0 +1,77 @@
+package inventory
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+)
+
+// configureLotRouter mounts the lot routes under /inventory/{sku}/lots.
+// Lots are created by production, so these are read-only.
+func (a *InventoryApi) configureLotRouter(r chi.Router) {
+	r.Get("/", a.GetLots)
+	r.With(httpx.Paginate).Get("/{lot}/recipients", a.GetLotRecipients)
+}
+
+// GetLots returns a SKU's lots that still hold stock, first-expiring
+// first, which is the order reservations draw from them.
+//
+//	@Summary	List a SKU's lots
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku	path		string	true	"product SKU"
+//	@Success	200	{array}		LotResponse
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/lots [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) GetLots(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	lots, err := a.service.GetLots(r.Context(), product.Sku)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to get lots")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.RenderList(w, r, NewLotListResponse(lots))
+}
+
+// GetLotRecipients answers a recall: a page of the reservations that
+// drew stock from a lot and how much of it each still holds or has
+// shipped.
+//
+//	@Summary	List the reservations that drew from a lot
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku		path		string	true	"product SKU"
+//	@Param		lot		path		string	true	"lot number"
+//	@Param		limit	query		int		false	"max items per page (≤ 200)"	default(50)
+//	@Param		offset	query		int		false	"page offset"					default(0)
+//	@Success	200		{array}		LotRecipientResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	Link	"RFC 8288 next/prev links"
+//	@Router		/api/v1/inventory/{sku}/lots/{lot}/recipients [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) GetLotRecipients(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+	lot := chi.URLParam(r, "lot")
+	p := httpx.PaginationFrom(r.Context())
+
+	recipients, err := a.service.GetLotRecipients(r.Context(), product.Sku, lot, p.Limit, p.Offset)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Str("lot", lot).Msg("failed to get lot recipients")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.WriteLinkHeader(w, r, p, len(recipients))
+	httpx.RenderList(w, r, NewLotRecipientListResponse(recipients))
+}
@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/auth"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+	"github.com/sksmith/go-micro-example/internal/platform/persistence"
+)
+
+// configurePlannedRouter mounts the planned production routes under
+// /inventory/{sku}/planned. The production schedule is kept by
+// planners, so changing it is limited to admins and inventory managers.
+func (a *InventoryApi) configurePlannedRouter(r chi.Router) {
+	r.With(httpx.Paginate).Get("/", a.ListPlannedOrders)
+	plan := auth.InventoryManagerOnly(http.HandlerFunc(a.CreatePlannedOrder))
+	if a.idempotency != nil {
+		plan = a.idempotency(plan)
+	}
+	r.Method(http.MethodPut, "/", plan)
+	r.Method(http.MethodDelete, "/{requestID}", auth.InventoryManagerOnly(http.HandlerFunc(a.CancelPlannedOrder)))
+}
+
+// CreatePlannedOrder schedules production of a SKU for an expected
+// date so it counts towards available-to-promise.
+//
+//	@Summary	Plan production of a SKU
+//	@Tags		inventory
+//	@Accept		json
+//	@Produce	json
+//	@Param		sku		path		string					true	"product SKU"
+//	@Param		planned	body		PlannedOrderRequestDto	true	"planned production order"
+//	@Success	201		{object}	PlannedOrderResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	409		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/planned [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) CreatePlannedOrder(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	data := &PlannedOrderRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	o, err := a.service.PlanProduction(r.Context(), product, *data.PlannedOrderRequest)
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Str("requestId", data.RequestID).Msg("failed to plan production")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	httpx.Render(w, r, &PlannedOrderResponse{PlannedOrder: o})
+}
+
+// ListPlannedOrders returns a page of a SKU's scheduled production,
+// earliest expected first.
+//
+//	@Summary	List scheduled production for a SKU
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku		path		string	true	"product SKU"
+//	@Param		limit	query		int		false	"max items per page (≤ 200)"	default(50)
+//	@Param		offset	query		int		false	"page offset"					default(0)
+//	@Success	200		{array}		PlannedOrderResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	Link	"RFC 8288 next/prev links"
+//	@Router		/api/v1/inventory/{sku}/planned [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) ListPlannedOrders(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+	p := httpx.PaginationFrom(r.Context())
+
+	orders, err := a.service.GetScheduledOrders(r.Context(), product.Sku, p.Limit, p.Offset)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to get planned orders")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.WriteLinkHeader(w, r, p, len(orders))
+	httpx.RenderList(w, r, NewPlannedOrderListResponse(orders))
+}
+
+// CancelPlannedOrder takes a scheduled production order off the plan.
+//
+//	@Summary	Cancel planned production
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku			path		string	true	"product SKU"
+//	@Param		requestID	path		string	true	"request ID the order was planned with"
+//	@Success	200			{object}	PlannedOrderResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/planned/{requestID} [delete]
+//	@Security	BearerAuth
+func (a *InventoryApi) CancelPlannedOrder(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+	requestID := chi.URLParam(r, "requestID")
+
+	o, err := a.service.CancelPlannedOrder(r.Context(), product.Sku, requestID)
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Str("requestId", requestID).Msg("failed to cancel planned order")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &PlannedOrderResponse{PlannedOrder: o})
+}
+
+// GetAvailableToPromise returns the earliest date a quantity of a SKU
+// can be promised, from current stock across every location, open
+// reservations' shortfall and scheduled production. Products that
+// aren't Active get a 409.
+//
+//	@Summary	Get available-to-promise for a SKU
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku			path		string	true	"product SKU"
+//	@Param		quantity	query		int		true	"quantity to promise"
+//	@Success	200			{object}	AvailableToPromiseResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	409			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/atp [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) GetAvailableToPromise(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	quantity, err := strconv.ParseInt(r.URL.Query().Get("quantity"), 10, 64)
+	if err != nil || quantity < 1 {
+		httpx.Render(w, r, httpx.BadRequestProblem(errors.New("quantity must be a whole number greater than zero")))
+		return
+	}
+
+	atp, err := a.service.AvailableToPromise(r.Context(), product.Sku, quantity)
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		if errors.Is(err, persistence.ErrNotFound) {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Int64("quantity", quantity).Msg("failed to get available to promise")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &AvailableToPromiseResponse{AvailableToPromise: atp})
+}
@@ -0,0 +1,153 @@
+package inventory
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/auth"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+	"github.com/sksmith/go-micro-example/internal/platform/persistence"
+)
+
+// configureReorderRouter mounts the reorder policy routes under
+// /inventory/{sku}/reorder. Reorder points are set by purchasing, so
+// changing them is limited to admins and inventory managers.
+func (a *InventoryApi) configureReorderRouter(r chi.Router) {
+	r.Get("/", a.GetReorderPolicy)
+	r.Method(http.MethodPut, "/", auth.InventoryManagerOnly(http.HandlerFunc(a.PutReorderPolicy)))
+	r.Method(http.MethodDelete, "/", auth.InventoryManagerOnly(http.HandlerFunc(a.DeleteReorderPolicy)))
+}
+
+// GetReorderPolicy returns a SKU's reorder point and target level.
+//
+//	@Summary	Get a SKU's reorder policy
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku	path		string	true	"product SKU"
+//	@Success	200	{object}	ReorderPolicyResponse
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/reorder [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) GetReorderPolicy(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	policy, err := a.service.GetReorderPolicy(r.Context(), product.Sku)
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to get reorder policy")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &ReorderPolicyResponse{ReorderPolicy: policy})
+}
+
+// PutReorderPolicy sets the level below which a SKU raises a low-stock
+// alert and the level a reorder should bring it back up to.
+//
+//	@Summary	Set a SKU's reorder policy
+//	@Tags		inventory
+//	@Accept		json
+//	@Produce	json
+//	@Param		sku		path		string					true	"product SKU"
+//	@Param		policy	body		ReorderPolicyRequestDto	true	"reorder point and target level"
+//	@Success	200		{object}	ReorderPolicyResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	409		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/reorder [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) PutReorderPolicy(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	data := &ReorderPolicyRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	policy, err := a.service.SetReorderPolicy(r.Context(), ReorderPolicy{
+		Sku:          product.Sku,
+		ReorderPoint: data.ReorderPoint,
+		TargetLevel:  data.TargetLevel,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to set reorder policy")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.Render(w, r, &ReorderPolicyResponse{ReorderPolicy: policy})
+}
+
+// DeleteReorderPolicy stops low-stock alerts for a SKU.
+//
+//	@Summary	Remove a SKU's reorder policy
+//	@Tags		inventory
+//	@Param		sku	path	string	true	"product SKU"
+//	@Success	204
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/reorder [delete]
+//	@Security	BearerAuth
+func (a *InventoryApi) DeleteReorderPolicy(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	if err := a.service.DeleteReorderPolicy(r.Context(), product.Sku); err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to delete reorder policy")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LowStock lists the SKUs whose available stock is below their reorder
+// point, with the quantity needed to bring each back to its target level.
+//
+//	@Summary	List SKUs below their reorder point
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		limit	query		int	false	"max items per page (≤ 200)"	default(50)
+//	@Param		offset	query		int	false	"page offset"					default(0)
+//	@Success	200		{array}		LowStockResponse
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Router		/api/v1/inventory/low-stock [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) LowStock(w http.ResponseWriter, r *http.Request) {
+	p := httpx.PaginationFrom(r.Context())
+
+	low, err := a.service.GetLowStock(r.Context(), p.Limit, p.Offset)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Int("limit", p.Limit).Int("offset", p.Offset).Msg("failed to list low stock")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.WriteLinkHeader(w, r, p, len(low))
+	httpx.RenderList(w, r, NewLowStockListResponse(low))
+}
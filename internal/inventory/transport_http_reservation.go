@@ -11,15 +11,27 @@ import (
 	"github.com/go-chi/render"
 	"github.com/gobwas/ws"
 	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/auth"
 	"github.com/sksmith/go-micro-example/internal/platform/httpx"
 	"github.com/sksmith/go-micro-example/internal/platform/persistence"
 )
 
 type ReservationService interface {
 	Reserve(ctx context.Context, rr ReservationRequest) (Reservation, error)
+	Cancel(ctx context.Context, ID uint64) (Reservation, error)
+	Modify(ctx context.Context, ID uint64, ru ReservationUpdate) (Reservation, error)
+	Ship(ctx context.Context, ID uint64, sr ShipmentRequest) (Shipment, error)
 
 	GetReservations(ctx context.Context, options GetReservationsOptions, limit, offset int) ([]Reservation, error)
+	GetReservationsAfter(ctx context.Context, options GetReservationsOptions, after *ReservationKey, limit int) ([]Reservation, error)
+	ExportReservations(ctx context.Context, options GetReservationsOptions, fn func(Reservation) error) error
 	GetReservation(ctx context.Context, ID uint64) (Reservation, error)
+	GetBackorders(ctx context.Context, sku string, limit, offset int) ([]Backorder, error)
+
+	GetRequesterLimits(ctx context.Context, requester string) (RequesterLimits, error)
+	GetAllRequesterLimits(ctx context.Context, limit, offset int) ([]RequesterLimits, error)
+	SetRequesterLimits(ctx context.Context, limits RequesterLimits) (RequesterLimits, error)
+	DeleteRequesterLimits(ctx context.Context, requester string) error
 
 	SubscribeReservations(ch chan<- Reservation) (id ReservationsSubID)
 	UnsubscribeReservations(id ReservationsSubID)
@@ -35,8 +47,8 @@ func NewReservationApi(service ReservationService) *ReservationApi {
 }
 
 // SetIdempotency installs the optional Idempotency-Key middleware
-// (DSN-019) on the reservation Create route. nil disables the
-// middleware entirely.
+// (DSN-019) on the reservation Create and shipment routes. nil
+// disables the middleware entirely.
 func (a *ReservationApi) SetIdempotency(mw func(http.Handler) http.Handler) {
 	a.idempotency = mw
 }
@@ -56,11 +68,21 @@ func (ra *ReservationApi) ConfigureRouter(r chi.Router) {
 		} else {
 			r.Put("/", create.ServeHTTP)
 		}
+		r.With(httpx.Paginate).Get("/backorders", ra.Backorders)
+		r.Get("/export", ra.Export)
+		r.With(auth.AdminOnly).Route("/limits", ra.configureLimitsRouter)
 
 		r.Route("/{ID}", func(r chi.Router) {
 			r.Use(ra.ReservationCtx)
 			r.Get("/", ra.Get)
+			r.Patch("/", ra.Modify)
 			r.Delete("/", ra.Cancel)
+			ship := http.HandlerFunc(ra.Ship)
+			if ra.idempotency != nil {
+				r.Method(http.MethodPut, "/shipment", ra.idempotency(ship))
+			} else {
+				r.Put("/shipment", ship.ServeHTTP)
+			}
 		})
 	})
 }
@@ -110,16 +132,24 @@ func streamReservationsToClient(svc ReservationService, writer textWriter) {
 //	@Tags		reservation
 //	@Produce	json
 //	@Param		ID	path		int	true	"reservation ID"
+//	@Param		If-None-Match	header		string	false	"answer 304 if the reservation is still at this ETag"
 //	@Success	200	{object}	ReservationResponse
+//	@Success	304	{string}	string	"not modified"
 //	@Failure	400	{object}	httpx.Problem
 //	@Failure	401	{object}	httpx.Problem
 //	@Failure	404	{object}	httpx.Problem
 //	@Failure	500	{object}	httpx.Problem
+//	@Header		200	{string}	ETag	"the reservation's current version"
 //	@Router		/api/v1/reservation/{ID} [get]
 //	@Security	BearerAuth
 func (a *ReservationApi) Get(w http.ResponseWriter, r *http.Request) {
 	res := r.Context().Value(CtxKeyReservation).(Reservation)
 
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(res.Version)) {
+		return
+	}
+	w.Header().Set("ETag", httpx.ETag(res.Version))
+
 	resp := &ReservationResponse{Reservation: res}
 	render.Status(r, http.StatusOK)
 	httpx.Render(w, r, resp)
@@ -136,6 +166,8 @@ func (a *ReservationApi) Get(w http.ResponseWriter, r *http.Request) {
 //	@Failure	400			{object}	httpx.Problem
 //	@Failure	401			{object}	httpx.Problem
 //	@Failure	404			{object}	httpx.Problem
+//	@Failure	409			{object}	httpx.Problem
+//	@Failure	422			{object}	httpx.Problem
 //	@Failure	500			{object}	httpx.Problem
 //	@Router		/api/v1/reservation [post]
 //	@Security	BearerAuth
@@ -148,11 +180,16 @@ func (a *ReservationApi) Create(w http.ResponseWriter, r *http.Request) {
 
 	res, err := a.service.Reserve(r.Context(), *data.ReservationRequest)
 	if err != nil {
+		var limitErr *RequesterLimitError
 		switch {
+		case errors.As(err, &limitErr):
+			httpx.Render(w, r, requesterLimitProblem(limitErr))
 		case errors.Is(err, persistence.ErrNotFound):
 			httpx.Render(w, r, httpx.NotFoundProblem())
 		case errors.Is(err, ErrInvalidInput):
 			httpx.Render(w, r, httpx.BadRequestProblem(err))
+		case errors.Is(err, ErrInsufficientStock), errors.Is(err, ErrProductState):
+			httpx.Render(w, r, httpx.ConflictProblem(err))
 		default:
 			log.Ctx(r.Context()).Error().Err(err).Interface("reservationRequest", data).Msg("failed to reserve")
 			httpx.Render(w, r, httpx.InternalServerProblem(err))
@@ -165,8 +202,158 @@ func (a *ReservationApi) Create(w http.ResponseWriter, r *http.Request) {
 	httpx.Render(w, r, resp)
 }
 
-func (a *ReservationApi) Cancel(_ http.ResponseWriter, _ *http.Request) {
-	// TODO Not implemented
+// Cancel backs out a reservation and releases its reserved quantity.
+//
+//	@Summary	Cancel a reservation
+//	@Tags		reservation
+//	@Produce	json
+//	@Param		ID	path		int	true	"reservation ID"
+//	@Param		If-Match	header		string	false	"apply only if the reservation is still at this ETag"
+//	@Success	200	{object}	ReservationResponse
+//	@Failure	400	{object}	httpx.Problem
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	412	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Header		200	{string}	ETag	"the reservation's new version"
+//	@Router		/api/v1/reservation/{ID} [delete]
+//	@Security	BearerAuth
+func (a *ReservationApi) Cancel(w http.ResponseWriter, r *http.Request) {
+	rsv := r.Context().Value(CtxKeyReservation).(Reservation)
+
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(rsv.Version)) {
+		return
+	}
+
+	res, err := a.service.Cancel(conditional(r), rsv.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, persistence.ErrNotFound):
+			httpx.Render(w, r, httpx.NotFoundProblem())
+		case errors.Is(err, ErrInvalidInput):
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+		case errors.Is(err, persistence.ErrVersionConflict):
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+		default:
+			log.Ctx(r.Context()).Error().Err(err).Uint64("id", rsv.ID).Msg("failed to cancel reservation")
+			httpx.Render(w, r, httpx.InternalServerProblem(err))
+		}
+		return
+	}
+
+	w.Header().Set("ETag", httpx.ETag(res.Version))
+	resp := &ReservationResponse{Reservation: res}
+	render.Status(r, http.StatusOK)
+	httpx.Render(w, r, resp)
+}
+
+// Modify raises or lowers an Open or Closed reservation's requested
+// quantity.
+//
+//	@Summary	Change a reservation's quantity
+//	@Tags		reservation
+//	@Accept		json
+//	@Produce	json
+//	@Param		ID		path		int						true	"reservation ID"
+//	@Param		update	body		ReservationUpdateDto	true	"new requested quantity"
+//	@Param		If-Match	header		string	false	"apply only if the reservation is still at this ETag"
+//	@Success	200		{object}	ReservationResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	412		{object}	httpx.Problem
+//	@Failure	422		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	ETag	"the reservation's new version"
+//	@Router		/api/v1/reservation/{ID} [patch]
+//	@Security	BearerAuth
+func (a *ReservationApi) Modify(w http.ResponseWriter, r *http.Request) {
+	rsv := r.Context().Value(CtxKeyReservation).(Reservation)
+
+	data := &ReservationUpdateDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(rsv.Version)) {
+		return
+	}
+
+	res, err := a.service.Modify(conditional(r), rsv.ID, *data.ReservationUpdate)
+	if err != nil {
+		var limitErr *RequesterLimitError
+		switch {
+		case errors.As(err, &limitErr):
+			httpx.Render(w, r, requesterLimitProblem(limitErr))
+		case errors.Is(err, persistence.ErrNotFound):
+			httpx.Render(w, r, httpx.NotFoundProblem())
+		case errors.Is(err, ErrInvalidInput):
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+		case errors.Is(err, persistence.ErrVersionConflict):
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+		default:
+			log.Ctx(r.Context()).Error().Err(err).Uint64("id", rsv.ID).Int64("quantity", data.Quantity).Msg("failed to modify reservation")
+			httpx.Render(w, r, httpx.InternalServerProblem(err))
+		}
+		return
+	}
+
+	w.Header().Set("ETag", httpx.ETag(res.Version))
+	resp := &ReservationResponse{Reservation: res}
+	render.Status(r, http.StatusOK)
+	httpx.Render(w, r, resp)
+}
+
+// Ship records an outbound shipment against a Closed reservation.
+//
+//	@Summary	Ship a reservation
+//	@Tags		reservation
+//	@Accept		json
+//	@Produce	json
+//	@Param		ID			path		int					true	"reservation ID"
+//	@Param		shipment	body		ShipmentRequestDto	true	"shipment request"
+//	@Param		If-Match	header		string	false	"apply only if the reservation is still at this ETag"
+//	@Success	201			{object}	ShipmentResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	412			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/reservation/{ID}/shipment [put]
+//	@Security	BearerAuth
+func (a *ReservationApi) Ship(w http.ResponseWriter, r *http.Request) {
+	rsv := r.Context().Value(CtxKeyReservation).(Reservation)
+
+	data := &ShipmentRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(rsv.Version)) {
+		return
+	}
+
+	shipment, err := a.service.Ship(conditional(r), rsv.ID, *data.ShipmentRequest)
+	if err != nil {
+		switch {
+		case errors.Is(err, persistence.ErrNotFound):
+			httpx.Render(w, r, httpx.NotFoundProblem())
+		case errors.Is(err, ErrInvalidInput):
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+		case errors.Is(err, persistence.ErrVersionConflict):
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+		default:
+			log.Ctx(r.Context()).Error().Err(err).Uint64("id", rsv.ID).Str("requestId", data.RequestID).Msg("failed to ship reservation")
+			httpx.Render(w, r, httpx.InternalServerProblem(err))
+		}
+		return
+	}
+
+	resp := &ShipmentResponse{Shipment: shipment}
+	render.Status(r, http.StatusCreated)
+	httpx.Render(w, r, resp)
 }
 
 func (a *ReservationApi) ReservationCtx(next http.Handler) http.Handler {
@@ -208,9 +395,10 @@ func (a *ReservationApi) ReservationCtx(next http.Handler) http.Handler {
 //	@Tags		reservation
 //	@Produce	json
 //	@Param		sku		query		string	false	"filter by SKU"
-//	@Param		state	query		string	false	"filter by state"	Enums(Open, Closed)
+//	@Param		state	query		string	false	"filter by state"	Enums(Open, Closed, Fulfilled, Cancelled, Expired)
 //	@Param		limit	query		int		false	"max items per page (≤ 200)"	default(50)
 //	@Param		offset	query		int		false	"page offset"					default(0)
+//	@Param		cursor	query		string	false	"keyset cursor from a next link; empty for the first page"
 //	@Success	200		{array}		ReservationResponse
 //	@Failure	400		{object}	httpx.Problem
 //	@Failure	401		{object}	httpx.Problem
@@ -230,7 +418,18 @@ func (a *ReservationApi) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := a.service.GetReservations(r.Context(), GetReservationsOptions{Sku: sku, State: state}, p.Limit, p.Offset)
+	options := GetReservationsOptions{Sku: sku, State: state}
+	var res []Reservation
+	if p.Keyset {
+		var after *ReservationKey
+		if after, err = reservationsAfter(p); err != nil {
+			httpx.Render(w, r, httpx.InvalidCursorProblem())
+			return
+		}
+		res, err = a.service.GetReservationsAfter(r.Context(), options, after, p.Limit)
+	} else {
+		res, err = a.service.GetReservations(r.Context(), options, p.Limit, p.Offset)
+	}
 	if err != nil {
 		if errors.Is(err, persistence.ErrNotFound) {
 			httpx.Render(w, r, httpx.NotFoundProblem())
@@ -241,9 +440,47 @@ func (a *ReservationApi) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if p.Keyset && len(res) > 0 {
+		last := res[len(res)-1]
+		p.NextCursor, err = httpx.EncodeCursor(reservationCursor{Created: last.Created, ID: last.ID})
+		if err != nil {
+			httpx.Render(w, r, httpx.InternalServerProblem(err))
+			return
+		}
+	}
 	httpx.WriteLinkHeader(w, r, p, len(res))
 
 	resList := NewReservationListResponse(res)
 	render.Status(r, http.StatusOK)
 	httpx.RenderList(w, r, resList)
 }
+
+// Backorders totals, per SKU, the open reservations still waiting on
+// stock, biggest shortfall first.
+//
+//	@Summary	List unfilled demand per SKU
+//	@Tags		reservation
+//	@Produce	json
+//	@Param		sku		query		string	false	"only this SKU"
+//	@Param		limit	query		int		false	"max items per page (≤ 200)"	default(50)
+//	@Param		offset	query		int		false	"page offset"					default(0)
+//	@Success	200		{array}		BackorderResponse
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	Link	"RFC 8288 next/prev links"
+//	@Router		/api/v1/reservation/backorders [get]
+//	@Security	BearerAuth
+func (a *ReservationApi) Backorders(w http.ResponseWriter, r *http.Request) {
+	p := httpx.PaginationFrom(r.Context())
+	sku := r.URL.Query().Get("sku")
+
+	backorders, err := a.service.GetBackorders(r.Context(), sku, p.Limit, p.Offset)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", sku).Msg("failed to list backorders")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.WriteLinkHeader(w, r, p, len(backorders))
+	httpx.RenderList(w, r, NewBackorderListResponse(backorders))
+}
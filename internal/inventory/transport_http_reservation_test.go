@@ -4,18 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/sksmith/go-micro-example/internal/auth"
 	"github.com/sksmith/go-micro-example/internal/inventory"
 	"github.com/sksmith/go-micro-example/internal/platform/httpx"
 	"github.com/sksmith/go-micro-example/internal/platform/persistence"
 	"github.com/sksmith/go-micro-example/internal/testutil"
+	"github.com/sksmith/go-micro-example/internal/user"
 )
 
 // TestReservationSubscribe_StreamsThenUnsubscribes is the OPS-009
@@ -211,6 +216,8 @@ func TestReservationGetBadID(t *testing.T) {
 func TestReservationCreate(t *testing.T) {
 	ts, mockResSvc := setupReservationTestServer()
 	defer ts.Close()
+	insufficient := fmt.Errorf("1 of \"sku1\" requested at default but 0 available: %w", inventory.ErrInsufficientStock)
+	overLimit := &inventory.RequesterLimitError{Requester: "requester1", Class: "default", Sku: "sku1", Limit: inventory.LimitReservedPerSku, Max: 10, Current: 10, Requested: 1}
 
 	tests := []struct {
 		reserveFunc    func(ctx context.Context, rr inventory.ReservationRequest) (inventory.Reservation, error)
@@ -237,6 +244,24 @@ func TestReservationCreate(t *testing.T) {
 			wantErr:        httpx.NotFoundProblem(),
 			wantStatusCode: http.StatusNotFound,
 		},
+		{
+			reserveFunc: func(ctx context.Context, rr inventory.ReservationRequest) (inventory.Reservation, error) {
+				return inventory.Reservation{}, insufficient
+			},
+			request:        createReservationRequest("requestid1", "requester1", "sku1", 1),
+			wantResponse:   nil,
+			wantErr:        httpx.ConflictProblem(insufficient),
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			reserveFunc: func(ctx context.Context, rr inventory.ReservationRequest) (inventory.Reservation, error) {
+				return inventory.Reservation{}, fmt.Errorf("check limits: %w", overLimit)
+			},
+			request:        createReservationRequest("requestid1", "requester1", "sku1", 1),
+			wantResponse:   nil,
+			wantErr:        httpx.UnprocessableEntityProblem(overLimit),
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
 		{
 			reserveFunc: func(ctx context.Context, rr inventory.ReservationRequest) (inventory.Reservation, error) {
 				return inventory.Reservation{}, errors.New("some unexpected error")
@@ -279,6 +304,342 @@ func TestReservationCreate(t *testing.T) {
 	}
 }
 
+func TestReservationCancel(t *testing.T) {
+	ts, mockResSvc := setupReservationTestServer()
+	defer ts.Close()
+
+	cancelled := getTestReservations()[1]
+	cancelled.State = inventory.Cancelled
+	cancelled.ReservedQuantity = 0
+
+	tests := []struct {
+		cancelFunc     func(ctx context.Context, ID uint64) (inventory.Reservation, error)
+		wantResponse   *inventory.ReservationResponse
+		wantErr        *httpx.Problem
+		wantStatusCode int
+	}{
+		{
+			cancelFunc: func(ctx context.Context, ID uint64) (inventory.Reservation, error) {
+				if ID != 2 {
+					t.Errorf("cancel id got=%d want=%d", ID, 2)
+				}
+				return cancelled, nil
+			},
+			wantResponse:   &inventory.ReservationResponse{Reservation: cancelled},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			cancelFunc: func(ctx context.Context, ID uint64) (inventory.Reservation, error) {
+				return inventory.Reservation{}, persistence.ErrNotFound
+			},
+			wantErr:        httpx.NotFoundProblem(),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			cancelFunc: func(ctx context.Context, ID uint64) (inventory.Reservation, error) {
+				return inventory.Reservation{}, errors.New("some unexpected error")
+			},
+			wantErr:        httpx.InternalServerProblem(nil),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		mockResSvc.GetReservationFunc = func(ctx context.Context, ID uint64) (inventory.Reservation, error) {
+			return getTestReservations()[1], nil
+		}
+		mockResSvc.CancelFunc = test.cancelFunc
+
+		res := testutil.SendRequest(http.MethodDelete, ts.URL+"/2", nil, t)
+
+		if res.StatusCode != test.wantStatusCode {
+			t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+		}
+
+		if test.wantErr == nil {
+			got := inventory.ReservationResponse{}
+			testutil.Unmarshal(res, &got, t)
+
+			if !reflect.DeepEqual(got, *test.wantResponse) {
+				t.Errorf("reservation\n got=%+v\nwant=%+v", got, *test.wantResponse)
+			}
+		} else {
+			got := &httpx.Problem{}
+			testutil.Unmarshal(res, got, t)
+
+			if got.Title != test.wantErr.Title {
+				t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+			}
+			if got.Detail != test.wantErr.Detail {
+				t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+			}
+		}
+	}
+}
+
+func TestReservationModify(t *testing.T) {
+	ts, mockResSvc := setupReservationTestServer()
+	defer ts.Close()
+
+	modified := getTestReservations()[1]
+	modified.RequestedQuantity = 3
+	shipped := fmt.Errorf("quantity 1 is below the 2 already shipped on reservation 2: %w", inventory.ErrInvalidInput)
+
+	tests := []struct {
+		name           string
+		modifyFunc     func(ctx context.Context, ID uint64, ru inventory.ReservationUpdate) (inventory.Reservation, error)
+		request        *inventory.ReservationUpdateDto
+		wantResponse   *inventory.ReservationResponse
+		wantErr        *httpx.Problem
+		wantStatusCode int
+	}{
+		{
+			name: "quantity is changed",
+			modifyFunc: func(ctx context.Context, ID uint64, ru inventory.ReservationUpdate) (inventory.Reservation, error) {
+				if ID != 2 || ru.Quantity != 3 {
+					t.Errorf("modify got id=%d quantity=%d want id=2 quantity=3", ID, ru.Quantity)
+				}
+				return modified, nil
+			},
+			request:        &inventory.ReservationUpdateDto{ReservationUpdate: &inventory.ReservationUpdate{Quantity: 3}},
+			wantResponse:   &inventory.ReservationResponse{Reservation: modified},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "quantity is required",
+			request:        &inventory.ReservationUpdateDto{ReservationUpdate: &inventory.ReservationUpdate{}},
+			wantErr:        httpx.BadRequestProblem(errors.New("quantity must be greater than zero")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "below what has shipped",
+			modifyFunc: func(ctx context.Context, ID uint64, ru inventory.ReservationUpdate) (inventory.Reservation, error) {
+				return inventory.Reservation{}, shipped
+			},
+			request:        &inventory.ReservationUpdateDto{ReservationUpdate: &inventory.ReservationUpdate{Quantity: 1}},
+			wantErr:        httpx.BadRequestProblem(shipped),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "unexpected error",
+			modifyFunc: func(ctx context.Context, ID uint64, ru inventory.ReservationUpdate) (inventory.Reservation, error) {
+				return inventory.Reservation{}, errors.New("some unexpected error")
+			},
+			request:        &inventory.ReservationUpdateDto{ReservationUpdate: &inventory.ReservationUpdate{Quantity: 3}},
+			wantErr:        httpx.InternalServerProblem(nil),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockResSvc.GetReservationFunc = func(ctx context.Context, ID uint64) (inventory.Reservation, error) {
+				return getTestReservations()[1], nil
+			}
+			if test.modifyFunc != nil {
+				mockResSvc.ModifyFunc = test.modifyFunc
+			}
+
+			res := testutil.SendRequest(http.MethodPatch, ts.URL+"/2", test.request, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+
+			if test.wantErr == nil {
+				got := inventory.ReservationResponse{}
+				testutil.Unmarshal(res, &got, t)
+
+				if !reflect.DeepEqual(got, *test.wantResponse) {
+					t.Errorf("reservation\n got=%+v\nwant=%+v", got, *test.wantResponse)
+				}
+			} else {
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestReservationPreconditions(t *testing.T) {
+	ts, mockResSvc := setupReservationTestServer()
+	defer ts.Close()
+
+	current := getTestReservations()[1]
+	current.Version = 3
+	update := &inventory.ReservationUpdateDto{ReservationUpdate: &inventory.ReservationUpdate{Quantity: 3}}
+
+	tests := []struct {
+		name           string
+		method         string
+		headers        map[string]string
+		request        interface{}
+		modifyFunc     func(ctx context.Context, ID uint64, ru inventory.ReservationUpdate) (inventory.Reservation, error)
+		wantModify     int
+		wantETag       string
+		wantStatusCode int
+	}{
+		{
+			name:           "get returns the current etag",
+			method:         http.MethodGet,
+			wantETag:       `"3"`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "get with a matching if-none-match is not modified",
+			method:         http.MethodGet,
+			headers:        map[string]string{"If-None-Match": `W/"3"`},
+			wantETag:       `"3"`,
+			wantStatusCode: http.StatusNotModified,
+		},
+		{
+			name:    "modify with a matching if-match passes the version down",
+			method:  http.MethodPatch,
+			headers: map[string]string{"If-Match": `"3"`},
+			request: update,
+			modifyFunc: func(ctx context.Context, ID uint64, ru inventory.ReservationUpdate) (inventory.Reservation, error) {
+				if got := inventory.PreconditionFrom(ctx).Version; got != 3 {
+					t.Errorf("precondition version got=%d want=3", got)
+				}
+				modified := current
+				modified.Version++
+				return modified, nil
+			},
+			wantModify:     1,
+			wantETag:       `"4"`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "modify with a stale if-match is refused",
+			method:         http.MethodPatch,
+			headers:        map[string]string{"If-Match": `"2"`},
+			request:        update,
+			wantStatusCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "modify that loses the race is refused",
+			method:  http.MethodPatch,
+			headers: map[string]string{"If-Match": `"3"`},
+			request: update,
+			modifyFunc: func(ctx context.Context, ID uint64, ru inventory.ReservationUpdate) (inventory.Reservation, error) {
+				return inventory.Reservation{}, persistence.ErrVersionConflict
+			},
+			wantModify:     1,
+			wantStatusCode: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockResSvc.ModifyCalls = 0
+			mockResSvc.GetReservationFunc = func(ctx context.Context, ID uint64) (inventory.Reservation, error) {
+				return current, nil
+			}
+			mockResSvc.ModifyFunc = test.modifyFunc
+
+			res := testutil.SendRequest(test.method, ts.URL+"/2", test.request, t, testutil.RequestOptions{Headers: test.headers})
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if mockResSvc.ModifyCalls != test.wantModify {
+				t.Errorf("modify calls got=%d want=%d", mockResSvc.ModifyCalls, test.wantModify)
+			}
+			if got := res.Header.Get("ETag"); test.wantETag != "" && got != test.wantETag {
+				t.Errorf("etag got=%s want=%s", got, test.wantETag)
+			}
+		})
+	}
+}
+
+func TestReservationShip(t *testing.T) {
+	ts, mockResSvc := setupReservationTestServer()
+	defer ts.Close()
+
+	shipment := inventory.Shipment{ID: 9, RequestID: "ship1", ReservationID: 2, Sku: "sku1", Quantity: 1, Created: getTime("2021-06-01T00:00:00Z")}
+	invalid := fmt.Errorf("reservation 2 is Open; only Closed reservations can ship: %w", inventory.ErrInvalidInput)
+
+	tests := []struct {
+		shipFunc       func(ctx context.Context, ID uint64, sr inventory.ShipmentRequest) (inventory.Shipment, error)
+		request        *inventory.ShipmentRequestDto
+		wantResponse   *inventory.ShipmentResponse
+		wantErr        *httpx.Problem
+		wantStatusCode int
+	}{
+		{
+			shipFunc: func(ctx context.Context, ID uint64, sr inventory.ShipmentRequest) (inventory.Shipment, error) {
+				if ID != 2 {
+					t.Errorf("ship id got=%d want=%d", ID, 2)
+				}
+				return shipment, nil
+			},
+			request:        &inventory.ShipmentRequestDto{ShipmentRequest: &inventory.ShipmentRequest{RequestID: "ship1", Quantity: 1}},
+			wantResponse:   &inventory.ShipmentResponse{Shipment: shipment},
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			request:        &inventory.ShipmentRequestDto{ShipmentRequest: &inventory.ShipmentRequest{RequestID: "ship1"}},
+			wantErr:        httpx.BadRequestProblem(errors.New("quantity must be greater than zero")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			shipFunc: func(ctx context.Context, ID uint64, sr inventory.ShipmentRequest) (inventory.Shipment, error) {
+				return inventory.Shipment{}, invalid
+			},
+			request:        &inventory.ShipmentRequestDto{ShipmentRequest: &inventory.ShipmentRequest{RequestID: "ship1", Quantity: 1}},
+			wantErr:        httpx.BadRequestProblem(invalid),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			shipFunc: func(ctx context.Context, ID uint64, sr inventory.ShipmentRequest) (inventory.Shipment, error) {
+				return inventory.Shipment{}, errors.New("some unexpected error")
+			},
+			request:        &inventory.ShipmentRequestDto{ShipmentRequest: &inventory.ShipmentRequest{RequestID: "ship1", Quantity: 1}},
+			wantErr:        httpx.InternalServerProblem(nil),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		mockResSvc.GetReservationFunc = func(ctx context.Context, ID uint64) (inventory.Reservation, error) {
+			return getTestReservations()[1], nil
+		}
+		mockResSvc.ShipFunc = test.shipFunc
+
+		res := testutil.Put(ts.URL+"/2/shipment", test.request, t)
+
+		if res.StatusCode != test.wantStatusCode {
+			t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+		}
+
+		if test.wantErr == nil {
+			got := inventory.ShipmentResponse{}
+			testutil.Unmarshal(res, &got, t)
+
+			if !reflect.DeepEqual(got, *test.wantResponse) {
+				t.Errorf("shipment\n got=%+v\nwant=%+v", got, *test.wantResponse)
+			}
+		} else {
+			got := &httpx.Problem{}
+			testutil.Unmarshal(res, got, t)
+
+			if got.Title != test.wantErr.Title {
+				t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+			}
+			if got.Detail != test.wantErr.Detail {
+				t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+			}
+		}
+	}
+}
+
 func TestReservationList(t *testing.T) {
 	ts, mockResSvc := setupReservationTestServer()
 	defer ts.Close()
@@ -410,6 +771,256 @@ func TestReservationList(t *testing.T) {
 	}
 }
 
+func TestReservationBackorders(t *testing.T) {
+	ts, mockResSvc := setupReservationTestServer()
+	defer ts.Close()
+
+	oldest := getTime("2026-03-01T00:00:00Z")
+	backorders := []inventory.Backorder{
+		{Sku: "sku1", OpenReservations: 2, Requested: 10, Reserved: 4, Shortfall: 6, OldestCreated: oldest, OldestAgeSeconds: 3600},
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		err            error
+		wantSku        string
+		wantLimit      int
+		wantOffset     int
+		wantBody       []inventory.Backorder
+		wantStatusCode int
+	}{
+		{
+			name:           "every sku",
+			url:            ts.URL + "/backorders",
+			wantLimit:      50,
+			wantBody:       backorders,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "one sku, second page",
+			url:            ts.URL + "/backorders?sku=sku1&limit=10&offset=10",
+			wantSku:        "sku1",
+			wantLimit:      10,
+			wantOffset:     10,
+			wantBody:       backorders,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "service error",
+			url:            ts.URL + "/backorders",
+			err:            errors.New("some unexpected error"),
+			wantLimit:      50,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockResSvc.GetBackordersFunc = func(ctx context.Context, sku string, limit, offset int) ([]inventory.Backorder, error) {
+				if sku != test.wantSku || limit != test.wantLimit || offset != test.wantOffset {
+					t.Errorf("service called with sku=%q limit=%d offset=%d", sku, limit, offset)
+				}
+				if test.err != nil {
+					return nil, test.err
+				}
+				return backorders, nil
+			}
+
+			res, err := http.Get(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if test.wantBody != nil {
+				var got []inventory.Backorder
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got, test.wantBody) {
+					t.Errorf("backorders got=%+v want=%+v", got, test.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestReservationLimitProblem(t *testing.T) {
+	ts, mockResSvc := setupReservationTestServer()
+	defer ts.Close()
+	mockResSvc.ReserveFunc = func(ctx context.Context, rr inventory.ReservationRequest) (inventory.Reservation, error) {
+		return inventory.Reservation{}, &inventory.RequesterLimitError{Requester: rr.Requester, Class: "wholesale", Sku: rr.Sku, Limit: inventory.LimitDailyCap, Max: 100, Current: 98, Requested: 5}
+	}
+
+	res := testutil.Put(ts.URL, createReservationRequest("requestid1", "requester1", "sku1", 5), t)
+	if res.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusUnprocessableEntity)
+	}
+	got := &httpx.Problem{}
+	testutil.Unmarshal(res, got, t)
+	want := []httpx.FieldProblem{{Field: "dailyCap", Detail: "limit 100, 98 already held, 5 requested"}}
+	if !reflect.DeepEqual(got.Errors, want) {
+		t.Errorf("errors got=%+v want=%+v", got.Errors, want)
+	}
+}
+
+func TestReservationRequesterLimits(t *testing.T) {
+	admin := &user.User{Username: "admin", IsAdmin: true}
+	limits := inventory.RequesterLimits{Requester: "acme", Class: "wholesale", MaxReservedPerSku: 50, MaxOpenReservations: 5, DailyCap: 200}
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		method         string
+		path           string
+		request        interface{}
+		deleteFunc     func(ctx context.Context, requester string) error
+		wantSet        *inventory.RequesterLimits
+		wantBody       *inventory.RequesterLimits
+		wantErr        *httpx.Problem
+		wantStatusCode int
+	}{
+		{
+			name:           "put",
+			user:           admin,
+			method:         http.MethodPut,
+			path:           "/limits/acme",
+			request:        &inventory.RequesterLimitsRequestDto{Class: "wholesale", MaxReservedPerSku: 50, MaxOpenReservations: 5, DailyCap: 200},
+			wantSet:        &limits,
+			wantBody:       &limits,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "put defaults",
+			user:           admin,
+			method:         http.MethodPut,
+			path:           "/limits/*",
+			request:        &inventory.RequesterLimitsRequestDto{MaxOpenReservations: 10},
+			wantSet:        &inventory.RequesterLimits{Requester: inventory.DefaultRequester, MaxOpenReservations: 10},
+			wantBody:       &inventory.RequesterLimits{Requester: inventory.DefaultRequester, MaxOpenReservations: 10},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "put a negative limit",
+			user:           admin,
+			method:         http.MethodPut,
+			path:           "/limits/acme",
+			request:        &inventory.RequesterLimitsRequestDto{DailyCap: -1},
+			wantErr:        httpx.BadRequestProblem(errors.New("limits cannot be negative; use 0 for unlimited")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "get",
+			user:           admin,
+			method:         http.MethodGet,
+			path:           "/limits/acme",
+			wantBody:       &inventory.RequesterLimits{Requester: "acme"},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "delete unknown requester",
+			user:   admin,
+			method: http.MethodDelete,
+			path:   "/limits/acme",
+			deleteFunc: func(ctx context.Context, requester string) error {
+				return persistence.ErrNotFound
+			},
+			wantErr:        httpx.NotFoundProblem(),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "delete",
+			user:           admin,
+			method:         http.MethodDelete,
+			path:           "/limits/acme",
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:           "inventory managers can't manage limits",
+			user:           &user.User{Username: "carol", IsInventoryManager: true},
+			method:         http.MethodPut,
+			path:           "/limits/acme",
+			request:        &inventory.RequesterLimitsRequestDto{DailyCap: 1},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "plain users can't read limits",
+			user:           &user.User{Username: "dave"},
+			method:         http.MethodGet,
+			path:           "/limits",
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockResSvc := setupReservationUserTestServer(test.user)
+			defer ts.Close()
+			if test.deleteFunc != nil {
+				mockResSvc.DeleteRequesterLimitsFunc = test.deleteFunc
+			}
+			var gotSet *inventory.RequesterLimits
+			setFunc := mockResSvc.SetRequesterLimitsFunc
+			mockResSvc.SetRequesterLimitsFunc = func(ctx context.Context, l inventory.RequesterLimits) (inventory.RequesterLimits, error) {
+				gotSet = &l
+				return setFunc(ctx, l)
+			}
+
+			res := testutil.SendRequest(test.method, ts.URL+test.path, test.request, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if !reflect.DeepEqual(gotSet, test.wantSet) {
+				t.Errorf("limits set got=%+v want=%+v", gotSet, test.wantSet)
+			}
+
+			switch {
+			case test.wantBody != nil:
+				got := inventory.RequesterLimitsResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got.RequesterLimits, *test.wantBody) {
+					t.Errorf("limits\n got=%+v\nwant=%+v", got.RequesterLimits, *test.wantBody)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestReservationListRequesterLimits(t *testing.T) {
+	ts, mockResSvc := setupReservationUserTestServer(&user.User{Username: "admin", IsAdmin: true})
+	defer ts.Close()
+
+	var gotLimit, gotOffset int
+	mockResSvc.GetAllRequesterLimitsFunc = func(ctx context.Context, limit, offset int) ([]inventory.RequesterLimits, error) {
+		gotLimit, gotOffset = limit, offset
+		return []inventory.RequesterLimits{{Requester: "*", Class: "default", MaxOpenReservations: 10}}, nil
+	}
+
+	res := testutil.SendRequest(http.MethodGet, ts.URL+"/limits?limit=10&offset=20", nil, t)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	if gotLimit != 10 || gotOffset != 20 {
+		t.Errorf("service called with limit=%d offset=%d", gotLimit, gotOffset)
+	}
+	var got []inventory.RequesterLimits
+	testutil.Unmarshal(res, &got, t)
+	want := []inventory.RequesterLimits{{Requester: "*", Class: "default", MaxOpenReservations: 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("limits got=%+v want=%+v", got, want)
+	}
+}
+
 func createReservationRequest(requestID, requester, sku string, quantity int64) *inventory.ReservationRequestDto {
 	return &inventory.ReservationRequestDto{
 		ReservationRequest: &inventory.ReservationRequest{
@@ -418,6 +1029,53 @@ func createReservationRequest(requestID, requester, sku string, quantity int64)
 	}
 }
 
+func TestReservationListCursor(t *testing.T) {
+	ts, mockResSvc := setupReservationTestServer()
+	defer ts.Close()
+
+	created := time.Date(2026, 3, 1, 12, 0, 0, 123456000, time.UTC)
+	var gotAfter []*inventory.ReservationKey
+	mockResSvc.GetReservationsAfterFunc = func(ctx context.Context, options inventory.GetReservationsOptions, after *inventory.ReservationKey, limit int) ([]inventory.Reservation, error) {
+		if options.Sku != "sku1" {
+			t.Errorf("sku got=%s want=sku1", options.Sku)
+		}
+		gotAfter = append(gotAfter, after)
+		return []inventory.Reservation{{ID: 4, Created: created}, {ID: 9, Created: created}}, nil
+	}
+
+	res, err := http.Get(ts.URL + "?sku=sku1&limit=2&cursor=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+
+	link, _, _ := strings.Cut(strings.TrimPrefix(res.Header.Get("Link"), "<"), ">")
+	if !strings.Contains(link, "sku=sku1") {
+		t.Errorf("next link dropped the filter: %q", link)
+	}
+	res, err = http.Get(ts.URL + link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+
+	if len(gotAfter) != 2 || gotAfter[0] != nil {
+		t.Fatalf("after got=%v, want nil then the last key", gotAfter)
+	}
+	if want := (inventory.ReservationKey{Created: created, ID: 9}); !gotAfter[1].Created.Equal(want.Created) || gotAfter[1].ID != want.ID {
+		t.Errorf("after got=%+v want=%+v", *gotAfter[1], want)
+	}
+	if mockResSvc.GetReservationsCalls != 0 {
+		t.Errorf("offset listing called %d times", mockResSvc.GetReservationsCalls)
+	}
+}
+
 func setupReservationTestServer() (*httptest.Server, *inventory.MockReservationService) {
 	mockSvc := inventory.NewMockReservationService()
 	invApi := inventory.NewReservationApi(mockSvc)
@@ -428,6 +1086,22 @@ func setupReservationTestServer() (*httptest.Server, *inventory.MockReservationS
 	return ts, mockSvc
 }
 
+func setupReservationUserTestServer(u *user.User) (*httptest.Server, *inventory.MockReservationService) {
+	mockSvc := inventory.NewMockReservationService()
+	resApi := inventory.NewReservationApi(mockSvc)
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u != nil {
+				r = r.WithContext(context.WithValue(r.Context(), auth.CtxKeyUser, *u))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	resApi.ConfigureRouter(r)
+	return httptest.NewServer(r), mockSvc
+}
+
 var testReservations = []inventory.Reservation{
 	{ID: 1, RequestID: "requestID1", Requester: "requester1", Sku: "sku1", State: inventory.Closed, ReservedQuantity: 1, RequestedQuantity: 1, Created: getTime("2020-01-01T01:01:01Z")},
 	{ID: 2, RequestID: "requestID2", Requester: "requester2", Sku: "sku2", State: inventory.Open, ReservedQuantity: 1, RequestedQuantity: 2, Created: getTime("2020-01-01T01:01:01Z")},
@@ -465,3 +1139,102 @@ func getTime(t string) time.Time {
 	}
 	return tm
 }
+
+func TestReservationExport(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	expires := created.Add(time.Hour)
+	reservations := []inventory.Reservation{
+		{ID: 1, RequestID: "req1", Requester: "acme", Sku: "sku1", Location: "default", State: inventory.Open, RequestedQuantity: 5, ReservedQuantity: 2, Created: created, ExpiresAt: &expires, FillPolicy: inventory.FillPartial, Version: 2},
+		{ID: 2, RequestID: "req2", Requester: "acme", Sku: "sku1", Location: "east", State: inventory.Open, RequestedQuantity: 3, Created: created, Priority: 1, FillPolicy: inventory.FillMinimum, MinQuantity: 2, Version: 1},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+
+		wantOptions     *inventory.GetReservationsOptions
+		wantStatusCode  int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "filtered csv",
+			query:           "?sku=sku1&state=Open&format=csv",
+			wantOptions:     &inventory.GetReservationsOptions{Sku: "sku1", State: inventory.Open},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody: "id,requestId,requester,sku,location,state,requestedQuantity,reservedQuantity,shippedQuantity,priority,fillPolicy,minQuantity,created,expiresAt,version\n" +
+				"1,req1,acme,sku1,default,Open,5,2,0,0," + string(inventory.FillPartial) + ",0,2026-03-01T12:00:00Z,2026-03-01T13:00:00Z,2\n" +
+				"2,req2,acme,sku1,east,Open,3,0,0,1," + string(inventory.FillMinimum) + ",2,2026-03-01T12:00:00Z,,1\n",
+		},
+		{
+			name:            "ndjson",
+			wantOptions:     &inventory.GetReservationsOptions{},
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/x-ndjson",
+		},
+		{
+			name:           "invalid state",
+			query:          "?state=Pending",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockResSvc := setupReservationTestServer()
+			defer ts.Close()
+			var gotOptions *inventory.GetReservationsOptions
+			mockResSvc.ExportReservationsFunc = func(ctx context.Context, options inventory.GetReservationsOptions, fn func(inventory.Reservation) error) error {
+				gotOptions = &options
+				for _, r := range reservations {
+					if err := fn(r); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			res, err := http.Get(ts.URL + "/export" + test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Fatalf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if !reflect.DeepEqual(gotOptions, test.wantOptions) {
+				t.Errorf("options got=%+v want=%+v", gotOptions, test.wantOptions)
+			}
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+			if got := res.Header.Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("content type got=%q want=%q", got, test.wantContentType)
+			}
+			if test.wantBody == "" {
+				dec := json.NewDecoder(res.Body)
+				var got []inventory.Reservation
+				for dec.More() {
+					r := inventory.Reservation{}
+					if err := dec.Decode(&r); err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, r)
+				}
+				if !reflect.DeepEqual(got, reservations) {
+					t.Errorf("reservations\n got:%+v\nwant:%+v", got, reservations)
+				}
+				return
+			}
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != test.wantBody {
+				t.Errorf("body\n got:%q\nwant:%q", body, test.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package inventory
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/auth"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+	"github.com/sksmith/go-micro-example/internal/platform/persistence"
+)
+
+// configureReturnRouter mounts the returns routes under
+// /inventory/{sku}/returns. Recording a return puts stock back on the
+// books after inspection, so it shares the adjustment roles.
+func (a *InventoryApi) configureReturnRouter(r chi.Router) {
+	r.With(httpx.Paginate).Get("/", a.ListReturns)
+	create := auth.InventoryManagerOnly(http.HandlerFunc(a.CreateReturn))
+	if a.idempotency != nil {
+		create = a.idempotency(create)
+	}
+	r.Method(http.MethodPut, "/", create)
+}
+
+// CreateReturn records goods of a SKU coming back and applies the
+// outcome of their inspection.
+//
+//	@Summary	Record a return
+//	@Tags		inventory
+//	@Accept		json
+//	@Produce	json
+//	@Param		sku			path		string				true	"product SKU"
+//	@Param		return		body		ReturnRequestDto	true	"return"
+//	@Param		If-Match	header		string	false	"apply only if the SKU is still at this ETag"
+//	@Success	201			{object}	ReturnResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	409			{object}	httpx.Problem
+//	@Failure	412			{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/returns [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) CreateReturn(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	data := &ReturnRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(product.Version)) {
+		return
+	}
+
+	ret, err := a.service.RecordReturn(conditional(r), product, *data.ReturnRequest)
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		if errors.Is(err, persistence.ErrVersionConflict) {
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+			return
+		}
+		if errors.Is(err, ErrProductState) {
+			httpx.Render(w, r, httpx.ConflictProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Str("requestId", data.RequestID).Msg("failed to record return")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	httpx.Render(w, r, &ReturnResponse{Return: ret})
+}
+
+// ListReturns returns a page of a SKU's returns, oldest first.
+//
+//	@Summary	List returns for a SKU
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku		path		string	true	"product SKU"
+//	@Param		limit	query		int		false	"max items per page (≤ 200)"	default(50)
+//	@Param		offset	query		int		false	"page offset"					default(0)
+//	@Success	200		{array}		ReturnResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	Link	"RFC 8288 next/prev links"
+//	@Router		/api/v1/inventory/{sku}/returns [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) ListReturns(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+	p := httpx.PaginationFrom(r.Context())
+
+	returns, err := a.service.GetReturns(r.Context(), product.Sku, p.Limit, p.Offset)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to get returns")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.WriteLinkHeader(w, r, p, len(returns))
+	httpx.RenderList(w, r, NewReturnListResponse(returns))
+}
@@ -5,17 +5,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/sksmith/go-micro-example/internal/auth"
 	"github.com/sksmith/go-micro-example/internal/catalog"
 	"github.com/sksmith/go-micro-example/internal/inventory"
 	"github.com/sksmith/go-micro-example/internal/platform/httpx"
 	"github.com/sksmith/go-micro-example/internal/platform/persistence"
 	"github.com/sksmith/go-micro-example/internal/testutil"
+	"github.com/sksmith/go-micro-example/internal/user"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -224,7 +229,7 @@ func TestInventoryList(t *testing.T) {
 	for _, test := range tests {
 		gotLimit := -1
 		gotOffset := -1
-		mockInvSvc.GetAllProductInventoryFunc = func(ctx context.Context, limit int, offset int) ([]inventory.ProductInventory, error) {
+		mockInvSvc.GetAllProductInventoryFunc = func(ctx context.Context, options inventory.ProductListOptions, limit, offset int) ([]inventory.ProductInventory, error) {
 			gotLimit = limit
 			gotOffset = offset
 			return test.inventory, test.serviceErr
@@ -270,390 +275,2631 @@ func TestInventoryList(t *testing.T) {
 	}
 }
 
+func TestInventoryListFilters(t *testing.T) {
+	min, max := int64(3), int64(9)
+	tests := []struct {
+		name  string
+		query string
+
+		wantOptions    inventory.ProductListOptions
+		wantStatusCode int
+	}{
+		{
+			name:           "search and sort descending",
+			query:          "?search=+wid+&sort=-name",
+			wantOptions:    inventory.ProductListOptions{Search: "wid", Sort: inventory.SortByName, Desc: true},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "exclusive bounds become inclusive",
+			query:          "?available_gt=2&available_lt=10&sort=available",
+			wantOptions:    inventory.ProductListOptions{AvailableMin: &min, AvailableMax: &max, Sort: inventory.SortByAvailable},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "tightest bound wins",
+			query:          "?available_gte=3&available_gt=1&available_lte=9&available_lt=20",
+			wantOptions:    inventory.ProductListOptions{AvailableMin: &min, AvailableMax: &max},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "unknown sort",
+			query:          "?sort=upc",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "non-numeric bound",
+			query:          "?available_lt=ten",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "exclusive lower bound at the largest integer",
+			query:          "?available_gt=9223372036854775807",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "exclusive upper bound at the smallest integer",
+			query:          "?available_lt=-9223372036854775808",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "filters with asOf",
+			query:          "?search=wid&asOf=2026-01-01T00:00:00Z",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupInventoryTestServer()
+			defer ts.Close()
+			var got *inventory.ProductListOptions
+			mockInvSvc.GetAllProductInventoryFunc = func(ctx context.Context, options inventory.ProductListOptions, limit, offset int) ([]inventory.ProductInventory, error) {
+				got = &options
+				return []inventory.ProductInventory{}, nil
+			}
+
+			res, err := http.Get(ts.URL + test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Fatalf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if test.wantStatusCode != http.StatusOK {
+				if got != nil {
+					t.Errorf("service called with %+v on a rejected request", *got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("service not called")
+			}
+			if !reflect.DeepEqual(*got, test.wantOptions) {
+				t.Errorf("options got=%+v want=%+v", *got, test.wantOptions)
+			}
+		})
+	}
+}
+
+func TestInventoryListCursorKeepsSort(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	var gotAfter *inventory.ProductKey
+	mockInvSvc.GetAllProductInventoryAfterFunc = func(ctx context.Context, options inventory.ProductListOptions, after *inventory.ProductKey, limit int) ([]inventory.ProductInventory, error) {
+		gotAfter = after
+		return []inventory.ProductInventory{{Product: inventory.Product{Sku: "sku2", Name: "Widget"}, Available: 7}}, nil
+	}
+
+	res, err := http.Get(ts.URL + "?sort=-available&limit=1&cursor=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	next, _, _ := strings.Cut(strings.TrimPrefix(res.Header.Get("Link"), "<"), ">")
+	if next == "" {
+		t.Fatalf("no next link in %q", res.Header.Get("Link"))
+	}
+
+	res, err = http.Get(ts.URL + next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	if gotAfter == nil || gotAfter.Sku != "sku2" || gotAfter.Available != 7 {
+		t.Errorf("after got=%+v want sku2 at 7 available", gotAfter)
+	}
+
+	// The same cursor under a different sort would seek on the wrong key.
+	res, err = http.Get(ts.URL + strings.Replace(next, "sort=-available", "sort=name", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("status code got=%d want=%d", res.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// setupAdjustmentTestServer mounts the inventory routes behind a stub
+// that plays the part of auth.Authenticate, attaching u (when non-nil)
+// as the request's authenticated user.
+func setupAdjustmentTestServer(u *user.User) (*httptest.Server, *inventory.MockInventoryService) {
+	mockSvc := inventory.NewMockInventoryService()
+	invApi := inventory.NewInventoryApi(mockSvc)
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u != nil {
+				r = r.WithContext(context.WithValue(r.Context(), auth.CtxKeyUser, *u))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	invApi.ConfigureRouter(r)
+	return httptest.NewServer(r), mockSvc
+}
+
+func TestInventoryCreateAdjustment(t *testing.T) {
+	adjustment := inventory.Adjustment{ID: 4, RequestID: "adj1", Sku: "sku1", Quantity: -2, Reason: inventory.AdjustDamage, Actor: "carol", Created: getTime("2021-06-01T00:00:00Z")}
+	request := &inventory.AdjustmentRequestDto{AdjustmentRequest: &inventory.AdjustmentRequest{RequestID: "adj1", Quantity: -2, Reason: inventory.AdjustDamage}}
+	belowZero := fmt.Errorf("adjustment of -2 would take available (1) below zero: %w", inventory.ErrInvalidInput)
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		adjustFunc     func(ctx context.Context, product inventory.Product, ar inventory.AdjustmentRequest) (inventory.Adjustment, error)
+		request        *inventory.AdjustmentRequestDto
+		wantResponse   *inventory.AdjustmentResponse
+		wantErr        *httpx.Problem
+		wantStatusCode int
+		wantAdjusts    int
+	}{
+		{
+			name: "inventory manager adjusts",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			adjustFunc: func(ctx context.Context, product inventory.Product, ar inventory.AdjustmentRequest) (inventory.Adjustment, error) {
+				return adjustment, nil
+			},
+			request:        request,
+			wantResponse:   &inventory.AdjustmentResponse{Adjustment: adjustment},
+			wantStatusCode: http.StatusCreated,
+			wantAdjusts:    1,
+		},
+		{
+			name: "admin adjusts",
+			user: &user.User{Username: "admin", IsAdmin: true},
+			adjustFunc: func(ctx context.Context, product inventory.Product, ar inventory.AdjustmentRequest) (inventory.Adjustment, error) {
+				return adjustment, nil
+			},
+			request:        request,
+			wantResponse:   &inventory.AdjustmentResponse{Adjustment: adjustment},
+			wantStatusCode: http.StatusCreated,
+			wantAdjusts:    1,
+		},
+		{
+			name:           "plain user is rejected",
+			user:           &user.User{Username: "dave"},
+			request:        request,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "anonymous is rejected",
+			request:        request,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "missing reason",
+			user:           &user.User{Username: "carol", IsInventoryManager: true},
+			request:        &inventory.AdjustmentRequestDto{AdjustmentRequest: &inventory.AdjustmentRequest{RequestID: "adj1", Quantity: -2}},
+			wantErr:        httpx.BadRequestProblem(errors.New("reason is required")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "below zero",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			adjustFunc: func(ctx context.Context, product inventory.Product, ar inventory.AdjustmentRequest) (inventory.Adjustment, error) {
+				return inventory.Adjustment{}, belowZero
+			},
+			request:        request,
+			wantErr:        httpx.BadRequestProblem(belowZero),
+			wantStatusCode: http.StatusBadRequest,
+			wantAdjusts:    1,
+		},
+		{
+			name: "unexpected error",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			adjustFunc: func(ctx context.Context, product inventory.Product, ar inventory.AdjustmentRequest) (inventory.Adjustment, error) {
+				return inventory.Adjustment{}, errors.New("some unexpected error")
+			},
+			request:        request,
+			wantErr:        httpx.InternalServerProblem(nil),
+			wantStatusCode: http.StatusInternalServerError,
+			wantAdjusts:    1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			if test.adjustFunc != nil {
+				mockInvSvc.AdjustFunc = test.adjustFunc
+			}
+
+			res := testutil.Put(ts.URL+"/sku1/adjustment", test.request, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if mockInvSvc.AdjustCalls != test.wantAdjusts {
+				t.Errorf("Adjust calls got=%d want=%d", mockInvSvc.AdjustCalls, test.wantAdjusts)
+			}
+
+			switch {
+			case test.wantResponse != nil:
+				got := inventory.AdjustmentResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got, *test.wantResponse) {
+					t.Errorf("adjustment\n got=%+v\nwant=%+v", got, *test.wantResponse)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryChangeStatus(t *testing.T) {
+	change := inventory.StatusChange{ID: 6, RequestID: "st1", Sku: "sku1", Location: "default", From: inventory.StatusAvailable, To: inventory.StatusOnHold, Quantity: 3, Reason: "QA sample", Actor: "carol", Created: getTime("2021-06-01T00:00:00Z")}
+	request := &inventory.StatusChangeRequestDto{StatusChangeRequest: &inventory.StatusChangeRequest{RequestID: "st1", From: inventory.StatusAvailable, To: inventory.StatusOnHold, Quantity: 3, Reason: "QA sample"}}
+	tooMuch := fmt.Errorf("available at \"default\" holds 1, not 3: %w", inventory.ErrInvalidInput)
+	archived := fmt.Errorf("product \"sku1\" is archived: %w", inventory.ErrProductState)
+
+	tests := []struct {
+		name             string
+		user             *user.User
+		changeStatusFunc func(ctx context.Context, product inventory.Product, sr inventory.StatusChangeRequest) (inventory.StatusChange, error)
+		request          *inventory.StatusChangeRequestDto
+		wantResponse     *inventory.StatusChangeResponse
+		wantErr          *httpx.Problem
+		wantStatusCode   int
+		wantChanges      int
+	}{
+		{
+			name: "inventory manager holds stock",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			changeStatusFunc: func(ctx context.Context, product inventory.Product, sr inventory.StatusChangeRequest) (inventory.StatusChange, error) {
+				return change, nil
+			},
+			request:        request,
+			wantResponse:   &inventory.StatusChangeResponse{StatusChange: change},
+			wantStatusCode: http.StatusCreated,
+			wantChanges:    1,
+		},
+		{
+			name:           "plain user is rejected",
+			user:           &user.User{Username: "dave"},
+			request:        request,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "missing reason",
+			user:           &user.User{Username: "carol", IsInventoryManager: true},
+			request:        &inventory.StatusChangeRequestDto{StatusChangeRequest: &inventory.StatusChangeRequest{RequestID: "st1", From: inventory.StatusAvailable, To: inventory.StatusOnHold, Quantity: 3}},
+			wantErr:        httpx.BadRequestProblem(errors.New("reason is required")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "missing status",
+			user:           &user.User{Username: "carol", IsInventoryManager: true},
+			request:        &inventory.StatusChangeRequestDto{StatusChangeRequest: &inventory.StatusChangeRequest{RequestID: "st1", To: inventory.StatusOnHold, Quantity: 3, Reason: "QA sample"}},
+			wantErr:        httpx.BadRequestProblem(errors.New("from and to are required")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "more than the status holds",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			changeStatusFunc: func(ctx context.Context, product inventory.Product, sr inventory.StatusChangeRequest) (inventory.StatusChange, error) {
+				return inventory.StatusChange{}, tooMuch
+			},
+			request:        request,
+			wantErr:        httpx.BadRequestProblem(tooMuch),
+			wantStatusCode: http.StatusBadRequest,
+			wantChanges:    1,
+		},
+		{
+			name: "archived product",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			changeStatusFunc: func(ctx context.Context, product inventory.Product, sr inventory.StatusChangeRequest) (inventory.StatusChange, error) {
+				return inventory.StatusChange{}, archived
+			},
+			request:        request,
+			wantErr:        httpx.ConflictProblem(archived),
+			wantStatusCode: http.StatusConflict,
+			wantChanges:    1,
+		},
+		{
+			name: "unexpected error",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			changeStatusFunc: func(ctx context.Context, product inventory.Product, sr inventory.StatusChangeRequest) (inventory.StatusChange, error) {
+				return inventory.StatusChange{}, errors.New("some unexpected error")
+			},
+			request:        request,
+			wantErr:        httpx.InternalServerProblem(nil),
+			wantStatusCode: http.StatusInternalServerError,
+			wantChanges:    1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			if test.changeStatusFunc != nil {
+				mockInvSvc.ChangeStatusFunc = test.changeStatusFunc
+			}
+
+			res := testutil.Put(ts.URL+"/sku1/status", test.request, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if mockInvSvc.ChangeStatusCalls != test.wantChanges {
+				t.Errorf("ChangeStatus calls got=%d want=%d", mockInvSvc.ChangeStatusCalls, test.wantChanges)
+			}
+
+			switch {
+			case test.wantResponse != nil:
+				got := inventory.StatusChangeResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got, *test.wantResponse) {
+					t.Errorf("status change\n got=%+v\nwant=%+v", got, *test.wantResponse)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryCreateTransfer(t *testing.T) {
+	transfer := inventory.Transfer{ID: 6, RequestID: "xfer1", Sku: "sku1", From: "east", To: "west", Quantity: 3, State: inventory.TransferRequested, Actor: "carol", Created: getTime("2021-06-01T00:00:00Z"), Updated: getTime("2021-06-01T00:00:00Z")}
+	request := &inventory.TransferRequestDto{TransferRequest: &inventory.TransferRequest{RequestID: "xfer1", From: "east", To: "west", Quantity: 3}}
+	short := fmt.Errorf("transfer of 3 exceeds available (1) at east: %w", inventory.ErrInvalidInput)
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		transferFunc   func(ctx context.Context, product inventory.Product, tr inventory.TransferRequest) (inventory.Transfer, error)
+		request        *inventory.TransferRequestDto
+		wantResponse   *inventory.TransferResponse
+		wantErr        *httpx.Problem
+		wantStatusCode int
+		wantTransfers  int
+	}{
+		{
+			name: "inventory manager requests a transfer",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			transferFunc: func(ctx context.Context, product inventory.Product, tr inventory.TransferRequest) (inventory.Transfer, error) {
+				return transfer, nil
+			},
+			request:        request,
+			wantResponse:   &inventory.TransferResponse{Transfer: transfer},
+			wantStatusCode: http.StatusCreated,
+			wantTransfers:  1,
+		},
+		{
+			name:           "plain user is rejected",
+			user:           &user.User{Username: "dave"},
+			request:        request,
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "missing destination",
+			user:           &user.User{Username: "carol", IsInventoryManager: true},
+			request:        &inventory.TransferRequestDto{TransferRequest: &inventory.TransferRequest{RequestID: "xfer1", From: "east", Quantity: 3}},
+			wantErr:        httpx.BadRequestProblem(errors.New("from and to are required")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "insufficient stock at source",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			transferFunc: func(ctx context.Context, product inventory.Product, tr inventory.TransferRequest) (inventory.Transfer, error) {
+				return inventory.Transfer{}, short
+			},
+			request:        request,
+			wantErr:        httpx.BadRequestProblem(short),
+			wantStatusCode: http.StatusBadRequest,
+			wantTransfers:  1,
+		},
+		{
+			name: "unexpected error",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			transferFunc: func(ctx context.Context, product inventory.Product, tr inventory.TransferRequest) (inventory.Transfer, error) {
+				return inventory.Transfer{}, errors.New("some unexpected error")
+			},
+			request:        request,
+			wantErr:        httpx.InternalServerProblem(nil),
+			wantStatusCode: http.StatusInternalServerError,
+			wantTransfers:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			if test.transferFunc != nil {
+				mockInvSvc.TransferFunc = test.transferFunc
+			}
+
+			res := testutil.Put(ts.URL+"/sku1/transfer", test.request, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if mockInvSvc.TransferCalls != test.wantTransfers {
+				t.Errorf("Transfer calls got=%d want=%d", mockInvSvc.TransferCalls, test.wantTransfers)
+			}
+
+			switch {
+			case test.wantResponse != nil:
+				got := inventory.TransferResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got, *test.wantResponse) {
+					t.Errorf("transfer\n got=%+v\nwant=%+v", got, *test.wantResponse)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryReceiveTransfer(t *testing.T) {
+	inTransit := inventory.Transfer{ID: 6, RequestID: "xfer1", Sku: "sku1", From: "east", To: "west", Quantity: 3, State: inventory.TransferInTransit}
+	received := inTransit
+	received.State = inventory.TransferReceived
+	notDispatched := fmt.Errorf("transfer 6 is Requested, must be InTransit to receive: %w", inventory.ErrInvalidInput)
+
+	tests := []struct {
+		name            string
+		user            *user.User
+		getTransferFunc func(ctx context.Context, ID uint64) (inventory.Transfer, error)
+		receiveFunc     func(ctx context.Context, ID uint64) (inventory.Transfer, error)
+		wantState       inventory.TransferState
+		wantErr         *httpx.Problem
+		wantStatusCode  int
+		wantReceives    int
+	}{
+		{
+			name: "in-transit transfer is received",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			receiveFunc: func(ctx context.Context, ID uint64) (inventory.Transfer, error) {
+				return received, nil
+			},
+			wantState:      inventory.TransferReceived,
+			wantStatusCode: http.StatusOK,
+			wantReceives:   1,
+		},
+		{
+			name:           "plain user is rejected",
+			user:           &user.User{Username: "dave"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "transfer for another sku",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			getTransferFunc: func(ctx context.Context, ID uint64) (inventory.Transfer, error) {
+				other := inTransit
+				other.Sku = "sku2"
+				return other, nil
+			},
+			wantErr:        httpx.NotFoundProblem(),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name: "not yet dispatched",
+			user: &user.User{Username: "carol", IsInventoryManager: true},
+			receiveFunc: func(ctx context.Context, ID uint64) (inventory.Transfer, error) {
+				return inventory.Transfer{}, notDispatched
+			},
+			wantErr:        httpx.BadRequestProblem(notDispatched),
+			wantStatusCode: http.StatusBadRequest,
+			wantReceives:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			mockInvSvc.GetTransferFunc = func(ctx context.Context, ID uint64) (inventory.Transfer, error) {
+				return inTransit, nil
+			}
+			if test.getTransferFunc != nil {
+				mockInvSvc.GetTransferFunc = test.getTransferFunc
+			}
+			if test.receiveFunc != nil {
+				mockInvSvc.ReceiveTransferFunc = test.receiveFunc
+			}
+
+			res := testutil.Put(ts.URL+"/sku1/transfer/6/receive", nil, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if mockInvSvc.ReceiveTransferCalls != test.wantReceives {
+				t.Errorf("ReceiveTransfer calls got=%d want=%d", mockInvSvc.ReceiveTransferCalls, test.wantReceives)
+			}
+
+			switch {
+			case test.wantState != "":
+				got := inventory.TransferResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if got.State != test.wantState {
+					t.Errorf("state got=%s want=%s", got.State, test.wantState)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryHistory(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	created := time.Unix(0, 0).UTC()
+	movements := []inventory.InventoryMovement{
+		{ID: 1, Sku: "sku1", Delta: 5, Reason: inventory.MovementProduction, RequestID: "prod1", Actor: "alice", Balance: 5, Created: created},
+		{ID: 2, Sku: "sku1", Delta: -3, Reason: inventory.MovementReservation, RequestID: "rsv1", Actor: "bob", Balance: 2, Created: created},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		serviceErr     error
+		wantLimit      int
+		wantOffset     int
+		wantMovements  []inventory.InventoryMovement
+		wantStatusCode int
+	}{
+		{
+			name:           "default page",
+			wantLimit:      50,
+			wantMovements:  movements,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "explicit page",
+			query:          "?limit=5&offset=10",
+			wantLimit:      5,
+			wantOffset:     10,
+			wantMovements:  movements,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "service error",
+			serviceErr:     errors.New("something bad happened"),
+			wantLimit:      50,
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotSku string
+			gotLimit, gotOffset := -1, -1
+			mockInvSvc.GetInventoryHistoryFunc = func(ctx context.Context, sku string, limit, offset int) ([]inventory.InventoryMovement, error) {
+				gotSku, gotLimit, gotOffset = sku, limit, offset
+				return test.wantMovements, test.serviceErr
+			}
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+
+			res, err := http.Get(ts.URL + "/sku1/history" + test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=[%d] want=[%d]", res.StatusCode, test.wantStatusCode)
+			}
+			if test.serviceErr == nil {
+				got := []inventory.InventoryMovement{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got, test.wantMovements) {
+					t.Errorf("movements\n got:%+v\nwant:%+v\n", got, test.wantMovements)
+				}
+			}
+			if gotSku != "sku1" || gotLimit != test.wantLimit || gotOffset != test.wantOffset {
+				t.Errorf("service args got=(%q, %d, %d) want=(%q, %d, %d)", gotSku, gotLimit, gotOffset, "sku1", test.wantLimit, test.wantOffset)
+			}
+		})
+	}
+}
+
 func TestInventoryCreateProduct(t *testing.T) {
 	ts, mockInvSvc := setupInventoryTestServer()
 	defer ts.Close()
 
 	tests := []struct {
-		request             inventory.CreateProductRequest
-		serviceErr          error
-		wantProductResponse *inventory.ProductResponse
-		wantErr             *httpx.Problem
-		wantStatusCode      int
+		request             inventory.CreateProductRequest
+		serviceCreated      bool
+		serviceErr          error
+		wantProductResponse *inventory.ProductResponse
+		wantErr             *httpx.Problem
+		wantStatusCode      int
+	}{
+		{
+			request:             createProductRequest("name1", "sku1", "upc1"),
+			serviceCreated:      true,
+			serviceErr:          nil,
+			wantProductResponse: createProductResponse("name1", "sku1", "upc1", 0),
+			wantErr:             nil,
+			wantStatusCode:      http.StatusCreated,
+		},
+		{
+			request:             createProductRequest("name1", "sku1", "upc1"),
+			serviceCreated:      false,
+			serviceErr:          nil,
+			wantProductResponse: createProductResponse("name1", "sku1", "upc1", 0),
+			wantErr:             nil,
+			wantStatusCode:      http.StatusOK,
+		},
+		{
+			request:             createProductRequest("name1", "sku1", "upc1"),
+			serviceErr:          errors.New("some unexpected error"),
+			wantProductResponse: nil,
+			wantErr:             httpx.InternalServerProblem(nil),
+			wantStatusCode:      http.StatusInternalServerError,
+		},
+		{
+			request:             createProductRequest("name1", "sku1", ""),
+			serviceErr:          nil,
+			wantProductResponse: nil,
+			wantErr:             httpx.BadRequestProblem(errors.New("missing required field(s)")),
+			wantStatusCode:      http.StatusBadRequest,
+		},
+		{
+			request:             createProductRequest("name1", "", "upc1"),
+			serviceErr:          nil,
+			wantProductResponse: nil,
+			wantErr:             httpx.BadRequestProblem(errors.New("missing required field(s)")),
+			wantStatusCode:      http.StatusBadRequest,
+		},
+		{
+			request:             createProductRequest("", "sku1", "upc1"),
+			serviceErr:          nil,
+			wantProductResponse: nil,
+			wantErr:             httpx.BadRequestProblem(errors.New("missing required field(s)")),
+			wantStatusCode:      http.StatusBadRequest,
+		},
+		{
+			request:             createProductRequest("name1", "low-stock", "upc1"),
+			serviceErr:          nil,
+			wantProductResponse: nil,
+			wantErr:             httpx.BadRequestProblem(errors.New(`sku "low-stock" is reserved`)),
+			wantStatusCode:      http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		mockInvSvc.CreateProductFunc = func(ctx context.Context, product inventory.Product) (inventory.ProductInventory, bool, error) {
+			if test.serviceErr != nil {
+				return inventory.ProductInventory{}, false, test.serviceErr
+			}
+			product.Version = 1
+			return inventory.ProductInventory{Product: product}, test.serviceCreated, nil
+		}
+
+		res := testutil.Put(ts.URL, test.request, t)
+
+		if res.StatusCode != test.wantStatusCode {
+			t.Errorf("status code got=%d\nwant=%d", res.StatusCode, test.wantStatusCode)
+		}
+
+		if test.wantErr == nil {
+			if etag := res.Header.Get("ETag"); etag != httpx.ETag(1) {
+				t.Errorf("etag got=%q want=%q", etag, httpx.ETag(1))
+			}
+			got := inventory.ProductResponse{}
+			testutil.Unmarshal(res, &got, t)
+
+			want := *test.wantProductResponse
+			want.Version = 1
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("product\n got=%+v\nwant=%+v", got, want)
+			}
+		} else {
+			got := &httpx.Problem{}
+			testutil.Unmarshal(res, got, t)
+
+			if got.Title != test.wantErr.Title {
+				t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+			}
+			if got.Detail != test.wantErr.Detail {
+				t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+			}
+		}
+	}
+}
+
+func TestInventoryCreateProductRoles(t *testing.T) {
+	existing := inventory.Product{Sku: "sku1", Upc: "upc1", Name: "name1", State: inventory.ProductActive, Version: 7}
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		headers        map[string]string
+		wantStatusCode int
+		wantCreates    int
+	}{
+		{
+			name:           "plain user creates",
+			user:           &user.User{Username: "dave"},
+			wantStatusCode: http.StatusCreated,
+			wantCreates:    1,
+		},
+		{
+			name:           "plain user creates only if absent",
+			user:           &user.User{Username: "dave"},
+			headers:        map[string]string{"If-None-Match": "*"},
+			wantStatusCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "inventory manager replaces under if-match",
+			user:           &user.User{Username: "carol", IsInventoryManager: true},
+			headers:        map[string]string{"If-Match": `"7"`},
+			wantStatusCode: http.StatusOK,
+			wantCreates:    1,
+		},
+		{
+			name:           "admin replaces under if-match",
+			user:           &user.User{Username: "admin", IsAdmin: true},
+			headers:        map[string]string{"If-Match": `"7"`},
+			wantStatusCode: http.StatusOK,
+			wantCreates:    1,
+		},
+		{
+			name:           "plain user is rejected under if-match",
+			user:           &user.User{Username: "dave"},
+			headers:        map[string]string{"If-Match": `"7"`},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "anonymous is rejected under if-match",
+			headers:        map[string]string{"If-Match": `"7"`},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return existing, nil
+			}
+			mockInvSvc.CreateProductFunc = func(ctx context.Context, product inventory.Product) (inventory.ProductInventory, bool, error) {
+				return inventory.ProductInventory{Product: existing}, inventory.PreconditionFrom(ctx).Version == 0, nil
+			}
+
+			request := createProductRequest("newname", "sku1", "upc1")
+			res := testutil.SendRequest(http.MethodPut, ts.URL, request, t, testutil.RequestOptions{Headers: test.headers})
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if mockInvSvc.CreateProductCalls != test.wantCreates {
+				t.Errorf("CreateProduct calls got=%d want=%d", mockInvSvc.CreateProductCalls, test.wantCreates)
+			}
+		})
+	}
+}
+
+func TestInventoryCreateProductionEvent(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	tests := []struct {
+		getProductFunc              func(ctx context.Context, sku string) (inventory.Product, error)
+		produceFunc                 func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error
+		sku                         string
+		request                     *inventory.CreateProductionEventRequest
+		wantProductionEventResponse *inventory.ProductionEventResponse
+		wantErr                     *httpx.Problem
+		wantStatusCode              int
+	}{
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return getTestProductInventory()[0].Product, nil
+			},
+			produceFunc: func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error {
+				return nil
+			},
+			sku:                         "testsku1",
+			request:                     createProductionEventRequest("abc123", 1),
+			wantProductionEventResponse: &inventory.ProductionEventResponse{},
+			wantErr:                     nil,
+			wantStatusCode:              http.StatusCreated,
+		},
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{}, persistence.ErrNotFound
+			},
+			produceFunc:                 nil,
+			sku:                         "testsku1",
+			request:                     createProductionEventRequest("abc123", 1),
+			wantProductionEventResponse: nil,
+			wantErr:                     httpx.NotFoundProblem(),
+			wantStatusCode:              http.StatusNotFound,
+		},
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{}, errors.New("some unexpected error")
+			},
+			produceFunc:                 nil,
+			sku:                         "testsku1",
+			request:                     createProductionEventRequest("abc123", 1),
+			wantProductionEventResponse: nil,
+			wantErr:                     httpx.InternalServerProblem(nil),
+			wantStatusCode:              http.StatusInternalServerError,
+		},
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return getTestProductInventory()[0].Product, nil
+			},
+			produceFunc: func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error {
+				return errors.New("some unexpected error")
+			},
+			sku:                         "testsku1",
+			request:                     createProductionEventRequest("abc123", 1),
+			wantProductionEventResponse: nil,
+			wantErr:                     httpx.InternalServerProblem(nil),
+			wantStatusCode:              http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		mockInvSvc.GetProductFunc = test.getProductFunc
+		mockInvSvc.ProduceFunc = test.produceFunc
+
+		url := ts.URL + "/" + test.sku + "/productionEvent"
+		res := testutil.Put(url, test.request, t)
+
+		if res.StatusCode != test.wantStatusCode {
+			t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+		}
+
+		if test.wantErr == nil {
+			got := inventory.ProductionEventResponse{}
+			testutil.Unmarshal(res, &got, t)
+
+			if !reflect.DeepEqual(got, *test.wantProductionEventResponse) {
+				t.Errorf("product\n got=%+v\nwant=%+v", got, *test.wantProductionEventResponse)
+			}
+		} else {
+			got := &httpx.Problem{}
+			testutil.Unmarshal(res, got, t)
+
+			if got.Title != test.wantErr.Title {
+				t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+			}
+			if got.Detail != test.wantErr.Detail {
+				t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+			}
+		}
+	}
+}
+
+func TestInventoryUpdateProduct(t *testing.T) {
+	manager := &user.User{Username: "carol", IsInventoryManager: true}
+	updated := inventory.ProductInventory{Product: inventory.Product{Sku: "sku1", Upc: "upc1", Name: "newname", State: inventory.ProductActive, Version: 5}}
+	inUse := fmt.Errorf("product \"sku1\" still holds 2 available and 0 in transit: %w", inventory.ErrProductState)
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		method         string
+		request        interface{}
+		updateFunc     func(ctx context.Context, sku string, pu inventory.ProductUpdate) (inventory.ProductInventory, error)
+		wantUpdate     inventory.ProductUpdate
+		wantUpdates    int
+		wantErr        *httpx.Problem
+		wantStatusCode int
+	}{
+		{
+			name:    "rename",
+			user:    manager,
+			method:  http.MethodPatch,
+			request: &inventory.ProductUpdateDto{ProductUpdate: &inventory.ProductUpdate{Name: "newname"}},
+			updateFunc: func(ctx context.Context, sku string, pu inventory.ProductUpdate) (inventory.ProductInventory, error) {
+				return updated, nil
+			},
+			wantUpdate:     inventory.ProductUpdate{Name: "newname"},
+			wantUpdates:    1,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "empty update",
+			user:           manager,
+			method:         http.MethodPatch,
+			request:        &inventory.ProductUpdateDto{ProductUpdate: &inventory.ProductUpdate{}},
+			wantErr:        httpx.BadRequestProblem(errors.New("at least one of upc, name or state is required")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "plain user is rejected",
+			user:           &user.User{Username: "dave"},
+			method:         http.MethodPatch,
+			request:        &inventory.ProductUpdateDto{ProductUpdate: &inventory.ProductUpdate{Name: "newname"}},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "delete archives",
+			user:   manager,
+			method: http.MethodDelete,
+			updateFunc: func(ctx context.Context, sku string, pu inventory.ProductUpdate) (inventory.ProductInventory, error) {
+				return updated, nil
+			},
+			wantUpdate:     inventory.ProductUpdate{State: inventory.ProductArchived},
+			wantUpdates:    1,
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:   "archive with stock left",
+			user:   manager,
+			method: http.MethodDelete,
+			updateFunc: func(ctx context.Context, sku string, pu inventory.ProductUpdate) (inventory.ProductInventory, error) {
+				return inventory.ProductInventory{}, inUse
+			},
+			wantUpdate:     inventory.ProductUpdate{State: inventory.ProductArchived},
+			wantUpdates:    1,
+			wantErr:        httpx.ConflictProblem(inUse),
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:    "unexpected error",
+			user:    manager,
+			method:  http.MethodPatch,
+			request: &inventory.ProductUpdateDto{ProductUpdate: &inventory.ProductUpdate{State: inventory.ProductDiscontinued}},
+			updateFunc: func(ctx context.Context, sku string, pu inventory.ProductUpdate) (inventory.ProductInventory, error) {
+				return inventory.ProductInventory{}, errors.New("some unexpected error")
+			},
+			wantUpdate:     inventory.ProductUpdate{State: inventory.ProductDiscontinued},
+			wantUpdates:    1,
+			wantErr:        httpx.InternalServerProblem(nil),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku, Upc: "upc1", Name: "name1", State: inventory.ProductActive, Version: 4}, nil
+			}
+			var gotUpdate inventory.ProductUpdate
+			if test.updateFunc != nil {
+				mockInvSvc.UpdateProductFunc = func(ctx context.Context, sku string, pu inventory.ProductUpdate) (inventory.ProductInventory, error) {
+					gotUpdate = pu
+					return test.updateFunc(ctx, sku, pu)
+				}
+			}
+
+			res := testutil.SendRequest(test.method, ts.URL+"/sku1", test.request, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if mockInvSvc.UpdateProductCalls != test.wantUpdates {
+				t.Errorf("UpdateProduct calls got=%d want=%d", mockInvSvc.UpdateProductCalls, test.wantUpdates)
+			}
+			if gotUpdate != test.wantUpdate {
+				t.Errorf("update got=%+v want=%+v", gotUpdate, test.wantUpdate)
+			}
+
+			switch {
+			case test.wantStatusCode == http.StatusOK:
+				got := inventory.ProductResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if got.Product != updated.Product {
+					t.Errorf("product\n got=%+v\nwant=%+v", got.Product, updated.Product)
+				}
+				if etag := res.Header.Get("ETag"); etag != `"5"` {
+					t.Errorf("etag got=%s want=%s", etag, `"5"`)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryBillOfMaterials(t *testing.T) {
+	manager := &user.User{Username: "carol", IsInventoryManager: true}
+	components := []inventory.BOMComponent{{Sku: "bolt", Quantity: 2}, {Sku: "nut", Quantity: 4}}
+	cycle := fmt.Errorf("component \"subkit\" is itself made from \"sku1\": %w", inventory.ErrInvalidInput)
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		method         string
+		path           string
+		request        interface{}
+		getFunc        func(ctx context.Context, sku string) (inventory.BillOfMaterials, error)
+		setFunc        func(ctx context.Context, bom inventory.BillOfMaterials) (inventory.BillOfMaterials, error)
+		wantSet        *inventory.BillOfMaterials
+		wantBody       *inventory.BillOfMaterials
+		wantErr        *httpx.Problem
+		wantStatusCode int
+	}{
+		{
+			name:   "get",
+			method: http.MethodGet,
+			getFunc: func(ctx context.Context, sku string) (inventory.BillOfMaterials, error) {
+				return inventory.BillOfMaterials{Sku: sku, Components: components}, nil
+			},
+			wantBody:       &inventory.BillOfMaterials{Sku: "sku1", Components: components},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "get without a bill of materials",
+			method: http.MethodGet,
+			getFunc: func(ctx context.Context, sku string) (inventory.BillOfMaterials, error) {
+				return inventory.BillOfMaterials{}, persistence.ErrNotFound
+			},
+			wantErr:        httpx.NotFoundProblem(),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "put",
+			user:           manager,
+			method:         http.MethodPut,
+			request:        &inventory.BillOfMaterialsRequestDto{Components: components},
+			wantSet:        &inventory.BillOfMaterials{Sku: "sku1", Components: components},
+			wantBody:       &inventory.BillOfMaterials{Sku: "sku1", Components: components},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "put without components",
+			user:           manager,
+			method:         http.MethodPut,
+			request:        &inventory.BillOfMaterialsRequestDto{},
+			wantErr:        httpx.BadRequestProblem(errors.New("components are required; delete the bill of materials to remove it")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:    "put a cycle",
+			user:    manager,
+			method:  http.MethodPut,
+			request: &inventory.BillOfMaterialsRequestDto{Components: []inventory.BOMComponent{{Sku: "subkit", Quantity: 1}}},
+			setFunc: func(ctx context.Context, bom inventory.BillOfMaterials) (inventory.BillOfMaterials, error) {
+				return inventory.BillOfMaterials{}, cycle
+			},
+			wantSet:        &inventory.BillOfMaterials{Sku: "sku1", Components: []inventory.BOMComponent{{Sku: "subkit", Quantity: 1}}},
+			wantErr:        httpx.BadRequestProblem(cycle),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "plain user can't put",
+			user:           &user.User{Username: "dave"},
+			method:         http.MethodPut,
+			request:        &inventory.BillOfMaterialsRequestDto{Components: components},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "delete",
+			user:           manager,
+			method:         http.MethodDelete,
+			wantSet:        &inventory.BillOfMaterials{Sku: "sku1"},
+			wantStatusCode: http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			if test.getFunc != nil {
+				mockInvSvc.GetBillOfMaterialsFunc = test.getFunc
+			}
+			var gotSet *inventory.BillOfMaterials
+			mockInvSvc.SetBillOfMaterialsFunc = func(ctx context.Context, bom inventory.BillOfMaterials) (inventory.BillOfMaterials, error) {
+				gotSet = &bom
+				if test.setFunc != nil {
+					return test.setFunc(ctx, bom)
+				}
+				return bom, nil
+			}
+
+			res := testutil.SendRequest(test.method, ts.URL+"/sku1/bom", test.request, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if !reflect.DeepEqual(gotSet, test.wantSet) {
+				t.Errorf("set got=%+v want=%+v", gotSet, test.wantSet)
+			}
+
+			switch {
+			case test.wantBody != nil:
+				got := inventory.BillOfMaterialsResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got.BillOfMaterials, *test.wantBody) {
+					t.Errorf("bill of materials\n got=%+v\nwant=%+v", got.BillOfMaterials, *test.wantBody)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryBuildable(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	var gotLocation string
+	mockInvSvc.BuildableFunc = func(ctx context.Context, sku, location string) (inventory.Buildable, error) {
+		gotLocation = location
+		return inventory.Buildable{Sku: sku, Location: location, Quantity: 3, Components: []inventory.BuildableComponent{
+			{Sku: "bolt", Quantity: 2, Available: 7, Buildable: 3},
+		}}, nil
+	}
+
+	res := testutil.SendRequest(http.MethodGet, ts.URL+"/sku1/bom/buildable?location=east", nil, t)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	if gotLocation != "east" {
+		t.Errorf("location got=%q want=%q", gotLocation, "east")
+	}
+	got := inventory.BuildableResponse{}
+	testutil.Unmarshal(res, &got, t)
+	if got.Quantity != 3 || len(got.Components) != 1 || got.Components[0].Buildable != 3 {
+		t.Errorf("unexpected buildable %+v", got.Buildable)
+	}
+	if mockInvSvc.SetBillOfMaterialsCalls != 0 || mockInvSvc.ProduceCalls != 0 {
+		t.Errorf("dry run changed something")
+	}
+}
+
+func TestInventoryProductionComponentShortage(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	short := &inventory.ComponentShortageError{Sku: "sku1", Location: inventory.DefaultLocation, Shortages: []inventory.ComponentShortage{
+		{Sku: "bolt", Required: 12, Available: 10},
+		{Sku: "nut", Required: 24, Available: 8},
+	}}
+	mockInvSvc.ProduceFunc = func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error {
+		return fmt.Errorf("failed to produce: %w", short)
+	}
+
+	request := inventory.CreateProductionEventRequest{ProductionRequest: &inventory.ProductionRequest{RequestID: "kit1", Quantity: 6}}
+	res := testutil.SendRequest(http.MethodPut, ts.URL+"/sku1/productionEvent", request, t)
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusConflict)
+	}
+
+	got := &httpx.Problem{}
+	testutil.Unmarshal(res, got, t)
+	want := []httpx.FieldProblem{
+		{Field: "bolt", Detail: "needs 12, 10 available"},
+		{Field: "nut", Detail: "needs 24, 8 available"},
+	}
+	if !reflect.DeepEqual(got.Errors, want) {
+		t.Errorf("errors got=%+v want=%+v", got.Errors, want)
+	}
+}
+
+func TestInventoryPreconditions(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	product := getTestProductInventory()[0]
+	product.Version = 7
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		headers        map[string]string
+		request        interface{}
+		produceFunc    func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error
+		wantProduce    int
+		wantStatusCode int
+	}{
+		{
+			name:           "get returns the current etag",
+			method:         http.MethodGet,
+			path:           "/test1sku",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "get with a matching if-none-match is not modified",
+			method:         http.MethodGet,
+			path:           "/test1sku",
+			headers:        map[string]string{"If-None-Match": `"7"`},
+			wantStatusCode: http.StatusNotModified,
+		},
+		{
+			name:           "get with a stale if-none-match returns the body",
+			method:         http.MethodGet,
+			path:           "/test1sku",
+			headers:        map[string]string{"If-None-Match": `"6"`},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:    "production with a matching if-match passes the version down",
+			method:  http.MethodPut,
+			path:    "/test1sku/productionEvent",
+			headers: map[string]string{"If-Match": `"7"`},
+			request: createProductionEventRequest("abc123", 1),
+			produceFunc: func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error {
+				if got := inventory.PreconditionFrom(ctx).Version; got != 7 {
+					t.Errorf("precondition version got=%d want=7", got)
+				}
+				return nil
+			},
+			wantProduce:    1,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "production with a stale if-match is refused",
+			method:         http.MethodPut,
+			path:           "/test1sku/productionEvent",
+			headers:        map[string]string{"If-Match": `"6"`},
+			request:        createProductionEventRequest("abc123", 1),
+			wantStatusCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "production that loses the race is refused",
+			method:  http.MethodPut,
+			path:    "/test1sku/productionEvent",
+			headers: map[string]string{"If-Match": `"7"`},
+			request: createProductionEventRequest("abc123", 1),
+			produceFunc: func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error {
+				return persistence.ErrVersionConflict
+			},
+			wantProduce:    1,
+			wantStatusCode: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockInvSvc.ProduceCalls = 0
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return product.Product, nil
+			}
+			mockInvSvc.GetProductInventoryFunc = func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
+				return product, nil
+			}
+			mockInvSvc.ProduceFunc = test.produceFunc
+
+			res := testutil.SendRequest(test.method, ts.URL+test.path, test.request, t, testutil.RequestOptions{Headers: test.headers})
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if mockInvSvc.ProduceCalls != test.wantProduce {
+				t.Errorf("produce calls got=%d want=%d", mockInvSvc.ProduceCalls, test.wantProduce)
+			}
+			if test.method == http.MethodGet {
+				if got := res.Header.Get("ETag"); got != `"7"` {
+					t.Errorf("etag got=%s want=%s", got, `"7"`)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryGetProductInventory(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	tests := []struct {
+		sku                     string
+		getProductFunc          func(ctx context.Context, sku string) (inventory.Product, error)
+		getProductInventoryFunc func(ctx context.Context, sku string) (inventory.ProductInventory, error)
+		wantProductResponse     *inventory.ProductResponse
+		wantErr                 *httpx.Problem
+		wantStatusCode          int
+	}{
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return getTestProductInventory()[0].Product, nil
+			},
+			getProductInventoryFunc: func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
+				return getTestProductInventory()[0], nil
+			},
+			sku:                 "test1sku",
+			wantProductResponse: createProductResponse("test1name", "test1sku", "test1upc", 1),
+			wantErr:             nil,
+			wantStatusCode:      http.StatusOK,
+		},
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return getTestProductInventory()[0].Product, nil
+			},
+			getProductInventoryFunc: func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
+				return heldProductInventory(), nil
+			},
+			sku:                 "test1sku",
+			wantProductResponse: &inventory.ProductResponse{ProductInventory: heldProductInventory()},
+			wantStatusCode:      http.StatusOK,
+		},
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{}, persistence.ErrNotFound
+			},
+			getProductInventoryFunc: nil,
+			sku:                     "test1sku",
+			wantProductResponse:     nil,
+			wantErr:                 httpx.NotFoundProblem(),
+			wantStatusCode:          http.StatusNotFound,
+		},
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return getTestProductInventory()[0].Product, nil
+			},
+			getProductInventoryFunc: func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
+				return inventory.ProductInventory{}, persistence.ErrNotFound
+			},
+			sku:                 "test1sku",
+			wantProductResponse: nil,
+			wantErr:             httpx.NotFoundProblem(),
+			wantStatusCode:      http.StatusNotFound,
+		},
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{}, errors.New("some unexpected error")
+			},
+			getProductInventoryFunc: nil,
+			sku:                     "test1sku",
+			wantProductResponse:     nil,
+			wantErr:                 httpx.InternalServerProblem(nil),
+			wantStatusCode:          http.StatusInternalServerError,
+		},
+		{
+			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
+				return getTestProductInventory()[0].Product, nil
+			},
+			getProductInventoryFunc: func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
+				return inventory.ProductInventory{}, errors.New("some unexpected error")
+			},
+			sku:                 "test1sku",
+			wantProductResponse: nil,
+			wantErr:             httpx.InternalServerProblem(nil),
+			wantStatusCode:      http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		mockInvSvc.GetProductFunc = test.getProductFunc
+		mockInvSvc.GetProductInventoryFunc = test.getProductInventoryFunc
+
+		res, err := http.Get(ts.URL + "/" + test.sku)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != test.wantStatusCode {
+			t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+		}
+
+		if test.wantErr == nil {
+			got := inventory.ProductResponse{}
+			testutil.Unmarshal(res, &got, t)
+
+			if !reflect.DeepEqual(got, *test.wantProductResponse) {
+				t.Errorf("product\n got=%+v\nwant=%+v", got, *test.wantProductResponse)
+			}
+		} else {
+			got := &httpx.Problem{}
+			testutil.Unmarshal(res, got, t)
+
+			if got.Title != test.wantErr.Title {
+				t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+			}
+			if got.Detail != test.wantErr.Detail {
+				t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+			}
+		}
+	}
+}
+
+// TestInventoryGetProductInventoryEnriched covers the DSN-018
+// outbound-REST enrichment path: when a catalog client is wired and
+// returns a product, the response carries the upstream description;
+// when the client returns an error, the response still succeeds
+// (catalog is best-effort) and the catalog field is omitted.
+func TestInventoryGetProductInventoryAsOf(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+		return getTestProductInventory()[0].Product, nil
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		historicalErr  error
+		wantAsOf       time.Time
+		wantAvailable  int64
+		wantHistorical int
+		wantCurrent    int
+		wantStatusCode int
+	}{
+		{
+			name:           "no asOf reads current inventory",
+			wantAvailable:  1,
+			wantCurrent:    1,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "asOf reads reconstructed inventory",
+			query:          "?asOf=2026-01-31T23:59:59Z",
+			wantAsOf:       time.Date(2026, time.January, 31, 23, 59, 59, 0, time.UTC),
+			wantAvailable:  42,
+			wantHistorical: 1,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "asOf before the SKU's history",
+			query:          "?asOf=2020-01-01T00:00:00Z",
+			historicalErr:  inventory.ErrBeforeHistory,
+			wantHistorical: 1,
+			wantStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "malformed asOf",
+			query:          "?asOf=yesterday",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "asOf in the future",
+			query:          "?asOf=2999-01-01T00:00:00Z",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockInvSvc.GetProductInventoryCalls = 0
+			mockInvSvc.GetProductInventoryAsOfCalls = 0
+			mockInvSvc.GetProductInventoryFunc = func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
+				return getTestProductInventory()[0], nil
+			}
+			var gotAsOf time.Time
+			mockInvSvc.GetProductInventoryAsOfFunc = func(ctx context.Context, sku string, asOf time.Time) (inventory.ProductInventory, error) {
+				gotAsOf = asOf
+				if test.historicalErr != nil {
+					return inventory.ProductInventory{}, test.historicalErr
+				}
+				pi := getTestProductInventory()[0]
+				pi.Available = 42
+				return pi, nil
+			}
+
+			res, err := http.Get(ts.URL + "/test1sku" + test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=[%d] want=[%d]", res.StatusCode, test.wantStatusCode)
+			}
+			if mockInvSvc.GetProductInventoryCalls != test.wantCurrent || mockInvSvc.GetProductInventoryAsOfCalls != test.wantHistorical {
+				t.Errorf("service calls current=%d historical=%d want current=%d historical=%d",
+					mockInvSvc.GetProductInventoryCalls, mockInvSvc.GetProductInventoryAsOfCalls, test.wantCurrent, test.wantHistorical)
+			}
+			if test.wantStatusCode != http.StatusOK {
+				return
+			}
+
+			got := inventory.ProductResponse{}
+			testutil.Unmarshal(res, &got, t)
+			if got.Available != test.wantAvailable {
+				t.Errorf("available got=%d want=%d", got.Available, test.wantAvailable)
+			}
+			if !gotAsOf.Equal(test.wantAsOf) {
+				t.Errorf("asOf got=%v want=%v", gotAsOf, test.wantAsOf)
+			}
+			if test.wantHistorical > 0 && (got.AsOf == nil || !got.AsOf.Equal(test.wantAsOf)) {
+				t.Errorf("response asOf got=%v want=%v", got.AsOf, test.wantAsOf)
+			}
+		})
+	}
+}
+
+func TestInventoryGetProductInventoryEnriched(t *testing.T) {
+	tests := []struct {
+		name        string
+		client      catalog.Client
+		wantCatalog *inventory.CatalogInfo
+	}{
+		{
+			name: "lookup ok adds catalog fields",
+			client: stubCatalogClient{
+				lookup: func(_ context.Context, sku string) (catalog.Product, error) {
+					return catalog.Product{Sku: sku, Description: "Widget desc", Category: "tools"}, nil
+				},
+			},
+			wantCatalog: &inventory.CatalogInfo{Description: "Widget desc", Category: "tools"},
+		},
+		{
+			name: "lookup error drops to unenriched response",
+			client: stubCatalogClient{
+				lookup: func(_ context.Context, _ string) (catalog.Product, error) {
+					return catalog.Product{}, errors.New("upstream down")
+				},
+			},
+			wantCatalog: nil,
+		},
+		{
+			name:        "nil client skips enrichment entirely",
+			client:      nil,
+			wantCatalog: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSvc := inventory.NewMockInventoryService()
+			invApi := inventory.NewInventoryApi(mockSvc)
+			invApi.SetCatalog(tc.client)
+			r := chi.NewRouter()
+			invApi.ConfigureRouter(r)
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			pi := getTestProductInventory()[0]
+			mockSvc.GetProductFunc = func(_ context.Context, _ string) (inventory.Product, error) {
+				return pi.Product, nil
+			}
+			mockSvc.GetProductInventoryFunc = func(_ context.Context, _ string) (inventory.ProductInventory, error) {
+				return pi, nil
+			}
+
+			res, err := http.Get(ts.URL + "/" + pi.Sku)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status=%d, want 200", res.StatusCode)
+			}
+			got := inventory.ProductResponse{}
+			testutil.Unmarshal(res, &got, t)
+
+			if !reflect.DeepEqual(got.Catalog, tc.wantCatalog) {
+				t.Errorf("Catalog\n got=%+v\nwant=%+v", got.Catalog, tc.wantCatalog)
+			}
+		})
+	}
+}
+
+// stubCatalogClient is the test seam for the catalog.Client surface.
+// Production code receives a *catalog.HTTPClient; the API test only
+// needs Lookup to return canned data.
+type stubCatalogClient struct {
+	lookup func(ctx context.Context, sku string) (catalog.Product, error)
+}
+
+func (s stubCatalogClient) Lookup(ctx context.Context, sku string) (catalog.Product, error) {
+	return s.lookup(ctx, sku)
+}
+
+func createProductionEventRequest(requestID string, quantity int64) *inventory.CreateProductionEventRequest {
+	return &inventory.CreateProductionEventRequest{
+		ProductionRequest: &inventory.ProductionRequest{RequestID: requestID, Quantity: quantity},
+	}
+}
+
+func createProductRequest(name, sku, upc string) inventory.CreateProductRequest {
+	return inventory.CreateProductRequest{Product: inventory.Product{Name: name, Sku: sku, Upc: upc}}
+}
+
+func createProductResponse(name, sku, upc string, available int64) *inventory.ProductResponse {
+	return &inventory.ProductResponse{
+		ProductInventory: inventory.ProductInventory{
+			Available: available,
+			Product:   inventory.Product{Name: name, Sku: sku, Upc: upc},
+		},
+	}
+}
+
+// heldProductInventory has stock in every status, for checking the
+// breakdown reaches the response.
+func heldProductInventory() inventory.ProductInventory {
+	pi := inventory.ProductInventory{Product: inventory.Product{Sku: "test1sku", Upc: "test1upc", Name: "test1name"}}
+	pi.Add("east", 4)
+	pi.AddStatus("east", inventory.StatusOnHold, 3)
+	pi.AddStatus("east", inventory.StatusQuarantined, 2)
+	pi.AddStatus("west", inventory.StatusDamaged, 1)
+	return pi
+}
+
+func getTestProductInventory() []inventory.ProductInventory {
+	return []inventory.ProductInventory{
+		{Available: 1, Product: inventory.Product{Sku: "test1sku", Upc: "test1upc", Name: "test1name"}},
+		{Available: 2, Product: inventory.Product{Sku: "test2sku", Upc: "test2upc", Name: "test2name"}},
+		{Available: 3, Product: inventory.Product{Sku: "test3sku", Upc: "test3upc", Name: "test3name"}},
+	}
+}
+
+func TestInventoryLots(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	expiresAt := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+		return inventory.Product{Sku: sku}, nil
+	}
+	mockInvSvc.GetLotsFunc = func(ctx context.Context, sku string) ([]inventory.Lot, error) {
+		return []inventory.Lot{{Sku: sku, Location: "east", Lot: "L1", ExpiresAt: &expiresAt, Available: 4}}, nil
+	}
+
+	res := testutil.SendRequest(http.MethodGet, ts.URL+"/sku1/lots", nil, t)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	var got []inventory.Lot
+	testutil.Unmarshal(res, &got, t)
+	want := []inventory.Lot{{Sku: "sku1", Location: "east", Lot: "L1", ExpiresAt: &expiresAt, Available: 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lots got=%+v want=%+v", got, want)
+	}
+}
+
+func TestInventoryLotRecipients(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+		return inventory.Product{Sku: sku}, nil
+	}
+	var gotSku, gotLot string
+	var gotLimit, gotOffset int
+	mockInvSvc.GetLotRecipientsFunc = func(ctx context.Context, sku, lot string, limit, offset int) ([]inventory.LotRecipient, error) {
+		gotSku, gotLot, gotLimit, gotOffset = sku, lot, limit, offset
+		return []inventory.LotRecipient{{ReservationID: 9, RequestID: "req9", Requester: "alice", Location: "east", State: inventory.Closed, Quantity: 3}}, nil
+	}
+
+	res := testutil.SendRequest(http.MethodGet, ts.URL+"/sku1/lots/L1/recipients?limit=10&offset=20", nil, t)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	if gotSku != "sku1" || gotLot != "L1" || gotLimit != 10 || gotOffset != 20 {
+		t.Errorf("service called with sku=%q lot=%q limit=%d offset=%d", gotSku, gotLot, gotLimit, gotOffset)
+	}
+	var got []inventory.LotRecipient
+	testutil.Unmarshal(res, &got, t)
+	if len(got) != 1 || got[0].Requester != "alice" || got[0].Quantity != 3 {
+		t.Errorf("unexpected recipients %+v", got)
+	}
+}
+
+func TestInventoryReorderPolicy(t *testing.T) {
+	manager := &user.User{Username: "carol", IsInventoryManager: true}
+	archived := fmt.Errorf("product \"sku1\" is archived: %w", inventory.ErrProductState)
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		method         string
+		request        interface{}
+		getFunc        func(ctx context.Context, sku string) (inventory.ReorderPolicy, error)
+		setFunc        func(ctx context.Context, policy inventory.ReorderPolicy) (inventory.ReorderPolicy, error)
+		deleteFunc     func(ctx context.Context, sku string) error
+		wantSet        *inventory.ReorderPolicy
+		wantBody       *inventory.ReorderPolicy
+		wantErr        *httpx.Problem
+		wantStatusCode int
 	}{
 		{
-			request:             createProductRequest("name1", "sku1", "upc1"),
-			serviceErr:          nil,
-			wantProductResponse: createProductResponse("name1", "sku1", "upc1", 0),
-			wantErr:             nil,
-			wantStatusCode:      http.StatusCreated,
+			name:   "get",
+			method: http.MethodGet,
+			getFunc: func(ctx context.Context, sku string) (inventory.ReorderPolicy, error) {
+				return inventory.ReorderPolicy{Sku: sku, ReorderPoint: 10, TargetLevel: 50}, nil
+			},
+			wantBody:       &inventory.ReorderPolicy{Sku: "sku1", ReorderPoint: 10, TargetLevel: 50},
+			wantStatusCode: http.StatusOK,
 		},
 		{
-			request:             createProductRequest("name1", "sku1", "upc1"),
-			serviceErr:          errors.New("some unexpected error"),
-			wantProductResponse: nil,
-			wantErr:             httpx.InternalServerProblem(nil),
-			wantStatusCode:      http.StatusInternalServerError,
+			name:           "get without a policy",
+			method:         http.MethodGet,
+			wantErr:        httpx.NotFoundProblem(),
+			wantStatusCode: http.StatusNotFound,
 		},
 		{
-			request:             createProductRequest("name1", "sku1", ""),
-			serviceErr:          nil,
-			wantProductResponse: nil,
-			wantErr:             httpx.BadRequestProblem(errors.New("missing required field(s)")),
-			wantStatusCode:      http.StatusBadRequest,
+			name:           "put",
+			user:           manager,
+			method:         http.MethodPut,
+			request:        &inventory.ReorderPolicyRequestDto{ReorderPoint: 10, TargetLevel: 50},
+			wantSet:        &inventory.ReorderPolicy{Sku: "sku1", ReorderPoint: 10, TargetLevel: 50},
+			wantBody:       &inventory.ReorderPolicy{Sku: "sku1", ReorderPoint: 10, TargetLevel: 50},
+			wantStatusCode: http.StatusOK,
 		},
 		{
-			request:             createProductRequest("name1", "", "upc1"),
-			serviceErr:          nil,
-			wantProductResponse: nil,
-			wantErr:             httpx.BadRequestProblem(errors.New("missing required field(s)")),
-			wantStatusCode:      http.StatusBadRequest,
+			name:           "put a target below the reorder point",
+			user:           manager,
+			method:         http.MethodPut,
+			request:        &inventory.ReorderPolicyRequestDto{ReorderPoint: 10, TargetLevel: 5},
+			wantErr:        httpx.BadRequestProblem(errors.New("targetLevel must not be below reorderPoint")),
+			wantStatusCode: http.StatusBadRequest,
 		},
 		{
-			request:             createProductRequest("", "sku1", "upc1"),
-			serviceErr:          nil,
-			wantProductResponse: nil,
-			wantErr:             httpx.BadRequestProblem(errors.New("missing required field(s)")),
-			wantStatusCode:      http.StatusBadRequest,
+			name:    "put on an archived product",
+			user:    manager,
+			method:  http.MethodPut,
+			request: &inventory.ReorderPolicyRequestDto{ReorderPoint: 10, TargetLevel: 50},
+			setFunc: func(ctx context.Context, policy inventory.ReorderPolicy) (inventory.ReorderPolicy, error) {
+				return inventory.ReorderPolicy{}, archived
+			},
+			wantSet:        &inventory.ReorderPolicy{Sku: "sku1", ReorderPoint: 10, TargetLevel: 50},
+			wantErr:        httpx.ConflictProblem(archived),
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "plain user can't put",
+			user:           &user.User{Username: "dave"},
+			method:         http.MethodPut,
+			request:        &inventory.ReorderPolicyRequestDto{ReorderPoint: 10, TargetLevel: 50},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "delete",
+			user:           manager,
+			method:         http.MethodDelete,
+			wantStatusCode: http.StatusNoContent,
+		},
+		{
+			name:   "delete without a policy",
+			user:   manager,
+			method: http.MethodDelete,
+			deleteFunc: func(ctx context.Context, sku string) error {
+				return persistence.ErrNotFound
+			},
+			wantErr:        httpx.NotFoundProblem(),
+			wantStatusCode: http.StatusNotFound,
 		},
 	}
 
 	for _, test := range tests {
-		mockInvSvc.CreateProductFunc = func(ctx context.Context, product inventory.Product) error {
-			return test.serviceErr
-		}
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			if test.getFunc != nil {
+				mockInvSvc.GetReorderPolicyFunc = test.getFunc
+			}
+			if test.deleteFunc != nil {
+				mockInvSvc.DeleteReorderPolicyFunc = test.deleteFunc
+			}
+			var gotSet *inventory.ReorderPolicy
+			mockInvSvc.SetReorderPolicyFunc = func(ctx context.Context, policy inventory.ReorderPolicy) (inventory.ReorderPolicy, error) {
+				gotSet = &policy
+				if test.setFunc != nil {
+					return test.setFunc(ctx, policy)
+				}
+				return policy, nil
+			}
 
-		res := testutil.Put(ts.URL, test.request, t)
+			res := testutil.SendRequest(test.method, ts.URL+"/sku1/reorder", test.request, t)
 
-		if res.StatusCode != test.wantStatusCode {
-			t.Errorf("status code got=%d\nwant=%d", res.StatusCode, test.wantStatusCode)
-		}
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if !reflect.DeepEqual(gotSet, test.wantSet) {
+				t.Errorf("set got=%+v want=%+v", gotSet, test.wantSet)
+			}
 
-		if test.wantErr == nil {
-			got := inventory.ProductResponse{}
-			testutil.Unmarshal(res, &got, t)
+			switch {
+			case test.wantBody != nil:
+				got := inventory.ReorderPolicyResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got.ReorderPolicy, *test.wantBody) {
+					t.Errorf("reorder policy\n got=%+v\nwant=%+v", got.ReorderPolicy, *test.wantBody)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
 
-			if !reflect.DeepEqual(got, *test.wantProductResponse) {
-				t.Errorf("product\n got=%+v\nwant=%+v", got, *test.wantProductResponse)
+func TestInventoryLowStock(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+
+	lowSince := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+	var gotLimit, gotOffset int
+	mockInvSvc.GetLowStockFunc = func(ctx context.Context, limit, offset int) ([]inventory.LowStock, error) {
+		gotLimit, gotOffset = limit, offset
+		return []inventory.LowStock{{Sku: "sku1", Available: 4, ReorderPoint: 10, TargetLevel: 50, SuggestedQuantity: 46, LowSince: &lowSince}}, nil
+	}
+
+	res := testutil.SendRequest(http.MethodGet, ts.URL+"/low-stock?limit=10&offset=20", nil, t)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	if gotLimit != 10 || gotOffset != 20 {
+		t.Errorf("service called with limit=%d offset=%d", gotLimit, gotOffset)
+	}
+	if mockInvSvc.GetProductCalls != 0 {
+		t.Errorf("low-stock was routed as a sku")
+	}
+	var got []inventory.LowStock
+	testutil.Unmarshal(res, &got, t)
+	want := []inventory.LowStock{{Sku: "sku1", Available: 4, ReorderPoint: 10, TargetLevel: 50, SuggestedQuantity: 46, LowSince: &lowSince}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("low stock got=%+v want=%+v", got, want)
+	}
+}
+
+func TestInventoryPlannedOrders(t *testing.T) {
+	manager := &user.User{Username: "carol", IsInventoryManager: true}
+	expected := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	completed := fmt.Errorf("planned order \"plan1\" is Completed: %w", inventory.ErrInvalidInput)
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		method         string
+		path           string
+		request        interface{}
+		cancelFunc     func(ctx context.Context, sku, requestID string) (inventory.PlannedOrder, error)
+		wantPlanned    *inventory.PlannedOrderRequest
+		wantBody       *inventory.PlannedOrder
+		wantErr        *httpx.Problem
+		wantStatusCode int
+	}{
+		{
+			name:           "put",
+			user:           manager,
+			method:         http.MethodPut,
+			path:           "/sku1/planned",
+			request:        &inventory.PlannedOrderRequest{RequestID: "plan1", Quantity: 20, ExpectedDate: expected},
+			wantPlanned:    &inventory.PlannedOrderRequest{RequestID: "plan1", Quantity: 20, ExpectedDate: expected},
+			wantBody:       &inventory.PlannedOrder{RequestID: "plan1", Sku: "sku1", Quantity: 20, ExpectedDate: expected, State: inventory.PlannedOrderScheduled},
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "put without an expected date",
+			user:           manager,
+			method:         http.MethodPut,
+			path:           "/sku1/planned",
+			request:        &inventory.PlannedOrderRequest{RequestID: "plan1", Quantity: 20},
+			wantErr:        httpx.BadRequestProblem(errors.New("expectedDate is required")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "plain user can't put",
+			user:           &user.User{Username: "dave"},
+			method:         http.MethodPut,
+			path:           "/sku1/planned",
+			request:        &inventory.PlannedOrderRequest{RequestID: "plan1", Quantity: 20, ExpectedDate: expected},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "cancel",
+			user:           manager,
+			method:         http.MethodDelete,
+			path:           "/sku1/planned/plan1",
+			wantBody:       &inventory.PlannedOrder{RequestID: "plan1", Sku: "sku1", State: inventory.PlannedOrderCancelled},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:   "cancel an unknown order",
+			user:   manager,
+			method: http.MethodDelete,
+			path:   "/sku1/planned/plan1",
+			cancelFunc: func(ctx context.Context, sku, requestID string) (inventory.PlannedOrder, error) {
+				return inventory.PlannedOrder{}, persistence.ErrNotFound
+			},
+			wantErr:        httpx.NotFoundProblem(),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:   "cancel a completed order",
+			user:   manager,
+			method: http.MethodDelete,
+			path:   "/sku1/planned/plan1",
+			cancelFunc: func(ctx context.Context, sku, requestID string) (inventory.PlannedOrder, error) {
+				return inventory.PlannedOrder{}, completed
+			},
+			wantErr:        httpx.BadRequestProblem(completed),
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			if test.cancelFunc != nil {
+				mockInvSvc.CancelPlannedOrderFunc = test.cancelFunc
+			}
+			var gotPlanned *inventory.PlannedOrderRequest
+			planFunc := mockInvSvc.PlanProductionFunc
+			mockInvSvc.PlanProductionFunc = func(ctx context.Context, product inventory.Product, pr inventory.PlannedOrderRequest) (inventory.PlannedOrder, error) {
+				gotPlanned = &pr
+				return planFunc(ctx, product, pr)
 			}
-		} else {
-			got := &httpx.Problem{}
-			testutil.Unmarshal(res, got, t)
 
-			if got.Title != test.wantErr.Title {
-				t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+			res := testutil.SendRequest(test.method, ts.URL+test.path, test.request, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
 			}
-			if got.Detail != test.wantErr.Detail {
-				t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+			if !reflect.DeepEqual(gotPlanned, test.wantPlanned) {
+				t.Errorf("planned got=%+v want=%+v", gotPlanned, test.wantPlanned)
 			}
-		}
+
+			switch {
+			case test.wantBody != nil:
+				got := inventory.PlannedOrderResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got.PlannedOrder, *test.wantBody) {
+					t.Errorf("planned order\n got=%+v\nwant=%+v", got.PlannedOrder, *test.wantBody)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
 	}
 }
 
-func TestInventoryCreateProductionEvent(t *testing.T) {
+func TestInventoryListPlannedOrders(t *testing.T) {
 	ts, mockInvSvc := setupInventoryTestServer()
 	defer ts.Close()
 
+	expected := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+		return inventory.Product{Sku: sku}, nil
+	}
+	var gotSku string
+	var gotLimit, gotOffset int
+	mockInvSvc.GetScheduledOrdersFunc = func(ctx context.Context, sku string, limit, offset int) ([]inventory.PlannedOrder, error) {
+		gotSku, gotLimit, gotOffset = sku, limit, offset
+		return []inventory.PlannedOrder{{ID: 3, RequestID: "plan1", Sku: sku, Quantity: 20, ExpectedDate: expected, State: inventory.PlannedOrderScheduled}}, nil
+	}
+
+	res := testutil.SendRequest(http.MethodGet, ts.URL+"/sku1/planned?limit=10&offset=20", nil, t)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	if gotSku != "sku1" || gotLimit != 10 || gotOffset != 20 {
+		t.Errorf("service called with sku=%q limit=%d offset=%d", gotSku, gotLimit, gotOffset)
+	}
+	var got []inventory.PlannedOrder
+	testutil.Unmarshal(res, &got, t)
+	want := []inventory.PlannedOrder{{ID: 3, RequestID: "plan1", Sku: "sku1", Quantity: 20, ExpectedDate: expected, State: inventory.PlannedOrderScheduled}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planned orders got=%+v want=%+v", got, want)
+	}
+}
+
+func TestInventoryAvailableToPromise(t *testing.T) {
+	promise := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	discontinued := fmt.Errorf("product \"sku1\" is discontinued and can't be promised: %w", inventory.ErrProductState)
+
 	tests := []struct {
-		getProductFunc              func(ctx context.Context, sku string) (inventory.Product, error)
-		produceFunc                 func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error
-		sku                         string
-		request                     *inventory.CreateProductionEventRequest
-		wantProductionEventResponse *inventory.ProductionEventResponse
-		wantErr                     *httpx.Problem
-		wantStatusCode              int
+		name           string
+		query          string
+		atpFunc        func(ctx context.Context, sku string, quantity int64) (inventory.AvailableToPromise, error)
+		wantQuantity   int64
+		wantBody       *inventory.AvailableToPromise
+		wantErr        *httpx.Problem
+		wantStatusCode int
 	}{
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return getTestProductInventory()[0].Product, nil
+			name:  "promisable",
+			query: "?quantity=5",
+			atpFunc: func(ctx context.Context, sku string, quantity int64) (inventory.AvailableToPromise, error) {
+				return inventory.AvailableToPromise{Sku: sku, Quantity: quantity, Available: 2, Scheduled: 20, Promisable: true, PromiseDate: &promise}, nil
 			},
-			produceFunc: func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error {
-				return nil
-			},
-			sku:                         "testsku1",
-			request:                     createProductionEventRequest("abc123", 1),
-			wantProductionEventResponse: &inventory.ProductionEventResponse{},
-			wantErr:                     nil,
-			wantStatusCode:              http.StatusCreated,
+			wantQuantity:   5,
+			wantBody:       &inventory.AvailableToPromise{Sku: "sku1", Quantity: 5, Available: 2, Scheduled: 20, Promisable: true, PromiseDate: &promise},
+			wantStatusCode: http.StatusOK,
 		},
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return inventory.Product{}, persistence.ErrNotFound
-			},
-			produceFunc:                 nil,
-			sku:                         "testsku1",
-			request:                     createProductionEventRequest("abc123", 1),
-			wantProductionEventResponse: nil,
-			wantErr:                     httpx.NotFoundProblem(),
-			wantStatusCode:              http.StatusNotFound,
+			name:           "not promisable",
+			query:          "?quantity=500",
+			wantQuantity:   500,
+			wantBody:       &inventory.AvailableToPromise{Sku: "sku1", Quantity: 500},
+			wantStatusCode: http.StatusOK,
 		},
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return inventory.Product{}, errors.New("some unexpected error")
+			name:  "discontinued product",
+			query: "?quantity=5",
+			atpFunc: func(ctx context.Context, sku string, quantity int64) (inventory.AvailableToPromise, error) {
+				return inventory.AvailableToPromise{}, discontinued
 			},
-			produceFunc:                 nil,
-			sku:                         "testsku1",
-			request:                     createProductionEventRequest("abc123", 1),
-			wantProductionEventResponse: nil,
-			wantErr:                     httpx.InternalServerProblem(nil),
-			wantStatusCode:              http.StatusInternalServerError,
+			wantQuantity:   5,
+			wantErr:        httpx.ConflictProblem(discontinued),
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "missing quantity",
+			wantErr:        httpx.BadRequestProblem(errors.New("quantity must be a whole number greater than zero")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "zero quantity",
+			query:          "?quantity=0",
+			wantErr:        httpx.BadRequestProblem(errors.New("quantity must be a whole number greater than zero")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupInventoryTestServer()
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			if test.atpFunc != nil {
+				mockInvSvc.AvailableToPromiseFunc = test.atpFunc
+			}
+
+			res := testutil.SendRequest(http.MethodGet, ts.URL+"/sku1/atp"+test.query, nil, t)
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if test.wantQuantity == 0 && mockInvSvc.AvailableToPromiseCalls != 0 {
+				t.Errorf("service was called for an invalid quantity")
+			}
+
+			switch {
+			case test.wantBody != nil:
+				got := inventory.AvailableToPromiseResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got.AvailableToPromise, *test.wantBody) {
+					t.Errorf("available to promise\n got=%+v\nwant=%+v", got.AvailableToPromise, *test.wantBody)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryReturns(t *testing.T) {
+	manager := &user.User{Username: "carol", IsInventoryManager: true}
+	tooMany := fmt.Errorf("quantity 4 exceeds the 3 shipped on reservation 7 and not yet returned: %w", inventory.ErrInvalidInput)
+	archived := fmt.Errorf("product \"sku1\" is archived: %w", inventory.ErrProductState)
+
+	tests := []struct {
+		name           string
+		user           *user.User
+		request        interface{}
+		returnFunc     func(ctx context.Context, product inventory.Product, rr inventory.ReturnRequest) (inventory.Return, error)
+		wantReturn     *inventory.ReturnRequest
+		wantBody       *inventory.Return
+		wantErr        *httpx.Problem
+		wantStatusCode int
+	}{
+		{
+			name:           "restock against a reservation",
+			user:           manager,
+			request:        &inventory.ReturnRequest{RequestID: "rma1", Quantity: 2, Outcome: inventory.ReturnRestock, ReservationID: 7},
+			wantReturn:     &inventory.ReturnRequest{RequestID: "rma1", Quantity: 2, Outcome: inventory.ReturnRestock, ReservationID: 7},
+			wantBody:       &inventory.Return{RequestID: "rma1", Sku: "sku1", Quantity: 2, Outcome: inventory.ReturnRestock},
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "scrap from a requester",
+			user:           manager,
+			request:        &inventory.ReturnRequest{RequestID: "rma1", Quantity: 1, Outcome: inventory.ReturnScrap, Requester: "walk-in"},
+			wantReturn:     &inventory.ReturnRequest{RequestID: "rma1", Quantity: 1, Outcome: inventory.ReturnScrap, Requester: "walk-in"},
+			wantBody:       &inventory.Return{RequestID: "rma1", Sku: "sku1", Requester: "walk-in", Quantity: 1, Outcome: inventory.ReturnScrap},
+			wantStatusCode: http.StatusCreated,
 		},
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return getTestProductInventory()[0].Product, nil
+			name:           "neither reservation nor requester",
+			user:           manager,
+			request:        &inventory.ReturnRequest{RequestID: "rma1", Quantity: 1, Outcome: inventory.ReturnScrap},
+			wantErr:        httpx.BadRequestProblem(errors.New("reservationId or requester is required")),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:    "more than was shipped",
+			user:    manager,
+			request: &inventory.ReturnRequest{RequestID: "rma1", Quantity: 4, Outcome: inventory.ReturnRestock, ReservationID: 7},
+			returnFunc: func(ctx context.Context, product inventory.Product, rr inventory.ReturnRequest) (inventory.Return, error) {
+				return inventory.Return{}, tooMany
 			},
-			produceFunc: func(ctx context.Context, product inventory.Product, event inventory.ProductionRequest) error {
-				return errors.New("some unexpected error")
+			wantReturn:     &inventory.ReturnRequest{RequestID: "rma1", Quantity: 4, Outcome: inventory.ReturnRestock, ReservationID: 7},
+			wantErr:        httpx.BadRequestProblem(tooMany),
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:    "archived product",
+			user:    manager,
+			request: &inventory.ReturnRequest{RequestID: "rma1", Quantity: 1, Outcome: inventory.ReturnRestock, Requester: "walk-in"},
+			returnFunc: func(ctx context.Context, product inventory.Product, rr inventory.ReturnRequest) (inventory.Return, error) {
+				return inventory.Return{}, archived
 			},
-			sku:                         "testsku1",
-			request:                     createProductionEventRequest("abc123", 1),
-			wantProductionEventResponse: nil,
-			wantErr:                     httpx.InternalServerProblem(nil),
-			wantStatusCode:              http.StatusInternalServerError,
+			wantReturn:     &inventory.ReturnRequest{RequestID: "rma1", Quantity: 1, Outcome: inventory.ReturnRestock, Requester: "walk-in"},
+			wantErr:        httpx.ConflictProblem(archived),
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "plain user can't record returns",
+			user:           &user.User{Username: "dave"},
+			request:        &inventory.ReturnRequest{RequestID: "rma1", Quantity: 1, Outcome: inventory.ReturnScrap, Requester: "walk-in"},
+			wantStatusCode: http.StatusUnauthorized,
 		},
 	}
 
 	for _, test := range tests {
-		mockInvSvc.GetProductFunc = test.getProductFunc
-		mockInvSvc.ProduceFunc = test.produceFunc
-
-		url := ts.URL + "/" + test.sku + "/productionEvent"
-		res := testutil.Put(url, test.request, t)
-
-		if res.StatusCode != test.wantStatusCode {
-			t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
-		}
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
+			mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+				return inventory.Product{Sku: sku}, nil
+			}
+			if test.returnFunc != nil {
+				mockInvSvc.RecordReturnFunc = test.returnFunc
+			}
+			var gotReturn *inventory.ReturnRequest
+			returnFunc := mockInvSvc.RecordReturnFunc
+			mockInvSvc.RecordReturnFunc = func(ctx context.Context, product inventory.Product, rr inventory.ReturnRequest) (inventory.Return, error) {
+				gotReturn = &rr
+				return returnFunc(ctx, product, rr)
+			}
 
-		if test.wantErr == nil {
-			got := inventory.ProductionEventResponse{}
-			testutil.Unmarshal(res, &got, t)
+			res := testutil.SendRequest(http.MethodPut, ts.URL+"/sku1/returns", test.request, t)
 
-			if !reflect.DeepEqual(got, *test.wantProductionEventResponse) {
-				t.Errorf("product\n got=%+v\nwant=%+v", got, *test.wantProductionEventResponse)
+			if res.StatusCode != test.wantStatusCode {
+				t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
 			}
-		} else {
-			got := &httpx.Problem{}
-			testutil.Unmarshal(res, got, t)
-
-			if got.Title != test.wantErr.Title {
-				t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+			if !reflect.DeepEqual(gotReturn, test.wantReturn) {
+				t.Errorf("return got=%+v want=%+v", gotReturn, test.wantReturn)
 			}
-			if got.Detail != test.wantErr.Detail {
-				t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+
+			switch {
+			case test.wantBody != nil:
+				got := inventory.ReturnResponse{}
+				testutil.Unmarshal(res, &got, t)
+				if !reflect.DeepEqual(got.Return, *test.wantBody) {
+					t.Errorf("return\n got=%+v\nwant=%+v", got.Return, *test.wantBody)
+				}
+			case test.wantErr != nil:
+				got := &httpx.Problem{}
+				testutil.Unmarshal(res, got, t)
+				if got.Title != test.wantErr.Title {
+					t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+				}
+				if got.Detail != test.wantErr.Detail {
+					t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+				}
 			}
-		}
+		})
 	}
 }
 
-func TestInventoryGetProductInventory(t *testing.T) {
+func TestInventoryListReturns(t *testing.T) {
 	ts, mockInvSvc := setupInventoryTestServer()
 	defer ts.Close()
 
+	created := time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)
+	mockInvSvc.GetProductFunc = func(ctx context.Context, sku string) (inventory.Product, error) {
+		return inventory.Product{Sku: sku}, nil
+	}
+	var gotSku string
+	var gotLimit, gotOffset int
+	mockInvSvc.GetReturnsFunc = func(ctx context.Context, sku string, limit, offset int) ([]inventory.Return, error) {
+		gotSku, gotLimit, gotOffset = sku, limit, offset
+		return []inventory.Return{{ID: 3, RequestID: "rma1", Sku: sku, Location: "default", Requester: "walk-in", Quantity: 1, Outcome: inventory.ReturnScrap, Created: created}}, nil
+	}
+
+	res := testutil.SendRequest(http.MethodGet, ts.URL+"/sku1/returns?limit=10&offset=20", nil, t)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	if gotSku != "sku1" || gotLimit != 10 || gotOffset != 20 {
+		t.Errorf("service called with sku=%q limit=%d offset=%d", gotSku, gotLimit, gotOffset)
+	}
+	var got []inventory.Return
+	testutil.Unmarshal(res, &got, t)
+	want := []inventory.Return{{ID: 3, RequestID: "rma1", Sku: "sku1", Location: "default", Requester: "walk-in", Quantity: 1, Outcome: inventory.ReturnScrap, Created: created}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("returns got=%+v want=%+v", got, want)
+	}
+}
+
+func TestInventoryImport(t *testing.T) {
+	admin := &user.User{Username: "alice", IsAdmin: true}
+
 	tests := []struct {
-		sku                     string
-		getProductFunc          func(ctx context.Context, sku string) (inventory.Product, error)
-		getProductInventoryFunc func(ctx context.Context, sku string) (inventory.ProductInventory, error)
-		wantProductResponse     *inventory.ProductResponse
-		wantErr                 *httpx.Problem
-		wantStatusCode          int
+		name        string
+		user        *user.User
+		contentType string
+		query       string
+		body        string
+		serviceErr  error
+
+		wantDryRun     bool
+		wantRows       []inventory.ProductImport
+		wantRowErrors  []inventory.ImportRowError
+		wantStatusCode int
 	}{
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return getTestProductInventory()[0].Product, nil
+			name:        "csv",
+			user:        admin,
+			contentType: "text/csv",
+			body:        "sku,upc,name,quantity,location\nsku1,upc1,Widget,10,\n sku2 , upc2 ,\"Gadget, large\",0,east\n",
+			wantRows: []inventory.ProductImport{
+				{Line: 2, Sku: "sku1", Upc: "upc1", Name: "Widget", Quantity: 10},
+				{Line: 3, Sku: "sku2", Upc: "upc2", Name: "Gadget, large", Location: "east"},
 			},
-			getProductInventoryFunc: func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
-				return getTestProductInventory()[0], nil
-			},
-			sku:                 "test1sku",
-			wantProductResponse: createProductResponse("test1name", "test1sku", "test1upc", 1),
-			wantErr:             nil,
-			wantStatusCode:      http.StatusOK,
+			wantRowErrors:  []inventory.ImportRowError{},
+			wantStatusCode: http.StatusOK,
 		},
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return inventory.Product{}, persistence.ErrNotFound
+			name:        "csv columns in any order without location",
+			user:        admin,
+			contentType: "text/csv; charset=utf-8",
+			query:       "?dryRun=true",
+			body:        "Name,SKU,Quantity,UPC\nWidget,sku1,,upc1\n",
+			wantDryRun:  true,
+			wantRows: []inventory.ProductImport{
+				{Line: 2, Sku: "sku1", Upc: "upc1", Name: "Widget"},
 			},
-			getProductInventoryFunc: nil,
-			sku:                     "test1sku",
-			wantProductResponse:     nil,
-			wantErr:                 httpx.NotFoundProblem(),
-			wantStatusCode:          http.StatusNotFound,
+			wantRowErrors:  []inventory.ImportRowError{},
+			wantStatusCode: http.StatusOK,
 		},
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return getTestProductInventory()[0].Product, nil
+			name:        "csv rows that can't be read",
+			user:        admin,
+			contentType: "text/csv",
+			body:        "sku,upc,name,quantity\nsku1,upc1,Widget,ten\nsku2,upc2\nsku3,upc3,Gizmo,3\n",
+			wantRows: []inventory.ProductImport{
+				{Line: 4, Sku: "sku3", Upc: "upc3", Name: "Gizmo", Quantity: 3},
 			},
-			getProductInventoryFunc: func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
-				return inventory.ProductInventory{}, persistence.ErrNotFound
+			wantRowErrors: []inventory.ImportRowError{
+				{Line: 2, Sku: "sku1", Detail: `quantity "ten" is not a whole number`},
+				{Line: 3, Detail: "wrong number of fields"},
 			},
-			sku:                 "test1sku",
-			wantProductResponse: nil,
-			wantErr:             httpx.NotFoundProblem(),
-			wantStatusCode:      http.StatusNotFound,
+			wantStatusCode: http.StatusOK,
 		},
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return inventory.Product{}, errors.New("some unexpected error")
-			},
-			getProductInventoryFunc: nil,
-			sku:                     "test1sku",
-			wantProductResponse:     nil,
-			wantErr:                 httpx.InternalServerProblem(nil),
-			wantStatusCode:          http.StatusInternalServerError,
+			name:           "csv header missing a column",
+			user:           admin,
+			contentType:    "text/csv",
+			body:           "sku,name,quantity\nsku1,Widget,1\n",
+			wantStatusCode: http.StatusBadRequest,
 		},
 		{
-			getProductFunc: func(ctx context.Context, sku string) (inventory.Product, error) {
-				return getTestProductInventory()[0].Product, nil
+			name:           "empty csv",
+			user:           admin,
+			contentType:    "text/csv",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:        "ndjson",
+			user:        admin,
+			contentType: "application/x-ndjson",
+			body:        "{\"sku\":\"sku1\",\"upc\":\"upc1\",\"name\":\"Widget\",\"quantity\":10}\n\n{\"sku\":\"sku2\",\"upc\":\"upc2\",\"name\":\"Gadget\",\"location\":\"east\"}\n{\"sku\":\"sku3\",\"quantity\":\"many\"}\n",
+			wantRows: []inventory.ProductImport{
+				{Line: 1, Sku: "sku1", Upc: "upc1", Name: "Widget", Quantity: 10},
+				{Line: 3, Sku: "sku2", Upc: "upc2", Name: "Gadget", Location: "east"},
 			},
-			getProductInventoryFunc: func(ctx context.Context, sku string) (inventory.ProductInventory, error) {
-				return inventory.ProductInventory{}, errors.New("some unexpected error")
+			wantRowErrors: []inventory.ImportRowError{
+				{Line: 4, Detail: "json: cannot unmarshal string into Go struct field ProductImport.quantity of type int64"},
 			},
-			sku:                 "test1sku",
-			wantProductResponse: nil,
-			wantErr:             httpx.InternalServerProblem(nil),
-			wantStatusCode:      http.StatusInternalServerError,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "bad dryRun",
+			user:           admin,
+			contentType:    "text/csv",
+			query:          "?dryRun=maybe",
+			body:           "sku,upc,name,quantity\n",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported body",
+			user:           admin,
+			contentType:    "application/json",
+			body:           "[]",
+			wantStatusCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "service failure",
+			user:           admin,
+			contentType:    "text/csv",
+			body:           "sku,upc,name,quantity\n",
+			serviceErr:     errors.New("copy products: boom"),
+			wantStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:           "inventory managers can't import",
+			user:           &user.User{Username: "carol", IsInventoryManager: true},
+			contentType:    "text/csv",
+			body:           "sku,upc,name,quantity\n",
+			wantStatusCode: http.StatusUnauthorized,
 		},
 	}
 
 	for _, test := range tests {
-		mockInvSvc.GetProductFunc = test.getProductFunc
-		mockInvSvc.GetProductInventoryFunc = test.getProductInventoryFunc
-
-		res, err := http.Get(ts.URL + "/" + test.sku)
-		if err != nil {
-			t.Fatal(err)
-		}
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupAdjustmentTestServer(test.user)
+			defer ts.Close()
 
-		if res.StatusCode != test.wantStatusCode {
-			t.Errorf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
-		}
+			var gotRows []inventory.ProductImport
+			var gotDryRun bool
+			mockInvSvc.ImportProductsFunc = func(ctx context.Context, rows inventory.ProductImportReader, dryRun bool) (inventory.ImportResult, error) {
+				gotDryRun = dryRun
+				result := inventory.ImportResult{DryRun: dryRun, Errors: []inventory.ImportRowError{}}
+				for {
+					row, err := rows.Read()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					var rowErr *inventory.ImportRowError
+					if errors.As(err, &rowErr) {
+						result.Errors = append(result.Errors, *rowErr)
+						continue
+					}
+					if err != nil {
+						return result, err
+					}
+					gotRows = append(gotRows, row)
+				}
+				result.Rows = len(gotRows) + len(result.Errors)
+				result.Imported = len(gotRows)
+				return result, test.serviceErr
+			}
 
-		if test.wantErr == nil {
-			got := inventory.ProductResponse{}
-			testutil.Unmarshal(res, &got, t)
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/import"+test.query, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", test.contentType)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-			if !reflect.DeepEqual(got, *test.wantProductResponse) {
-				t.Errorf("product\n got=%+v\nwant=%+v", got, *test.wantProductResponse)
+			if res.StatusCode != test.wantStatusCode {
+				t.Fatalf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+			if !reflect.DeepEqual(gotRows, test.wantRows) {
+				t.Errorf("rows\n got:%+v\nwant:%+v", gotRows, test.wantRows)
+			}
+			if gotDryRun != test.wantDryRun {
+				t.Errorf("dryRun got=%v want=%v", gotDryRun, test.wantDryRun)
 			}
-		} else {
-			got := &httpx.Problem{}
-			testutil.Unmarshal(res, got, t)
 
-			if got.Title != test.wantErr.Title {
-				t.Errorf("status text got=%s want=%s", got.Title, test.wantErr.Title)
+			got := inventory.ImportResponse{}
+			testutil.Unmarshal(res, &got, t)
+			if !reflect.DeepEqual(got.Errors, test.wantRowErrors) {
+				t.Errorf("row errors\n got:%+v\nwant:%+v", got.Errors, test.wantRowErrors)
 			}
-			if got.Detail != test.wantErr.Detail {
-				t.Errorf("error text got=%s want=%s", got.Detail, test.wantErr.Detail)
+			if got.Imported != len(test.wantRows) || got.DryRun != test.wantDryRun {
+				t.Errorf("result got=%+v", got.ImportResult)
 			}
-		}
+		})
 	}
 }
 
-// TestInventoryGetProductInventoryEnriched covers the DSN-018
-// outbound-REST enrichment path: when a catalog client is wired and
-// returns a product, the response carries the upstream description;
-// when the client returns an error, the response still succeeds
-// (catalog is best-effort) and the catalog field is omitted.
-func TestInventoryGetProductInventoryEnriched(t *testing.T) {
+func TestInventoryExport(t *testing.T) {
+	products := []inventory.ProductInventory{
+		{Product: inventory.Product{Sku: "sku1", Upc: "upc1", Name: "Widget, large", State: inventory.ProductActive, Version: 3}, Available: 7, OnHold: 1,
+			Locations: []inventory.LocationInventory{{Location: "default", Available: 5, OnHold: 1}, {Location: "east", Available: 2}}},
+		{Product: inventory.Product{Sku: "sku2", Upc: "upc2", Name: "Gadget", State: inventory.ProductDiscontinued, Version: 1},
+			Locations: []inventory.LocationInventory{{Location: "default"}}},
+	}
+	ndjson := func(products ...inventory.ProductInventory) string {
+		var b strings.Builder
+		for _, pi := range products {
+			line, _ := json.Marshal(pi)
+			b.Write(line)
+			b.WriteByte('\n')
+		}
+		return b.String()
+	}
+	csvHeader := "sku,upc,name,state,version,location,available,inTransit,expired,onHold,quarantined,damaged\n"
+
 	tests := []struct {
-		name        string
-		client      catalog.Client
-		wantCatalog *inventory.CatalogInfo
+		name       string
+		query      string
+		accept     string
+		products   []inventory.ProductInventory
+		serviceErr error
+
+		wantStatusCode  int
+		wantContentType string
+		wantBody        string
 	}{
 		{
-			name: "lookup ok adds catalog fields",
-			client: stubCatalogClient{
-				lookup: func(_ context.Context, sku string) (catalog.Product, error) {
-					return catalog.Product{Sku: sku, Description: "Widget desc", Category: "tools"}, nil
-				},
-			},
-			wantCatalog: &inventory.CatalogInfo{Description: "Widget desc", Category: "tools"},
+			name:            "ndjson by default",
+			products:        products,
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			wantBody:        ndjson(products...),
 		},
 		{
-			name: "lookup error drops to unenriched response",
-			client: stubCatalogClient{
-				lookup: func(_ context.Context, _ string) (catalog.Product, error) {
-					return catalog.Product{}, errors.New("upstream down")
-				},
-			},
-			wantCatalog: nil,
+			name:            "csv by format",
+			query:           "?format=csv",
+			accept:          "application/x-ndjson",
+			products:        products,
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        csvHeader + "sku1,upc1,\"Widget, large\",Active,3,default,5,0,0,1,0,0\nsku1,upc1,\"Widget, large\",Active,3,east,2,0,0,0,0,0\nsku2,upc2,Gadget,Discontinued,1,default,0,0,0,0,0,0\n",
 		},
 		{
-			name:        "nil client skips enrichment entirely",
-			client:      nil,
-			wantCatalog: nil,
+			name:            "csv by accept",
+			accept:          "application/json;q=0.5, text/csv",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        csvHeader,
+		},
+		{
+			name:           "unknown format",
+			query:          "?format=xml",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "failure before any rows",
+			serviceErr:     errors.New("declare cursor: connection refused"),
+			wantStatusCode: http.StatusInternalServerError,
 		},
 	}
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			mockSvc := inventory.NewMockInventoryService()
-			invApi := inventory.NewInventoryApi(mockSvc)
-			invApi.SetCatalog(tc.client)
-			r := chi.NewRouter()
-			invApi.ConfigureRouter(r)
-			ts := httptest.NewServer(r)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, mockInvSvc := setupInventoryTestServer()
 			defer ts.Close()
+			mockInvSvc.ExportProductInventoryFunc = func(ctx context.Context, fn func(inventory.ProductInventory) error) error {
+				for _, pi := range test.products {
+					if err := fn(pi); err != nil {
+						return err
+					}
+				}
+				return test.serviceErr
+			}
 
-			pi := getTestProductInventory()[0]
-			mockSvc.GetProductFunc = func(_ context.Context, _ string) (inventory.Product, error) {
-				return pi.Product, nil
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/export"+test.query, nil)
+			if err != nil {
+				t.Fatal(err)
 			}
-			mockSvc.GetProductInventoryFunc = func(_ context.Context, _ string) (inventory.ProductInventory, error) {
-				return pi, nil
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
 			}
-
-			res, err := http.Get(ts.URL + "/" + pi.Sku)
+			res, err := http.DefaultClient.Do(req)
 			if err != nil {
 				t.Fatal(err)
 			}
+			defer res.Body.Close()
+
+			if res.StatusCode != test.wantStatusCode {
+				t.Fatalf("status code got=%d want=%d", res.StatusCode, test.wantStatusCode)
+			}
 			if res.StatusCode != http.StatusOK {
-				t.Fatalf("status=%d, want 200", res.StatusCode)
+				return
 			}
-			got := inventory.ProductResponse{}
-			testutil.Unmarshal(res, &got, t)
-
-			if !reflect.DeepEqual(got.Catalog, tc.wantCatalog) {
-				t.Errorf("Catalog\n got=%+v\nwant=%+v", got.Catalog, tc.wantCatalog)
+			if got := res.Header.Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("content type got=%q want=%q", got, test.wantContentType)
+			}
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != test.wantBody {
+				t.Errorf("body\n got:%q\nwant:%q", body, test.wantBody)
 			}
 		})
 	}
 }
 
-// stubCatalogClient is the test seam for the catalog.Client surface.
-// Production code receives a *catalog.HTTPClient; the API test only
-// needs Lookup to return canned data.
-type stubCatalogClient struct {
-	lookup func(ctx context.Context, sku string) (catalog.Product, error)
-}
-
-func (s stubCatalogClient) Lookup(ctx context.Context, sku string) (catalog.Product, error) {
-	return s.lookup(ctx, sku)
-}
-
-func createProductionEventRequest(requestID string, quantity int64) *inventory.CreateProductionEventRequest {
-	return &inventory.CreateProductionEventRequest{
-		ProductionRequest: &inventory.ProductionRequest{RequestID: requestID, Quantity: quantity},
+func TestInventoryExportFailsMidStream(t *testing.T) {
+	ts, mockInvSvc := setupInventoryTestServer()
+	defer ts.Close()
+	mockInvSvc.ExportProductInventoryFunc = func(ctx context.Context, fn func(inventory.ProductInventory) error) error {
+		if err := fn(inventory.ProductInventory{Product: inventory.Product{Sku: "sku1"}}); err != nil {
+			return err
+		}
+		return errors.New("fetch: connection reset")
 	}
-}
-
-func createProductRequest(name, sku, upc string) inventory.CreateProductRequest {
-	return inventory.CreateProductRequest{Product: inventory.Product{Name: name, Sku: sku, Upc: upc}}
-}
 
-func createProductResponse(name, sku, upc string, available int64) *inventory.ProductResponse {
-	return &inventory.ProductResponse{
-		ProductInventory: inventory.ProductInventory{
-			Available: available,
-			Product:   inventory.Product{Name: name, Sku: sku, Upc: upc},
-		},
+	res, err := http.Get(ts.URL + "/export")
+	if err != nil {
+		t.Fatal(err)
 	}
-}
-
-func getTestProductInventory() []inventory.ProductInventory {
-	return []inventory.ProductInventory{
-		{Available: 1, Product: inventory.Product{Sku: "test1sku", Upc: "test1upc", Name: "test1name"}},
-		{Available: 2, Product: inventory.Product{Sku: "test2sku", Upc: "test2upc", Name: "test2name"}},
-		{Available: 3, Product: inventory.Product{Sku: "test3sku", Upc: "test3upc", Name: "test3name"}},
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status code got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	// The stream is cut off rather than ended cleanly, so the client
+	// can tell the export is incomplete.
+	if _, err := io.ReadAll(res.Body); err == nil {
+		t.Error("expected reading a cut-off export to fail")
 	}
 }
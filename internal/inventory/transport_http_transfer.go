@@ -0,0 +1,234 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog/log"
+	"github.com/sksmith/go-micro-example/internal/auth"
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+	"github.com/sksmith/go-micro-example/internal/platform/persistence"
+)
+
+const (
+	CtxKeyTransfer CtxKey = "transfer"
+)
+
+// configureTransferRouter mounts the transfer routes under
+// /inventory/{sku}/transfer. Moving stock between locations is
+// limited to admins and inventory managers, like adjustments.
+func (a *InventoryApi) configureTransferRouter(r chi.Router) {
+	r.With(httpx.Paginate).Get("/", a.ListTransfers)
+	create := auth.InventoryManagerOnly(http.HandlerFunc(a.CreateTransfer))
+	if a.idempotency != nil {
+		create = a.idempotency(create)
+	}
+	r.Method(http.MethodPut, "/", create)
+
+	r.Route("/{ID}", func(r chi.Router) {
+		r.Use(a.TransferCtx)
+		r.Get("/", a.GetTransfer)
+		r.Method(http.MethodPut, "/dispatch", auth.InventoryManagerOnly(http.HandlerFunc(a.DispatchTransfer)))
+		r.Method(http.MethodPut, "/receive", auth.InventoryManagerOnly(http.HandlerFunc(a.ReceiveTransfer)))
+	})
+}
+
+// CreateTransfer starts moving stock of a SKU from one location to
+// another.
+//
+//	@Summary	Transfer inventory between locations
+//	@Tags		inventory
+//	@Accept		json
+//	@Produce	json
+//	@Param		sku			path		string				true	"product SKU"
+//	@Param		transfer	body		TransferRequestDto	true	"transfer"
+//	@Param		If-Match	header		string	false	"apply only if the SKU is still at this ETag"
+//	@Success	201			{object}	TransferResponse
+//	@Failure	400			{object}	httpx.Problem
+//	@Failure	401			{object}	httpx.Problem
+//	@Failure	404			{object}	httpx.Problem
+//	@Failure	412		{object}	httpx.Problem
+//	@Failure	500			{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/transfer [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+
+	data := &TransferRequestDto{}
+	if err := render.Bind(r, data); err != nil {
+		httpx.Render(w, r, httpx.BadRequestProblem(err))
+		return
+	}
+
+	if !httpx.CheckPreconditions(w, r, httpx.ETag(product.Version)) {
+		return
+	}
+
+	t, err := a.service.Transfer(conditional(r), product, *data.TransferRequest)
+	if err != nil {
+		if errors.Is(err, ErrInvalidInput) {
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+			return
+		}
+		if errors.Is(err, persistence.ErrVersionConflict) {
+			httpx.Render(w, r, httpx.PreconditionFailedProblem(err))
+			return
+		}
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Str("requestId", data.RequestID).Msg("failed to transfer inventory")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	httpx.Render(w, r, &TransferResponse{Transfer: t})
+}
+
+// ListTransfers returns a page of a SKU's transfers, oldest first.
+//
+//	@Summary	List transfers for a SKU
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku		path		string	true	"product SKU"
+//	@Param		limit	query		int		false	"max items per page (≤ 200)"	default(50)
+//	@Param		offset	query		int		false	"page offset"					default(0)
+//	@Success	200		{array}		TransferResponse
+//	@Failure	400		{object}	httpx.Problem
+//	@Failure	401		{object}	httpx.Problem
+//	@Failure	404		{object}	httpx.Problem
+//	@Failure	500		{object}	httpx.Problem
+//	@Header		200		{string}	Link	"RFC 8288 next/prev links"
+//	@Router		/api/v1/inventory/{sku}/transfer [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) ListTransfers(w http.ResponseWriter, r *http.Request) {
+	product := r.Context().Value(CtxKeyProduct).(Product)
+	p := httpx.PaginationFrom(r.Context())
+
+	transfers, err := a.service.GetTransfers(r.Context(), product.Sku, p.Limit, p.Offset)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("sku", product.Sku).Msg("failed to get transfers")
+		httpx.Render(w, r, httpx.InternalServerProblem(err))
+		return
+	}
+
+	httpx.WriteLinkHeader(w, r, p, len(transfers))
+	httpx.RenderList(w, r, NewTransferListResponse(transfers))
+}
+
+// GetTransfer returns a single transfer by ID.
+//
+//	@Summary	Get a transfer
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku	path		string	true	"product SKU"
+//	@Param		ID	path		int		true	"transfer ID"
+//	@Success	200	{object}	TransferResponse
+//	@Failure	400	{object}	httpx.Problem
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/transfer/{ID} [get]
+//	@Security	BearerAuth
+func (a *InventoryApi) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	t := r.Context().Value(CtxKeyTransfer).(Transfer)
+
+	render.Status(r, http.StatusOK)
+	httpx.Render(w, r, &TransferResponse{Transfer: t})
+}
+
+// DispatchTransfer marks a Requested transfer as In-Transit.
+//
+//	@Summary	Dispatch a transfer
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku	path		string	true	"product SKU"
+//	@Param		ID	path		int		true	"transfer ID"
+//	@Success	200	{object}	TransferResponse
+//	@Failure	400	{object}	httpx.Problem
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/transfer/{ID}/dispatch [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) DispatchTransfer(w http.ResponseWriter, r *http.Request) {
+	a.advanceTransfer(w, r, a.service.DispatchTransfer, "failed to dispatch transfer")
+}
+
+// ReceiveTransfer lands an In-Transit transfer at its destination.
+//
+//	@Summary	Receive a transfer
+//	@Tags		inventory
+//	@Produce	json
+//	@Param		sku	path		string	true	"product SKU"
+//	@Param		ID	path		int		true	"transfer ID"
+//	@Success	200	{object}	TransferResponse
+//	@Failure	400	{object}	httpx.Problem
+//	@Failure	401	{object}	httpx.Problem
+//	@Failure	404	{object}	httpx.Problem
+//	@Failure	500	{object}	httpx.Problem
+//	@Router		/api/v1/inventory/{sku}/transfer/{ID}/receive [put]
+//	@Security	BearerAuth
+func (a *InventoryApi) ReceiveTransfer(w http.ResponseWriter, r *http.Request) {
+	a.advanceTransfer(w, r, a.service.ReceiveTransfer, "failed to receive transfer")
+}
+
+// advanceTransfer runs one of the transfer state transitions and
+// renders the result; dispatch and receive only differ in the
+// service call.
+func (a *InventoryApi) advanceTransfer(w http.ResponseWriter, r *http.Request, next func(ctx context.Context, ID uint64) (Transfer, error), failMsg string) {
+	t := r.Context().Value(CtxKeyTransfer).(Transfer)
+
+	t, err := next(r.Context(), t.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, persistence.ErrNotFound):
+			httpx.Render(w, r, httpx.NotFoundProblem())
+		case errors.Is(err, ErrInvalidInput):
+			httpx.Render(w, r, httpx.BadRequestProblem(err))
+		default:
+			log.Ctx(r.Context()).Error().Err(err).Uint64("id", t.ID).Msg(failMsg)
+			httpx.Render(w, r, httpx.InternalServerProblem(err))
+		}
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	httpx.Render(w, r, &TransferResponse{Transfer: t})
+}
+
+// TransferCtx resolves the {ID} path parameter to a transfer of the
+// SKU already in context. A transfer that belongs to another SKU is
+// reported as not found rather than served under the wrong path.
+func (a *InventoryApi) TransferCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		product := r.Context().Value(CtxKeyProduct).(Product)
+
+		IDStr := chi.URLParam(r, "ID")
+		ID, err := strconv.ParseUint(IDStr, 10, 64)
+		if err != nil {
+			httpx.Render(w, r, httpx.BadRequestProblem(errors.New("invalid transfer id")))
+			return
+		}
+
+		t, err := a.service.GetTransfer(r.Context(), ID)
+		if err != nil {
+			if errors.Is(err, persistence.ErrNotFound) {
+				httpx.Render(w, r, httpx.NotFoundProblem())
+			} else {
+				log.Ctx(r.Context()).Error().Err(err).Str("id", IDStr).Msg("error acquiring transfer")
+				httpx.Render(w, r, httpx.InternalServerProblem(err))
+			}
+			return
+		}
+		if t.Sku != product.Sku {
+			httpx.Render(w, r, httpx.NotFoundProblem())
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxKeyTransfer, t)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
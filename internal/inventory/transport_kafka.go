@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/sksmith/go-micro-example/internal/platform/events"
 	"github.com/sksmith/go-micro-example/internal/platform/messaging/kafka"
@@ -16,14 +17,60 @@ import (
 type InventoryEmitter struct{ Producer *kafka.Producer }
 
 // EmitProductQuantityChanged publishes an inventory.product_quantity_changed
-// v1 event for the given SKU.
-func (e *InventoryEmitter) EmitProductQuantityChanged(ctx context.Context, sku string, available int64) error {
-	return e.Producer.Publish(ctx, events.TypeProductQuantityChanged, productQuantityChangedPayload{Sku: sku, Available: available})
+// v1 event for the given SKU, carrying the per-location breakdown so
+// consumers can route picks to the right warehouse.
+func (e *InventoryEmitter) EmitProductQuantityChanged(ctx context.Context, pi ProductInventory) error {
+	return e.Producer.Publish(ctx, events.TypeProductQuantityChanged, productQuantityChangedPayload{
+		Sku:         pi.Sku,
+		Available:   pi.Available,
+		InTransit:   pi.InTransit,
+		Expired:     pi.Expired,
+		OnHold:      pi.OnHold,
+		Quarantined: pi.Quarantined,
+		Damaged:     pi.Damaged,
+		Locations:   pi.Locations,
+	})
 }
 
 type productQuantityChangedPayload struct {
-	Sku       string `json:"sku"`
-	Available int64  `json:"available"`
+	Sku         string              `json:"sku"`
+	Available   int64               `json:"available"`
+	InTransit   int64               `json:"inTransit,omitempty"`
+	Expired     int64               `json:"expired,omitempty"`
+	OnHold      int64               `json:"onHold,omitempty"`
+	Quarantined int64               `json:"quarantined,omitempty"`
+	Damaged     int64               `json:"damaged,omitempty"`
+	Locations   []LocationInventory `json:"locations,omitempty"`
+}
+
+// EmitProductChanged publishes an inventory.product_changed v1 event
+// carrying the product's details and lifecycle state after a change.
+func (e *InventoryEmitter) EmitProductChanged(ctx context.Context, product Product) error {
+	return e.Producer.Publish(ctx, events.TypeProductChanged, product)
+}
+
+// EmitLowStock publishes an inventory.low_stock v1 event for a SKU
+// that has just fallen below its reorder point.
+func (e *InventoryEmitter) EmitLowStock(ctx context.Context, lowStock LowStock) error {
+	return e.Producer.Publish(ctx, events.TypeLowStock, lowStock)
+}
+
+// EmitTransferChanged publishes the event matching the transfer's new
+// state: inventory.transfer_requested, inventory.transfer_dispatched
+// or inventory.transfer_received, each v1 and carrying the transfer.
+func (e *InventoryEmitter) EmitTransferChanged(ctx context.Context, t Transfer) error {
+	var eventType string
+	switch t.State {
+	case TransferRequested:
+		eventType = events.TypeTransferRequested
+	case TransferInTransit:
+		eventType = events.TypeTransferDispatched
+	case TransferReceived:
+		eventType = events.TypeTransferReceived
+	default:
+		return fmt.Errorf("no event type for transfer state %q", t.State)
+	}
+	return e.Producer.Publish(ctx, eventType, t)
 }
 
 // InventoryCommandHandler decodes inventory commands off the inbound
@@ -38,14 +85,31 @@ type InventoryCommandHandler struct {
 type InventoryCommandTarget interface {
 	GetProduct(ctx context.Context, sku string) (Product, error)
 	Produce(ctx context.Context, product Product, event ProductionRequest) error
+	Adjust(ctx context.Context, product Product, ar AdjustmentRequest) (Adjustment, error)
+	Ship(ctx context.Context, ID uint64, sr ShipmentRequest) (Shipment, error)
+	RecordReturn(ctx context.Context, product Product, rr ReturnRequest) (Return, error)
 }
 
-// Handle implements Handler. Only inventory.record_production v1 is
-// recognized today; unknown event types are an error and route to DLT.
+// Handle implements Handler. inventory.record_production v1,
+// inventory.adjust_inventory v1, inventory.ship_reservation v1 and
+// inventory.record_return v1 are recognized; unknown event types are
+// an error and route to DLT.
 func (h *InventoryCommandHandler) Handle(ctx context.Context, env events.Envelope) error {
-	if env.EventType != events.TypeRecordProduction {
+	switch env.EventType {
+	case events.TypeRecordProduction:
+		return h.recordProduction(ctx, env)
+	case events.TypeAdjustInventory:
+		return h.adjustInventory(ctx, env)
+	case events.TypeShipReservation:
+		return h.shipReservation(ctx, env)
+	case events.TypeRecordReturn:
+		return h.recordReturn(ctx, env)
+	default:
 		return fmt.Errorf("kafka command handler: unsupported event_type %q", env.EventType)
 	}
+}
+
+func (h *InventoryCommandHandler) recordProduction(ctx context.Context, env events.Envelope) error {
 	var cmd recordProductionPayload
 	if err := json.Unmarshal(env.Payload, &cmd); err != nil {
 		return fmt.Errorf("decode record_production: %w", err)
@@ -54,11 +118,98 @@ func (h *InventoryCommandHandler) Handle(ctx context.Context, env events.Envelop
 	if err != nil {
 		return fmt.Errorf("lookup product %q: %w", cmd.Sku, err)
 	}
-	return h.Service.Produce(ctx, product, ProductionRequest{RequestID: cmd.RequestID, Quantity: cmd.Quantity})
+	pr := ProductionRequest{RequestID: cmd.RequestID, Quantity: cmd.Quantity, Location: cmd.Location, Lot: cmd.Lot, ExpiresAt: cmd.ExpiresAt, PlannedRequestID: cmd.PlannedRequestID}
+	return h.Service.Produce(ctx, product, pr)
+}
+
+// adjustInventory applies an adjustment with no role check and the
+// ledger actor "system": the commands topic is trusted, and who may
+// adjust stock through it is decided by the broker's produce ACLs.
+func (h *InventoryCommandHandler) adjustInventory(ctx context.Context, env events.Envelope) error {
+	var cmd adjustInventoryPayload
+	if err := json.Unmarshal(env.Payload, &cmd); err != nil {
+		return fmt.Errorf("decode adjust_inventory: %w", err)
+	}
+	product, err := h.Service.GetProduct(ctx, cmd.Sku)
+	if err != nil {
+		return fmt.Errorf("lookup product %q: %w", cmd.Sku, err)
+	}
+	ar := AdjustmentRequest{RequestID: cmd.RequestID, Quantity: cmd.Quantity, Reason: AdjustmentReason(cmd.Reason), Location: cmd.Location}
+	if _, err := h.Service.Adjust(ctx, product, ar); err != nil {
+		return fmt.Errorf("adjust inventory %q: %w", cmd.Sku, err)
+	}
+	return nil
+}
+
+func (h *InventoryCommandHandler) shipReservation(ctx context.Context, env events.Envelope) error {
+	var cmd shipReservationPayload
+	if err := json.Unmarshal(env.Payload, &cmd); err != nil {
+		return fmt.Errorf("decode ship_reservation: %w", err)
+	}
+	if _, err := h.Service.Ship(ctx, cmd.ReservationID, ShipmentRequest{RequestID: cmd.RequestID, Quantity: cmd.Quantity}); err != nil {
+		return fmt.Errorf("ship reservation %d: %w", cmd.ReservationID, err)
+	}
+	return nil
+}
+
+func (h *InventoryCommandHandler) recordReturn(ctx context.Context, env events.Envelope) error {
+	var cmd recordReturnPayload
+	if err := json.Unmarshal(env.Payload, &cmd); err != nil {
+		return fmt.Errorf("decode record_return: %w", err)
+	}
+	product, err := h.Service.GetProduct(ctx, cmd.Sku)
+	if err != nil {
+		return fmt.Errorf("lookup product %q: %w", cmd.Sku, err)
+	}
+	rr := ReturnRequest{
+		RequestID:     cmd.RequestID,
+		Quantity:      cmd.Quantity,
+		Outcome:       ReturnOutcome(cmd.Outcome),
+		ReservationID: cmd.ReservationID,
+		Requester:     cmd.Requester,
+		Reason:        cmd.Reason,
+		Location:      cmd.Location,
+		Lot:           cmd.Lot,
+	}
+	if _, err := h.Service.RecordReturn(ctx, product, rr); err != nil {
+		return fmt.Errorf("record return %q: %w", cmd.Sku, err)
+	}
+	return nil
 }
 
 type recordProductionPayload struct {
+	Sku       string     `json:"sku"`
+	RequestID string     `json:"requestId"`
+	Quantity  int64      `json:"quantity"`
+	Location  string     `json:"location,omitempty"`
+	Lot       string     `json:"lot,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	PlannedRequestID string `json:"plannedRequestId,omitempty"`
+}
+
+type adjustInventoryPayload struct {
 	Sku       string `json:"sku"`
 	RequestID string `json:"requestId"`
 	Quantity  int64  `json:"quantity"`
+	Reason    string `json:"reason"`
+	Location  string `json:"location,omitempty"`
+}
+
+type shipReservationPayload struct {
+	ReservationID uint64 `json:"reservationId"`
+	RequestID     string `json:"requestId"`
+	Quantity      int64  `json:"quantity"`
+}
+
+type recordReturnPayload struct {
+	Sku           string `json:"sku"`
+	RequestID     string `json:"requestId"`
+	Quantity      int64  `json:"quantity"`
+	Outcome       string `json:"outcome"`
+	ReservationID uint64 `json:"reservationId,omitempty"`
+	Requester     string `json:"requester,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	Location      string `json:"location,omitempty"`
+	Lot           string `json:"lot,omitempty"`
 }
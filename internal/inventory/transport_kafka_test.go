@@ -16,8 +16,22 @@ type fakeInventory struct {
 	produceCalls int
 	lastSku      string
 	lastQty      int64
+	lastPlanned  string
 	getErr       error
 	produceErr   error
+
+	shipCalls   int
+	lastShipID  uint64
+	lastShipReq inventory.ShipmentRequest
+	shipErr     error
+
+	adjustCalls   int
+	lastAdjustReq inventory.AdjustmentRequest
+	adjustErr     error
+
+	returnCalls   int
+	lastReturnReq inventory.ReturnRequest
+	returnErr     error
 }
 
 func (f *fakeInventory) GetProduct(_ context.Context, sku string) (inventory.Product, error) {
@@ -32,9 +46,38 @@ func (f *fakeInventory) GetProduct(_ context.Context, sku string) (inventory.Pro
 func (f *fakeInventory) Produce(_ context.Context, product inventory.Product, event inventory.ProductionRequest) error {
 	f.produceCalls++
 	f.lastQty = event.Quantity
+	f.lastPlanned = event.PlannedRequestID
 	return f.produceErr
 }
 
+func (f *fakeInventory) Adjust(_ context.Context, product inventory.Product, ar inventory.AdjustmentRequest) (inventory.Adjustment, error) {
+	f.adjustCalls++
+	f.lastAdjustReq = ar
+	if f.adjustErr != nil {
+		return inventory.Adjustment{}, f.adjustErr
+	}
+	return inventory.Adjustment{Sku: product.Sku, RequestID: ar.RequestID, Quantity: ar.Quantity, Reason: ar.Reason}, nil
+}
+
+func (f *fakeInventory) Ship(_ context.Context, ID uint64, sr inventory.ShipmentRequest) (inventory.Shipment, error) {
+	f.shipCalls++
+	f.lastShipID = ID
+	f.lastShipReq = sr
+	if f.shipErr != nil {
+		return inventory.Shipment{}, f.shipErr
+	}
+	return inventory.Shipment{ReservationID: ID, RequestID: sr.RequestID, Quantity: sr.Quantity}, nil
+}
+
+func (f *fakeInventory) RecordReturn(_ context.Context, product inventory.Product, rr inventory.ReturnRequest) (inventory.Return, error) {
+	f.returnCalls++
+	f.lastReturnReq = rr
+	if f.returnErr != nil {
+		return inventory.Return{}, f.returnErr
+	}
+	return inventory.Return{Sku: product.Sku, RequestID: rr.RequestID, Quantity: rr.Quantity, Outcome: rr.Outcome}, nil
+}
+
 func TestInventoryCommandHandlerHappyPath(t *testing.T) {
 	fake := &fakeInventory{}
 	h := &inventory.InventoryCommandHandler{Service: fake}
@@ -61,6 +104,29 @@ func TestInventoryCommandHandlerHappyPath(t *testing.T) {
 	}
 }
 
+func TestInventoryCommandHandlerCompletesPlannedOrder(t *testing.T) {
+	fake := &fakeInventory{}
+	h := &inventory.InventoryCommandHandler{Service: fake}
+
+	env, err := events.NewEnvelope(
+		"event-1",
+		events.TypeRecordProduction,
+		1,
+		time.Now(),
+		map[string]any{"sku": "sku-1", "requestId": "req-1", "quantity": 5, "plannedRequestId": "plan-1"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Handle(context.Background(), env); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if fake.produceCalls != 1 || fake.lastPlanned != "plan-1" {
+		t.Errorf("Produce calls=%d plannedRequestId=%q", fake.produceCalls, fake.lastPlanned)
+	}
+}
+
 func TestInventoryCommandHandlerRejectsUnknownType(t *testing.T) {
 	h := &inventory.InventoryCommandHandler{Service: &fakeInventory{}}
 	env, _ := events.NewEnvelope("e", "inventory.never_heard_of_it", 1, time.Now(), struct{}{})
@@ -99,3 +165,119 @@ func TestInventoryCommandHandlerSurfacesBadPayload(t *testing.T) {
 		t.Fatal("expected decode error on malformed payload")
 	}
 }
+
+func TestInventoryCommandHandlerShipReservation(t *testing.T) {
+	fake := &fakeInventory{}
+	h := &inventory.InventoryCommandHandler{Service: fake}
+
+	env, err := events.NewEnvelope(
+		"event-1",
+		events.TypeShipReservation,
+		1,
+		time.Now(),
+		map[string]any{"reservationId": 42, "requestId": "ship-1", "quantity": 3},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Handle(context.Background(), env); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if fake.shipCalls != 1 || fake.lastShipID != 42 {
+		t.Errorf("Ship calls=%d id=%d", fake.shipCalls, fake.lastShipID)
+	}
+	if fake.lastShipReq.RequestID != "ship-1" || fake.lastShipReq.Quantity != 3 {
+		t.Errorf("Ship request=%+v", fake.lastShipReq)
+	}
+	if fake.produceCalls != 0 {
+		t.Error("Produce should not run for a ship command")
+	}
+}
+
+func TestInventoryCommandHandlerSurfacesShipError(t *testing.T) {
+	fake := &fakeInventory{shipErr: inventory.ErrInvalidInput}
+	h := &inventory.InventoryCommandHandler{Service: fake}
+	env, _ := events.NewEnvelope("e", events.TypeShipReservation, 1, time.Now(),
+		map[string]any{"reservationId": 42, "requestId": "ship-1", "quantity": 3})
+	err := h.Handle(context.Background(), env)
+	if !errors.Is(err, inventory.ErrInvalidInput) {
+		t.Fatalf("expected wrapped ship error, got %v", err)
+	}
+}
+
+func TestInventoryCommandHandlerAdjustInventory(t *testing.T) {
+	fake := &fakeInventory{}
+	h := &inventory.InventoryCommandHandler{Service: fake}
+
+	env, err := events.NewEnvelope(
+		"event-1",
+		events.TypeAdjustInventory,
+		1,
+		time.Now(),
+		map[string]any{"sku": "sku-1", "requestId": "adj-1", "quantity": -2, "reason": "damage"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Handle(context.Background(), env); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if fake.getCalls != 1 || fake.lastSku != "sku-1" {
+		t.Errorf("GetProduct calls=%d sku=%q", fake.getCalls, fake.lastSku)
+	}
+	want := inventory.AdjustmentRequest{RequestID: "adj-1", Quantity: -2, Reason: inventory.AdjustDamage}
+	if fake.adjustCalls != 1 || fake.lastAdjustReq != want {
+		t.Errorf("Adjust calls=%d request=%+v", fake.adjustCalls, fake.lastAdjustReq)
+	}
+}
+
+func TestInventoryCommandHandlerSurfacesAdjustError(t *testing.T) {
+	fake := &fakeInventory{adjustErr: inventory.ErrInvalidInput}
+	h := &inventory.InventoryCommandHandler{Service: fake}
+	env, _ := events.NewEnvelope("e", events.TypeAdjustInventory, 1, time.Now(),
+		map[string]any{"sku": "sku-1", "requestId": "adj-1", "quantity": -2, "reason": "damage"})
+	err := h.Handle(context.Background(), env)
+	if !errors.Is(err, inventory.ErrInvalidInput) {
+		t.Fatalf("expected wrapped adjust error, got %v", err)
+	}
+}
+
+func TestInventoryCommandHandlerRecordReturn(t *testing.T) {
+	fake := &fakeInventory{}
+	h := &inventory.InventoryCommandHandler{Service: fake}
+
+	env, err := events.NewEnvelope(
+		"event-1",
+		events.TypeRecordReturn,
+		1,
+		time.Now(),
+		map[string]any{"sku": "sku-1", "requestId": "ret-1", "quantity": 2, "outcome": "restock", "reservationId": 7, "reason": "wrong size"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Handle(context.Background(), env); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if fake.getCalls != 1 || fake.lastSku != "sku-1" {
+		t.Errorf("GetProduct calls=%d sku=%q", fake.getCalls, fake.lastSku)
+	}
+	want := inventory.ReturnRequest{RequestID: "ret-1", Quantity: 2, Outcome: inventory.ReturnRestock, ReservationID: 7, Reason: "wrong size"}
+	if fake.returnCalls != 1 || fake.lastReturnReq != want {
+		t.Errorf("RecordReturn calls=%d request=%+v", fake.returnCalls, fake.lastReturnReq)
+	}
+}
+
+func TestInventoryCommandHandlerSurfacesReturnError(t *testing.T) {
+	fake := &fakeInventory{returnErr: inventory.ErrInvalidInput}
+	h := &inventory.InventoryCommandHandler{Service: fake}
+	env, _ := events.NewEnvelope("e", events.TypeRecordReturn, 1, time.Now(),
+		map[string]any{"sku": "sku-1", "requestId": "ret-1", "quantity": 2, "outcome": "scrap", "requester": "portal"})
+	err := h.Handle(context.Background(), env)
+	if !errors.Is(err, inventory.ErrInvalidInput) {
+		t.Fatalf("expected wrapped return error, got %v", err)
+	}
+}
@@ -99,6 +99,28 @@ func (i *InventoryQueue) PublishInventory(ctx context.Context, productInventory
 	return nil
 }
 
+// PublishProduct sends a product's lifecycle change on the inventory
+// exchange, alongside the stock changes for the same SKU.
+func (i *InventoryQueue) PublishProduct(ctx context.Context, product Product) error {
+	body, err := amqp.EncodeEvent(events.TypeProductChanged, product)
+	if err != nil {
+		return fmt.Errorf("failed to serialize product event: %w", err)
+	}
+	i.inventory <- amqp.NewMessage(ctx, body, i.cfg.RabbitMQ.Inventory.Exchange.Value)
+	return nil
+}
+
+// PublishLowStock sends a SKU's low-stock alert on the inventory
+// exchange, alongside the stock change that set it off.
+func (i *InventoryQueue) PublishLowStock(ctx context.Context, lowStock LowStock) error {
+	body, err := amqp.EncodeEvent(events.TypeLowStock, lowStock)
+	if err != nil {
+		return fmt.Errorf("failed to serialize low stock event: %w", err)
+	}
+	i.inventory <- amqp.NewMessage(ctx, body, i.cfg.RabbitMQ.Inventory.Exchange.Value)
+	return nil
+}
+
 func (i *InventoryQueue) PublishReservation(ctx context.Context, reservation Reservation) error {
 	body, err := amqp.EncodeEvent(events.TypeReservationChanged, reservation)
 	if err != nil {
@@ -185,7 +207,7 @@ func pingFromLastSession(lastNanos int64) error {
 }
 
 type ProductHandler interface {
-	CreateProduct(ctx context.Context, product Product) error
+	CreateProduct(ctx context.Context, product Product) (ProductInventory, bool, error)
 }
 
 func (p *ProductQueue) sendToDlt(ctx context.Context, body []byte) {
@@ -232,7 +254,7 @@ func (p *ProductQueue) handleProductMessage(ctx context.Context, handler Product
 		return
 	}
 
-	if err := handler.CreateProduct(msgCtx, product); err != nil {
+	if _, _, err := handler.CreateProduct(msgCtx, product); err != nil {
 		log.Ctx(msgCtx).Error().Err(err).Str("event_id", env.EventID).Msg("failed to create product, sending to dlt")
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "handler failure")
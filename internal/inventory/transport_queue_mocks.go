@@ -7,9 +7,13 @@ import (
 type MockQueue struct {
 	PublishInventoryFunc   func(ctx context.Context, productInventory ProductInventory) error
 	PublishReservationFunc func(ctx context.Context, reservation Reservation) error
+	PublishProductFunc     func(ctx context.Context, product Product) error
+	PublishLowStockFunc    func(ctx context.Context, lowStock LowStock) error
 
 	PublishInventoryCalls   int
 	PublishReservationCalls int
+	PublishProductCalls     int
+	PublishLowStockCalls    int
 }
 
 func NewMockQueue() *MockQueue {
@@ -20,6 +24,12 @@ func NewMockQueue() *MockQueue {
 		PublishReservationFunc: func(ctx context.Context, reservation Reservation) error {
 			return nil
 		},
+		PublishProductFunc: func(ctx context.Context, product Product) error {
+			return nil
+		},
+		PublishLowStockFunc: func(ctx context.Context, lowStock LowStock) error {
+			return nil
+		},
 	}
 }
 
@@ -32,3 +42,13 @@ func (m *MockQueue) PublishReservation(ctx context.Context, reservation Reservat
 	m.PublishReservationCalls++
 	return m.PublishReservationFunc(ctx, reservation)
 }
+
+func (m *MockQueue) PublishProduct(ctx context.Context, product Product) error {
+	m.PublishProductCalls++
+	return m.PublishProductFunc(ctx, product)
+}
+
+func (m *MockQueue) PublishLowStock(ctx context.Context, lowStock LowStock) error {
+	m.PublishLowStockCalls++
+	return m.PublishLowStockFunc(ctx, lowStock)
+}
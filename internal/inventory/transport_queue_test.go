@@ -19,11 +19,11 @@ type productHandlerStub struct {
 	lastCtxReq string
 }
 
-func (s *productHandlerStub) CreateProduct(ctx context.Context, p Product) error {
+func (s *productHandlerStub) CreateProduct(ctx context.Context, p Product) (ProductInventory, bool, error) {
 	s.called++
 	s.lastSku = p.Sku
 	s.lastCtxReq = observability.RequestIDFromContext(ctx)
-	return s.err
+	return ProductInventory{Product: p}, s.err == nil, s.err
 }
 
 // newProductQueueForTest builds a ProductQueue with a buffered DLT
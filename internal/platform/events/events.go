@@ -48,8 +48,16 @@ const (
 	TypeProductInventoryChanged = "inventory.product_inventory_changed"
 	TypeReservationChanged      = "inventory.reservation_changed"
 	TypeProductCreated          = "inventory.product_created"
+	TypeProductChanged          = "inventory.product_changed"
 	TypeProductQuantityChanged  = "inventory.product_quantity_changed"
 	TypeRecordProduction        = "inventory.record_production"
+	TypeShipReservation         = "inventory.ship_reservation"
+	TypeAdjustInventory         = "inventory.adjust_inventory"
+	TypeRecordReturn            = "inventory.record_return"
+	TypeTransferRequested       = "inventory.transfer_requested"
+	TypeTransferDispatched      = "inventory.transfer_dispatched"
+	TypeTransferReceived        = "inventory.transfer_received"
+	TypeLowStock                = "inventory.low_stock"
 )
 
 // Envelope is the RFC 7807-flavored common shape that wraps every
@@ -0,0 +1,76 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag formats a record version as a strong entity tag.
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// CheckPreconditions evaluates the request's If-Match and If-None-Match
+// headers (RFC 9110 §13.1) against current, the target's entity tag, or
+// "" when the target doesn't exist. When a precondition fails it
+// answers the request itself — 304 Not Modified for a GET or HEAD whose
+// If-None-Match matched, a 412 Problem otherwise — and returns false.
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, current string) bool {
+	if v := r.Header.Get("If-Match"); v != "" && !matchesAny(v, current, false) {
+		Render(w, r, PreconditionFailedProblem(errors.New("If-Match does not match the current entity tag")))
+		return false
+	}
+	if v := r.Header.Get("If-None-Match"); v != "" && matchesAny(v, current, true) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			w.Header().Set("ETag", current)
+			w.WriteHeader(http.StatusNotModified)
+			return false
+		}
+		Render(w, r, PreconditionFailedProblem(errors.New("If-None-Match matches the current entity tag")))
+		return false
+	}
+	return true
+}
+
+// IfMatchVersion returns the version named by an If-Match header that
+// holds exactly one strong entity tag, which is the case a handler can
+// hand down for the write itself to check atomically.
+func IfMatchVersion(r *http.Request) (int64, bool) {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// matchesAny reports whether the comma-separated entity tag list in
+// header matches current. "*" matches any existing target. Weak
+// comparison, used for If-None-Match, ignores the W/ prefix; strong
+// comparison, used for If-Match, never matches a weak tag.
+func matchesAny(header, current string, weak bool) bool {
+	if current == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == strings.TrimPrefix(current, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package httpx_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sksmith/go-micro-example/internal/platform/httpx"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		current string
+		ok      bool
+		status  int
+	}{
+		{name: "no preconditions", method: http.MethodPatch, current: `"3"`, ok: true},
+		{name: "if-match matches", method: http.MethodPatch, headers: map[string]string{"If-Match": `"2", "3"`}, current: `"3"`, ok: true},
+		{name: "if-match mismatch", method: http.MethodPatch, headers: map[string]string{"If-Match": `"2"`}, current: `"3"`, status: http.StatusPreconditionFailed},
+		{name: "if-match never matches a weak tag", method: http.MethodPatch, headers: map[string]string{"If-Match": `W/"3"`}, current: `"3"`, status: http.StatusPreconditionFailed},
+		{name: "if-match star needs the target to exist", method: http.MethodPut, headers: map[string]string{"If-Match": "*"}, status: http.StatusPreconditionFailed},
+		{name: "if-none-match star on a missing target", method: http.MethodPut, headers: map[string]string{"If-None-Match": "*"}, ok: true},
+		{name: "if-none-match star on an existing target", method: http.MethodPut, headers: map[string]string{"If-None-Match": "*"}, current: `"1"`, status: http.StatusPreconditionFailed},
+		{name: "if-none-match on get is not modified", method: http.MethodGet, headers: map[string]string{"If-None-Match": `W/"3"`}, current: `"3"`, status: http.StatusNotModified},
+		{name: "if-none-match on get that changed", method: http.MethodGet, headers: map[string]string{"If-None-Match": `"2"`}, current: `"3"`, ok: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "/thing", nil)
+			for k, v := range test.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			ok := httpx.CheckPreconditions(w, r, test.current)
+			if ok != test.ok {
+				t.Fatalf("ok got=%v want=%v", ok, test.ok)
+			}
+			if !ok && w.Code != test.status {
+				t.Errorf("status got=%d want=%d", w.Code, test.status)
+			}
+			if w.Code == http.StatusPreconditionFailed && w.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("content type got=%q want application/problem+json", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int64
+		ok      bool
+	}{
+		{header: `"7"`, version: 7, ok: true},
+		{header: ""},
+		{header: "*"},
+		{header: `"1", "2"`},
+		{header: `W/"7"`},
+		{header: `"abc"`},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPatch, "/thing", nil)
+		r.Header.Set("If-Match", test.header)
+		version, ok := httpx.IfMatchVersion(r)
+		if version != test.version || ok != test.ok {
+			t.Errorf("%q got=%d,%v want=%d,%v", test.header, version, ok, test.version, test.ok)
+		}
+	}
+}
@@ -59,9 +59,16 @@ func methodCarriesBody(method string) bool {
 }
 
 func writeMaxBytesProblem(w http.ResponseWriter, r *http.Request, limit int64) {
-	(&Problem{
+	RequestTooLargeProblem(limit).WriteTo(w, r)
+}
+
+// RequestTooLargeProblem is the 413 for a body over limit bytes. Handlers
+// that stream a body use it when a read fails with *http.MaxBytesError
+// part way through.
+func RequestTooLargeProblem(limit int64) *Problem {
+	return &Problem{
 		Title:  http.StatusText(http.StatusRequestEntityTooLarge),
 		Status: http.StatusRequestEntityTooLarge,
 		Detail: "request body exceeds " + strconv.FormatInt(limit, 10) + " bytes",
-	}).WriteTo(w, r)
+	}
 }
@@ -1,7 +1,13 @@
 package httpx
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,9 +18,19 @@ import (
 // Pagination is the validated representation of `?limit=…&offset=…`
 // that list handlers receive. DSN-011 made this typed and explicit
 // instead of stashing raw ints under string-keyed context values.
+//
+// A `cursor` param switches the request to keyset pagination: Keyset is
+// set and After holds the verified key the previous page ended on (nil
+// for the first page, requested with an empty `cursor=`). Handlers that
+// support it decode After with DecodeCursor and set NextCursor before
+// calling WriteLinkHeader.
 type Pagination struct {
 	Limit  int
 	Offset int
+
+	Keyset     bool
+	After      []byte
+	NextCursor string
 }
 
 const (
@@ -50,6 +66,78 @@ func PaginationFrom(ctx context.Context) Pagination {
 	return Pagination{Limit: DefaultPageLimit}
 }
 
+// cursorKey signs the cursors handed out in Link headers so clients
+// can't forge a key into the middle of someone else's listing. It is
+// random per process until SetCursorKey is called, which is enough
+// for a single replica but means cursors don't survive a restart.
+var cursorKey = func() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("generate cursor key: %v", err))
+	}
+	return key
+}()
+
+// SetCursorKey replaces the key cursors are signed with. Call it once
+// at startup, before serving; replicas behind one load balancer need
+// the same key to accept each other's cursors.
+func SetCursorKey(key []byte) {
+	cursorKey = append([]byte(nil), key...)
+}
+
+// EncodeCursor returns the opaque, signed `cursor` value for key, which
+// is marshalled as JSON.
+func EncodeCursor(key interface{}) (string, error) {
+	payload, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(signCursor(payload)), nil
+}
+
+// DecodeCursor unmarshals the key the request's cursor carries into v.
+// A cursor issued by a different listing doesn't fit v and is an error,
+// which handlers report with InvalidCursorProblem.
+func (p Pagination) DecodeCursor(v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(p.After))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("decode cursor: %w", err)
+	}
+	return nil
+}
+
+// InvalidCursorProblem is the 400 for a cursor that fails to verify or
+// doesn't belong to the listing it was sent to.
+func InvalidCursorProblem() *Problem {
+	return ValidationProblem(FieldProblem{Field: "cursor", Detail: "is not a valid cursor"})
+}
+
+func signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// verifyCursor returns the payload of a cursor made by EncodeCursor, or
+// false if it is malformed or its signature doesn't match.
+func verifyCursor(cursor string) ([]byte, bool) {
+	encPayload, encSig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return nil, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil || !hmac.Equal(sig, signCursor(payload)) {
+		return nil, false
+	}
+	return payload, true
+}
+
 func parsePagination(q url.Values) (Pagination, *Problem) {
 	p := Pagination{Limit: DefaultPageLimit}
 	var fields []FieldProblem
@@ -75,6 +163,20 @@ func parsePagination(q url.Values) (Pagination, *Problem) {
 		}
 	}
 
+	if q.Has("cursor") {
+		p.Keyset = true
+		if v := q.Get("cursor"); v != "" {
+			after, ok := verifyCursor(v)
+			if !ok {
+				fields = append(fields, FieldProblem{Field: "cursor", Detail: "is not a valid cursor"})
+			}
+			p.After = after
+		}
+		if q.Has("offset") {
+			fields = append(fields, FieldProblem{Field: "offset", Detail: "cannot be combined with cursor"})
+		}
+	}
+
 	if len(fields) > 0 {
 		return Pagination{}, ValidationProblem(fields...)
 	}
@@ -87,10 +189,17 @@ func parsePagination(q url.Values) (Pagination, *Problem) {
 // (we can't know without counting whether more truly exist, but
 // returning a full page is the conventional signal that another page
 // is probably available).
+//
+// In keyset mode the `next` link carries p.NextCursor instead, and
+// there is no `prev`: a cursor only walks forward.
 func WriteLinkHeader(w http.ResponseWriter, r *http.Request, p Pagination, returnedCount int) {
 	var links []string
 
-	if returnedCount == p.Limit {
+	if p.Keyset {
+		if returnedCount == p.Limit && p.NextCursor != "" {
+			links = append(links, formatCursorLink(r, p.Limit, p.NextCursor, "next"))
+		}
+	} else if returnedCount == p.Limit {
 		links = append(links, formatLink(r, p.Limit, p.Offset+p.Limit, "next"))
 	}
 	if p.Offset > 0 {
@@ -114,3 +223,12 @@ func formatLink(r *http.Request, limit, offset int, rel string) string {
 	u.RawQuery = q.Encode()
 	return fmt.Sprintf(`<%s>; rel=%q`, u.RequestURI(), rel)
 }
+
+func formatCursorLink(r *http.Request, limit int, cursor, rel string) string {
+	u := *r.URL
+	q := u.Query()
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("cursor", cursor)
+	u.RawQuery = q.Encode()
+	return fmt.Sprintf(`<%s>; rel=%q`, u.RequestURI(), rel)
+}
@@ -2,10 +2,12 @@ package httpx_test
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
@@ -80,7 +82,7 @@ func TestPaginationDefaultsAndClamp(t *testing.T) {
 	defer ts.Close()
 
 	var gotLimit, gotOffset int
-	mockSvc.GetAllProductInventoryFunc = func(ctx context.Context, limit, offset int) ([]inventory.ProductInventory, error) {
+	mockSvc.GetAllProductInventoryFunc = func(ctx context.Context, options inventory.ProductListOptions, limit, offset int) ([]inventory.ProductInventory, error) {
 		gotLimit, gotOffset = limit, offset
 		return nil, nil
 	}
@@ -114,7 +116,7 @@ func TestPaginationLinkHeader(t *testing.T) {
 	defer ts.Close()
 
 	t.Run("full page emits next, no prev when offset=0", func(t *testing.T) {
-		mockSvc.GetAllProductInventoryFunc = func(ctx context.Context, limit, offset int) ([]inventory.ProductInventory, error) {
+		mockSvc.GetAllProductInventoryFunc = func(ctx context.Context, options inventory.ProductListOptions, limit, offset int) ([]inventory.ProductInventory, error) {
 			return make([]inventory.ProductInventory, limit), nil
 		}
 		res, err := http.Get(ts.URL + "/?limit=10")
@@ -135,7 +137,7 @@ func TestPaginationLinkHeader(t *testing.T) {
 	})
 
 	t.Run("middle page emits both next and prev", func(t *testing.T) {
-		mockSvc.GetAllProductInventoryFunc = func(ctx context.Context, limit, offset int) ([]inventory.ProductInventory, error) {
+		mockSvc.GetAllProductInventoryFunc = func(ctx context.Context, options inventory.ProductListOptions, limit, offset int) ([]inventory.ProductInventory, error) {
 			return make([]inventory.ProductInventory, limit), nil
 		}
 		res, err := http.Get(ts.URL + "/?limit=10&offset=20")
@@ -156,7 +158,7 @@ func TestPaginationLinkHeader(t *testing.T) {
 	})
 
 	t.Run("partial page omits next", func(t *testing.T) {
-		mockSvc.GetAllProductInventoryFunc = func(ctx context.Context, limit, offset int) ([]inventory.ProductInventory, error) {
+		mockSvc.GetAllProductInventoryFunc = func(ctx context.Context, options inventory.ProductListOptions, limit, offset int) ([]inventory.ProductInventory, error) {
 			return make([]inventory.ProductInventory, limit-1), nil
 		}
 		res, err := http.Get(ts.URL + "/?limit=10&offset=10")
@@ -173,3 +175,106 @@ func TestPaginationLinkHeader(t *testing.T) {
 		}
 	})
 }
+
+// nextLink returns the target of the rel="next" link in a Link header.
+func nextLink(t *testing.T, header string) string {
+	t.Helper()
+	for _, link := range strings.Split(header, ", ") {
+		target, rel, ok := strings.Cut(link, ">; ")
+		if ok && rel == `rel="next"` {
+			return strings.TrimPrefix(target, "<")
+		}
+	}
+	t.Fatalf("no next link in %q", header)
+	return ""
+}
+
+func TestPaginationCursor(t *testing.T) {
+	ts, mockSvc := paginationTestServer(t)
+	defer ts.Close()
+
+	var gotAfter []string
+	mockSvc.GetAllProductInventoryAfterFunc = func(ctx context.Context, options inventory.ProductListOptions, after *inventory.ProductKey, limit int) ([]inventory.ProductInventory, error) {
+		var sku string
+		if after != nil {
+			sku = after.Sku
+		}
+		gotAfter = append(gotAfter, sku)
+		page := make([]inventory.ProductInventory, limit)
+		for i := range page {
+			page[i].Sku = sku + strconv.Itoa(i)
+		}
+		return page, nil
+	}
+
+	res, err := http.Get(ts.URL + "/?limit=2&cursor=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	link := res.Header.Get("Link")
+	if strings.Contains(link, `rel="prev"`) || strings.Contains(link, "offset=") {
+		t.Errorf("cursor links should not page by offset, got %q", link)
+	}
+
+	res, err = http.Get(ts.URL + nextLink(t, link))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status got=%d want=%d", res.StatusCode, http.StatusOK)
+	}
+	if len(gotAfter) != 2 || gotAfter[0] != "" || gotAfter[1] != "1" {
+		t.Errorf("after got=%q want [\"\" \"1\"]", gotAfter)
+	}
+
+	t.Run("partial page omits next", func(t *testing.T) {
+		mockSvc.GetAllProductInventoryAfterFunc = func(ctx context.Context, options inventory.ProductListOptions, after *inventory.ProductKey, limit int) ([]inventory.ProductInventory, error) {
+			return []inventory.ProductInventory{{Product: inventory.Product{Sku: "a"}}}, nil
+		}
+		res, err := http.Get(ts.URL + "/?limit=2&cursor=")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if link := res.Header.Get("Link"); link != "" {
+			t.Errorf("did not expect a Link header, got %q", link)
+		}
+	})
+
+	// A genuine signature over a different key.
+	signed := mustEncodeCursor(t, map[string]string{"sku": "a"})
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"sku":"z"}`)) + signed[strings.Index(signed, "."):]
+
+	for name, query := range map[string]string{
+		"tampered cursor":       "?cursor=" + url.QueryEscape(forged),
+		"garbage cursor":        "?cursor=not-a-cursor",
+		"cursor with offset":    "?cursor=&offset=10",
+		"cursor with asOf":      "?cursor=&asOf=2026-01-01T00:00:00Z",
+		"other listing's shape": "?cursor=" + mustEncodeCursor(t, map[string]int{"id": 1}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := http.Get(ts.URL + "/" + query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("status got=%d want=%d", res.StatusCode, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func mustEncodeCursor(t *testing.T, key interface{}) string {
+	t.Helper()
+	c, err := httpx.EncodeCursor(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -73,6 +73,55 @@ func ValidationProblem(fields ...FieldProblem) *Problem {
 	}
 }
 
+// ConflictProblem is a 409: the request was understood but can't be
+// applied to the resource's current state.
+func ConflictProblem(err error) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusConflict),
+		Status: http.StatusConflict,
+		Detail: err.Error(),
+		Err:    err,
+	}
+}
+
+// UnprocessableEntityProblem is a 422: the request was well formed and
+// valid, but a business rule refused it.
+func UnprocessableEntityProblem(err error) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusUnprocessableEntity),
+		Status: http.StatusUnprocessableEntity,
+		Detail: err.Error(),
+		Err:    err,
+	}
+}
+
+// UnsupportedMediaTypeProblem is a 415: the request body is in a
+// format the endpoint doesn't accept.
+func UnsupportedMediaTypeProblem(err error) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusUnsupportedMediaType),
+		Status: http.StatusUnsupportedMediaType,
+		Detail: err.Error(),
+		Err:    err,
+	}
+}
+
+// PreconditionFailedProblem is a 412: an If-Match or If-None-Match
+// precondition on the request didn't hold for the resource's current
+// version.
+func PreconditionFailedProblem(err error) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusPreconditionFailed),
+		Status: http.StatusPreconditionFailed,
+		Detail: err.Error(),
+		Err:    err,
+	}
+}
+
 // NotFoundProblem returns a fresh problem each call so concurrent
 // requests cannot race on a shared Instance field.
 func NotFoundProblem() *Problem {
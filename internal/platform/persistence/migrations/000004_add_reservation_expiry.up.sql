@@ -0,0 +1,10 @@
+-- Reservations may carry a hold deadline. NULL means the reservation
+-- never expires; the sweeper only looks at rows with a deadline.
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+
+-- Partial index keeps the sweeper's "what's overdue" scan cheap
+-- without indexing every reservation that has no deadline.
+CREATE INDEX IF NOT EXISTS res_expires_at_idx
+    ON reservations (expires_at)
+    WHERE expires_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS shipments;
+ALTER TABLE reservations DROP COLUMN IF EXISTS shipped_quantity;
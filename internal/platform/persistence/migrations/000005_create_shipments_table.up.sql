@@ -0,0 +1,18 @@
+-- Running total of what has shipped against each reservation. The
+-- unshipped remainder is reserved_quantity - shipped_quantity.
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS shipped_quantity INTEGER NOT NULL DEFAULT 0;
+
+-- One row per physical outbound shipment. request_id is the caller's
+-- idempotency key, mirroring production_events.
+CREATE TABLE IF NOT EXISTS shipments
+(
+    id             INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    request_id     VARCHAR(100) UNIQUE NOT NULL,
+    reservation_id INTEGER REFERENCES reservations (id),
+    sku            VARCHAR(50) REFERENCES products (sku),
+    quantity       INTEGER,
+    created        TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS shipments_reservation_idx ON shipments (reservation_id);
@@ -0,0 +1,17 @@
+-- Append-only ledger of every change to product_inventory.available.
+-- Rows are written in the same transaction as the balance update, so
+-- replaying a SKU's deltas in id order reproduces each balance.
+CREATE TABLE IF NOT EXISTS inventory_movements
+(
+    id             BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    sku            VARCHAR(50)  NOT NULL REFERENCES products (sku),
+    delta          INTEGER      NOT NULL,
+    reason         VARCHAR(50)  NOT NULL,
+    request_id     VARCHAR(100),
+    reservation_id INTEGER REFERENCES reservations (id),
+    actor          VARCHAR(100) NOT NULL,
+    balance        INTEGER      NOT NULL,
+    created        TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS inventory_movements_sku_idx ON inventory_movements (sku, id);
@@ -0,0 +1,3 @@
+DELETE FROM inventory_movements WHERE reason = 'opening';
+
+DROP INDEX IF EXISTS inventory_movements_sku_created_idx;
@@ -0,0 +1,11 @@
+-- Point-in-time reads look up the last movement at or before a given
+-- instant, so index the ledger by creation time as well as id.
+CREATE INDEX IF NOT EXISTS inventory_movements_sku_created_idx ON inventory_movements (sku, created);
+
+-- Stock that predates the ledger has no movement to reconstruct it
+-- from. Seed an opening entry carrying the current balance for every
+-- SKU that has not moved yet so as-of reads after this point are exact.
+INSERT INTO inventory_movements (sku, delta, reason, actor, balance)
+SELECT pi.sku, pi.available, 'opening', 'system', pi.available
+FROM product_inventory pi
+WHERE NOT EXISTS (SELECT 1 FROM inventory_movements m WHERE m.sku = pi.sku);
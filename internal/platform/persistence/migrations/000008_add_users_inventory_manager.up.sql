@@ -0,0 +1,4 @@
+-- Inventory managers may post stock adjustments without holding full
+-- admin rights. Surfaces as the "inventory-manager" JWT role.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_inventory_manager BOOLEAN NOT NULL DEFAULT FALSE;
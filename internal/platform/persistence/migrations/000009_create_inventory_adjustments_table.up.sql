@@ -0,0 +1,15 @@
+-- Manual stock corrections (cycle counts, damage, shrinkage, found
+-- stock). request_id is the caller's idempotency key, mirroring
+-- production_events; quantity is signed.
+CREATE TABLE IF NOT EXISTS inventory_adjustments
+(
+    id         INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    request_id VARCHAR(100) UNIQUE NOT NULL,
+    sku        VARCHAR(50)  NOT NULL REFERENCES products (sku),
+    quantity   INTEGER      NOT NULL,
+    reason     VARCHAR(50)  NOT NULL,
+    actor      VARCHAR(100) NOT NULL,
+    created    TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS inventory_adjustments_sku_idx ON inventory_adjustments (sku);
@@ -0,0 +1,21 @@
+ALTER TABLE inventory_movements DROP COLUMN IF EXISTS location;
+ALTER TABLE inventory_adjustments DROP COLUMN IF EXISTS location;
+ALTER TABLE reservations DROP COLUMN IF EXISTS location;
+ALTER TABLE production_events DROP COLUMN IF EXISTS location;
+
+-- Fold every SKU's locations back into a single row before the key
+-- goes back to sku alone.
+UPDATE product_inventory pi
+   SET available = t.total
+  FROM (SELECT sku, MIN(location) AS keep, SUM(available) AS total FROM product_inventory GROUP BY sku) t
+ WHERE pi.sku = t.sku
+   AND pi.location = t.keep;
+
+DELETE FROM product_inventory pi
+ USING (SELECT sku, MIN(location) AS keep FROM product_inventory GROUP BY sku) t
+ WHERE pi.sku = t.sku
+   AND pi.location <> t.keep;
+
+ALTER TABLE product_inventory DROP CONSTRAINT IF EXISTS product_inventory_pkey;
+ALTER TABLE product_inventory DROP COLUMN IF EXISTS location;
+ALTER TABLE product_inventory ADD PRIMARY KEY (sku);
@@ -0,0 +1,22 @@
+-- Stock is tracked per warehouse location. Existing rows land in the
+-- 'default' location, which is also what the service uses when a
+-- request doesn't name one (inventory.defaultLocation).
+ALTER TABLE product_inventory
+    ADD COLUMN IF NOT EXISTS location VARCHAR(50) NOT NULL DEFAULT 'default';
+
+ALTER TABLE product_inventory DROP CONSTRAINT IF EXISTS product_inventory_pkey;
+ALTER TABLE product_inventory ADD PRIMARY KEY (sku, location);
+
+ALTER TABLE production_events
+    ADD COLUMN IF NOT EXISTS location VARCHAR(50) NOT NULL DEFAULT 'default';
+
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS location VARCHAR(50) NOT NULL DEFAULT 'default';
+
+ALTER TABLE inventory_adjustments
+    ADD COLUMN IF NOT EXISTS location VARCHAR(50) NOT NULL DEFAULT 'default';
+
+-- Ledger balances become per location: a movement's balance is what
+-- its location held afterwards, not the SKU-wide total.
+ALTER TABLE inventory_movements
+    ADD COLUMN IF NOT EXISTS location VARCHAR(50) NOT NULL DEFAULT 'default';
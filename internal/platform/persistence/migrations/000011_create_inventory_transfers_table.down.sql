@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS inventory_transfers;
+
+ALTER TABLE product_inventory DROP COLUMN IF EXISTS in_transit;
@@ -0,0 +1,21 @@
+-- Stock moving between locations. Creating a transfer takes the
+-- quantity out of from_location's available stock and parks it in
+-- to_location's in_transit bucket until the transfer is received.
+ALTER TABLE product_inventory
+    ADD COLUMN IF NOT EXISTS in_transit INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS inventory_transfers
+(
+    id            INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    request_id    VARCHAR(100) UNIQUE NOT NULL,
+    sku           VARCHAR(50)  NOT NULL REFERENCES products (sku),
+    from_location VARCHAR(50)  NOT NULL,
+    to_location   VARCHAR(50)  NOT NULL,
+    quantity      INTEGER      NOT NULL,
+    state         VARCHAR(50)  NOT NULL,
+    actor         VARCHAR(100) NOT NULL,
+    created       TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated       TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS inventory_transfers_sku_idx ON inventory_transfers (sku, id);
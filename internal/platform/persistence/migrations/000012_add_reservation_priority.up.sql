@@ -0,0 +1,4 @@
+-- Higher priority reservations are filled first by the priority
+-- allocation strategy. Existing reservations all start level.
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
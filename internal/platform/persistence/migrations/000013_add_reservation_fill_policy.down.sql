@@ -0,0 +1,3 @@
+ALTER TABLE reservations
+    DROP COLUMN IF EXISTS min_quantity,
+    DROP COLUMN IF EXISTS fill_policy;
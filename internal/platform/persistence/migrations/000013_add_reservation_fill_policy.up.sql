@@ -0,0 +1,6 @@
+-- A reservation's fill policy decides the smallest first fill it will
+-- accept: any amount (partial), everything (all_or_nothing) or at
+-- least min_quantity (minimum).
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS fill_policy VARCHAR(20) NOT NULL DEFAULT 'partial',
+    ADD COLUMN IF NOT EXISTS min_quantity INTEGER NOT NULL DEFAULT 0;
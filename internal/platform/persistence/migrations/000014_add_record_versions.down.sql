@@ -0,0 +1,3 @@
+ALTER TABLE reservations DROP COLUMN IF EXISTS version;
+ALTER TABLE product_inventory DROP COLUMN IF EXISTS version;
+ALTER TABLE products DROP COLUMN IF EXISTS version;
@@ -0,0 +1,12 @@
+-- Row versions for optimistic concurrency. Every write bumps the
+-- version of the row it changes; products.version is also bumped by
+-- every write to the SKU's stock, so it changes whenever anything
+-- GET /inventory/{sku} returns does and serves as that resource's
+-- ETag. product_inventory.version only moves when that location's
+-- own balances change.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE product_inventory
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE reservations
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
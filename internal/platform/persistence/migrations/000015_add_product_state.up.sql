@@ -0,0 +1,4 @@
+-- A product's lifecycle state: Active, Discontinued (no new production
+-- or reservations) or Archived (retired, with no stock left).
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS state VARCHAR(20) NOT NULL DEFAULT 'Active';
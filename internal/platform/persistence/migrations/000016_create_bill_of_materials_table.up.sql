@@ -0,0 +1,10 @@
+-- A kit's bill of materials: how many of each component SKU go into
+-- one unit of the parent. Producing the parent consumes them.
+CREATE TABLE IF NOT EXISTS bill_of_materials
+(
+    parent_sku    VARCHAR(50) NOT NULL REFERENCES products (sku),
+    component_sku VARCHAR(50) NOT NULL REFERENCES products (sku),
+    quantity      INTEGER     NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (parent_sku, component_sku),
+    CHECK (parent_sku <> component_sku)
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS reservation_lots;
+DROP TABLE IF EXISTS inventory_lots;
+
+ALTER TABLE production_events
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS lot;
+
+ALTER TABLE product_inventory
+    DROP COLUMN IF EXISTS expired;
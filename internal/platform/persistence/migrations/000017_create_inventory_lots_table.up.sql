@@ -0,0 +1,36 @@
+-- Lot-tracked stock. A lot's available quantity is part of its
+-- location's available stock in product_inventory, not in addition
+-- to it; stock produced without a lot is whatever the lots at a
+-- location don't account for. Expired lots move what they held into
+-- the expired bucket at both levels.
+ALTER TABLE product_inventory
+    ADD COLUMN IF NOT EXISTS expired INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE production_events
+    ADD COLUMN IF NOT EXISTS lot        VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS inventory_lots
+(
+    sku        VARCHAR(50) NOT NULL REFERENCES products (sku),
+    location   VARCHAR(50) NOT NULL,
+    lot        VARCHAR(50) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    available  INTEGER     NOT NULL DEFAULT 0 CHECK (available >= 0),
+    expired    INTEGER     NOT NULL DEFAULT 0 CHECK (expired >= 0),
+    PRIMARY KEY (sku, location, lot)
+);
+
+CREATE INDEX IF NOT EXISTS inventory_lots_expiry_idx ON inventory_lots (expires_at) WHERE available > 0;
+
+-- How much of each lot a reservation drew, for recalls. Quantities
+-- only go down when unshipped stock is handed back.
+CREATE TABLE IF NOT EXISTS reservation_lots
+(
+    reservation_id INTEGER     NOT NULL REFERENCES reservations (id),
+    lot            VARCHAR(50) NOT NULL,
+    quantity       INTEGER     NOT NULL CHECK (quantity >= 0),
+    PRIMARY KEY (reservation_id, lot)
+);
+
+CREATE INDEX IF NOT EXISTS reservation_lots_lot_idx ON reservation_lots (lot);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS inventory_status_changes;
+
+ALTER TABLE product_inventory
+    DROP COLUMN IF EXISTS damaged,
+    DROP COLUMN IF EXISTS quarantined,
+    DROP COLUMN IF EXISTS on_hold;
@@ -0,0 +1,25 @@
+-- Stock held back from sale. Each bucket is counted per location
+-- alongside available, and only available stock is ever reserved.
+ALTER TABLE product_inventory
+    ADD COLUMN IF NOT EXISTS on_hold     INTEGER NOT NULL DEFAULT 0 CHECK (on_hold >= 0),
+    ADD COLUMN IF NOT EXISTS quarantined INTEGER NOT NULL DEFAULT 0 CHECK (quarantined >= 0),
+    ADD COLUMN IF NOT EXISTS damaged     INTEGER NOT NULL DEFAULT 0 CHECK (damaged >= 0);
+
+-- Moves of stock between buckets. request_id is the caller's
+-- idempotency key, mirroring inventory_adjustments.
+CREATE TABLE IF NOT EXISTS inventory_status_changes
+(
+    id          INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    request_id  VARCHAR(100) UNIQUE NOT NULL,
+    sku         VARCHAR(50)  NOT NULL REFERENCES products (sku),
+    location    VARCHAR(50)  NOT NULL,
+    from_status VARCHAR(20)  NOT NULL,
+    to_status   VARCHAR(20)  NOT NULL,
+    quantity    INTEGER      NOT NULL CHECK (quantity > 0),
+    lot         VARCHAR(50),
+    reason      VARCHAR(255) NOT NULL,
+    actor       VARCHAR(100) NOT NULL,
+    created     TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS inventory_status_changes_sku_idx ON inventory_status_changes (sku);
@@ -0,0 +1,10 @@
+-- Per-SKU reorder settings. low_since is when available stock last
+-- fell below reorder_point and is cleared once it recovers, so the
+-- low-stock alert fires once per crossing rather than on every write.
+CREATE TABLE IF NOT EXISTS inventory_reorder_policies
+(
+    sku           VARCHAR(50) PRIMARY KEY REFERENCES products (sku),
+    reorder_point INTEGER     NOT NULL CHECK (reorder_point >= 0),
+    target_level  INTEGER     NOT NULL CHECK (target_level >= reorder_point),
+    low_since     TIMESTAMP WITH TIME ZONE
+);
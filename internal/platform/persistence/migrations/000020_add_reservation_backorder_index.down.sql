@@ -0,0 +1 @@
+DROP INDEX IF EXISTS res_backorder_idx;
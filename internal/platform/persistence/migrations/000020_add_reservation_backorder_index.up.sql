@@ -0,0 +1,6 @@
+-- Covers the backorder report: only open reservations still short of
+-- what they asked for, with the columns it sums, so the aggregate
+-- never has to visit the heap for filled or finished reservations.
+CREATE INDEX IF NOT EXISTS res_backorder_idx ON reservations (sku)
+    INCLUDE (requested_quantity, reserved_quantity, created)
+    WHERE state = 'Open' AND reserved_quantity < requested_quantity;
@@ -0,0 +1,18 @@
+-- Production that is scheduled but hasn't happened yet. Scheduled
+-- orders count towards available-to-promise from their expected date;
+-- production_request_id is the production event that completed one.
+CREATE TABLE IF NOT EXISTS planned_orders
+(
+    id                    INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    request_id            VARCHAR(100) UNIQUE NOT NULL,
+    sku                   VARCHAR(50)  NOT NULL REFERENCES products (sku),
+    quantity              INTEGER      NOT NULL CHECK (quantity > 0),
+    expected_date         TIMESTAMP WITH TIME ZONE NOT NULL,
+    state                 VARCHAR(50)  NOT NULL,
+    production_request_id VARCHAR(100),
+    actor                 VARCHAR(100) NOT NULL,
+    created               TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated               TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS planned_orders_scheduled_idx ON planned_orders (sku, expected_date, id) WHERE state = 'Scheduled';
@@ -0,0 +1,22 @@
+-- Goods coming back from customers, and what inspection decided to do
+-- with them: restock to available, quarantine or scrap. A return is
+-- either against a reservation it was shipped on or from a free-form
+-- requester. request_id is the caller's idempotency key.
+CREATE TABLE IF NOT EXISTS inventory_returns
+(
+    id             INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    request_id     VARCHAR(100) UNIQUE NOT NULL,
+    sku            VARCHAR(50)  NOT NULL REFERENCES products (sku),
+    location       VARCHAR(50)  NOT NULL,
+    reservation_id INTEGER REFERENCES reservations (id),
+    requester      VARCHAR(100) NOT NULL,
+    quantity       INTEGER      NOT NULL CHECK (quantity > 0),
+    outcome        VARCHAR(20)  NOT NULL,
+    lot            VARCHAR(50),
+    reason         VARCHAR(255) NOT NULL DEFAULT '',
+    actor          VARCHAR(100) NOT NULL,
+    created        TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS inventory_returns_sku_idx ON inventory_returns (sku);
+CREATE INDEX IF NOT EXISTS inventory_returns_reservation_idx ON inventory_returns (reservation_id) WHERE reservation_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS res_requester_idx;
+DROP TABLE IF EXISTS requester_limits;
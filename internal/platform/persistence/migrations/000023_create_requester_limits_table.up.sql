@@ -0,0 +1,16 @@
+-- Per-requester reservation quotas. A zero limit is unlimited. The
+-- requester '*' holds the defaults for every requester without a row
+-- of its own. class only labels rejections in metrics.
+CREATE TABLE IF NOT EXISTS requester_limits
+(
+    requester             VARCHAR(100) PRIMARY KEY,
+    class                 VARCHAR(50)  NOT NULL DEFAULT 'default',
+    max_reserved_per_sku  INTEGER      NOT NULL DEFAULT 0 CHECK (max_reserved_per_sku >= 0),
+    max_open_reservations INTEGER      NOT NULL DEFAULT 0 CHECK (max_open_reservations >= 0),
+    daily_cap             INTEGER      NOT NULL DEFAULT 0 CHECK (daily_cap >= 0)
+);
+
+-- Covers the usage Reserve checks against those limits: a
+-- requester's outstanding reservations and what it reserved today.
+CREATE INDEX IF NOT EXISTS res_requester_idx ON reservations (requester, created)
+    INCLUDE (sku, state, requested_quantity, shipped_quantity);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS res_created_id_idx;
@@ -0,0 +1,3 @@
+-- Lets a keyset page of reservations seek straight to the row after
+-- its cursor in (created, id) order instead of sorting the table.
+CREATE INDEX IF NOT EXISTS res_created_id_idx ON reservations (created, id);
@@ -0,0 +1,4 @@
+-- pg_trgm is left installed: it may predate this migration, and other
+-- objects in the database can depend on it.
+DROP INDEX IF EXISTS products_upc_trgm_idx;
+DROP INDEX IF EXISTS products_name_trgm_idx;
@@ -0,0 +1,8 @@
+-- Trigram indexes let the inventory list's substring search
+-- (name/upc ILIKE '%term%') use an index instead of scanning every
+-- product. pg_trgm is a trusted extension from Postgres 13, so the
+-- database owner can create it without superuser.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING gin (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS products_upc_trgm_idx ON products USING gin (upc gin_trgm_ops);
@@ -0,0 +1,10 @@
+-- How much of each lot a transfer took from its source location, so
+-- receiving it can put the stock back into the same lots, with their
+-- expiry dates, at the destination.
+CREATE TABLE IF NOT EXISTS transfer_lots
+(
+    transfer_id INTEGER     NOT NULL REFERENCES inventory_transfers (id),
+    lot         VARCHAR(50) NOT NULL,
+    quantity    INTEGER     NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (transfer_id, lot)
+);
@@ -0,0 +1,16 @@
+-- When a product's history begins. As-of reads refuse instants before
+-- it, and the as-of list leaves out products created after the instant.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS created TIMESTAMP WITH TIME ZONE;
+
+-- Existing products have no recorded creation time, and the ledger is
+-- all an as-of read can rebuild from, so date each one to its first
+-- movement: the opening entry from 000007 for anything older than the
+-- ledger. A product that has never moved is dated to now.
+UPDATE products p
+   SET created = COALESCE((SELECT MIN(m.created) FROM inventory_movements m WHERE m.sku = p.sku), NOW())
+ WHERE p.created IS NULL;
+
+ALTER TABLE products
+    ALTER COLUMN created SET DEFAULT NOW(),
+    ALTER COLUMN created SET NOT NULL;
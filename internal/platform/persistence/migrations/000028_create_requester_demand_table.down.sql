@@ -0,0 +1 @@
+DROP TABLE IF EXISTS requester_demand;
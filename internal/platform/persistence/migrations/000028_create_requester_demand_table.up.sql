@@ -0,0 +1,24 @@
+-- Units each requester asked for and when: a row for every new
+-- reservation and every change to one's quantity. The daily cap sums
+-- the day's rows, so raising a reservation made on an earlier day
+-- still counts against the day it was raised on.
+CREATE TABLE IF NOT EXISTS requester_demand
+(
+    id             INTEGER PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    requester      VARCHAR(100)             NOT NULL,
+    reservation_id INTEGER                  NOT NULL REFERENCES reservations (id),
+    quantity       INTEGER                  NOT NULL,
+    created        TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS requester_demand_requester_created_idx ON requester_demand (requester, created);
+
+-- Reservations made before this table only have their current
+-- quantity to go on. Carry over the last day's so today's cap still
+-- sees them.
+INSERT INTO requester_demand (requester, reservation_id, quantity, created)
+SELECT r.requester, r.id, r.requested_quantity, r.created
+  FROM reservations r
+ WHERE r.created >= NOW() - INTERVAL '1 day'
+   AND r.requester IS NOT NULL
+   AND NOT EXISTS (SELECT 1 FROM requester_demand d WHERE d.reservation_id = r.id);
@@ -0,0 +1,5 @@
+UPDATE inventory_movements
+   SET request_id = lot
+ WHERE reason = 'lot_expiry';
+
+ALTER TABLE inventory_movements DROP COLUMN IF EXISTS lot;
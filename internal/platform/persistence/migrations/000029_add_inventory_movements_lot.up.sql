@@ -0,0 +1,9 @@
+-- The lot a movement concerns, when it concerns one. request_id only
+-- holds the idempotency key of the operation behind a movement, so lot
+-- expiries, which have none, carried their lot there until now.
+ALTER TABLE inventory_movements
+    ADD COLUMN IF NOT EXISTS lot VARCHAR(50);
+
+UPDATE inventory_movements
+   SET lot = request_id, request_id = NULL
+ WHERE reason = 'lot_expiry' AND lot IS NULL;
@@ -20,6 +20,7 @@ type MockConn struct {
 	QueryFunc    func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
 	QueryRowFunc func(ctx context.Context, sql string, args ...interface{}) pgx.Row
 	ExecFunc     func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	CopyFromFunc func(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
 	BeginFunc    func(ctx context.Context) (pgx.Tx, error)
 }
 
@@ -30,6 +31,9 @@ func NewMockConn() MockConn {
 		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
 			return pgconn.CommandTag{}, nil
 		},
+		CopyFromFunc: func(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+			return 0, nil
+		},
 		BeginFunc: func(ctx context.Context) (pgx.Tx, error) { return NewMockPgxTx(), nil },
 	}
 }
@@ -46,6 +50,10 @@ func (c *MockConn) Exec(ctx context.Context, sql string, args ...interface{}) (p
 	return c.ExecFunc(ctx, sql, args)
 }
 
+func (c *MockConn) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return c.CopyFromFunc(ctx, tableName, columnNames, rowSrc)
+}
+
 func (c *MockConn) Begin(ctx context.Context) (pgx.Tx, error) {
 	return c.BeginFunc(ctx)
 }
@@ -219,3 +219,12 @@ func GetUpdateOptions(cn Conn, options ...UpdateOptions) (conn Conn) {
 
 	return conn
 }
+
+// GetExpectedVersion returns the version an update is conditional on,
+// zero when it isn't.
+func GetExpectedVersion(options ...UpdateOptions) int64 {
+	if len(options) > 0 {
+		return options[0].Version
+	}
+	return 0
+}
@@ -10,10 +10,16 @@ import (
 
 var ErrNotFound = errors.New("core: record not found")
 
+// ErrVersionConflict is returned by a conditional write when the record
+// it targets is no longer at the version the caller expected, or, for
+// an insert, already exists.
+var ErrVersionConflict = errors.New("core: record version conflict")
+
 type Conn interface {
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
 	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
 	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
@@ -25,6 +31,9 @@ type Transaction interface {
 
 type UpdateOptions struct {
 	Tx Transaction
+	// Version, when non-zero, makes the write conditional on the
+	// record still being at that version.
+	Version int64
 }
 
 type QueryOptions struct {
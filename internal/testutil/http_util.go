@@ -20,7 +20,8 @@ func Unmarshal(res *http.Response, v interface{}, t *testing.T) {
 }
 
 type RequestOptions struct {
-	Token string
+	Token   string
+	Headers map[string]string
 }
 
 func Put(url string, request interface{}, t *testing.T, op ...RequestOptions) *http.Response {
@@ -47,6 +48,11 @@ func SendRequest(method, url string, request interface{}, t *testing.T, op ...Re
 	}
 
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if len(op) > 0 {
+		for k, v := range op[0].Headers {
+			req.Header.Set(k, v)
+		}
+	}
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatal(err)
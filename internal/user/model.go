@@ -3,14 +3,16 @@ package user
 import "time"
 
 type CreateUserRequest struct {
-	Username          string `json:"username,omitempty"`
-	IsAdmin           bool   `json:"isAdmin,omitempty"`
-	PlainTextPassword string `json:"-"`
+	Username           string `json:"username,omitempty"`
+	IsAdmin            bool   `json:"isAdmin,omitempty"`
+	IsInventoryManager bool   `json:"isInventoryManager,omitempty"`
+	PlainTextPassword  string `json:"-"`
 }
 
 type User struct {
-	Username       string
-	HashedPassword string
-	IsAdmin        bool
-	Created        time.Time
+	Username           string
+	HashedPassword     string
+	IsAdmin            bool
+	IsInventoryManager bool
+	Created            time.Time
 }
@@ -42,16 +42,16 @@ func (r *dbRepo) SetCache(c cache.Cache, ttl time.Duration) {
 // userCacheKey is the per-username cache key. The "v1" suffix is the
 // global invalidation lever — bump it when the cached shape changes
 // to drop every entry without touching Redis directly.
-func userCacheKey(username string) string { return "user:" + username + ":v1" }
+func userCacheKey(username string) string { return "user:" + username + ":v2" }
 
 func (r *dbRepo) Create(ctx context.Context, u *User, txs ...persistence.UpdateOptions) error {
 	m := persistence.StartMetric("Create")
 	tx := persistence.GetUpdateOptions(r.conn, txs...)
 
 	_, err := tx.Exec(ctx, `
-		INSERT INTO users (username, password, is_admin, created_at)
-                      VALUES ($1, $2, $3, $4);`,
-		u.Username, u.HashedPassword, u.IsAdmin, u.Created)
+		INSERT INTO users (username, password, is_admin, is_inventory_manager, created_at)
+                      VALUES ($1, $2, $3, $4, $5);`,
+		u.Username, u.HashedPassword, u.IsAdmin, u.IsInventoryManager, u.Created)
 	if err != nil {
 		m.Complete(err)
 		return err
@@ -74,12 +74,12 @@ func (r *dbRepo) Get(ctx context.Context, username string, txs ...persistence.Qu
 		}
 	}
 
-	query := `SELECT username, password, is_admin, created_at FROM users WHERE username = $1 ` + forUpdate
+	query := `SELECT username, password, is_admin, is_inventory_manager, created_at FROM users WHERE username = $1 ` + forUpdate
 	log.Ctx(ctx).Debug().Str("query", query).Str("username", username).Msg("getting user")
 
 	var u User
 	err := tx.QueryRow(ctx, query, username).
-		Scan(&u.Username, &u.HashedPassword, &u.IsAdmin, &u.Created)
+		Scan(&u.Username, &u.HashedPassword, &u.IsAdmin, &u.IsInventoryManager, &u.Created)
 	if err != nil {
 		m.Complete(err)
 		if errors.Is(err, pgx.ErrNoRows) {
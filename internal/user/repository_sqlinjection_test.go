@@ -34,7 +34,7 @@ var sqlInjectionPayloads = []string{
 // against a future change that bypasses that protection (e.g. by
 // pre-rendering the SQL).
 func TestGetSQLInjectionPayloadsAreBoundParameters(t *testing.T) {
-	const selectUser = `^SELECT username, password, is_admin, created_at FROM users WHERE username = \$1\s*$`
+	const selectUser = `^SELECT username, password, is_admin, is_inventory_manager, created_at FROM users WHERE username = \$1\s*$`
 
 	for _, payload := range sqlInjectionPayloads {
 		t.Run(payload, func(t *testing.T) {
@@ -44,8 +44,8 @@ func TestGetSQLInjectionPayloadsAreBoundParameters(t *testing.T) {
 			// statement. Both must hold for the call to succeed.
 			mock.ExpectQuery(selectUser).
 				WithArgs(payload).
-				WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "created_at"}).
-					AddRow(payload, "h", false, zeroTime()))
+				WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "is_inventory_manager", "created_at"}).
+					AddRow(payload, "h", false, false, zeroTime()))
 
 			_, err := repo.Get(context.Background(), payload)
 			if err != nil {
@@ -61,12 +61,12 @@ func TestRepositoryCreate(t *testing.T) {
 		{
 			name:      "insert succeeds",
 			user:      user.User{Username: "alice", HashedPassword: "h", IsAdmin: false, Created: time.Unix(0, 0).UTC()},
-			expectSQL: `^\s*INSERT INTO users \(username, password, is_admin, created_at\)\s+VALUES \(\$1, \$2, \$3, \$4\);?\s*$`,
+			expectSQL: `^\s*INSERT INTO users \(username, password, is_admin, is_inventory_manager, created_at\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5\);?\s*$`,
 		},
 		{
 			name:      "exec error is propagated",
 			user:      user.User{Username: "bob", HashedPassword: "h", IsAdmin: true, Created: time.Unix(0, 0).UTC()},
-			expectSQL: `^\s*INSERT INTO users \(username, password, is_admin, created_at\)\s+VALUES \(\$1, \$2, \$3, \$4\);?\s*$`,
+			expectSQL: `^\s*INSERT INTO users \(username, password, is_admin, is_inventory_manager, created_at\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5\);?\s*$`,
 			execErr:   errors.New("boom"),
 			wantErr:   true,
 		},
@@ -77,7 +77,7 @@ func TestRepositoryCreate(t *testing.T) {
 			repo, mock := newRepo(t)
 
 			expect := mock.ExpectExec(test.expectSQL).
-				WithArgs(test.user.Username, test.user.HashedPassword, test.user.IsAdmin, test.user.Created)
+				WithArgs(test.user.Username, test.user.HashedPassword, test.user.IsAdmin, test.user.IsInventoryManager, test.user.Created)
 			if test.execErr != nil {
 				expect.WillReturnError(test.execErr)
 			} else {
@@ -100,14 +100,14 @@ func TestRepositoryCreate(t *testing.T) {
 
 func TestRepositoryGet(t *testing.T) {
 	row := user.User{Username: "alice", HashedPassword: "h", IsAdmin: true, Created: time.Unix(0, 0).UTC()}
-	const selectUser = `^SELECT username, password, is_admin, created_at FROM users WHERE username = \$1\s*$`
+	const selectUser = `^SELECT username, password, is_admin, is_inventory_manager, created_at FROM users WHERE username = \$1\s*$`
 
 	t.Run("hit returns row", func(t *testing.T) {
 		repo, mock := newRepo(t)
 		mock.ExpectQuery(selectUser).
 			WithArgs(row.Username).
-			WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "created_at"}).
-				AddRow(row.Username, row.HashedPassword, row.IsAdmin, row.Created))
+			WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "is_inventory_manager", "created_at"}).
+				AddRow(row.Username, row.HashedPassword, row.IsAdmin, row.IsInventoryManager, row.Created))
 
 		got, err := repo.Get(context.Background(), row.Username)
 		if err != nil {
@@ -138,8 +138,8 @@ func TestRepositoryGet(t *testing.T) {
 		// Only one expectation: the second call must be served from cache.
 		rt.mock.ExpectQuery(selectUser).
 			WithArgs(row.Username).
-			WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "created_at"}).
-				AddRow(row.Username, row.HashedPassword, row.IsAdmin, row.Created))
+			WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "is_inventory_manager", "created_at"}).
+				AddRow(row.Username, row.HashedPassword, row.IsAdmin, row.IsInventoryManager, row.Created))
 
 		if _, err := rt.repo.Get(context.Background(), row.Username); err != nil {
 			t.Fatalf("first get: %v", err)
@@ -158,8 +158,8 @@ func TestRepositoryGet(t *testing.T) {
 		for i := 0; i < 2; i++ {
 			mock.ExpectQuery(selectUser).
 				WithArgs(row.Username).
-				WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "created_at"}).
-					AddRow(row.Username, row.HashedPassword, row.IsAdmin, row.Created))
+				WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "is_inventory_manager", "created_at"}).
+					AddRow(row.Username, row.HashedPassword, row.IsAdmin, row.IsInventoryManager, row.Created))
 		}
 		for i := 0; i < 2; i++ {
 			if _, err := repo.Get(context.Background(), row.Username); err != nil {
@@ -176,7 +176,7 @@ func TestCachePopulatedOnCreate(t *testing.T) {
 	rt := newRepoWithCache(t)
 	u := user.User{Username: "alice", HashedPassword: "h", IsAdmin: false, Created: time.Unix(0, 0).UTC()}
 	rt.mock.ExpectExec(`^\s*INSERT INTO users`).
-		WithArgs(u.Username, u.HashedPassword, u.IsAdmin, u.Created).
+		WithArgs(u.Username, u.HashedPassword, u.IsAdmin, u.IsInventoryManager, u.Created).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 	if err := rt.repo.Create(context.Background(), &u); err != nil {
@@ -202,10 +202,10 @@ func TestCacheInvalidatedOnDelete(t *testing.T) {
 	u := user.User{Username: "alice", HashedPassword: "h", IsAdmin: false, Created: time.Unix(0, 0).UTC()}
 
 	// First Get populates the cache.
-	rt.mock.ExpectQuery(`^SELECT username, password, is_admin, created_at FROM users WHERE username = \$1\s*$`).
+	rt.mock.ExpectQuery(`^SELECT username, password, is_admin, is_inventory_manager, created_at FROM users WHERE username = \$1\s*$`).
 		WithArgs(u.Username).
-		WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "created_at"}).
-			AddRow(u.Username, u.HashedPassword, u.IsAdmin, u.Created))
+		WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "is_inventory_manager", "created_at"}).
+			AddRow(u.Username, u.HashedPassword, u.IsAdmin, u.IsInventoryManager, u.Created))
 	if _, err := rt.repo.Get(context.Background(), u.Username); err != nil {
 		t.Fatalf("seed Get: %v", err)
 	}
@@ -243,8 +243,8 @@ func TestCacheTTLExpiryFallsThroughToDB(t *testing.T) {
 	for i := 0; i < 2; i++ {
 		mock.ExpectQuery(`^SELECT username`).
 			WithArgs(row.Username).
-			WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "created_at"}).
-				AddRow(row.Username, row.HashedPassword, row.IsAdmin, row.Created))
+			WillReturnRows(pgxmock.NewRows([]string{"username", "password", "is_admin", "is_inventory_manager", "created_at"}).
+				AddRow(row.Username, row.HashedPassword, row.IsAdmin, row.IsInventoryManager, row.Created))
 	}
 
 	if _, err := r.Get(context.Background(), row.Username); err != nil {
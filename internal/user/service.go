@@ -63,9 +63,10 @@ func (s *service) Create(ctx context.Context, req CreateUserRequest) (u User, er
 		return User{}, err
 	}
 	user := &User{
-		Username:       req.Username,
-		HashedPassword: string(hash),
-		Created:        time.Now(),
+		Username:           req.Username,
+		HashedPassword:     string(hash),
+		IsInventoryManager: req.IsInventoryManager,
+		Created:            time.Now(),
 	}
 	err = s.repo.Create(ctx, user)
 	if err != nil {